@@ -6,9 +6,11 @@
 Package api is a generated protocol buffer package.
 
 It is generated from these files:
+
 	api/api.proto
 
 It has these top-level messages:
+
 	Client
 	CreateClientReq
 	CreateClientResp
@@ -30,6 +32,11 @@ It has these top-level messages:
 	ListRefreshResp
 	RevokeRefreshReq
 	RevokeRefreshResp
+	Consent
+	ListConsentReq
+	ListConsentResp
+	RevokeConsentReq
+	RevokeConsentResp
 */
 package api
 
@@ -55,13 +62,47 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 // Client represents an OAuth2 client.
 type Client struct {
-	Id           string   `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
-	Secret       string   `protobuf:"bytes,2,opt,name=secret" json:"secret,omitempty"`
-	RedirectUris []string `protobuf:"bytes,3,rep,name=redirect_uris,json=redirectUris" json:"redirect_uris,omitempty"`
-	TrustedPeers []string `protobuf:"bytes,4,rep,name=trusted_peers,json=trustedPeers" json:"trusted_peers,omitempty"`
-	Public       bool     `protobuf:"varint,5,opt,name=public" json:"public,omitempty"`
-	Name         string   `protobuf:"bytes,6,opt,name=name" json:"name,omitempty"`
-	LogoUrl      string   `protobuf:"bytes,7,opt,name=logo_url,json=logoUrl" json:"logo_url,omitempty"`
+	Id                string   `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Secret            string   `protobuf:"bytes,2,opt,name=secret" json:"secret,omitempty"`
+	RedirectUris      []string `protobuf:"bytes,3,rep,name=redirect_uris,json=redirectUris" json:"redirect_uris,omitempty"`
+	TrustedPeers      []string `protobuf:"bytes,4,rep,name=trusted_peers,json=trustedPeers" json:"trusted_peers,omitempty"`
+	Public            bool     `protobuf:"varint,5,opt,name=public" json:"public,omitempty"`
+	Name              string   `protobuf:"bytes,6,opt,name=name" json:"name,omitempty"`
+	LogoUrl           string   `protobuf:"bytes,7,opt,name=logo_url,json=logoUrl" json:"logo_url,omitempty"`
+	AllowedGrantTypes []string `protobuf:"bytes,8,rep,name=allowed_grant_types,json=allowedGrantTypes" json:"allowed_grant_types,omitempty"`
+	// JSON encoded JSON Web Key Set used to verify "private_key_jwt" client assertions.
+	Jwks    string `protobuf:"bytes,9,opt,name=jwks" json:"jwks,omitempty"`
+	JwksUri string `protobuf:"bytes,10,opt,name=jwks_uri,json=jwksUri" json:"jwks_uri,omitempty"`
+	// URIs the client may redirect the end user to after logging them out.
+	PostLogoutRedirectUris []string `protobuf:"bytes,11,rep,name=post_logout_redirect_uris,json=postLogoutRedirectUris" json:"post_logout_redirect_uris,omitempty"`
+	// URI the server notifies when an end user logs out of the client.
+	BackchannelLogoutUri string `protobuf:"bytes,12,opt,name=backchannel_logout_uri,json=backchannelLogoutUri" json:"backchannel_logout_uri,omitempty"`
+	// Format of access tokens issued to the client, "opaque" or "jwt". If empty,
+	// the server's default is used.
+	AccessTokenFormat string `protobuf:"bytes,13,opt,name=access_token_format,json=accessTokenFormat" json:"access_token_format,omitempty"`
+	// Require the client to push its authorization requests, or to pass their
+	// parameters in a signed request object.
+	RequirePushedAuthRequests  bool `protobuf:"varint,14,opt,name=require_pushed_auth_requests,json=requirePushedAuthRequests" json:"require_pushed_auth_requests,omitempty"`
+	RequireSignedRequestObject bool `protobuf:"varint,15,opt,name=require_signed_request_object,json=requireSignedRequestObject" json:"require_signed_request_object,omitempty"`
+	// Type of subject identifiers issued to the client, "public" or "pairwise".
+	// Clients sharing the host of their sector identifier URI are issued the
+	// same pairwise subjects.
+	SubjectType         string `protobuf:"bytes,16,opt,name=subject_type,json=subjectType" json:"subject_type,omitempty"`
+	SectorIdentifierUri string `protobuf:"bytes,17,opt,name=sector_identifier_uri,json=sectorIdentifierUri" json:"sector_identifier_uri,omitempty"`
+	// Restrict the connectors end users may log in to the client with, the scopes
+	// the client may request and the response types it may use. If empty, the
+	// client isn't restricted.
+	AllowedConnectors    []string `protobuf:"bytes,18,rep,name=allowed_connectors,json=allowedConnectors" json:"allowed_connectors,omitempty"`
+	AllowedScopes        []string `protobuf:"bytes,19,rep,name=allowed_scopes,json=allowedScopes" json:"allowed_scopes,omitempty"`
+	AllowedResponseTypes []string `protobuf:"bytes,20,rep,name=allowed_response_types,json=allowedResponseTypes" json:"allowed_response_types,omitempty"`
+	// Override how long tokens and authorization codes issued to the client are
+	// valid, formatted as durations such as "10m". If empty, the server's
+	// defaults are used.
+	IdTokenLifetime              string `protobuf:"bytes,21,opt,name=id_token_lifetime,json=idTokenLifetime" json:"id_token_lifetime,omitempty"`
+	AccessTokenLifetime          string `protobuf:"bytes,22,opt,name=access_token_lifetime,json=accessTokenLifetime" json:"access_token_lifetime,omitempty"`
+	AuthCodeLifetime             string `protobuf:"bytes,23,opt,name=auth_code_lifetime,json=authCodeLifetime" json:"auth_code_lifetime,omitempty"`
+	RefreshTokenIdleTimeout      string `protobuf:"bytes,24,opt,name=refresh_token_idle_timeout,json=refreshTokenIdleTimeout" json:"refresh_token_idle_timeout,omitempty"`
+	RefreshTokenAbsoluteLifetime string `protobuf:"bytes,25,opt,name=refresh_token_absolute_lifetime,json=refreshTokenAbsoluteLifetime" json:"refresh_token_absolute_lifetime,omitempty"`
 }
 
 func (m *Client) Reset()                    { *m = Client{} }
@@ -323,6 +364,70 @@ func (m *RevokeRefreshResp) String() string            { return proto.CompactTex
 func (*RevokeRefreshResp) ProtoMessage()               {}
 func (*RevokeRefreshResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
 
+// Consent contains the scopes an end user approved for a client.
+type Consent struct {
+	ClientId    string   `protobuf:"bytes,1,opt,name=client_id,json=clientId" json:"client_id,omitempty"`
+	Scopes      []string `protobuf:"bytes,2,rep,name=scopes" json:"scopes,omitempty"`
+	CreatedAt   int64    `protobuf:"varint,3,opt,name=created_at,json=createdAt" json:"created_at,omitempty"`
+	LastUpdated int64    `protobuf:"varint,4,opt,name=last_updated,json=lastUpdated" json:"last_updated,omitempty"`
+}
+
+func (m *Consent) Reset()                    { *m = Consent{} }
+func (m *Consent) String() string            { return proto.CompactTextString(m) }
+func (*Consent) ProtoMessage()               {}
+func (*Consent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+// ListConsentReq is a request to enumerate the consents of a user.
+type ListConsentReq struct {
+	// The "sub" claim returned in the ID Token.
+	UserId string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+}
+
+func (m *ListConsentReq) Reset()                    { *m = ListConsentReq{} }
+func (m *ListConsentReq) String() string            { return proto.CompactTextString(m) }
+func (*ListConsentReq) ProtoMessage()               {}
+func (*ListConsentReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+// ListConsentResp returns a list of consents for a user.
+type ListConsentResp struct {
+	Consents []*Consent `protobuf:"bytes,1,rep,name=consents" json:"consents,omitempty"`
+}
+
+func (m *ListConsentResp) Reset()                    { *m = ListConsentResp{} }
+func (m *ListConsentResp) String() string            { return proto.CompactTextString(m) }
+func (*ListConsentResp) ProtoMessage()               {}
+func (*ListConsentResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *ListConsentResp) GetConsents() []*Consent {
+	if m != nil {
+		return m.Consents
+	}
+	return nil
+}
+
+// RevokeConsentReq is a request to revoke the consent of the user-client pair.
+type RevokeConsentReq struct {
+	// The "sub" claim returned in the ID Token.
+	UserId   string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+	ClientId string `protobuf:"bytes,2,opt,name=client_id,json=clientId" json:"client_id,omitempty"`
+}
+
+func (m *RevokeConsentReq) Reset()                    { *m = RevokeConsentReq{} }
+func (m *RevokeConsentReq) String() string            { return proto.CompactTextString(m) }
+func (*RevokeConsentReq) ProtoMessage()               {}
+func (*RevokeConsentReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+// RevokeConsentResp determines if the consent is revoked successfully.
+type RevokeConsentResp struct {
+	// Set to true if the consent was not found and could not be revoked.
+	NotFound bool `protobuf:"varint,1,opt,name=not_found,json=notFound" json:"not_found,omitempty"`
+}
+
+func (m *RevokeConsentResp) Reset()                    { *m = RevokeConsentResp{} }
+func (m *RevokeConsentResp) String() string            { return proto.CompactTextString(m) }
+func (*RevokeConsentResp) ProtoMessage()               {}
+func (*RevokeConsentResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
 func init() {
 	proto.RegisterType((*Client)(nil), "api.Client")
 	proto.RegisterType((*CreateClientReq)(nil), "api.CreateClientReq")
@@ -345,6 +450,11 @@ func init() {
 	proto.RegisterType((*ListRefreshResp)(nil), "api.ListRefreshResp")
 	proto.RegisterType((*RevokeRefreshReq)(nil), "api.RevokeRefreshReq")
 	proto.RegisterType((*RevokeRefreshResp)(nil), "api.RevokeRefreshResp")
+	proto.RegisterType((*Consent)(nil), "api.Consent")
+	proto.RegisterType((*ListConsentReq)(nil), "api.ListConsentReq")
+	proto.RegisterType((*ListConsentResp)(nil), "api.ListConsentResp")
+	proto.RegisterType((*RevokeConsentReq)(nil), "api.RevokeConsentReq")
+	proto.RegisterType((*RevokeConsentResp)(nil), "api.RevokeConsentResp")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -378,6 +488,11 @@ type DexClient interface {
 	//
 	// Note that each user-client pair can have only one refresh token at a time.
 	RevokeRefresh(ctx context.Context, in *RevokeRefreshReq, opts ...grpc.CallOption) (*RevokeRefreshResp, error)
+	// ListConsents lists the clients a particular user approved and their scopes.
+	ListConsents(ctx context.Context, in *ListConsentReq, opts ...grpc.CallOption) (*ListConsentResp, error)
+	// RevokeConsent revokes the consent for the provided user-client pair, so the
+	// user is asked to approve the client's requests again.
+	RevokeConsent(ctx context.Context, in *RevokeConsentReq, opts ...grpc.CallOption) (*RevokeConsentResp, error)
 }
 
 type dexClient struct {
@@ -469,6 +584,24 @@ func (c *dexClient) RevokeRefresh(ctx context.Context, in *RevokeRefreshReq, opt
 	return out, nil
 }
 
+func (c *dexClient) ListConsents(ctx context.Context, in *ListConsentReq, opts ...grpc.CallOption) (*ListConsentResp, error) {
+	out := new(ListConsentResp)
+	err := grpc.Invoke(ctx, "/api.Dex/ListConsents", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) RevokeConsent(ctx context.Context, in *RevokeConsentReq, opts ...grpc.CallOption) (*RevokeConsentResp, error) {
+	out := new(RevokeConsentResp)
+	err := grpc.Invoke(ctx, "/api.Dex/RevokeConsent", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Dex service
 
 type DexServer interface {
@@ -492,6 +625,11 @@ type DexServer interface {
 	//
 	// Note that each user-client pair can have only one refresh token at a time.
 	RevokeRefresh(context.Context, *RevokeRefreshReq) (*RevokeRefreshResp, error)
+	// ListConsents lists the clients a particular user approved and their scopes.
+	ListConsents(context.Context, *ListConsentReq) (*ListConsentResp, error)
+	// RevokeConsent revokes the consent for the provided user-client pair, so the
+	// user is asked to approve the client's requests again.
+	RevokeConsent(context.Context, *RevokeConsentReq) (*RevokeConsentResp, error)
 }
 
 func RegisterDexServer(s *grpc.Server, srv DexServer) {
@@ -660,6 +798,42 @@ func _Dex_RevokeRefresh_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Dex_ListConsents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListConsentReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).ListConsents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/ListConsents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).ListConsents(ctx, req.(*ListConsentReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_RevokeConsent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeConsentReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).RevokeConsent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/RevokeConsent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).RevokeConsent(ctx, req.(*RevokeConsentReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Dex_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "api.Dex",
 	HandlerType: (*DexServer)(nil),
@@ -700,6 +874,14 @@ var _Dex_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeRefresh",
 			Handler:    _Dex_RevokeRefresh_Handler,
 		},
+		{
+			MethodName: "ListConsents",
+			Handler:    _Dex_ListConsents_Handler,
+		},
+		{
+			MethodName: "RevokeConsent",
+			Handler:    _Dex_RevokeConsent_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/api.proto",
@@ -708,55 +890,85 @@ var _Dex_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("api/api.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 786 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0x6d, 0x4f, 0xdb, 0x48,
-	0x10, 0x4e, 0xe2, 0x90, 0x38, 0x93, 0xf7, 0x3d, 0x5e, 0x4c, 0xd0, 0x49, 0xb0, 0xe8, 0x24, 0xd0,
-	0x49, 0x70, 0x70, 0xd2, 0x9d, 0x74, 0xe8, 0xb8, 0x3b, 0xc1, 0xb5, 0x20, 0x55, 0x15, 0xb2, 0x9a,
-	0x7e, 0xac, 0x65, 0xe2, 0x01, 0x56, 0x18, 0xdb, 0xdd, 0xdd, 0x10, 0xda, 0x7f, 0x57, 0xf5, 0x8f,
-	0x55, 0xbb, 0xde, 0x04, 0xdb, 0x49, 0x1b, 0xbe, 0x79, 0x9e, 0x9d, 0x79, 0x66, 0xe7, 0xd9, 0x99,
-	0x31, 0xb4, 0xfd, 0x84, 0x1d, 0xfa, 0x09, 0x3b, 0x48, 0x78, 0x2c, 0x63, 0x62, 0xf9, 0x09, 0xa3,
-	0x5f, 0xca, 0x50, 0x3b, 0x0b, 0x19, 0x46, 0x92, 0x74, 0xa0, 0xc2, 0x02, 0xa7, 0xbc, 0x5d, 0xde,
-	0x6b, 0xb8, 0x15, 0x16, 0x90, 0x75, 0xa8, 0x09, 0x1c, 0x71, 0x94, 0x4e, 0x45, 0x63, 0xc6, 0x22,
-	0xbb, 0xd0, 0xe6, 0x18, 0x30, 0x8e, 0x23, 0xe9, 0x8d, 0x39, 0x13, 0x8e, 0xb5, 0x6d, 0xed, 0x35,
-	0xdc, 0xd6, 0x14, 0x1c, 0x72, 0x26, 0x94, 0x93, 0xe4, 0x63, 0x21, 0x31, 0xf0, 0x12, 0x44, 0x2e,
-	0x9c, 0x6a, 0xea, 0x64, 0xc0, 0x2b, 0x85, 0xa9, 0x0c, 0xc9, 0xf8, 0x3a, 0x64, 0x23, 0x67, 0x65,
-	0xbb, 0xbc, 0x67, 0xbb, 0xc6, 0x22, 0x04, 0xaa, 0x91, 0xff, 0x80, 0x4e, 0x4d, 0xe7, 0xd5, 0xdf,
-	0x64, 0x13, 0xec, 0x30, 0xbe, 0x8d, 0xbd, 0x31, 0x0f, 0x9d, 0xba, 0xc6, 0xeb, 0xca, 0x1e, 0xf2,
-	0x90, 0xfe, 0x01, 0xdd, 0x33, 0x8e, 0xbe, 0xc4, 0xb4, 0x10, 0x17, 0x3f, 0x92, 0x5d, 0xa8, 0x8d,
-	0xb4, 0xa1, 0xeb, 0x69, 0x1e, 0x37, 0x0f, 0x54, 0xdd, 0xe6, 0xdc, 0x1c, 0xd1, 0x0f, 0xd0, 0xcb,
-	0xc7, 0x89, 0x84, 0xfc, 0x02, 0x1d, 0x3f, 0xe4, 0xe8, 0x07, 0x9f, 0x3c, 0x7c, 0x62, 0x42, 0x0a,
-	0x4d, 0x60, 0xbb, 0x6d, 0x83, 0xfe, 0xaf, 0xc1, 0x0c, 0x7f, 0xe5, 0xfb, 0xfc, 0x3b, 0xd0, 0x3d,
-	0xc7, 0x10, 0xb3, 0xf7, 0x2a, 0x68, 0x4c, 0x0f, 0xa1, 0x97, 0x77, 0x11, 0x09, 0xd9, 0x82, 0x46,
-	0x14, 0x4b, 0xef, 0x26, 0x1e, 0x47, 0x81, 0xc9, 0x6e, 0x47, 0xb1, 0x7c, 0xa5, 0x6c, 0xca, 0xc0,
-	0xbe, 0xf2, 0x85, 0x98, 0xc4, 0x3c, 0x20, 0xab, 0xb0, 0x82, 0x0f, 0x3e, 0x0b, 0x0d, 0x5f, 0x6a,
-	0x28, 0xf1, 0xee, 0x7c, 0x71, 0xa7, 0x2f, 0xd6, 0x72, 0xf5, 0x37, 0x19, 0x80, 0x3d, 0x16, 0xc8,
-	0xb5, 0xa8, 0x96, 0x76, 0x9e, 0xd9, 0x64, 0x03, 0xea, 0xea, 0xdb, 0x63, 0x81, 0x53, 0x4d, 0xdf,
-	0x59, 0x99, 0x97, 0x01, 0x3d, 0x85, 0x7e, 0x2a, 0xcf, 0x34, 0xa1, 0x2a, 0x60, 0x1f, 0xec, 0xc4,
-	0x98, 0x46, 0xda, 0xb6, 0x2e, 0x7d, 0xe6, 0x33, 0x3b, 0xa6, 0x27, 0x40, 0x8a, 0xf1, 0x2f, 0x16,
-	0x98, 0xde, 0x42, 0x7f, 0x98, 0x04, 0x85, 0xe4, 0x8b, 0x0b, 0xde, 0x04, 0x3b, 0xc2, 0x89, 0x97,
-	0x29, 0xba, 0x1e, 0xe1, 0xe4, 0x42, 0xd5, 0xbd, 0x03, 0x2d, 0x75, 0x54, 0xa8, 0xbd, 0x19, 0xe1,
-	0x64, 0x68, 0x20, 0x7a, 0x04, 0xa4, 0x98, 0x68, 0xd9, 0x1b, 0xec, 0x43, 0x3f, 0x7d, 0xb4, 0xa5,
-	0x77, 0x53, 0xec, 0x45, 0xd7, 0x65, 0xec, 0x7d, 0xe8, 0xbe, 0x61, 0x42, 0x66, 0xb8, 0xe9, 0x3f,
-	0xd0, 0xcb, 0x43, 0x22, 0x21, 0xbf, 0x42, 0x63, 0xaa, 0xb4, 0x92, 0xd0, 0x9a, 0x7f, 0x89, 0xe7,
-	0x73, 0xda, 0x02, 0x78, 0x8f, 0x5c, 0xb0, 0x38, 0x52, 0x74, 0x7f, 0x42, 0x73, 0x66, 0x89, 0x24,
-	0x9d, 0x73, 0xfe, 0x88, 0xdc, 0x5c, 0xdd, 0x58, 0xa4, 0x07, 0x6a, 0x43, 0x68, 0x49, 0x57, 0x5c,
-	0xbd, 0x2c, 0x3e, 0x43, 0xd7, 0xc5, 0x1b, 0x8e, 0xe2, 0xee, 0x5d, 0x7c, 0x8f, 0x91, 0x8b, 0x37,
-	0x73, 0x4b, 0x63, 0x0b, 0x1a, 0x69, 0xf7, 0xab, 0x7e, 0x4a, 0xf7, 0x86, 0x9d, 0x02, 0x97, 0x01,
-	0xf9, 0x19, 0x60, 0xa4, 0x3b, 0x22, 0xf0, 0x7c, 0xa9, 0x67, 0xde, 0x72, 0x1b, 0x06, 0xf9, 0x4f,
-	0xaa, 0xd8, 0xd0, 0x17, 0x52, 0x3d, 0x57, 0xa0, 0x67, 0xdf, 0x72, 0x6d, 0x05, 0x0c, 0x05, 0x2a,
-	0xd1, 0x3b, 0x4a, 0x03, 0x93, 0x5f, 0x29, 0x9e, 0x69, 0xdc, 0x72, 0xae, 0x71, 0xdf, 0xa6, 0x0a,
-	0xce, 0x5c, 0x45, 0x42, 0x4e, 0xa0, 0xc3, 0x53, 0xd3, 0x93, 0xea, 0xea, 0x53, 0xc9, 0x56, 0xb5,
-	0x64, 0x85, 0xa2, 0xdc, 0x36, 0xcf, 0x00, 0x82, 0x5e, 0x40, 0xcf, 0xc5, 0xc7, 0xf8, 0x1e, 0x5f,
-	0x90, 0xfc, 0x87, 0x02, 0xd0, 0xdf, 0xa0, 0x5f, 0x60, 0x5a, 0xd2, 0x0d, 0xc7, 0x5f, 0xab, 0x60,
-	0x9d, 0xe3, 0x13, 0xf9, 0x1b, 0x5a, 0xd9, 0x5d, 0x45, 0xd2, 0x8b, 0x17, 0xd6, 0xde, 0x60, 0x6d,
-	0x01, 0x2a, 0x12, 0x5a, 0x52, 0xe1, 0xd9, 0x3d, 0x63, 0xc2, 0x0b, 0xdb, 0xc9, 0x84, 0x17, 0x17,
-	0x12, 0x2d, 0x91, 0x33, 0xe8, 0xe4, 0x47, 0x99, 0xac, 0x67, 0x32, 0x65, 0x5a, 0x75, 0xb0, 0xb1,
-	0x10, 0x9f, 0x92, 0xe4, 0x27, 0xcd, 0x90, 0xcc, 0xcd, 0xb9, 0x21, 0x99, 0x1f, 0xcb, 0x94, 0x24,
-	0x3f, 0x50, 0x86, 0x64, 0x6e, 0x20, 0x0d, 0xc9, 0xfc, 0xf4, 0xd1, 0x12, 0x39, 0x85, 0x76, 0x76,
-	0x9e, 0x84, 0x91, 0xa3, 0x30, 0x76, 0x46, 0x8e, 0xe2, 0xe4, 0xd1, 0x12, 0x39, 0x02, 0x78, 0x8d,
-	0xd2, 0xcc, 0x10, 0xe9, 0x6a, 0xb7, 0xe7, 0xf9, 0x1a, 0xf4, 0xf2, 0x80, 0x0e, 0xf9, 0x0b, 0x9a,
-	0x99, 0x9e, 0x24, 0x3f, 0xcd, 0xa8, 0x9f, 0x7b, 0x6a, 0xb0, 0x3a, 0x0f, 0xea, 0xd8, 0x7f, 0xa1,
-	0x9d, 0xeb, 0x1a, 0xb2, 0x66, 0xba, 0x36, 0xdf, 0x93, 0x83, 0xf5, 0x45, 0xb0, 0x62, 0xb8, 0xae,
-	0xe9, 0x3f, 0xfe, 0xef, 0xdf, 0x02, 0x00, 0x00, 0xff, 0xff, 0xbe, 0x1f, 0x5a, 0x3e, 0x02, 0x08,
-	0x00, 0x00,
+	// 1278 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x57, 0x5d, 0x6f, 0xdb, 0xc6,
+	0x12, 0xbd, 0xb2, 0x62, 0x5b, 0x1a, 0x49, 0x96, 0xb4, 0x96, 0x65, 0x9a, 0x49, 0x70, 0x63, 0x06,
+	0x17, 0x70, 0x6e, 0xdb, 0xa4, 0x49, 0x8b, 0x16, 0xad, 0xd1, 0xa4, 0x86, 0xf3, 0x65, 0x20, 0x68,
+	0x03, 0x26, 0xee, 0x63, 0x09, 0x9a, 0x1c, 0x5b, 0x1b, 0xd3, 0x24, 0xb3, 0xbb, 0x8c, 0x93, 0x3e,
+	0xf5, 0x77, 0xf5, 0xb1, 0xbf, 0xac, 0xd8, 0xd9, 0xa5, 0x4c, 0xd2, 0x6a, 0xec, 0x27, 0x6b, 0xce,
+	0x9c, 0x99, 0xd9, 0x3d, 0x33, 0x9c, 0x85, 0x61, 0x10, 0xe6, 0xfc, 0x41, 0x98, 0xf3, 0xfb, 0xb9,
+	0xc8, 0x54, 0xc6, 0xda, 0x61, 0xce, 0xbd, 0xbf, 0x3a, 0xb0, 0xb2, 0x9f, 0x70, 0x4c, 0x15, 0x5b,
+	0x83, 0x25, 0x1e, 0x3b, 0xad, 0x3b, 0xad, 0x9d, 0xae, 0xbf, 0xc4, 0x63, 0x36, 0x85, 0x15, 0x89,
+	0x91, 0x40, 0xe5, 0x2c, 0x11, 0x66, 0x2d, 0x76, 0x17, 0x06, 0x02, 0x63, 0x2e, 0x30, 0x52, 0x41,
+	0x21, 0xb8, 0x74, 0xda, 0x77, 0xda, 0x3b, 0x5d, 0xbf, 0x5f, 0x82, 0x87, 0x82, 0x4b, 0x4d, 0x52,
+	0xa2, 0x90, 0x0a, 0xe3, 0x20, 0x47, 0x14, 0xd2, 0xb9, 0x61, 0x48, 0x16, 0x7c, 0xad, 0x31, 0x5d,
+	0x21, 0x2f, 0x8e, 0x12, 0x1e, 0x39, 0xcb, 0x77, 0x5a, 0x3b, 0x1d, 0xdf, 0x5a, 0x8c, 0xc1, 0x8d,
+	0x34, 0x3c, 0x43, 0x67, 0x85, 0xea, 0xd2, 0x6f, 0xb6, 0x05, 0x9d, 0x24, 0x3b, 0xc9, 0x82, 0x42,
+	0x24, 0xce, 0x2a, 0xe1, 0xab, 0xda, 0x3e, 0x14, 0x09, 0xbb, 0x0f, 0xeb, 0x61, 0x92, 0x64, 0xe7,
+	0x18, 0x07, 0x27, 0x22, 0x4c, 0x55, 0xa0, 0x3e, 0xe5, 0x28, 0x9d, 0x0e, 0x55, 0x1c, 0x5b, 0xd7,
+	0x0b, 0xed, 0x79, 0xab, 0x1d, 0x3a, 0xfd, 0xbb, 0xf3, 0x53, 0xe9, 0x74, 0x4d, 0x7a, 0xfd, 0x5b,
+	0xa7, 0xd7, 0x7f, 0xf5, 0x85, 0x1c, 0x30, 0xe9, 0xb5, 0x7d, 0x28, 0x38, 0xfb, 0x01, 0xb6, 0xf2,
+	0x4c, 0xaa, 0x40, 0x97, 0x2b, 0x54, 0x50, 0xbf, 0x7b, 0x8f, 0x8a, 0x4c, 0x35, 0xe1, 0x15, 0xf9,
+	0xfd, 0xaa, 0x0a, 0xdf, 0xc2, 0xf4, 0x28, 0x8c, 0x4e, 0xa3, 0x59, 0x98, 0xa6, 0x98, 0x94, 0x19,
+	0x74, 0x8d, 0x3e, 0xd5, 0x98, 0x54, 0xbc, 0x26, 0x5c, 0x17, 0xd4, 0xf7, 0x89, 0x22, 0x94, 0x32,
+	0x50, 0xd9, 0x29, 0xa6, 0xc1, 0x71, 0x26, 0xce, 0x42, 0xe5, 0x0c, 0x28, 0x64, 0x6c, 0x5c, 0x6f,
+	0xb5, 0xe7, 0x39, 0x39, 0xd8, 0x13, 0xb8, 0x25, 0xf0, 0x7d, 0xc1, 0x05, 0x06, 0x79, 0x21, 0x67,
+	0x18, 0x07, 0x61, 0xa1, 0x66, 0x81, 0xc6, 0x50, 0x2a, 0xe9, 0xac, 0x91, 0xb8, 0x5b, 0x96, 0xf3,
+	0x9a, 0x28, 0x7b, 0x85, 0x9a, 0xf9, 0x96, 0xc0, 0xf6, 0xe0, 0x76, 0x99, 0x40, 0xf2, 0x93, 0x14,
+	0xe3, 0x32, 0x36, 0xc8, 0x8e, 0xde, 0x61, 0xa4, 0x9c, 0x21, 0x65, 0x70, 0x2d, 0xe9, 0x0d, 0x71,
+	0x6c, 0xf4, 0xaf, 0xc4, 0x60, 0xdb, 0xd0, 0x97, 0x05, 0xfd, 0x24, 0xf5, 0x9d, 0x11, 0x1d, 0xb6,
+	0x67, 0x31, 0xad, 0x3b, 0x7b, 0x04, 0x1b, 0x12, 0x23, 0x95, 0x89, 0x80, 0xc7, 0x98, 0x2a, 0x7e,
+	0xcc, 0x51, 0x90, 0x16, 0x63, 0xe2, 0xae, 0x1b, 0xe7, 0xc1, 0xdc, 0xa7, 0xa5, 0xf8, 0x0a, 0x58,
+	0xd9, 0xda, 0x28, 0x4b, 0x53, 0x62, 0x48, 0x87, 0xd5, 0x3a, 0xbb, 0x3f, 0x77, 0xb0, 0xff, 0xc1,
+	0x5a, 0x49, 0x97, 0x51, 0xa6, 0x87, 0x60, 0x9d, 0xa8, 0x03, 0x8b, 0xbe, 0x21, 0x50, 0xb7, 0xa5,
+	0xa4, 0x09, 0x94, 0x79, 0x96, 0x4a, 0xb4, 0x33, 0x33, 0x21, 0xfa, 0xc4, 0x7a, 0x7d, 0xeb, 0x34,
+	0x63, 0xf3, 0x7f, 0x18, 0xf3, 0xd8, 0xb6, 0x24, 0xe1, 0xc7, 0xa8, 0xf8, 0x19, 0x3a, 0x1b, 0x74,
+	0xf6, 0x21, 0x8f, 0xa9, 0x21, 0xaf, 0x2c, 0xac, 0xef, 0x5a, 0x6b, 0xe1, 0x9c, 0x3f, 0x35, 0x77,
+	0xad, 0x34, 0x71, 0x1e, 0xf3, 0x25, 0x30, 0xea, 0x5b, 0x94, 0xc5, 0x78, 0x11, 0xb0, 0x49, 0x01,
+	0x23, 0xed, 0xd9, 0xcf, 0x62, 0x9c, 0xb3, 0x77, 0xc1, 0x15, 0x78, 0x2c, 0x50, 0xce, 0x6c, 0x09,
+	0x1e, 0x27, 0x18, 0x68, 0x57, 0x56, 0x28, 0xc7, 0xa1, 0xa8, 0x4d, 0xcb, 0xa0, 0x3a, 0x07, 0x71,
+	0x82, 0x6f, 0x8d, 0x9b, 0x3d, 0x83, 0xff, 0xd6, 0x83, 0xc3, 0x23, 0x99, 0x25, 0x85, 0xaa, 0xd4,
+	0xdd, 0xa2, 0x0c, 0xb7, 0xaa, 0x19, 0xf6, 0x2c, 0xa9, 0x3c, 0x83, 0xf7, 0x1d, 0x0c, 0xf7, 0x05,
+	0x86, 0x0a, 0xcd, 0x06, 0xf1, 0xf1, 0x3d, 0xbb, 0x0b, 0x2b, 0x11, 0x19, 0xb4, 0x48, 0x7a, 0x8f,
+	0x7a, 0xf7, 0xf5, 0xc2, 0xb1, 0x7e, 0xeb, 0xf2, 0x7e, 0x87, 0x51, 0x3d, 0x4e, 0xe6, 0xa6, 0x75,
+	0x02, 0xc3, 0xf8, 0x53, 0x80, 0x1f, 0xb9, 0x1e, 0xdb, 0x16, 0x0d, 0xdd, 0xc0, 0xa2, 0xcf, 0x08,
+	0xac, 0xe4, 0x5f, 0xfa, 0xf7, 0xfc, 0xdb, 0x30, 0x7c, 0x8a, 0x09, 0x56, 0xcf, 0xd5, 0x58, 0x6e,
+	0xde, 0x03, 0x18, 0xd5, 0x29, 0x32, 0x67, 0x37, 0xa1, 0x9b, 0x66, 0x2a, 0x38, 0xce, 0x8a, 0x34,
+	0xb6, 0xd5, 0x3b, 0x69, 0xa6, 0x9e, 0x6b, 0xdb, 0xe3, 0xd0, 0x79, 0x1d, 0x4a, 0x79, 0x9e, 0x89,
+	0x98, 0x4d, 0x60, 0x19, 0xcf, 0x42, 0x9e, 0xd8, 0x7c, 0xc6, 0xd0, 0x6b, 0x65, 0x16, 0xca, 0x19,
+	0x1d, 0xac, 0xef, 0xd3, 0x6f, 0xe6, 0x42, 0xa7, 0x90, 0x28, 0x68, 0x9b, 0xb5, 0x89, 0x3c, 0xb7,
+	0xd9, 0x26, 0xac, 0xea, 0xdf, 0x01, 0x8f, 0x9d, 0x1b, 0x66, 0xc1, 0x6a, 0xf3, 0x20, 0xf6, 0x1e,
+	0xc3, 0xd8, 0xc8, 0x53, 0x16, 0xd4, 0x17, 0xb8, 0x07, 0x9d, 0xdc, 0x9a, 0x56, 0xda, 0x01, 0x5d,
+	0x7d, 0xce, 0x99, 0xbb, 0xbd, 0x5d, 0x60, 0xcd, 0xf8, 0x6b, 0x0b, 0xec, 0x9d, 0xc0, 0xf8, 0x30,
+	0x8f, 0x1b, 0xc5, 0x17, 0x5f, 0x78, 0x0b, 0x3a, 0x29, 0x9e, 0x07, 0x95, 0x4b, 0xaf, 0xa6, 0x78,
+	0xfe, 0x52, 0xdf, 0x7b, 0x1b, 0xfa, 0xda, 0xd5, 0xb8, 0x7b, 0x2f, 0xc5, 0xf3, 0x43, 0x0b, 0x79,
+	0x0f, 0x81, 0x35, 0x0b, 0x5d, 0xd5, 0x83, 0x7b, 0x30, 0x36, 0x4d, 0xbb, 0xf2, 0x6c, 0x3a, 0x7b,
+	0x93, 0x7a, 0x55, 0xf6, 0x31, 0x0c, 0x5f, 0x71, 0xa9, 0x2a, 0xb9, 0xbd, 0x27, 0x30, 0xaa, 0x43,
+	0x32, 0x67, 0x5f, 0x40, 0xb7, 0x54, 0x5a, 0x4b, 0xd8, 0xbe, 0xdc, 0x89, 0x0b, 0xbf, 0xd7, 0x07,
+	0xf8, 0x0d, 0x85, 0xe4, 0x59, 0xaa, 0xd3, 0x7d, 0x0f, 0xbd, 0xb9, 0x25, 0x73, 0xf3, 0xc0, 0x8a,
+	0x0f, 0x28, 0xec, 0xd1, 0xad, 0xc5, 0x46, 0xa0, 0x9f, 0x66, 0x92, 0x74, 0xd9, 0xd7, 0x3f, 0xbd,
+	0x3f, 0x60, 0xe8, 0x57, 0x3e, 0x44, 0x1f, 0x8f, 0x2f, 0xbd, 0xd6, 0x37, 0xa1, 0x6b, 0xa6, 0x5f,
+	0xcf, 0x93, 0x79, 0xb0, 0x3b, 0x06, 0x38, 0x88, 0xd9, 0x6d, 0x80, 0x88, 0x26, 0x22, 0x0e, 0x42,
+	0x45, 0x8f, 0x6d, 0xdb, 0xef, 0x5a, 0x64, 0x4f, 0xe9, 0xd8, 0x24, 0x94, 0x4a, 0xb7, 0x2b, 0xa6,
+	0x47, 0xb7, 0xed, 0x77, 0x34, 0x70, 0x28, 0x51, 0x8b, 0xbe, 0xa6, 0x35, 0xb0, 0xf5, 0xb5, 0xe2,
+	0x95, 0xc1, 0x6d, 0xd5, 0x06, 0xf7, 0x17, 0x18, 0xd6, 0xa8, 0x32, 0x67, 0xbb, 0xb0, 0x56, 0xdb,
+	0x34, 0xa5, 0x64, 0x13, 0x92, 0xac, 0x71, 0x29, 0x7f, 0x50, 0x5d, 0x37, 0xd2, 0x7b, 0x09, 0x23,
+	0x1f, 0x3f, 0x64, 0xa7, 0x78, 0x8d, 0xe2, 0x9f, 0x15, 0xc0, 0xfb, 0x1a, 0xc6, 0x8d, 0x4c, 0x57,
+	0x4d, 0xc3, 0x9f, 0x2d, 0x58, 0xdd, 0xd7, 0xcb, 0x3f, 0x55, 0xf5, 0xd4, 0xad, 0x86, 0xb6, 0xba,
+	0x8b, 0xe6, 0xad, 0x59, 0xa2, 0xc7, 0xc3, 0x5a, 0x0d, 0xcd, 0xdb, 0x4d, 0xcd, 0xb7, 0xa1, 0x6f,
+	0x34, 0xa7, 0x6f, 0xc0, 0xac, 0x80, 0xb6, 0xdf, 0x23, 0xd9, 0x0d, 0x54, 0x2a, 0x6f, 0x4f, 0xf1,
+	0x59, 0xe5, 0x77, 0x61, 0x58, 0xa3, 0xca, 0x9c, 0xed, 0x40, 0x27, 0x32, 0x66, 0xa9, 0x79, 0xdf,
+	0xec, 0x4a, 0xcb, 0x99, 0x7b, 0x2f, 0x64, 0xbe, 0x46, 0xa5, 0x6b, 0xca, 0x5c, 0x3d, 0xc8, 0xe7,
+	0x64, 0x7e, 0xf4, 0xf7, 0x32, 0xb4, 0x9f, 0xe2, 0x47, 0xf6, 0x13, 0xf4, 0xab, 0x4f, 0x02, 0x33,
+	0xf3, 0xd1, 0x78, 0x5d, 0xdc, 0x8d, 0x05, 0xa8, 0xcc, 0xbd, 0xff, 0xe8, 0xf0, 0xea, 0x3a, 0xb7,
+	0xe1, 0x8d, 0x47, 0xc0, 0xdd, 0x58, 0x80, 0x52, 0xf8, 0x3e, 0xac, 0xd5, 0x37, 0x26, 0x9b, 0x56,
+	0x2a, 0x55, 0x36, 0x82, 0xbb, 0xb9, 0x10, 0x2f, 0x93, 0xd4, 0x17, 0x9a, 0x4d, 0x72, 0x69, 0x9d,
+	0xba, 0x9b, 0x0b, 0xf1, 0x32, 0x49, 0x7d, 0x6f, 0xd9, 0x24, 0x97, 0xf6, 0x9e, 0xbb, 0xb9, 0x10,
+	0xa7, 0x24, 0x8f, 0x61, 0x50, 0x5d, 0x5b, 0xd2, 0xca, 0xd1, 0xd8, 0x6e, 0xee, 0xc6, 0x02, 0x94,
+	0xe2, 0x1f, 0x02, 0xbc, 0x40, 0x65, 0x57, 0x15, 0x1b, 0x12, 0xed, 0x62, 0x8d, 0xb9, 0xa3, 0x3a,
+	0x40, 0x21, 0x3f, 0x42, 0xaf, 0xf2, 0xe9, 0xb3, 0xf5, 0x79, 0xea, 0x8b, 0x4f, 0xd7, 0x9d, 0x5c,
+	0x06, 0x29, 0xf6, 0x67, 0x18, 0xd4, 0x3e, 0x4e, 0xb6, 0x61, 0x97, 0x43, 0xfd, 0xd3, 0x77, 0xa7,
+	0x8b, 0x60, 0xca, 0xb0, 0x0b, 0xfd, 0xca, 0xf8, 0xcb, 0x4a, 0xf9, 0x8b, 0x91, 0x76, 0x27, 0x97,
+	0xc1, 0x7a, 0x79, 0x0b, 0xd7, 0xca, 0x57, 0xe2, 0xa7, 0x8b, 0x60, 0x9d, 0xe1, 0x68, 0x85, 0xfe,
+	0xa1, 0xfa, 0xe6, 0x9f, 0x01, 0x00, 0x72, 0x04, 0xc7, 0xb3, 0x61, 0x0d, 0x00, 0x00,
 }
@@ -0,0 +1,229 @@
+// Code generated by protoc-gen-go.
+// source: api/signer/signer.proto
+// DO NOT EDIT!
+
+/*
+Package signer is a generated protocol buffer package.
+
+Package signer defines the protocol dex uses to have a separate process,
+holding the private keys, sign tokens.
+
+It is generated from these files:
+
+	api/signer/signer.proto
+
+It has these top-level messages:
+
+	SignReq
+	SignResp
+	GetKeysReq
+	GetKeysResp
+*/
+package signer
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// SignReq is a request to sign a payload.
+type SignReq struct {
+	// The JWT claims to sign.
+	Payload []byte `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	// The "typ" header of the JWS, such as "at+jwt" for access tokens. Omitted if
+	// empty.
+	Type string `protobuf:"bytes,2,opt,name=type" json:"type,omitempty"`
+}
+
+func (m *SignReq) Reset()                    { *m = SignReq{} }
+func (m *SignReq) String() string            { return proto.CompactTextString(m) }
+func (*SignReq) ProtoMessage()               {}
+func (*SignReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+// SignResp returns the signed payload.
+type SignResp struct {
+	// The payload signed with the current signing key, as a compact JWS.
+	Jws string `protobuf:"bytes,1,opt,name=jws" json:"jws,omitempty"`
+}
+
+func (m *SignResp) Reset()                    { *m = SignResp{} }
+func (m *SignResp) String() string            { return proto.CompactTextString(m) }
+func (*SignResp) ProtoMessage()               {}
+func (*SignResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+// GetKeysReq is a request for the public keys tokens are verified with.
+type GetKeysReq struct {
+}
+
+func (m *GetKeysReq) Reset()                    { *m = GetKeysReq{} }
+func (m *GetKeysReq) String() string            { return proto.CompactTextString(m) }
+func (*GetKeysReq) ProtoMessage()               {}
+func (*GetKeysReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+// GetKeysResp returns the public keys tokens are verified with.
+type GetKeysResp struct {
+	// The algorithm payloads are currently signed with, for example "RS256".
+	Algorithm string `protobuf:"bytes,1,opt,name=algorithm" json:"algorithm,omitempty"`
+	// JSON encoded JSON Web Key Set of the public keys, starting with the
+	// current signing key's.
+	Jwks []byte `protobuf:"bytes,2,opt,name=jwks,proto3" json:"jwks,omitempty"`
+	// Unix time the keys are next expected to change. Zero if unknown.
+	NextRotation int64 `protobuf:"varint,3,opt,name=next_rotation,json=nextRotation" json:"next_rotation,omitempty"`
+}
+
+func (m *GetKeysResp) Reset()                    { *m = GetKeysResp{} }
+func (m *GetKeysResp) String() string            { return proto.CompactTextString(m) }
+func (*GetKeysResp) ProtoMessage()               {}
+func (*GetKeysResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func init() {
+	proto.RegisterType((*SignReq)(nil), "signer.SignReq")
+	proto.RegisterType((*SignResp)(nil), "signer.SignResp")
+	proto.RegisterType((*GetKeysReq)(nil), "signer.GetKeysReq")
+	proto.RegisterType((*GetKeysResp)(nil), "signer.GetKeysResp")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Signer service
+
+type SignerClient interface {
+	// Sign signs a payload with the current signing key.
+	Sign(ctx context.Context, in *SignReq, opts ...grpc.CallOption) (*SignResp, error)
+	// GetKeys returns the public keys tokens are verified with.
+	GetKeys(ctx context.Context, in *GetKeysReq, opts ...grpc.CallOption) (*GetKeysResp, error)
+}
+
+type signerClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewSignerClient(cc *grpc.ClientConn) SignerClient {
+	return &signerClient{cc}
+}
+
+func (c *signerClient) Sign(ctx context.Context, in *SignReq, opts ...grpc.CallOption) (*SignResp, error) {
+	out := new(SignResp)
+	err := grpc.Invoke(ctx, "/signer.Signer/Sign", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signerClient) GetKeys(ctx context.Context, in *GetKeysReq, opts ...grpc.CallOption) (*GetKeysResp, error) {
+	out := new(GetKeysResp)
+	err := grpc.Invoke(ctx, "/signer.Signer/GetKeys", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Signer service
+
+type SignerServer interface {
+	// Sign signs a payload with the current signing key.
+	Sign(context.Context, *SignReq) (*SignResp, error)
+	// GetKeys returns the public keys tokens are verified with.
+	GetKeys(context.Context, *GetKeysReq) (*GetKeysResp, error)
+}
+
+func RegisterSignerServer(s *grpc.Server, srv SignerServer) {
+	s.RegisterService(&_Signer_serviceDesc, srv)
+}
+
+func _Signer_Sign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SignerServer).Sign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/signer.Signer/Sign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SignerServer).Sign(ctx, req.(*SignReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Signer_GetKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetKeysReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SignerServer).GetKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/signer.Signer/GetKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SignerServer).GetKeys(ctx, req.(*GetKeysReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Signer_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "signer.Signer",
+	HandlerType: (*SignerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Sign",
+			Handler:    _Signer_Sign_Handler,
+		},
+		{
+			MethodName: "GetKeys",
+			Handler:    _Signer_GetKeys_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/signer/signer.proto",
+}
+
+func init() { proto.RegisterFile("api/signer/signer.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 238 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x64, 0x90, 0x41, 0x4b, 0x03, 0x31,
+	0x10, 0x85, 0x5d, 0xb7, 0xec, 0xba, 0xe3, 0x8a, 0x65, 0x3c, 0x18, 0x4a, 0x0f, 0x4b, 0xbc, 0x2c,
+	0x08, 0x15, 0x54, 0xf0, 0x27, 0x78, 0xf0, 0x36, 0xfd, 0x01, 0x12, 0x69, 0x58, 0xd3, 0xd6, 0x24,
+	0x4d, 0x02, 0x75, 0xff, 0xbd, 0x24, 0x4d, 0x2d, 0xe2, 0x29, 0xef, 0x7d, 0xe1, 0x31, 0xf3, 0x06,
+	0x6e, 0x85, 0x55, 0x0f, 0x5e, 0x0d, 0x5a, 0xba, 0xfc, 0x2c, 0xac, 0x33, 0xc1, 0x60, 0x75, 0x70,
+	0xfc, 0x05, 0xea, 0xa5, 0x1a, 0x34, 0xc9, 0x1d, 0x32, 0xa8, 0xad, 0x18, 0xb7, 0x46, 0xac, 0x58,
+	0xd1, 0x15, 0x7d, 0x4b, 0x47, 0x8b, 0x08, 0x93, 0x30, 0x5a, 0xc9, 0xce, 0xbb, 0xa2, 0x6f, 0x28,
+	0x69, 0x3e, 0x87, 0x8b, 0x43, 0xd0, 0x5b, 0x9c, 0x42, 0xb9, 0xde, 0xfb, 0x94, 0x6a, 0x28, 0x4a,
+	0xde, 0x02, 0xbc, 0xca, 0xf0, 0x26, 0x47, 0x4f, 0x72, 0xc7, 0x57, 0x70, 0xf9, 0xeb, 0xbc, 0xc5,
+	0x39, 0x34, 0x62, 0x3b, 0x18, 0xa7, 0xc2, 0xe7, 0x57, 0x0e, 0x9d, 0x40, 0x1c, 0xb6, 0xde, 0x6f,
+	0x7c, 0x1a, 0xd6, 0x52, 0xd2, 0x78, 0x07, 0x57, 0x5a, 0x7e, 0x87, 0x77, 0x67, 0x82, 0x08, 0xca,
+	0x68, 0x56, 0x76, 0x45, 0x5f, 0x52, 0x1b, 0x21, 0x65, 0xf6, 0xb8, 0x81, 0x6a, 0x99, 0x4a, 0xe1,
+	0x3d, 0x4c, 0xa2, 0xc2, 0xeb, 0x45, 0xee, 0x9c, 0x2b, 0xce, 0xa6, 0x7f, 0x81, 0xb7, 0xfc, 0x0c,
+	0x9f, 0xa1, 0xce, 0xcb, 0x21, 0x1e, 0xbf, 0x4f, 0xbb, 0xcf, 0x6e, 0xfe, 0xb1, 0x98, 0xfa, 0xa8,
+	0xd2, 0x19, 0x9f, 0x7e, 0x06, 0x00, 0x7a, 0x79, 0xc6, 0x02, 0x61, 0x01, 0x00, 0x00,
+}
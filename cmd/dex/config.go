@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -24,8 +25,12 @@ type Config struct {
 	OAuth2  OAuth2  `json:"oauth2"`
 	GRPC    GRPC    `json:"grpc"`
 	Expiry  Expiry  `json:"expiry"`
+	Signer  Signer  `json:"signer"`
 	Logger  Logger  `json:"logger"`
 
+	Sessions Sessions `json:"sessions"`
+	Logout   Logout   `json:"logout"`
+
 	Frontend server.WebConfig `json:"frontend"`
 
 	// StaticConnectors are user defined connectors specified in the ConfigMap
@@ -92,6 +97,34 @@ type OAuth2 struct {
 	// If specified, do not prompt the user to approve client authorization. The
 	// act of logging in implies authorization.
 	SkipApprovalScreen bool `json:"skipApprovalScreen"`
+	// If specified, clients allowed to use the "password" grant log in end users
+	// through this connector. The connector must support password logins.
+	PasswordConnector string `json:"passwordConnector"`
+	// If specified, clients can register themselves at the registration endpoint
+	// by presenting one of the initial access tokens.
+	Registration Registration `json:"registration"`
+	// Format of access tokens issued to clients that don't configure their
+	// own, "opaque" or "jwt". Defaults to "opaque".
+	AccessTokenFormat string `json:"accessTokenFormat"`
+	// Secret used to compute the subjects of clients using pairwise subject
+	// identifiers. Changing it changes the subjects those clients see.
+	PairwiseSubjectSalt string `json:"pairwiseSubjectSalt"`
+	// IDs of clients, such as resource servers, which may introspect tokens
+	// issued to any client. Other clients can only introspect their own.
+	IntrospectionClients []string `json:"introspectionClients"`
+}
+
+// Registration is the config for dynamic client registration.
+type Registration struct {
+	// Bearer tokens callers must present to register a client.
+	InitialAccessTokens []string `json:"initialAccessTokens"`
+	// Patterns registered redirect URIs must match, such as
+	// "https://*.example.com/callback".
+	AllowedRedirectURIs []string `json:"allowedRedirectURIs"`
+	// Patterns registered "jwks_uri" and "backchannel_logout_uri" values must
+	// match. Clients can't set them if no patterns are configured.
+	AllowedJWKSURIs              []string `json:"allowedJWKSURIs"`
+	AllowedBackchannelLogoutURIs []string `json:"allowedBackchannelLogoutURIs"`
 }
 
 // Web is the config format for the HTTP server.
@@ -159,6 +192,52 @@ func (s *Storage) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// Signer holds configuration for signing tokens with keys kept out of the
+// storage. If no type is specified, keys are rotated through the storage.
+type Signer struct {
+	Type   string       `json:"type"`
+	Config SignerConfig `json:"config"`
+}
+
+// SignerConfig is a configuration that can create a signer.
+type SignerConfig interface {
+	Open(context.Context, logrus.FieldLogger) (server.Signer, error)
+}
+
+var signers = map[string]func() SignerConfig{
+	"static": func() SignerConfig { return new(server.StaticSignerConfig) },
+	"remote": func() SignerConfig { return new(server.RemoteSignerConfig) },
+}
+
+// UnmarshalJSON allows Signer to implement the unmarshaler interface to
+// dynamically determine the type of the signer config.
+func (s *Signer) UnmarshalJSON(b []byte) error {
+	var signer struct {
+		Type   string          `json:"type"`
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(b, &signer); err != nil {
+		return fmt.Errorf("parse signer: %v", err)
+	}
+	f, ok := signers[signer.Type]
+	if !ok {
+		return fmt.Errorf("unknown signer type %q", signer.Type)
+	}
+
+	signerConfig := f()
+	if len(signer.Config) != 0 {
+		data := []byte(os.ExpandEnv(string(signer.Config)))
+		if err := json.Unmarshal(data, signerConfig); err != nil {
+			return fmt.Errorf("parse signer config: %v", err)
+		}
+	}
+	*s = Signer{
+		Type:   signer.Type,
+		Config: signerConfig,
+	}
+	return nil
+}
+
 // Connector is a magical type that can unmarshal YAML dynamically. The
 // Type field determines the connector type, which is then customized for Config.
 type Connector struct {
@@ -225,6 +304,57 @@ type Expiry struct {
 
 	// IdTokens defines the duration of time for which the IdTokens will be valid.
 	IDTokens string `json:"idTokens"`
+
+	// MaxTokens is the longest ID and access token lifetime clients may be
+	// configured with. Signing keys are kept around this long. Defaults to IDTokens.
+	MaxTokens string `json:"maxTokens"`
+
+	// SigningAlgorithm defines the algorithm signing keys are generated for:
+	// "RS256", "ES256", "ES384" or "EdDSA". Defaults to "RS256".
+	SigningAlgorithm string `json:"signingAlgorithm"`
+
+	// RefreshTokens holds configuration for refresh tokens.
+	RefreshTokens RefreshTokenExpiry `json:"refreshTokens"`
+}
+
+// RefreshTokenExpiry holds configuration for the validity period of refresh tokens.
+type RefreshTokenExpiry struct {
+	// ReuseInterval defines the duration of time for which a rotated refresh
+	// token is still accepted. Disabled by default.
+	ReuseInterval string `json:"reuseInterval"`
+
+	// IdleTimeout defines the duration of time after which unused refresh tokens
+	// expire. Refresh tokens don't expire by default.
+	IdleTimeout string `json:"idleTimeout"`
+
+	// AbsoluteLifetime defines the duration of time after which refresh tokens
+	// expire even if they're in use. Refresh tokens don't expire by default.
+	AbsoluteLifetime string `json:"absoluteLifetime"`
+}
+
+// Sessions holds configuration for end users' browser sessions.
+type Sessions struct {
+	// If enabled, end users who have logged in don't have to log in again to
+	// authorize further clients until their session expires.
+	Enabled bool `json:"enabled"`
+
+	// IdleTimeout defines the duration of time after which unused sessions expire.
+	IdleTimeout string `json:"idleTimeout"`
+
+	// AbsoluteLifetime defines the duration of time after which sessions expire
+	// even if they're in use.
+	AbsoluteLifetime string `json:"absoluteLifetime"`
+}
+
+// Logout holds configuration for clients logging end users out.
+type Logout struct {
+	// If enabled, logging out through a client also revokes the client's refresh
+	// token for the end user.
+	RevokeRefreshTokens bool `json:"revokeRefreshTokens"`
+
+	// How many times to try delivering a back-channel logout notification to a
+	// client before giving up. Defaults to 5.
+	BackchannelMaxAttempts int `json:"backchannelMaxAttempts"`
 }
 
 // Logger holds configuration required to customize logging for dex.
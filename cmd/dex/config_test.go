@@ -5,6 +5,7 @@ import (
 
 	"github.com/coreos/dex/connector/mock"
 	"github.com/coreos/dex/connector/oidc"
+	"github.com/coreos/dex/server"
 	"github.com/coreos/dex/storage"
 	"github.com/coreos/dex/storage/sql"
 	"github.com/ghodss/yaml"
@@ -23,12 +24,34 @@ storage:
 
 web:
   http: 127.0.0.1:5556
+
+oauth2:
+  passwordConnector: local
+  registration:
+    initialAccessTokens:
+    - 'f3Bhn9kLq2'
+    allowedRedirectURIs:
+    - 'https://*.example.com/callback'
+    allowedJWKSURIs:
+    - 'https://*.example.com/jwks.json'
+  accessTokenFormat: jwt
+  pairwiseSubjectSalt: 'c2FsdA'
+  introspectionClients:
+  - resource-server
 staticClients:
 - id: example-app
   redirectURIs:
   - 'http://127.0.0.1:5555/callback'
   name: 'Example App'
   secret: ZXhhbXBsZS1hcHAtc2VjcmV0
+  allowedConnectors:
+  - mock
+  allowedScopes:
+  - email
+  - groups
+  allowedResponseTypes:
+  - code
+  idTokenLifetime: "10m"
 
 connectors:
 - type: mockCallback
@@ -59,6 +82,21 @@ staticPasswords:
 expiry:
   signingKeys: "6h"
   idTokens: "24h"
+  maxTokens: "72h"
+  signingAlgorithm: "ES256"
+  refreshTokens:
+    reuseInterval: "3s"
+    idleTimeout: "168h"
+    absoluteLifetime: "720h"
+
+sessions:
+  enabled: true
+  idleTimeout: "1h"
+  absoluteLifetime: "12h"
+
+logout:
+  revokeRefreshTokens: true
+  backchannelMaxAttempts: 3
 
 logger:
   level: "debug"
@@ -76,6 +114,17 @@ logger:
 		Web: Web{
 			HTTP: "127.0.0.1:5556",
 		},
+		OAuth2: OAuth2{
+			PasswordConnector: "local",
+			Registration: Registration{
+				InitialAccessTokens: []string{"f3Bhn9kLq2"},
+				AllowedRedirectURIs: []string{"https://*.example.com/callback"},
+				AllowedJWKSURIs:     []string{"https://*.example.com/jwks.json"},
+			},
+			AccessTokenFormat:    "jwt",
+			PairwiseSubjectSalt:  "c2FsdA",
+			IntrospectionClients: []string{"resource-server"},
+		},
 		StaticClients: []storage.Client{
 			{
 				ID:     "example-app",
@@ -84,6 +133,10 @@ logger:
 				RedirectURIs: []string{
 					"http://127.0.0.1:5555/callback",
 				},
+				AllowedConnectors:    []string{"mock"},
+				AllowedScopes:        []string{"email", "groups"},
+				AllowedResponseTypes: []string{"code"},
+				IDTokenLifetime:      "10m",
 			},
 		},
 		StaticConnectors: []Connector{
@@ -121,8 +174,24 @@ logger:
 			},
 		},
 		Expiry: Expiry{
-			SigningKeys: "6h",
-			IDTokens:    "24h",
+			SigningKeys:      "6h",
+			IDTokens:         "24h",
+			MaxTokens:        "72h",
+			SigningAlgorithm: "ES256",
+			RefreshTokens: RefreshTokenExpiry{
+				ReuseInterval:    "3s",
+				IdleTimeout:      "168h",
+				AbsoluteLifetime: "720h",
+			},
+		},
+		Sessions: Sessions{
+			Enabled:          true,
+			IdleTimeout:      "1h",
+			AbsoluteLifetime: "12h",
+		},
+		Logout: Logout{
+			RevokeRefreshTokens:    true,
+			BackchannelMaxAttempts: 3,
 		},
 		Logger: Logger{
 			Level:  "debug",
@@ -139,3 +208,34 @@ logger:
 	}
 
 }
+
+func TestUnmarshalSignerConfig(t *testing.T) {
+	rawConfig := []byte(`
+signer:
+  type: static
+  config:
+    keyFile: /etc/dex/signing-key.pem
+    verificationKeyFiles:
+    - /etc/dex/previous-signing-key.pem
+`)
+
+	want := Signer{
+		Type: "static",
+		Config: &server.StaticSignerConfig{
+			KeyFile:              "/etc/dex/signing-key.pem",
+			VerificationKeyFiles: []string{"/etc/dex/previous-signing-key.pem"},
+		},
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(rawConfig, &c); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if diff := pretty.Compare(c.Signer, want); diff != "" {
+		t.Errorf("got!=want: %s", diff)
+	}
+
+	if err := yaml.Unmarshal([]byte("signer:\n  type: hsm\n"), &c); err == nil {
+		t.Errorf("expected unknown signer type to be rejected")
+	}
+}
@@ -135,6 +135,37 @@ func serve(cmd *cobra.Command, args []string) error {
 
 	if len(c.StaticClients) > 0 {
 		for _, client := range c.StaticClients {
+			lifetimes := []string{
+				client.IDTokenLifetime,
+				client.AccessTokenLifetime,
+				client.AuthCodeLifetime,
+				client.RefreshTokenIdleTimeout,
+				client.RefreshTokenAbsoluteLifetime,
+			}
+			for _, lifetime := range lifetimes {
+				if d, err := time.ParseDuration(lifetime); lifetime != "" && (err != nil || d <= 0) {
+					return fmt.Errorf("invalid lifetime %q for static client %s", lifetime, client.ID)
+				}
+			}
+			switch client.SubjectType {
+			case "", "public":
+			case "pairwise":
+				if c.OAuth2.PairwiseSubjectSalt == "" {
+					return fmt.Errorf("invalid config: static client %s uses pairwise subjects but no pairwiseSubjectSalt is configured", client.ID)
+				}
+			default:
+				return fmt.Errorf("invalid subject type %q for static client %s", client.SubjectType, client.ID)
+			}
+			if err := server.ValidateSectorIdentifier(client); err != nil {
+				return fmt.Errorf("invalid config: static client %s: %v", client.ID, err)
+			}
+			for _, responseType := range client.AllowedResponseTypes {
+				switch responseType {
+				case "code", "token", "id_token":
+				default:
+					return fmt.Errorf("invalid allowed response type %q for static client %s", responseType, client.ID)
+				}
+			}
 			logger.Infof("config static client: %s", client.ID)
 		}
 		s = storage.WithStaticClients(s, c.StaticClients)
@@ -183,6 +214,21 @@ func serve(cmd *cobra.Command, args []string) error {
 	if c.OAuth2.SkipApprovalScreen {
 		logger.Infof("config skipping approval screen")
 	}
+	if c.OAuth2.PasswordConnector != "" {
+		logger.Infof("config password grant connector: %s", c.OAuth2.PasswordConnector)
+	}
+	if len(c.OAuth2.Registration.InitialAccessTokens) > 0 {
+		logger.Infof("config dynamic client registration enabled, allowed redirect URIs: %s", c.OAuth2.Registration.AllowedRedirectURIs)
+	}
+	if c.OAuth2.AccessTokenFormat != "" {
+		logger.Infof("config access token format: %s", c.OAuth2.AccessTokenFormat)
+	}
+	if c.OAuth2.PairwiseSubjectSalt != "" {
+		logger.Infof("config pairwise subjects enabled")
+	}
+	if len(c.OAuth2.IntrospectionClients) > 0 {
+		logger.Infof("config introspection clients: %s", c.OAuth2.IntrospectionClients)
+	}
 	if len(c.Web.AllowedOrigins) > 0 {
 		logger.Infof("config allowed origins: %s", c.Web.AllowedOrigins)
 	}
@@ -193,12 +239,22 @@ func serve(cmd *cobra.Command, args []string) error {
 	serverConfig := server.Config{
 		SupportedResponseTypes: c.OAuth2.ResponseTypes,
 		SkipApprovalScreen:     c.OAuth2.SkipApprovalScreen,
+		PasswordConnector:      c.OAuth2.PasswordConnector,
+		AccessTokenFormat:      c.OAuth2.AccessTokenFormat,
+		PairwiseSubjectSalt:    c.OAuth2.PairwiseSubjectSalt,
+		IntrospectionClients:   c.OAuth2.IntrospectionClients,
 		AllowedOrigins:         c.Web.AllowedOrigins,
 		Issuer:                 c.Issuer,
 		Storage:                s,
 		Web:                    c.Frontend,
 		Logger:                 logger,
 		Now:                    now,
+		Registration: server.RegistrationConfig{
+			InitialAccessTokens:          c.OAuth2.Registration.InitialAccessTokens,
+			AllowedRedirectURIs:          c.OAuth2.Registration.AllowedRedirectURIs,
+			AllowedJWKSURIs:              c.OAuth2.Registration.AllowedJWKSURIs,
+			AllowedBackchannelLogoutURIs: c.OAuth2.Registration.AllowedBackchannelLogoutURIs,
+		},
 	}
 	if c.Expiry.SigningKeys != "" {
 		signingKeys, err := time.ParseDuration(c.Expiry.SigningKeys)
@@ -216,6 +272,85 @@ func serve(cmd *cobra.Command, args []string) error {
 		logger.Infof("config id tokens valid for: %v", idTokens)
 		serverConfig.IDTokensValidFor = idTokens
 	}
+	if c.Expiry.MaxTokens != "" {
+		maxTokens, err := time.ParseDuration(c.Expiry.MaxTokens)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for max token expiry: %v", c.Expiry.MaxTokens, err)
+		}
+		logger.Infof("config clients may issue tokens valid for up to: %v", maxTokens)
+		serverConfig.MaxTokensValidFor = maxTokens
+	}
+	if c.Expiry.RefreshTokens.ReuseInterval != "" {
+		reuseInterval, err := time.ParseDuration(c.Expiry.RefreshTokens.ReuseInterval)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for refresh token reuse interval: %v", c.Expiry.RefreshTokens.ReuseInterval, err)
+		}
+		logger.Infof("config refresh tokens reusable for: %v", reuseInterval)
+		serverConfig.RefreshTokens.ReuseInterval = reuseInterval
+	}
+	if c.Expiry.RefreshTokens.IdleTimeout != "" {
+		idleTimeout, err := time.ParseDuration(c.Expiry.RefreshTokens.IdleTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for refresh token idle timeout: %v", c.Expiry.RefreshTokens.IdleTimeout, err)
+		}
+		logger.Infof("config refresh tokens expire when idle for: %v", idleTimeout)
+		serverConfig.RefreshTokens.IdleTimeout = idleTimeout
+	}
+	if c.Expiry.RefreshTokens.AbsoluteLifetime != "" {
+		absoluteLifetime, err := time.ParseDuration(c.Expiry.RefreshTokens.AbsoluteLifetime)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for refresh token absolute lifetime: %v", c.Expiry.RefreshTokens.AbsoluteLifetime, err)
+		}
+		logger.Infof("config refresh tokens expire after: %v", absoluteLifetime)
+		serverConfig.RefreshTokens.AbsoluteLifetime = absoluteLifetime
+	}
+	if c.Expiry.SigningAlgorithm != "" {
+		logger.Infof("config signing algorithm: %s", c.Expiry.SigningAlgorithm)
+		serverConfig.SigningAlgorithm = c.Expiry.SigningAlgorithm
+	}
+	if c.Signer.Config != nil {
+		if c.Expiry.SigningAlgorithm != "" {
+			return errors.New("expiry.signingAlgorithm can't be combined with a signer, the signer's keys determine the algorithm")
+		}
+		signer, err := c.Signer.Config.Open(context.Background(), logger)
+		if err != nil {
+			return fmt.Errorf("failed to initialize signer: %v", err)
+		}
+		logger.Infof("config signer: %s", c.Signer.Type)
+		serverConfig.Signer = signer
+	}
+
+	if c.Sessions.Enabled {
+		serverConfig.Sessions.Enabled = true
+		logger.Infof("config browser sessions enabled")
+	}
+	if c.Sessions.IdleTimeout != "" {
+		idleTimeout, err := time.ParseDuration(c.Sessions.IdleTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for session idle timeout: %v", c.Sessions.IdleTimeout, err)
+		}
+		logger.Infof("config sessions expire when idle for: %v", idleTimeout)
+		serverConfig.Sessions.IdleTimeout = idleTimeout
+	}
+	if c.Sessions.AbsoluteLifetime != "" {
+		lifetime, err := time.ParseDuration(c.Sessions.AbsoluteLifetime)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for session lifetime: %v", c.Sessions.AbsoluteLifetime, err)
+		}
+		logger.Infof("config sessions expire after: %v", lifetime)
+		serverConfig.Sessions.AbsoluteLifetime = lifetime
+	}
+	if c.Logout.RevokeRefreshTokens {
+		serverConfig.Logout.RevokeRefreshTokens = true
+		logger.Infof("config refresh tokens revoked on logout")
+	}
+	if c.Logout.BackchannelMaxAttempts != 0 {
+		if c.Logout.BackchannelMaxAttempts < 0 {
+			return fmt.Errorf("invalid config value %d for back-channel logout attempts", c.Logout.BackchannelMaxAttempts)
+		}
+		logger.Infof("config back-channel logout attempts: %d", c.Logout.BackchannelMaxAttempts)
+		serverConfig.Logout.BackchannelMaxAttempts = c.Logout.BackchannelMaxAttempts
+	}
 
 	serv, err := server.NewServer(context.Background(), serverConfig)
 	if err != nil {
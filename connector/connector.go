@@ -65,6 +65,17 @@ type CallbackConnector interface {
 	HandleCallback(s Scopes, r *http.Request) (identity Identity, err error)
 }
 
+// LoginHintConnector is an optional interface for callback connectors which can
+// pass a hint about the end user's login identifier, such as their email, on to
+// the upstream identity provider.
+type LoginHintConnector interface {
+	CallbackConnector
+
+	// LoginURLWithHint behaves like LoginURL, but asks the identity provider to
+	// prefill its login form with the hint.
+	LoginURLWithHint(s Scopes, callbackURL, state, loginHint string) (string, error)
+}
+
 // SAMLConnector represents SAML connectors which implement the HTTP POST binding.
 //  RelayState is handled by the server.
 //
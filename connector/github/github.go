@@ -82,8 +82,9 @@ type connectorData struct {
 }
 
 var (
-	_ connector.CallbackConnector = (*githubConnector)(nil)
-	_ connector.RefreshConnector  = (*githubConnector)(nil)
+	_ connector.CallbackConnector  = (*githubConnector)(nil)
+	_ connector.LoginHintConnector = (*githubConnector)(nil)
+	_ connector.RefreshConnector   = (*githubConnector)(nil)
 )
 
 type githubConnector struct {
@@ -136,6 +137,15 @@ func (c *githubConnector) LoginURL(scopes connector.Scopes, callbackURL, state s
 	return c.oauth2Config(scopes).AuthCodeURL(state), nil
 }
 
+func (c *githubConnector) LoginURLWithHint(scopes connector.Scopes, callbackURL, state, loginHint string) (string, error) {
+	if c.redirectURI != callbackURL {
+		return "", fmt.Errorf("expected callback URL did not match the URL in the config")
+	}
+
+	// GitHub calls the hint "login" and expects a username rather than an email.
+	return c.oauth2Config(scopes).AuthCodeURL(state, oauth2.SetAuthURLParam("login", loginHint)), nil
+}
+
 type oauth2Error struct {
 	error            string
 	errorDescription string
@@ -116,8 +116,9 @@ func (c *Config) Open(logger logrus.FieldLogger) (conn connector.Connector, err
 }
 
 var (
-	_ connector.CallbackConnector = (*oidcConnector)(nil)
-	_ connector.RefreshConnector  = (*oidcConnector)(nil)
+	_ connector.CallbackConnector  = (*oidcConnector)(nil)
+	_ connector.LoginHintConnector = (*oidcConnector)(nil)
+	_ connector.RefreshConnector   = (*oidcConnector)(nil)
 )
 
 type oidcConnector struct {
@@ -141,6 +142,13 @@ func (c *oidcConnector) LoginURL(s connector.Scopes, callbackURL, state string)
 	return c.oauth2Config.AuthCodeURL(state), nil
 }
 
+func (c *oidcConnector) LoginURLWithHint(s connector.Scopes, callbackURL, state, loginHint string) (string, error) {
+	if c.redirectURI != callbackURL {
+		return "", fmt.Errorf("expected callback URL did not match the URL in the config")
+	}
+	return c.oauth2Config.AuthCodeURL(state, oauth2.SetAuthURLParam("login_hint", loginHint)), nil
+}
+
 type oauth2Error struct {
 	error            string
 	errorDescription string
@@ -1,10 +1,13 @@
 package server
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
+	jose "gopkg.in/square/go-jose.v2"
 
 	// go-grpc doesn't use the standard library's context.
 	// https://github.com/grpc/grpc-go/issues/711
@@ -19,7 +22,7 @@ import (
 
 // apiVersion increases every time a new call is added to the API. Clients should use this info
 // to determine if the server supports specific features.
-const apiVersion = 2
+const apiVersion = 3
 
 // NewAPI returns a server which implements the gRPC API interface.
 func NewAPI(s storage.Storage, logger logrus.FieldLogger) api.DexServer {
@@ -42,10 +45,49 @@ func (d dexAPI) CreateClient(ctx context.Context, req *api.CreateClientReq) (*ap
 	if req.Client.Id == "" {
 		req.Client.Id = storage.NewID()
 	}
-	if req.Client.Secret == "" {
+	// Clients authenticating with "private_key_jwt" don't need a secret.
+	if req.Client.Secret == "" && req.Client.Jwks == "" && req.Client.JwksUri == "" {
 		req.Client.Secret = storage.NewID() + storage.NewID()
 	}
 
+	switch req.Client.AccessTokenFormat {
+	case "", accessTokenFormatOpaque, accessTokenFormatJWT:
+	default:
+		return nil, fmt.Errorf("invalid access token format %q", req.Client.AccessTokenFormat)
+	}
+	switch req.Client.SubjectType {
+	case "", subjectTypePublic, subjectTypePairwise:
+	default:
+		return nil, fmt.Errorf("invalid subject type %q", req.Client.SubjectType)
+	}
+	for _, responseType := range req.Client.AllowedResponseTypes {
+		switch responseType {
+		case responseTypeCode, responseTypeIDToken, responseTypeToken:
+		default:
+			return nil, fmt.Errorf("invalid allowed response type %q", responseType)
+		}
+	}
+	lifetimes := []string{
+		req.Client.IdTokenLifetime,
+		req.Client.AccessTokenLifetime,
+		req.Client.AuthCodeLifetime,
+		req.Client.RefreshTokenIdleTimeout,
+		req.Client.RefreshTokenAbsoluteLifetime,
+	}
+	for _, lifetime := range lifetimes {
+		if d, err := time.ParseDuration(lifetime); lifetime != "" && (err != nil || d <= 0) {
+			return nil, fmt.Errorf("invalid lifetime %q", lifetime)
+		}
+	}
+
+	var jwks *jose.JSONWebKeySet
+	if req.Client.Jwks != "" {
+		jwks = new(jose.JSONWebKeySet)
+		if err := json.Unmarshal([]byte(req.Client.Jwks), jwks); err != nil {
+			return nil, fmt.Errorf("invalid client jwks: %v", err)
+		}
+	}
+
 	c := storage.Client{
 		ID:           req.Client.Id,
 		Secret:       req.Client.Secret,
@@ -54,6 +96,35 @@ func (d dexAPI) CreateClient(ctx context.Context, req *api.CreateClientReq) (*ap
 		Public:       req.Client.Public,
 		Name:         req.Client.Name,
 		LogoURL:      req.Client.LogoUrl,
+
+		AllowedGrantTypes: req.Client.AllowedGrantTypes,
+
+		AllowedConnectors:    req.Client.AllowedConnectors,
+		AllowedScopes:        req.Client.AllowedScopes,
+		AllowedResponseTypes: req.Client.AllowedResponseTypes,
+
+		IDTokenLifetime:              req.Client.IdTokenLifetime,
+		AccessTokenLifetime:          req.Client.AccessTokenLifetime,
+		AuthCodeLifetime:             req.Client.AuthCodeLifetime,
+		RefreshTokenIdleTimeout:      req.Client.RefreshTokenIdleTimeout,
+		RefreshTokenAbsoluteLifetime: req.Client.RefreshTokenAbsoluteLifetime,
+
+		JWKS:    jwks,
+		JWKSURI: req.Client.JwksUri,
+
+		PostLogoutRedirectURIs: req.Client.PostLogoutRedirectUris,
+		BackchannelLogoutURI:   req.Client.BackchannelLogoutUri,
+
+		AccessTokenFormat: req.Client.AccessTokenFormat,
+
+		RequirePushedAuthRequests:  req.Client.RequirePushedAuthRequests,
+		RequireSignedRequestObject: req.Client.RequireSignedRequestObject,
+
+		SubjectType:         req.Client.SubjectType,
+		SectorIdentifierURI: req.Client.SectorIdentifierUri,
+	}
+	if err := ValidateSectorIdentifier(c); err != nil {
+		return nil, fmt.Errorf("invalid sector identifier: %v", err)
 	}
 	if err := d.s.CreateClient(c); err != nil {
 		if err == storage.ErrAlreadyExists {
@@ -277,5 +348,68 @@ func (d dexAPI) RevokeRefresh(ctx context.Context, req *api.RevokeRefreshReq) (*
 		return nil, err
 	}
 
+	// The client may still hold a session for the user, which it should end.
+	if err := queueLogoutNotifications(d.s, time.Now(), req.UserId, []string{req.ClientId}); err != nil {
+		d.logger.Errorf("api: failed to queue logout notification: %v", err)
+	}
+
 	return &api.RevokeRefreshResp{}, nil
 }
+
+func (d dexAPI) ListConsents(ctx context.Context, req *api.ListConsentReq) (*api.ListConsentResp, error) {
+	id := new(internal.IDTokenSubject)
+	if err := internal.Unmarshal(req.UserId, id); err != nil {
+		d.logger.Errorf("api: failed to unmarshal ID Token subject: %v", err)
+		return nil, err
+	}
+
+	var consents []*api.Consent
+	c, err := d.s.GetConsents(id.UserId, id.ConnId)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			// The user hasn't approved any client yet.
+			return &api.ListConsentResp{Consents: consents}, nil
+		}
+		d.logger.Errorf("api: failed to list consents: %v", err)
+		return nil, err
+	}
+
+	for clientID, consent := range c.Clients {
+		consents = append(consents, &api.Consent{
+			ClientId:    clientID,
+			Scopes:      consent.Scopes,
+			CreatedAt:   consent.CreatedAt.Unix(),
+			LastUpdated: consent.LastUpdated.Unix(),
+		})
+	}
+
+	return &api.ListConsentResp{Consents: consents}, nil
+}
+
+func (d dexAPI) RevokeConsent(ctx context.Context, req *api.RevokeConsentReq) (*api.RevokeConsentResp, error) {
+	id := new(internal.IDTokenSubject)
+	if err := internal.Unmarshal(req.UserId, id); err != nil {
+		d.logger.Errorf("api: failed to unmarshal ID Token subject: %v", err)
+		return nil, err
+	}
+
+	notFound := false
+	updater := func(old storage.Consents) (storage.Consents, error) {
+		if _, ok := old.Clients[req.ClientId]; !ok {
+			notFound = true
+			return old, nil
+		}
+		delete(old.Clients, req.ClientId)
+		return old, nil
+	}
+
+	if err := d.s.UpdateConsents(id.UserId, id.ConnId, updater); err != nil {
+		if err == storage.ErrNotFound {
+			return &api.RevokeConsentResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to update consents: %v", err)
+		return nil, err
+	}
+
+	return &api.RevokeConsentResp{NotFound: notFound}, nil
+}
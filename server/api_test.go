@@ -218,3 +218,72 @@ func TestRefreshToken(t *testing.T) {
 		t.Fatalf("Refresh token returned inspite of revoking it.")
 	}
 }
+
+func TestConsents(t *testing.T) {
+	logger := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: &logrus.TextFormatter{DisableColors: true},
+		Level:     logrus.DebugLevel,
+	}
+
+	s := memory.New(logger)
+	client := newAPI(s, logger, t)
+	defer client.Close()
+
+	ctx := context.Background()
+
+	now := time.Now().UTC().Round(time.Second)
+	consents := storage.Consents{
+		UserID: "1",
+		ConnID: "mock",
+		Clients: map[string]*storage.Consent{
+			"client_id": {
+				Scopes:      []string{"openid", "email"},
+				CreatedAt:   now,
+				LastUpdated: now,
+			},
+		},
+	}
+	if err := s.CreateConsents(consents); err != nil {
+		t.Fatalf("create consents: %v", err)
+	}
+
+	subjectString, err := internal.Marshal(&internal.IDTokenSubject{
+		UserId: consents.UserID,
+		ConnId: consents.ConnID,
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal subject: %v", err)
+	}
+
+	listReq := api.ListConsentReq{UserId: subjectString}
+	listResp, err := client.ListConsents(ctx, &listReq)
+	if err != nil {
+		t.Fatalf("Unable to list consents for user: %v", err)
+	}
+	if len(listResp.Consents) != 1 {
+		t.Fatalf("Expected 1 consent, got %d", len(listResp.Consents))
+	}
+	consent := listResp.Consents[0]
+	if consent.ClientId != "client_id" {
+		t.Errorf("Expected consent for client %q, got %q", "client_id", consent.ClientId)
+	}
+	if len(consent.Scopes) != 2 {
+		t.Errorf("Expected scopes %q, got %q", consents.Clients["client_id"].Scopes, consent.Scopes)
+	}
+	if consent.CreatedAt != now.Unix() {
+		t.Errorf("Expected CreatedAt timestamp %v, got %v", now.Unix(), consent.CreatedAt)
+	}
+
+	revokeReq := api.RevokeConsentReq{UserId: subjectString, ClientId: "client_id"}
+	resp, err := client.RevokeConsent(ctx, &revokeReq)
+	if err != nil || resp.NotFound {
+		t.Fatalf("Unable to revoke consent for user: %v", err)
+	}
+	if resp, _ := client.ListConsents(ctx, &listReq); len(resp.Consents) != 0 {
+		t.Fatalf("Consent returned inspite of revoking it.")
+	}
+	if resp, err := client.RevokeConsent(ctx, &revokeReq); err != nil || !resp.NotFound {
+		t.Errorf("Expected revoking the consent again to report it not found: %v", err)
+	}
+}
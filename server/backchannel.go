@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/dex/storage"
+)
+
+// The event a logout token carries to tell a client an end user logged out.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+const backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// The "typ" header of logout tokens.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+const logoutTokenType = "logout+jwt"
+
+const (
+	// Notifications that haven't been delivered by then are dropped by garbage
+	// collection, even if they have attempts left.
+	logoutNotificationLifetime = 24 * time.Hour
+
+	// How long to wait before retrying a failed delivery. Doubles with every
+	// failed attempt.
+	logoutNotificationRetryDelay = 30 * time.Second
+
+	// How long a server may spend delivering a notification before other
+	// servers sharing the storage consider it abandoned and try again.
+	logoutNotificationLease = time.Minute
+)
+
+// errNotificationClaimed is returned when another server is already delivering
+// a logout notification.
+var errNotificationClaimed = errors.New("logout notification claimed by another server")
+
+type logoutTokenClaims struct {
+	Issuer   string              `json:"iss"`
+	Subject  string              `json:"sub"`
+	Audience audience            `json:"aud"`
+	IssuedAt int64               `json:"iat"`
+	Expiry   int64               `json:"exp"`
+	ID       string              `json:"jti"`
+	Events   map[string]struct{} `json:"events"`
+}
+
+// queueLogoutNotifications persists back-channel logout notifications telling
+// clients that the end user identified by subject has logged out. Clients
+// without a back-channel logout URI are skipped.
+func queueLogoutNotifications(s storage.Storage, now time.Time, subject string, clientIDs []string) error {
+	queued := make(map[string]bool)
+	for _, clientID := range clientIDs {
+		if queued[clientID] {
+			continue
+		}
+		queued[clientID] = true
+
+		client, err := s.GetClient(clientID)
+		if err != nil {
+			if err == storage.ErrNotFound {
+				continue
+			}
+			return fmt.Errorf("get client %q: %v", clientID, err)
+		}
+		if client.BackchannelLogoutURI == "" {
+			continue
+		}
+
+		n := storage.LogoutNotification{
+			ID:          storage.NewID(),
+			ClientID:    clientID,
+			Subject:     subject,
+			NextAttempt: now,
+			Expiry:      now.Add(logoutNotificationLifetime),
+		}
+		if err := s.CreateLogoutNotification(n); err != nil {
+			return fmt.Errorf("create logout notification for client %q: %v", clientID, err)
+		}
+	}
+	return nil
+}
+
+// startLogoutNotifications delivers queued logout notifications in a new
+// goroutine, closing once the context is canceled.
+func (s *Server) startLogoutNotifications(ctx context.Context, frequency time.Duration) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(frequency):
+				s.deliverLogoutNotifications(ctx)
+			}
+		}
+	}()
+}
+
+// deliverLogoutNotifications tries to deliver every queued logout notification
+// which is due.
+func (s *Server) deliverLogoutNotifications(ctx context.Context) {
+	notifications, err := s.storage.ListLogoutNotifications()
+	if err != nil {
+		s.logger.Errorf("failed to list logout notifications: %v", err)
+		return
+	}
+
+	for _, n := range notifications {
+		if ctx.Err() != nil {
+			return
+		}
+		now := s.now()
+		if now.Before(n.NextAttempt) {
+			continue
+		}
+
+		// Claim the notification so other servers don't deliver it at the
+		// same time.
+		claim := func(old storage.LogoutNotification) (storage.LogoutNotification, error) {
+			if now.Before(old.NextAttempt) {
+				return old, errNotificationClaimed
+			}
+			old.NextAttempt = now.Add(logoutNotificationLease)
+			return old, nil
+		}
+		if err := s.storage.UpdateLogoutNotification(n.ID, claim); err != nil {
+			if err != errNotificationClaimed && err != storage.ErrNotFound {
+				s.logger.Errorf("failed to claim logout notification: %v", err)
+			}
+			continue
+		}
+
+		err := s.sendLogoutNotification(ctx, n)
+		if err == nil {
+			if err := s.storage.DeleteLogoutNotification(n.ID); err != nil && err != storage.ErrNotFound {
+				s.logger.Errorf("failed to delete logout notification: %v", err)
+			}
+			continue
+		}
+
+		attempts := n.Attempts + 1
+		if attempts >= s.logout.BackchannelMaxAttempts {
+			s.logger.Errorf("giving up notifying client %q of logout after %d attempts: %v", n.ClientID, attempts, err)
+			if err := s.storage.DeleteLogoutNotification(n.ID); err != nil && err != storage.ErrNotFound {
+				s.logger.Errorf("failed to delete logout notification: %v", err)
+			}
+			continue
+		}
+
+		s.logger.Infof("failed to notify client %q of logout, will retry: %v", n.ClientID, err)
+		retry := func(old storage.LogoutNotification) (storage.LogoutNotification, error) {
+			old.Attempts = attempts
+			old.NextAttempt = s.now().Add(logoutNotificationRetryDelay << uint(attempts-1))
+			return old, nil
+		}
+		if err := s.storage.UpdateLogoutNotification(n.ID, retry); err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to update logout notification: %v", err)
+		}
+	}
+}
+
+// sendLogoutNotification POSTs a logout token to the client's back-channel
+// logout URI.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest
+func (s *Server) sendLogoutNotification(ctx context.Context, n storage.LogoutNotification) error {
+	client, err := s.storage.GetClient(n.ClientID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			// Nothing left to notify.
+			return nil
+		}
+		return fmt.Errorf("get client: %v", err)
+	}
+	if client.BackchannelLogoutURI == "" {
+		return nil
+	}
+
+	subject, err := s.clientSubject(client, n.Subject)
+	if err != nil {
+		return fmt.Errorf("compute subject: %v", err)
+	}
+	logoutToken, err := s.newLogoutToken(client.ID, subject)
+	if err != nil {
+		return err
+	}
+
+	body := url.Values{"logout_token": {logoutToken}}.Encode()
+	req, err := http.NewRequest("POST", client.BackchannelLogoutURI, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.logoutClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
+
+// newLogoutToken signs a logout token telling a client that an end user has
+// logged out.
+func (s *Server) newLogoutToken(clientID, subject string) (string, error) {
+	issuedAt := s.now()
+	tok := logoutTokenClaims{
+		Issuer:   s.issuerURL.String(),
+		Subject:  subject,
+		Audience: audience{clientID},
+		IssuedAt: issuedAt.Unix(),
+		Expiry:   issuedAt.Add(2 * time.Minute).Unix(),
+		ID:       storage.NewID(),
+		Events:   map[string]struct{}{backchannelLogoutEvent: {}},
+	}
+	payload, err := json.Marshal(tok)
+	if err != nil {
+		return "", fmt.Errorf("could not serialize claims: %v", err)
+	}
+	logoutToken, err := s.signer.Sign(payload, logoutTokenType)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign payload: %v", err)
+	}
+	return logoutToken, nil
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/coreos/dex/api"
+	"github.com/coreos/dex/storage"
+)
+
+// logoutReceiver is a client's back-channel logout endpoint.
+type logoutReceiver struct {
+	mu     sync.Mutex
+	status int
+	tokens []string
+}
+
+func (l *logoutReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = append(l.tokens, r.PostFormValue("logout_token"))
+	w.WriteHeader(l.status)
+}
+
+func (l *logoutReceiver) setStatus(status int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.status = status
+}
+
+// received returns the logout tokens received since it was last called.
+func (l *logoutReceiver) received() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tokens := l.tokens
+	l.tokens = nil
+	return tokens
+}
+
+func TestBackchannelLogout(t *testing.T) {
+	receiver := &logoutReceiver{status: http.StatusOK}
+	receiverServer := httptest.NewServer(receiver)
+	defer receiverServer.Close()
+
+	clients := []storage.Client{
+		{
+			ID:                   "app",
+			Secret:               "secret",
+			RedirectURIs:         []string{"https://app.example.com/callback"},
+			BackchannelLogoutURI: receiverServer.URL,
+		},
+		{
+			// Clients without a back-channel logout URI aren't notified.
+			ID:           "other-app",
+			Secret:       "secret",
+			RedirectURIs: []string{"https://other-app.example.com/callback"},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+		c.Sessions = SessionConfig{Enabled: true}
+		c.Logout = LogoutConfig{BackchannelMaxAttempts: 2}
+		c.Now = func() time.Time { return now }
+	})
+	defer httpServer.Close()
+
+	subject, err := idTokenSubject("user", "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending := func() []storage.LogoutNotification {
+		notifications, err := server.storage.ListLogoutNotifications()
+		if err != nil {
+			t.Fatalf("failed to list logout notifications: %v", err)
+		}
+		return notifications
+	}
+
+	session := storage.Session{
+		ID:          storage.NewID(),
+		ConnectorID: "mock",
+		Claims:      storage.Claims{UserID: "user"},
+		CreatedAt:   now,
+		LastUsed:    now,
+		Expiry:      now.Add(time.Hour),
+		ClientIDs:   []string{"app", "other-app"},
+	}
+	if err := server.storage.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	v := url.Values{"confirm": {logoutConfirmation(session.ID)}}
+	req := httptest.NewRequest("POST", "/logout", strings.NewReader(v.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session.ID})
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	notifications := pending()
+	if len(notifications) != 1 {
+		t.Fatalf("expected 1 logout notification, got %d", len(notifications))
+	}
+	if n := notifications[0]; n.ClientID != "app" || n.Subject != subject {
+		t.Fatalf("unexpected logout notification %#v", n)
+	}
+
+	// A failed delivery is retried, but not before the retry delay has passed.
+	receiver.setStatus(http.StatusInternalServerError)
+	server.deliverLogoutNotifications(ctx)
+	if tokens := receiver.received(); len(tokens) != 1 {
+		t.Fatalf("expected 1 delivery attempt, got %d", len(tokens))
+	}
+	if n := pending(); len(n) != 1 || n[0].Attempts != 1 {
+		t.Fatalf("expected failed notification to stay queued, got %#v", n)
+	}
+	server.deliverLogoutNotifications(ctx)
+	if tokens := receiver.received(); len(tokens) != 0 {
+		t.Fatalf("expected notification not to be retried before its delay, got %d attempts", len(tokens))
+	}
+
+	now = now.Add(logoutNotificationRetryDelay)
+	receiver.setStatus(http.StatusOK)
+	server.deliverLogoutNotifications(ctx)
+	tokens := receiver.received()
+	if len(tokens) != 1 {
+		t.Fatalf("expected 1 delivery attempt, got %d", len(tokens))
+	}
+	if n := pending(); len(n) != 0 {
+		t.Fatalf("expected delivered notification to be deleted, got %#v", n)
+	}
+
+	jws, err := jose.ParseSigned(tokens[0])
+	if err != nil {
+		t.Fatalf("failed to parse logout token: %v", err)
+	}
+	payload, err := jws.Verify(testKey.Public())
+	if err != nil {
+		t.Fatalf("failed to verify logout token: %v", err)
+	}
+	var claims logoutTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("failed to unmarshal logout token: %v", err)
+	}
+	if claims.Subject != subject {
+		t.Errorf("expected subject %q got %q", subject, claims.Subject)
+	}
+	if !claims.Audience.contains("app") || len(claims.Audience) != 1 {
+		t.Errorf("expected audience [app] got %v", claims.Audience)
+	}
+	if _, ok := claims.Events[backchannelLogoutEvent]; !ok {
+		t.Errorf("expected logout token to carry the back-channel logout event, got %v", claims.Events)
+	}
+
+	// Revoking a client's refresh token also notifies it, and notifications
+	// are dropped after too many failed attempts.
+	refresh := storage.RefreshToken{
+		ID:          storage.NewID(),
+		Token:       storage.NewID(),
+		ClientID:    "app",
+		ConnectorID: "mock",
+		Claims:      storage.Claims{UserID: "user"},
+	}
+	if err := server.storage.CreateRefresh(refresh); err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+	offlineSessions := storage.OfflineSessions{
+		UserID:  "user",
+		ConnID:  "mock",
+		Refresh: map[string]*storage.RefreshTokenRef{"app": {ID: refresh.ID, ClientID: "app"}},
+	}
+	if err := server.storage.CreateOfflineSessions(offlineSessions); err != nil {
+		t.Fatalf("failed to create offline sessions: %v", err)
+	}
+
+	client := newAPI(server.storage, logger, t)
+	defer client.Close()
+	if _, err := client.RevokeRefresh(ctx, &api.RevokeRefreshReq{UserId: subject, ClientId: "app"}); err != nil {
+		t.Fatalf("failed to revoke refresh token: %v", err)
+	}
+	if n := pending(); len(n) != 1 {
+		t.Fatalf("expected 1 logout notification, got %d", len(n))
+	}
+
+	receiver.setStatus(http.StatusServiceUnavailable)
+	server.deliverLogoutNotifications(ctx)
+	now = now.Add(logoutNotificationRetryDelay)
+	server.deliverLogoutNotifications(ctx)
+	if tokens := receiver.received(); len(tokens) != 2 {
+		t.Fatalf("expected 2 delivery attempts, got %d", len(tokens))
+	}
+	if n := pending(); len(n) != 0 {
+		t.Fatalf("expected notification to be dropped after max attempts, got %#v", n)
+	}
+
+	// Without a session, an ID token hint doesn't notify the clients holding
+	// refresh tokens for the end user.
+	refresh.ID = storage.NewID()
+	if err := server.storage.CreateRefresh(refresh); err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+	updater := func(o storage.OfflineSessions) (storage.OfflineSessions, error) {
+		o.Refresh["app"] = &storage.RefreshTokenRef{ID: refresh.ID, ClientID: "app"}
+		return o, nil
+	}
+	if err := server.storage.UpdateOfflineSessions("user", "mock", updater); err != nil {
+		t.Fatalf("failed to update offline sessions: %v", err)
+	}
+	idToken, _, err := server.newIDToken(storage.Client{ID: "app"}, storage.Claims{UserID: "user"}, []string{"openid"}, "", "", "mock", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/logout?id_token_hint="+idToken, nil))
+	if n := pending(); len(n) != 0 {
+		t.Fatalf("expected logout without a session not to notify clients, got %#v", n)
+	}
+}
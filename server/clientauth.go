@@ -0,0 +1,314 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/coreos/dex/storage"
+)
+
+// Client authentication methods supported by the token endpoints.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
+const (
+	authMethodClientSecretBasic = "client_secret_basic"
+	authMethodClientSecretPost  = "client_secret_post"
+	authMethodClientSecretJWT   = "client_secret_jwt"
+	authMethodPrivateKeyJWT     = "private_key_jwt"
+)
+
+// clientAssertionTypeJWT is the only "client_assertion_type" accepted.
+//
+// https://tools.ietf.org/html/rfc7523#section-2.2
+const clientAssertionTypeJWT = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+const (
+	// Assertions must expire within this duration, bounding how long their
+	// IDs are remembered to prevent replays.
+	maxClientAssertionLifetime = time.Hour
+
+	// How long JSON Web Key Sets fetched from a client's "jwksURI" are cached,
+	// and how often they may be refetched when no key matches a signature.
+	clientKeysValidFor       = 5 * time.Minute
+	clientKeysRefreshBackoff = 30 * time.Second
+
+	// Key sets larger than this are rejected rather than read into memory.
+	maxClientKeysSize = 1 << 20
+)
+
+// Algorithms client assertions may be signed with. The HMAC algorithms are
+// used by "client_secret_jwt", the rest by "private_key_jwt".
+var clientAssertionAlgs = []string{
+	string(jose.RS256), string(jose.RS384), string(jose.RS512),
+	string(jose.ES256), string(jose.ES384), string(jose.ES512),
+	string(jose.EdDSA),
+	string(jose.HS256), string(jose.HS384), string(jose.HS512),
+}
+
+// clientAssertionClaims are the claims of a JWT a client authenticates with.
+//
+// https://tools.ietf.org/html/rfc7523#section-3
+type clientAssertionClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	Expiry    int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	ID        string   `json:"jti"`
+}
+
+// parseClientAssertion parses a client assertion and decodes its claims
+// WITHOUT verifying the signature. The claims identify the client whose keys
+// must then be used to verify the assertion.
+func parseClientAssertion(assertion string) (*jose.JSONWebSignature, clientAssertionClaims, error) {
+	var claims clientAssertionClaims
+
+	jws, payload, err := parseUnverifiedJWT(assertion)
+	if err != nil {
+		return nil, claims, err
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, claims, fmt.Errorf("failed to decode assertion claims: %v", err)
+	}
+	return jws, claims, nil
+}
+
+// parseUnverifiedJWT parses a compact JWT and returns its payload WITHOUT
+// verifying the signature.
+func parseUnverifiedJWT(token string) (*jose.JSONWebSignature, []byte, error) {
+	jws, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed JWT: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, errors.New("JWT must use the compact serialization")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed JWT payload: %v", err)
+	}
+	return jws, payload, nil
+}
+
+// verifyClientAssertion checks the signature and claims of an assertion
+// presented by a client, then records the assertion so it can't be replayed.
+func (s *Server) verifyClientAssertion(client storage.Client, jws *jose.JSONWebSignature, claims clientAssertionClaims) error {
+	if err := s.verifyClientSignature(client, jws); err != nil {
+		return err
+	}
+
+	if claims.Issuer != client.ID || claims.Subject != client.ID {
+		return errors.New(`assertion "iss" and "sub" must be the client ID`)
+	}
+	if !claims.Audience.contains(s.absURL("/token")) && !claims.Audience.contains(s.issuerURL.String()) {
+		return fmt.Errorf("assertion audience %q doesn't include the token endpoint", claims.Audience)
+	}
+
+	now := s.now()
+	if claims.Expiry == 0 {
+		return errors.New(`assertion has no "exp" claim`)
+	}
+	expiry := time.Unix(claims.Expiry, 0)
+	if !now.Before(expiry) {
+		return errors.New("assertion has expired")
+	}
+	if expiry.Sub(now) > maxClientAssertionLifetime {
+		return fmt.Errorf("assertion must expire within %s", maxClientAssertionLifetime)
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0)) {
+		return errors.New("assertion isn't valid yet")
+	}
+	if claims.ID == "" {
+		return errors.New(`assertion has no "jti" claim`)
+	}
+
+	err := s.storage.CreateClientAssertion(storage.ClientAssertion{
+		ID:       clientAssertionID(client.ID, claims.ID),
+		ClientID: client.ID,
+		Expiry:   expiry,
+	})
+	if err == storage.ErrAlreadyExists {
+		return errors.New("assertion has already been used")
+	}
+	return err
+}
+
+// verifyClientSignature checks that a JWT was signed by the client, either
+// with its secret ("client_secret_jwt") or one of its public keys
+// ("private_key_jwt").
+func (s *Server) verifyClientSignature(client storage.Client, jws *jose.JSONWebSignature) error {
+	if len(jws.Signatures) != 1 {
+		return errors.New("JWT must have exactly one signature")
+	}
+	header := jws.Signatures[0].Header
+
+	switch jose.SignatureAlgorithm(header.Algorithm) {
+	case jose.HS256, jose.HS384, jose.HS512:
+		// client_secret_jwt
+		if client.Secret == "" {
+			return errors.New("client has no secret to verify HMAC signatures with")
+		}
+		if _, err := jws.Verify([]byte(client.Secret)); err != nil {
+			return fmt.Errorf("invalid signature: %v", err)
+		}
+	default:
+		// private_key_jwt
+		return s.verifyWithClientKeys(client, jws, header.KeyID)
+	}
+	return nil
+}
+
+// clientAssertionID maps a client chosen "jti" to a storage ID.
+func clientAssertionID(clientID, jti string) string {
+	h := sha256.New()
+	h.Write([]byte(clientID))
+	h.Write([]byte{0})
+	h.Write([]byte(jti))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verifyWithClientKeys verifies a signature with one of the client's public
+// keys. Keys fetched from a URL are refreshed once if no key matches, in case
+// the client rotated its keys.
+func (s *Server) verifyWithClientKeys(client storage.Client, jws *jose.JSONWebSignature, keyID string) error {
+	switch {
+	case client.JWKS != nil:
+		if verifyWithKeys(jws, client.JWKS.Keys, keyID) {
+			return nil
+		}
+	case client.JWKSURI != "":
+		for _, refresh := range []bool{false, true} {
+			keys, err := s.clientKeys.get(client.JWKSURI, refresh)
+			if err != nil {
+				return err
+			}
+			if verifyWithKeys(jws, keys, keyID) {
+				return nil
+			}
+		}
+	default:
+		return errors.New("client has no keys to verify signatures with")
+	}
+	return errors.New("invalid signature")
+}
+
+func verifyWithKeys(jws *jose.JSONWebSignature, keys []jose.JSONWebKey, keyID string) bool {
+	for _, key := range keys {
+		if keyID != "" && key.KeyID != keyID {
+			continue
+		}
+		if _, err := jws.Verify(&key); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// jwksCache caches JSON Web Key Sets fetched from clients' "jwksURI".
+type jwksCache struct {
+	client *http.Client
+	now    func() time.Time
+
+	// mu guards the maps, but isn't held while fetching keys, so a slow
+	// client only holds up requests needing its own keys.
+	mu       sync.Mutex
+	sets     map[string]cachedJWKS
+	inflight map[string]*jwksFetch
+}
+
+type cachedJWKS struct {
+	keys    []jose.JSONWebKey
+	fetched time.Time
+}
+
+// jwksFetch is a fetch of a key set in progress. Requests needing the same
+// keys wait for it rather than fetching them again.
+type jwksFetch struct {
+	done chan struct{}
+	keys []jose.JSONWebKey
+	err  error
+}
+
+func newJWKSCache(now func() time.Time) *jwksCache {
+	return &jwksCache{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		now:      now,
+		sets:     make(map[string]cachedJWKS),
+		inflight: make(map[string]*jwksFetch),
+	}
+}
+
+// get returns the keys at the URL. If refresh is true, the keys are refetched
+// unless they were fetched very recently.
+func (c *jwksCache) get(url string, refresh bool) ([]jose.JSONWebKey, error) {
+	c.mu.Lock()
+	if set, ok := c.sets[url]; ok {
+		maxAge := clientKeysValidFor
+		if refresh {
+			maxAge = clientKeysRefreshBackoff
+		}
+		if c.now().Before(set.fetched.Add(maxAge)) {
+			c.mu.Unlock()
+			return set.keys, nil
+		}
+	}
+	if f, ok := c.inflight[url]; ok {
+		c.mu.Unlock()
+		<-f.done
+		return f.keys, f.err
+	}
+	f := &jwksFetch{done: make(chan struct{})}
+	c.inflight[url] = f
+	c.mu.Unlock()
+
+	f.keys, f.err = c.fetch(url)
+
+	c.mu.Lock()
+	if f.err == nil {
+		c.sets[url] = cachedJWKS{keys: f.keys, fetched: c.now()}
+	}
+	delete(c.inflight, url)
+	c.mu.Unlock()
+	close(f.done)
+
+	return f.keys, f.err
+}
+
+// fetch downloads the key set at the URL.
+func (c *jwksCache) fetch(url string) ([]jose.JSONWebKey, error) {
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch client keys: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxClientKeysSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read client keys: %v", err)
+	}
+	if len(body) > maxClientKeysSize {
+		return nil, fmt.Errorf("client keys exceed %d bytes", maxClientKeysSize)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch client keys: %s", resp.Status)
+	}
+
+	var set jose.JSONWebKeySet
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("decode client keys: %v", err)
+	}
+	return set.Keys, nil
+}
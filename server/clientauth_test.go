@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/coreos/dex/storage"
+)
+
+func TestClientAssertionAuthentication(t *testing.T) {
+	pub := jose.JSONWebKey{Key: testKey.Public(), KeyID: "client-key", Algorithm: string(jose.RS256), Use: "sig"}
+	jwks := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{pub}}
+
+	keyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	defer keyServer.Close()
+
+	grantTypes := []string{grantTypeClientCredentials}
+	clients := []storage.Client{
+		{ID: "inline", JWKS: jwks, AllowedGrantTypes: grantTypes},
+		{ID: "remote", JWKSURI: keyServer.URL, AllowedGrantTypes: grantTypes},
+		{ID: "hmac", Secret: "secret", AllowedGrantTypes: grantTypes},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+	})
+	defer httpServer.Close()
+
+	tokenURL := httpServer.URL + "/token"
+
+	sign := func(key jose.SigningKey, claims clientAssertionClaims) string {
+		signer, err := jose.NewSigner(key, nil)
+		if err != nil {
+			t.Fatalf("failed to create signer: %v", err)
+		}
+		payload, err := json.Marshal(claims)
+		if err != nil {
+			t.Fatalf("failed to marshal claims: %v", err)
+		}
+		jws, err := signer.Sign(payload)
+		if err != nil {
+			t.Fatalf("failed to sign assertion: %v", err)
+		}
+		assertion, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatalf("failed to serialize assertion: %v", err)
+		}
+		return assertion
+	}
+	rsaKey := jose.SigningKey{Algorithm: jose.RS256, Key: &jose.JSONWebKey{Key: testKey, KeyID: "client-key"}}
+	hmacKey := jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")}
+	claimsFor := func(clientID, jti string) clientAssertionClaims {
+		return clientAssertionClaims{
+			Issuer:   clientID,
+			Subject:  clientID,
+			Audience: audience{tokenURL},
+			Expiry:   time.Now().Add(time.Minute).Unix(),
+			ID:       jti,
+		}
+	}
+
+	expired := claimsFor("inline", "expired")
+	expired.Expiry = time.Now().Add(-time.Minute).Unix()
+	wrongAudience := claimsFor("inline", "wrong-audience")
+	wrongAudience.Audience = audience{"https://example.com/token"}
+
+	replayed := sign(rsaKey, claimsFor("inline", "replayed"))
+
+	tests := []struct {
+		name      string
+		assertion string
+		// Set to authenticate with client_secret_basic instead.
+		basicAuth []string
+
+		wantCode int
+	}{
+		{
+			name:      "private_key_jwt with inline keys",
+			assertion: sign(rsaKey, claimsFor("inline", "1")),
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "private_key_jwt with keys from url",
+			assertion: sign(rsaKey, claimsFor("remote", "1")),
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "client_secret_jwt",
+			assertion: sign(hmacKey, claimsFor("hmac", "1")),
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "first use of assertion",
+			assertion: replayed,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "replayed assertion",
+			assertion: replayed,
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "expired assertion",
+			assertion: sign(rsaKey, expired),
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "wrong audience",
+			assertion: sign(rsaKey, wrongAudience),
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "client_secret_jwt signed with wrong secret",
+			assertion: sign(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("wrong")}, claimsFor("hmac", "2")),
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "HMAC assertion for client without secret",
+			assertion: sign(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("")}, claimsFor("inline", "3")),
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "client without secret using basic auth",
+			basicAuth: []string{"inline", ""},
+			wantCode:  http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		v := url.Values{}
+		v.Set("grant_type", grantTypeClientCredentials)
+		if tc.assertion != "" {
+			v.Set("client_assertion_type", clientAssertionTypeJWT)
+			v.Set("client_assertion", tc.assertion)
+		}
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tc.basicAuth != nil {
+			req.SetBasicAuth(tc.basicAuth[0], tc.basicAuth[1])
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode {
+			t.Errorf("%s: expected %d got %d: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestJWKSCache(t *testing.T) {
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: testKey.Public(), KeyID: "client-key", Algorithm: string(jose.RS256), Use: "sig"},
+	}}
+
+	var (
+		mu         sync.Mutex
+		slowCalls  int
+		slowCalled = make(chan struct{}, 2)
+		release    = make(chan struct{})
+	)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		slowCalls++
+		mu.Unlock()
+		slowCalled <- struct{}{}
+		<-release
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	defer fast.Close()
+	large := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat(" ", maxClientKeysSize+1)))
+	}))
+	defer large.Close()
+
+	c := newJWKSCache(time.Now)
+
+	errc := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := c.get(slow.URL, false)
+			errc <- err
+		}()
+	}
+	<-slowCalled
+
+	// Other clients' keys can be fetched while the slow fetch is in progress.
+	done := make(chan error)
+	go func() {
+		_, err := c.get(fast.URL, false)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("failed to fetch keys: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("fetching keys was blocked by a slow key server")
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			t.Errorf("failed to fetch keys: %v", err)
+		}
+	}
+	mu.Lock()
+	if slowCalls != 1 {
+		t.Errorf("expected concurrent requests to share one fetch, got %d", slowCalls)
+	}
+	mu.Unlock()
+
+	if _, err := c.get(large.URL, false); err == nil {
+		t.Errorf("expected oversized key set to be rejected")
+	}
+}
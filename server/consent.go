@@ -0,0 +1,75 @@
+package server
+
+import (
+	"github.com/coreos/dex/storage"
+)
+
+// consentCovers reports if the end user has already approved every scope the
+// authorization request asks for, letting the server skip the approval screen.
+func (s *Server) consentCovers(authReq storage.AuthRequest) (bool, error) {
+	consents, err := s.storage.GetConsents(authReq.Claims.UserID, authReq.ConnectorID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	consent, ok := consents.Clients[authReq.ClientID]
+	if !ok || consent == nil {
+		return false, nil
+	}
+	for _, scope := range authReq.Scopes {
+		if !hasScope(consent.Scopes, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// recordConsent remembers that the end user approved the scopes of the
+// authorization request, in addition to any scopes approved earlier.
+func (s *Server) recordConsent(authReq storage.AuthRequest) error {
+	now := s.now()
+	updater := func(c storage.Consents) (storage.Consents, error) {
+		if c.Clients == nil {
+			c.Clients = make(map[string]*storage.Consent)
+		}
+		consent, ok := c.Clients[authReq.ClientID]
+		if !ok || consent == nil {
+			consent = &storage.Consent{CreatedAt: now}
+			c.Clients[authReq.ClientID] = consent
+		}
+		for _, scope := range authReq.Scopes {
+			if !hasScope(consent.Scopes, scope) {
+				consent.Scopes = append(consent.Scopes, scope)
+			}
+		}
+		consent.LastUpdated = now
+		return c, nil
+	}
+
+	err := s.storage.UpdateConsents(authReq.Claims.UserID, authReq.ConnectorID, updater)
+	if err != storage.ErrNotFound {
+		return err
+	}
+	consents, _ := updater(storage.Consents{
+		UserID: authReq.Claims.UserID,
+		ConnID: authReq.ConnectorID,
+	})
+	if err := s.storage.CreateConsents(consents); err != storage.ErrAlreadyExists {
+		return err
+	}
+	// Another request created the consents in the meantime.
+	return s.storage.UpdateConsents(authReq.Claims.UserID, authReq.ConnectorID, updater)
+}
+
+// consentGiven is like consentCovers, but treats storage errors as missing
+// consent so the end user is asked to approve the request instead.
+func (s *Server) consentGiven(authReq storage.AuthRequest) bool {
+	covered, err := s.consentCovers(authReq)
+	if err != nil {
+		s.logger.Errorf("Failed to get consents: %v", err)
+		return false
+	}
+	return covered
+}
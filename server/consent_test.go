@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/dex/storage"
+)
+
+func TestConsentSkipsApproval(t *testing.T) {
+	const redirectURI = "https://example.com/callback"
+	client := storage.Client{ID: "client", Secret: "secret", Name: "Client", RedirectURIs: []string{redirectURI}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{client})
+	})
+	defer httpServer.Close()
+	server.skipApproval = false
+
+	// approval runs the approval step for a logged in end user and reports if
+	// the approval screen was shown.
+	approval := func(scopes []string, forcePrompt bool) bool {
+		authReq := storage.AuthRequest{
+			ID:                  storage.NewID(),
+			ClientID:            client.ID,
+			ResponseTypes:       []string{responseTypeCode},
+			Scopes:              scopes,
+			RedirectURI:         redirectURI,
+			State:               "state",
+			ForceApprovalPrompt: forcePrompt,
+			LoggedIn:            true,
+			Claims:              storage.Claims{UserID: "user", Username: "jane"},
+			ConnectorID:         "mock",
+			Expiry:              server.now().Add(time.Hour),
+		}
+		if err := server.storage.CreateAuthRequest(authReq); err != nil {
+			t.Fatalf("create auth request: %v", err)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", "/approval?req="+authReq.ID, nil))
+		if rr.Code == http.StatusOK {
+			return true
+		}
+		if !strings.HasPrefix(rr.Header().Get("Location"), redirectURI) {
+			t.Fatalf("expected redirect to the client, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return false
+	}
+
+	// approve has the end user approve a request for the scopes.
+	approve := func(scopes []string) {
+		authReq := storage.AuthRequest{
+			ID:            storage.NewID(),
+			ClientID:      client.ID,
+			ResponseTypes: []string{responseTypeCode},
+			Scopes:        scopes,
+			RedirectURI:   redirectURI,
+			State:         "state",
+			LoggedIn:      true,
+			Claims:        storage.Claims{UserID: "user", Username: "jane"},
+			ConnectorID:   "mock",
+			Expiry:        server.now().Add(time.Hour),
+		}
+		if err := server.storage.CreateAuthRequest(authReq); err != nil {
+			t.Fatalf("create auth request: %v", err)
+		}
+		v := url.Values{"req": {authReq.ID}, "approval": {"approve"}}
+		req := httptest.NewRequest("POST", "/approval", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if !strings.HasPrefix(rr.Header().Get("Location"), redirectURI) {
+			t.Fatalf("expected approval to redirect to the client, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	if !approval([]string{"openid", "email"}, false) {
+		t.Fatalf("expected approval screen before the end user consented")
+	}
+	approve([]string{"openid", "email"})
+
+	if approval([]string{"openid", "email"}, false) {
+		t.Errorf("expected approval screen to be skipped for approved scopes")
+	}
+	if approval([]string{"openid"}, false) {
+		t.Errorf("expected approval screen to be skipped for a subset of the approved scopes")
+	}
+	if !approval([]string{"openid", "email", "groups"}, false) {
+		t.Errorf("expected approval screen for new scopes")
+	}
+	if !approval([]string{"openid"}, true) {
+		t.Errorf("expected approval screen when the client asks for consent")
+	}
+
+	// Approving new scopes adds them to the earlier consent.
+	approve([]string{"openid", "groups"})
+	consents, err := server.storage.GetConsents("user", "mock")
+	if err != nil {
+		t.Fatalf("get consents: %v", err)
+	}
+	if got := consents.Clients[client.ID].Scopes; len(got) != 3 {
+		t.Errorf("expected consent to cover three scopes, got %q", got)
+	}
+	if approval([]string{"openid", "email", "groups"}, false) {
+		t.Errorf("expected approval screen to be skipped after approving new scopes")
+	}
+}
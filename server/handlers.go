@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -50,27 +51,42 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Health check passed in %s", t)
 }
 
+// publishedSigningAlgs returns the algorithms of every published key, starting
+// with the current signing key's.
+func (s *Server) publishedSigningAlgs() ([]string, error) {
+	signingAlg, err := s.signer.Algorithm()
+	if err != nil {
+		return nil, err
+	}
+	keys, _, err := s.signer.ValidationKeys()
+	if err != nil {
+		return nil, err
+	}
+	algs := []string{string(signingAlg)}
+	seen := map[string]bool{string(signingAlg): true}
+	for _, key := range keys {
+		if key != nil && key.Algorithm != "" && !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algs = append(algs, key.Algorithm)
+		}
+	}
+	return algs, nil
+}
+
 func (s *Server) handlePublicKeys(w http.ResponseWriter, r *http.Request) {
 	// TODO(ericchiang): Cache this.
-	keys, err := s.storage.GetKeys()
+	keys, nextRotation, err := s.signer.ValidationKeys()
 	if err != nil {
 		s.logger.Errorf("failed to get keys: %v", err)
 		s.renderError(w, http.StatusInternalServerError, "Internal server error.")
 		return
 	}
 
-	if keys.SigningKeyPub == nil {
-		s.logger.Errorf("No public keys found.")
-		s.renderError(w, http.StatusInternalServerError, "Internal server error.")
-		return
-	}
-
 	jwks := jose.JSONWebKeySet{
-		Keys: make([]jose.JSONWebKey, len(keys.VerificationKeys)+1),
+		Keys: make([]jose.JSONWebKey, len(keys)),
 	}
-	jwks.Keys[0] = *keys.SigningKeyPub
-	for i, verificationKey := range keys.VerificationKeys {
-		jwks.Keys[i+1] = *verificationKey.PublicKey
+	for i, key := range keys {
+		jwks.Keys[i] = *key
 	}
 
 	data, err := json.MarshalIndent(jwks, "", "  ")
@@ -79,9 +95,9 @@ func (s *Server) handlePublicKeys(w http.ResponseWriter, r *http.Request) {
 		s.renderError(w, http.StatusInternalServerError, "Internal server error.")
 		return
 	}
-	maxAge := keys.NextRotation.Sub(s.now())
-	if maxAge < (time.Minute * 2) {
-		maxAge = time.Minute * 2
+	maxAge := nextRotation.Sub(s.now())
+	if maxAge < minKeysCacheAge {
+		maxAge = minKeysCacheAge
 	}
 
 	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d, must-revalidate", int(maxAge.Seconds())))
@@ -94,29 +110,79 @@ type discovery struct {
 	Issuer        string   `json:"issuer"`
 	Auth          string   `json:"authorization_endpoint"`
 	Token         string   `json:"token_endpoint"`
+	Revocation    string   `json:"revocation_endpoint"`
+	Introspection string   `json:"introspection_endpoint"`
+	UserInfo      string   `json:"userinfo_endpoint"`
+	Device        string   `json:"device_authorization_endpoint"`
+	EndSession    string   `json:"end_session_endpoint"`
+	Registration  string   `json:"registration_endpoint,omitempty"`
+	GrantTypes    []string `json:"grant_types_supported"`
 	Keys          string   `json:"jwks_uri"`
 	ResponseTypes []string `json:"response_types_supported"`
+	ResponseModes []string `json:"response_modes_supported"`
 	Subjects      []string `json:"subject_types_supported"`
 	IDTokenAlgs   []string `json:"id_token_signing_alg_values_supported"`
 	Scopes        []string `json:"scopes_supported"`
 	AuthMethods   []string `json:"token_endpoint_auth_methods_supported"`
 	Claims        []string `json:"claims_supported"`
+
+	CodeChallengeMethods []string `json:"code_challenge_methods_supported"`
+	AuthSigningAlgs      []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	BackchannelLogout    bool     `json:"backchannel_logout_supported"`
+
+	PushedAuthRequests  string   `json:"pushed_authorization_request_endpoint"`
+	RequestParameter    bool     `json:"request_parameter_supported"`
+	RequestURIParameter bool     `json:"request_uri_parameter_supported"`
+	RequestObjectAlgs   []string `json:"request_object_signing_alg_values_supported"`
 }
 
 func (s *Server) discoveryHandler() (http.HandlerFunc, error) {
 	d := discovery{
-		Issuer:      s.issuerURL.String(),
-		Auth:        s.absURL("/auth"),
-		Token:       s.absURL("/token"),
+		Issuer:        s.issuerURL.String(),
+		Auth:          s.absURL("/auth"),
+		Token:         s.absURL("/token"),
+		Revocation:    s.absURL("/token/revoke"),
+		Introspection: s.absURL("/token/introspect"),
+		UserInfo:      s.absURL("/userinfo"),
+		Device:        s.absURL("/device/code"),
+		EndSession:    s.absURL("/logout"),
+		GrantTypes: []string{
+			grantTypeAuthorizationCode, grantTypeRefreshToken, grantTypeClientCredentials,
+			grantTypeDeviceCode, grantTypeTokenExchange,
+		},
 		Keys:        s.absURL("/keys"),
-		Subjects:    []string{"public"},
-		IDTokenAlgs: []string{string(jose.RS256)},
+		Subjects:    []string{subjectTypePublic},
+		IDTokenAlgs: []string{string(s.signingAlg)},
 		Scopes:      []string{"openid", "email", "groups", "profile", "offline_access"},
-		AuthMethods: []string{"client_secret_basic"},
+		AuthMethods: []string{
+			authMethodClientSecretBasic, authMethodClientSecretPost,
+			authMethodClientSecretJWT, authMethodPrivateKeyJWT,
+		},
+		AuthSigningAlgs: clientAssertionAlgs,
 		Claims: []string{
-			"aud", "email", "email_verified", "exp",
+			"aud", "auth_time", "email", "email_verified", "exp",
 			"iat", "iss", "locale", "name", "sub",
 		},
+		ResponseModes:        []string{responseModeQuery, responseModeFragment, responseModeFormPost},
+		CodeChallengeMethods: []string{codeChallengeMethodS256, codeChallengeMethodPlain},
+		BackchannelLogout:    true,
+
+		PushedAuthRequests: s.absURL("/par"),
+		RequestParameter:   true,
+		// Only request URIs returned by the pushed authorization request
+		// endpoint are supported, not ones hosted by the client.
+		RequestURIParameter: false,
+		RequestObjectAlgs:   clientAssertionAlgs,
+	}
+
+	if s.passwordConnector != "" {
+		d.GrantTypes = append(d.GrantTypes, grantTypePassword)
+	}
+	if len(s.registration.InitialAccessTokens) > 0 {
+		d.Registration = s.absURL("/register")
+	}
+	if s.pairwiseSubjectSalt != "" {
+		d.Subjects = append(d.Subjects, subjectTypePairwise)
 	}
 
 	for responseType := range s.supportedResponseTypes {
@@ -124,12 +190,25 @@ func (s *Server) discoveryHandler() (http.HandlerFunc, error) {
 	}
 	sort.Strings(d.ResponseTypes)
 
-	data, err := json.MarshalIndent(d, "", "  ")
-	if err != nil {
+	if _, err := json.Marshal(d); err != nil {
 		return nil, fmt.Errorf("failed to marshal discovery data: %v", err)
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// While migrating between algorithms, keys for both are published and
+		// tokens may be signed with either.
+		d := d
+		if algs, err := s.publishedSigningAlgs(); err != nil {
+			s.logger.Errorf("failed to get keys: %v", err)
+		} else if len(algs) > 0 {
+			d.IDTokenAlgs = algs
+		}
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			s.logger.Errorf("failed to marshal discovery data: %v", err)
+			s.renderError(w, http.StatusInternalServerError, "Internal server error.")
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 		w.Write(data)
@@ -138,10 +217,10 @@ func (s *Server) discoveryHandler() (http.HandlerFunc, error) {
 
 // handleAuthorization handles the OAuth2 auth endpoint.
 func (s *Server) handleAuthorization(w http.ResponseWriter, r *http.Request) {
-	authReq, err := s.parseAuthorizationRequest(r)
+	authReq, login, err := s.parseAuthorizationRequest(r)
 	if err != nil {
 		s.logger.Errorf("Failed to parse authorization request: %v", err)
-		if handler, ok := err.Handle(); ok {
+		if handler, ok := err.Handle(s); ok {
 			// client_id and redirect_uri checked out and we can redirect back to
 			// the client with the error.
 			handler.ServeHTTP(w, r)
@@ -161,24 +240,83 @@ func (s *Server) handleAuthorization(w http.ResponseWriter, r *http.Request) {
 	//
 	// See: https://github.com/coreos/dex/issues/646
 	authReq.Expiry = s.now().Add(24 * time.Hour) // Totally arbitrary value.
+
+	// If the end user already logged in with this browser, skip straight to
+	// the approval step.
+	session, loggedIn := s.currentSession(r)
+	if loggedIn && !login.allowsSession(session, s.now()) {
+		loggedIn = false
+	}
+	if loggedIn {
+		authReq.LoggedIn = true
+		authReq.Claims = session.Claims
+		authReq.ConnectorID = session.ConnectorID
+		authReq.ConnectorData = session.ConnectorData
+		authReq.AuthTime = session.CreatedAt
+		s.addSessionClient(session.ID, authReq.ClientID)
+	}
+
+	if login.noPrompt {
+		var err *authErr
+		switch {
+		case !loggedIn:
+			err = &authErr{authReq.State, authReq.RedirectURI, errLoginRequired, "End user must log in.", authReq.ResponseMode}
+		case !s.skipApproval && !s.consentGiven(authReq):
+			err = &authErr{authReq.State, authReq.RedirectURI, errConsentRequired, "End user must approve the request.", authReq.ResponseMode}
+		}
+		if err != nil {
+			handler, _ := err.Handle(s)
+			handler.ServeHTTP(w, r)
+			return
+		}
+	}
+
 	if err := s.storage.CreateAuthRequest(authReq); err != nil {
 		s.logger.Errorf("Failed to create authorization request: %v", err)
 		s.renderError(w, http.StatusInternalServerError, "Failed to connect to the database.")
 		return
 	}
 
-	connectors, e := s.storage.ListConnectors()
-	if e != nil {
+	if loggedIn {
+		http.Redirect(w, r, s.absPath("/approval")+"?req="+authReq.ID, http.StatusFound)
+		return
+	}
+	s.renderLogin(w, r, authReq)
+}
+
+// renderLogin sends the end user to the login page of the only connector the
+// client allows, or lets them pick a connector if there's more than one.
+func (s *Server) renderLogin(w http.ResponseWriter, r *http.Request, authReq storage.AuthRequest) {
+	client, err := s.storage.GetClient(authReq.ClientID)
+	if err != nil {
+		s.logger.Errorf("Failed to get client %q: %v", authReq.ClientID, err)
+		s.renderError(w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	allConnectors, err := s.storage.ListConnectors()
+	if err != nil {
 		s.logger.Errorf("Failed to get list of connectors: %v", err)
 		s.renderError(w, http.StatusInternalServerError, "Failed to retrieve connector list.")
 		return
 	}
+	var connectors []storage.Connector
+	for _, conn := range allConnectors {
+		if clientAllowsConnector(client, conn.ID) {
+			connectors = append(connectors, conn)
+		}
+	}
+	if len(connectors) == 0 {
+		s.logger.Errorf("Client %q isn't allowed to use any of the configured connectors", client.ID)
+		s.renderError(w, http.StatusInternalServerError, "No connectors available for this client.")
+		return
+	}
+	authReqID := authReq.ID
 
 	if len(connectors) == 1 {
 		for _, c := range connectors {
 			// TODO(ericchiang): Make this pass on r.URL.RawQuery and let something latter
 			// on create the auth request.
-			http.Redirect(w, r, s.absPath("/auth", c.ID)+"?req="+authReq.ID, http.StatusFound)
+			http.Redirect(w, r, s.absPath("/auth", c.ID)+"?req="+authReqID, http.StatusFound)
 			return
 		}
 	}
@@ -191,7 +329,7 @@ func (s *Server) handleAuthorization(w http.ResponseWriter, r *http.Request) {
 			Name: conn.Name,
 			// TODO(ericchiang): Make this pass on r.URL.RawQuery and let something latter
 			// on create the auth request.
-			URL: s.absPath("/auth", conn.ID) + "?req=" + authReq.ID,
+			URL: s.absPath("/auth", conn.ID) + "?req=" + authReqID,
 		}
 		i++
 	}
@@ -222,6 +360,17 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	client, err := s.storage.GetClient(authReq.ClientID)
+	if err != nil {
+		s.logger.Errorf("Failed to get client %q: %v", authReq.ClientID, err)
+		s.renderError(w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	if !clientAllowsConnector(client, connID) {
+		s.logger.Errorf("Client %q is not allowed to use connector %q", client.ID, connID)
+		s.renderError(w, http.StatusForbidden, "Connector not allowed for this client.")
+		return
+	}
 	scopes := parseScopes(authReq.Scopes)
 
 	switch r.Method {
@@ -242,7 +391,12 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 			// Use the auth request ID as the "state" token.
 			//
 			// TODO(ericchiang): Is this appropriate or should we also be using a nonce?
-			callbackURL, err := conn.LoginURL(scopes, s.absURL("/callback"), authReqID)
+			var callbackURL string
+			if hintConn, ok := conn.(connector.LoginHintConnector); ok && authReq.LoginHint != "" {
+				callbackURL, err = hintConn.LoginURLWithHint(scopes, s.absURL("/callback"), authReqID, authReq.LoginHint)
+			} else {
+				callbackURL, err = conn.LoginURL(scopes, s.absURL("/callback"), authReqID)
+			}
 			if err != nil {
 				s.logger.Errorf("Connector %q returned error when creating callback: %v", connID, err)
 				s.renderError(w, http.StatusInternalServerError, "Login error.")
@@ -250,7 +404,7 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 			}
 			http.Redirect(w, r, callbackURL, http.StatusFound)
 		case connector.PasswordConnector:
-			if err := s.templates.password(w, r.URL.String(), "", false); err != nil {
+			if err := s.templates.password(w, r.URL.String(), authReq.LoginHint, false); err != nil {
 				s.logger.Errorf("Server template error: %v", err)
 			}
 		case connector.SAMLConnector:
@@ -303,7 +457,7 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
-		redirectURL, err := s.finalizeLogin(identity, authReq, conn.Connector)
+		redirectURL, err := s.finalizeLogin(w, r, identity, authReq, conn.Connector)
 		if err != nil {
 			s.logger.Errorf("Failed to finalize login: %v", err)
 			s.renderError(w, http.StatusInternalServerError, "Login error.")
@@ -380,7 +534,7 @@ func (s *Server) handleConnectorCallback(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	redirectURL, err := s.finalizeLogin(identity, authReq, conn.Connector)
+	redirectURL, err := s.finalizeLogin(w, r, identity, authReq, conn.Connector)
 	if err != nil {
 		s.logger.Errorf("Failed to finalize login: %v", err)
 		s.renderError(w, http.StatusInternalServerError, "Login error.")
@@ -390,7 +544,7 @@ func (s *Server) handleConnectorCallback(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
 }
 
-func (s *Server) finalizeLogin(identity connector.Identity, authReq storage.AuthRequest, conn connector.Connector) (string, error) {
+func (s *Server) finalizeLogin(w http.ResponseWriter, r *http.Request, identity connector.Identity, authReq storage.AuthRequest, conn connector.Connector) (string, error) {
 	claims := storage.Claims{
 		UserID:        identity.UserID,
 		Username:      identity.Username,
@@ -403,11 +557,15 @@ func (s *Server) finalizeLogin(identity connector.Identity, authReq storage.Auth
 		a.LoggedIn = true
 		a.Claims = claims
 		a.ConnectorData = identity.ConnectorData
+		a.AuthTime = s.now()
 		return a, nil
 	}
 	if err := s.storage.UpdateAuthRequest(authReq.ID, updater); err != nil {
 		return "", fmt.Errorf("failed to update auth request: %v", err)
 	}
+	if err := s.createSession(w, r, identity, authReq.ConnectorID, claims, authReq.ClientID); err != nil {
+		return "", fmt.Errorf("failed to create session: %v", err)
+	}
 	return path.Join(s.issuerURL.Path, "/approval") + "?req=" + authReq.ID, nil
 }
 
@@ -426,7 +584,7 @@ func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "GET":
-		if s.skipApproval {
+		if !authReq.ForceApprovalPrompt && (s.skipApproval || s.consentGiven(authReq)) {
 			s.sendCodeResponse(w, r, authReq)
 			return
 		}
@@ -441,9 +599,22 @@ func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
 		}
 	case "POST":
 		if r.FormValue("approval") != "approve" {
+			if authReq.RedirectURI == s.absURL(deviceCallbackPath) {
+				// Let the device know it can stop polling.
+				updater := func(d storage.DeviceRequest) (storage.DeviceRequest, error) {
+					d.Denied = true
+					return d, nil
+				}
+				if err := s.storage.UpdateDeviceRequest(authReq.State, updater); err != nil {
+					s.logger.Errorf("Failed to deny device request: %v", err)
+				}
+			}
 			s.renderError(w, http.StatusInternalServerError, "Approval rejected.")
 			return
 		}
+		if err := s.recordConsent(authReq); err != nil {
+			s.logger.Errorf("Failed to record consent: %v", err)
+		}
 		s.sendCodeResponse(w, r, authReq)
 	}
 }
@@ -463,12 +634,6 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 		}
 		return
 	}
-	u, err := url.Parse(authReq.RedirectURI)
-	if err != nil {
-		s.renderError(w, http.StatusInternalServerError, "Invalid redirect URI.")
-		return
-	}
-
 	var (
 		// Was the initial request using the implicit or hybrid flow instead of
 		// the "normal" code flow?
@@ -482,9 +647,32 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 		idToken       string
 		idTokenExpiry time.Time
 
-		accessToken = storage.NewID()
+		// Access token returned by the implicit and hybrid flows.
+		accessToken string
 	)
 
+	client, err := s.storage.GetClient(authReq.ClientID)
+	if err != nil {
+		s.logger.Errorf("Failed to get client %q: %v", authReq.ClientID, err)
+		s.renderError(w, http.StatusInternalServerError, "Internal server error.")
+		return
+	}
+
+	for _, responseType := range authReq.ResponseTypes {
+		if responseType == responseTypeToken || responseType == responseTypeIDToken {
+			implicitOrHybrid = true
+		}
+	}
+	if implicitOrHybrid {
+		// The ID Token's at_hash covers the access token, so issue it first.
+		accessToken, _, err = s.newAccessToken(client, authReq.Claims, authReq.Scopes, authReq.Resources, authReq.ConnectorID)
+		if err != nil {
+			s.logger.Errorf("Failed to create access token: %v", err)
+			s.renderError(w, http.StatusInternalServerError, "Internal server error.")
+			return
+		}
+	}
+
 	for _, responseType := range authReq.ResponseTypes {
 		switch responseType {
 		case responseTypeCode:
@@ -495,9 +683,12 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 				Nonce:         authReq.Nonce,
 				Scopes:        authReq.Scopes,
 				Claims:        authReq.Claims,
-				Expiry:        s.now().Add(time.Minute * 30),
+				Expiry:        s.now().Add(s.authCodeLifetime(client)),
 				RedirectURI:   authReq.RedirectURI,
 				ConnectorData: authReq.ConnectorData,
+				PKCE:          authReq.PKCE,
+				AuthTime:      authReq.AuthTime,
+				Resources:     authReq.Resources,
 			}
 			if err := s.storage.CreateAuthCode(code); err != nil {
 				s.logger.Errorf("Failed to create auth code: %v", err)
@@ -513,12 +704,9 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 				}
 				return
 			}
-		case responseTypeToken:
-			implicitOrHybrid = true
 		case responseTypeIDToken:
-			implicitOrHybrid = true
 			var err error
-			idToken, idTokenExpiry, err = s.newIDToken(authReq.ClientID, authReq.Claims, authReq.Scopes, authReq.Nonce, accessToken, authReq.ConnectorID)
+			idToken, idTokenExpiry, err = s.newIDToken(client, authReq.Claims, authReq.Scopes, authReq.Nonce, accessToken, authReq.ConnectorID, authReq.AuthTime)
 			if err != nil {
 				s.logger.Errorf("failed to create ID token: %v", err)
 				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
@@ -527,8 +715,8 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 		}
 	}
 
+	v := url.Values{}
 	if implicitOrHybrid {
-		v := url.Values{}
 		v.Set("access_token", accessToken)
 		v.Set("token_type", "bearer")
 		v.Set("state", authReq.State)
@@ -546,52 +734,132 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 		if code.ID != "" {
 			v.Set("code", code.ID)
 		}
-
-		// Implicit and hybrid flows return their values as part of the fragment.
-		//
-		//   HTTP/1.1 303 See Other
-		//   Location: https://client.example.org/cb#
-		//     access_token=SlAV32hkKG
-		//     &token_type=bearer
-		//     &id_token=eyJ0 ... NiJ9.eyJ1c ... I6IjIifX0.DeWt4Qu ... ZXso
-		//     &expires_in=3600
-		//     &state=af0ifjsldkj
-		//
-		u.Fragment = v.Encode()
 	} else {
-		// The code flow add values to the URL query.
-		//
-		//   HTTP/1.1 303 See Other
-		//   Location: https://client.example.org/cb?
-		//     code=SplxlOBeZQQYbYS6WxSbIA
-		//     &state=af0ifjsldkj
-		//
-		q := u.Query()
-		q.Set("code", code.ID)
-		q.Set("state", authReq.State)
-		u.RawQuery = q.Encode()
+		v.Set("code", code.ID)
+		v.Set("state", authReq.State)
 	}
 
-	http.Redirect(w, r, u.String(), http.StatusSeeOther)
+	// By default, implicit and hybrid flows return their values as part of the
+	// fragment.
+	//
+	//   HTTP/1.1 303 See Other
+	//   Location: https://client.example.org/cb#
+	//     access_token=SlAV32hkKG
+	//     &token_type=bearer
+	//     &id_token=eyJ0 ... NiJ9.eyJ1c ... I6IjIifX0.DeWt4Qu ... ZXso
+	//     &expires_in=3600
+	//     &state=af0ifjsldkj
+	//
+	// While the code flow adds values to the URL query.
+	//
+	//   HTTP/1.1 303 See Other
+	//   Location: https://client.example.org/cb?
+	//     code=SplxlOBeZQQYbYS6WxSbIA
+	//     &state=af0ifjsldkj
+	//
+	responseMode := authReq.ResponseMode
+	if responseMode == "" {
+		// Authorization requests created before response modes were supported.
+		responseMode = responseModeQuery
+		if implicitOrHybrid {
+			responseMode = responseModeFragment
+		}
+	}
+	s.sendAuthResponse(w, r, authReq.RedirectURI, responseMode, v)
 }
 
-func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
-	clientID, clientSecret, ok := r.BasicAuth()
-	if ok {
-		var err error
-		if clientID, err = url.QueryUnescape(clientID); err != nil {
-			s.tokenErrHelper(w, errInvalidRequest, "client_id improperly encoded", http.StatusBadRequest)
-			return
-		}
-		if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
-			s.tokenErrHelper(w, errInvalidRequest, "client_secret improperly encoded", http.StatusBadRequest)
-			return
-		}
-	} else {
-		clientID = r.PostFormValue("client_id")
-		clientSecret = r.PostFormValue("client_secret")
+// clientCredentials reads the client ID and secret from either the basic auth
+// header or the form body of a token endpoint request. If the credentials
+// can't be decoded it writes an error response and returns false.
+func (s *Server) clientCredentials(w http.ResponseWriter, r *http.Request) (clientID, clientSecret string, ok bool) {
+	clientID, clientSecret, ok = r.BasicAuth()
+	if !ok {
+		return r.PostFormValue("client_id"), r.PostFormValue("client_secret"), true
+	}
+
+	var err error
+	if clientID, err = url.QueryUnescape(clientID); err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "client_id improperly encoded", http.StatusBadRequest)
+		return "", "", false
+	}
+	if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "client_secret improperly encoded", http.StatusBadRequest)
+		return "", "", false
+	}
+	return clientID, clientSecret, true
+}
+
+// authenticateClient looks up the client making a request to one of the token
+// endpoints and checks its secret or signed client assertion. If allowPublic
+// is true, public clients may omit their credentials. If the client can't be
+// authenticated it writes an error response and returns false.
+func (s *Server) authenticateClient(w http.ResponseWriter, r *http.Request, allowPublic bool) (storage.Client, bool) {
+	if r.PostFormValue("client_assertion") != "" || r.PostFormValue("client_assertion_type") != "" {
+		return s.authenticateClientAssertion(w, r)
+	}
+
+	clientID, clientSecret, ok := s.clientCredentials(w, r)
+	if !ok {
+		return storage.Client{}, false
+	}
+
+	client, ok := s.getTokenClient(w, clientID)
+	if !ok {
+		return storage.Client{}, false
+	}
+
+	switch {
+	case client.Public && clientSecret == "" && allowPublic:
+	case client.Secret == "":
+		// Confidential clients without a secret must use a client assertion.
+		// Public clients without one can't authenticate at all, since anyone
+		// may claim to be them.
+		s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
+		return storage.Client{}, false
+	case subtle.ConstantTimeCompare([]byte(client.Secret), []byte(clientSecret)) != 1:
+		s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
+		return storage.Client{}, false
+	}
+	return client, true
+}
+
+// authenticateClientAssertion authenticates a client using a signed JWT,
+// implementing the "private_key_jwt" and "client_secret_jwt" methods.
+//
+// https://tools.ietf.org/html/rfc7523#section-2.2
+func (s *Server) authenticateClientAssertion(w http.ResponseWriter, r *http.Request) (storage.Client, bool) {
+	if r.PostFormValue("client_assertion_type") != clientAssertionTypeJWT {
+		s.tokenErrHelper(w, errInvalidRequest, fmt.Sprintf("client_assertion_type must be %q.", clientAssertionTypeJWT), http.StatusBadRequest)
+		return storage.Client{}, false
+	}
+
+	jws, claims, err := parseClientAssertion(r.PostFormValue("client_assertion"))
+	if err != nil {
+		s.logger.Infof("invalid client assertion: %v", err)
+		s.tokenErrHelper(w, errInvalidClient, "Invalid client assertion.", http.StatusUnauthorized)
+		return storage.Client{}, false
 	}
+	if clientID := r.PostFormValue("client_id"); clientID != "" && clientID != claims.Subject {
+		s.tokenErrHelper(w, errInvalidClient, "client_id doesn't match client assertion.", http.StatusUnauthorized)
+		return storage.Client{}, false
+	}
+
+	client, ok := s.getTokenClient(w, claims.Subject)
+	if !ok {
+		return storage.Client{}, false
+	}
+	if err := s.verifyClientAssertion(client, jws, claims); err != nil {
+		s.logger.Infof("failed to verify client assertion for client %q: %v", client.ID, err)
+		s.tokenErrHelper(w, errInvalidClient, "Invalid client assertion.", http.StatusUnauthorized)
+		return storage.Client{}, false
+	}
+	return client, true
+}
 
+// getTokenClient looks up a client authenticating at one of the token
+// endpoints. If the client can't be found it writes an error response and
+// returns false.
+func (s *Server) getTokenClient(w http.ResponseWriter, clientID string) (storage.Client, bool) {
 	client, err := s.storage.GetClient(clientID)
 	if err != nil {
 		if err != storage.ErrNotFound {
@@ -600,110 +868,1167 @@ func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
 		} else {
 			s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
 		}
-		return
+		return storage.Client{}, false
 	}
-	if client.Secret != clientSecret {
-		s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
+	return client, true
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	grantType := r.PostFormValue("grant_type")
+
+	// Public clients can't keep a secret. Let them redeem an authorization code
+	// without one so long as they prove possession of the PKCE code_verifier.
+	// handleAuthCode rejects the verifier if the code wasn't issued with a challenge.
+	pkceOnly := grantType == grantTypeAuthorizationCode && r.PostFormValue("code_verifier") != ""
+
+	// Devices polling for a token are often public clients as well.
+	publicDevice := grantType == grantTypeDeviceCode
+
+	client, ok := s.authenticateClient(w, r, pkceOnly || publicDevice)
+	if !ok {
 		return
 	}
 
-	grantType := r.PostFormValue("grant_type")
+	switch grantType {
+	case grantTypeAuthorizationCode, grantTypeRefreshToken, grantTypeClientCredentials, grantTypePassword, grantTypeDeviceCode, grantTypeTokenExchange:
+		if !clientAllowsGrantType(client, grantType) {
+			s.tokenErrHelper(w, errUnauthorizedClient, fmt.Sprintf("Client is not allowed to use the %q grant type.", grantType), http.StatusBadRequest)
+			return
+		}
+	}
+
 	switch grantType {
 	case grantTypeAuthorizationCode:
 		s.handleAuthCode(w, r, client)
 	case grantTypeRefreshToken:
 		s.handleRefreshToken(w, r, client)
+	case grantTypeClientCredentials:
+		s.handleClientCredentials(w, r, client)
+	case grantTypePassword:
+		s.handlePasswordGrant(w, r, client)
+	case grantTypeDeviceCode:
+		s.handleDeviceToken(w, r, client)
+	case grantTypeTokenExchange:
+		s.handleTokenExchange(w, r, client)
 	default:
 		s.tokenErrHelper(w, errInvalidGrant, "", http.StatusBadRequest)
 	}
 }
 
-// handle an access token request https://tools.ietf.org/html/rfc6749#section-4.1.3
-func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client storage.Client) {
-	code := r.PostFormValue("code")
-	redirectURI := r.PostFormValue("redirect_uri")
+// deviceAuthorization is the response of the device authorization endpoint.
+//
+// https://tools.ietf.org/html/rfc8628#section-3.2
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
 
-	authCode, err := s.storage.GetAuthCode(code)
-	if err != nil || s.now().After(authCode.Expiry) || authCode.ClientID != client.ID {
-		if err != storage.ErrNotFound {
-			s.logger.Errorf("failed to get auth code: %v", err)
-			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
-		} else {
-			s.tokenErrHelper(w, errInvalidRequest, "Invalid or expired code parameter.", http.StatusBadRequest)
-		}
+// handleDeviceCode implements the device authorization endpoint. It starts a
+// device authorization grant, returning a code for the end user to enter on
+// the verification page and a code for the client to poll the token endpoint.
+//
+// https://tools.ietf.org/html/rfc8628
+func (s *Server) handleDeviceCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.tokenErrHelper(w, errInvalidRequest, "Device authorization requests must use POST.", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if authCode.RedirectURI != redirectURI {
-		s.tokenErrHelper(w, errInvalidRequest, "redirect_uri did not match URI from initial request.", http.StatusBadRequest)
+	// Devices are often public clients which can't keep a secret.
+	client, ok := s.authenticateClient(w, r, true)
+	if !ok {
+		return
+	}
+	if !clientAllowsGrantType(client, grantTypeDeviceCode) {
+		s.tokenErrHelper(w, errUnauthorizedClient, fmt.Sprintf("Client is not allowed to use the %q grant type.", grantTypeDeviceCode), http.StatusBadRequest)
 		return
 	}
 
-	accessToken := storage.NewID()
-	idToken, expiry, err := s.newIDToken(client.ID, authCode.Claims, authCode.Scopes, authCode.Nonce, accessToken, authCode.ConnectorID)
+	scopes := strings.Fields(r.PostFormValue("scope"))
+	unrecognized, invalidScopes, err := s.validateScopes(client, scopes)
 	if err != nil {
-		s.logger.Errorf("failed to create ID token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if len(unrecognized) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Unrecognized scope(s) %q", unrecognized), http.StatusBadRequest)
+		return
+	}
+	if len(invalidScopes) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Client can't request scope(s) %q", invalidScopes), http.StatusBadRequest)
+		return
+	}
+	if !hasScope(scopes, scopeOpenID) {
+		s.tokenErrHelper(w, errInvalidScope, `Missing required scope(s) ["openid"].`, http.StatusBadRequest)
+		return
+	}
+
+	req := storage.DeviceRequest{
+		DeviceCode: storage.NewID(),
+		ClientID:   client.ID,
+		Scopes:     scopes,
+		Interval:   deviceCodePollInterval,
+		Expiry:     s.now().Add(deviceCodeValidFor),
+	}
+
+	// User codes are short enough that they may collide. Retry a few times
+	// before giving up.
+	for i := 0; ; i++ {
+		req.UserCode = newUserCode()
+		err = s.storage.CreateDeviceRequest(req)
+		if err != storage.ErrAlreadyExists || i >= 2 {
+			break
+		}
+	}
+	if err != nil {
+		s.logger.Errorf("failed to create device request: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		return
 	}
 
-	if err := s.storage.DeleteAuthCode(code); err != nil {
-		s.logger.Errorf("failed to delete auth code: %v", err)
+	userCode := formatUserCode(req.UserCode)
+	resp := deviceAuthorization{
+		DeviceCode:              newDeviceCode(req.UserCode, req.DeviceCode),
+		UserCode:                userCode,
+		VerificationURI:         s.absURL("/device"),
+		VerificationURIComplete: s.absURL("/device") + "?" + url.Values{"user_code": {userCode}}.Encode(),
+		ExpiresIn:               int(deviceCodeValidFor.Seconds()),
+		Interval:                req.Interval,
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal device authorization response: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
 
-	reqRefresh := func() bool {
-		// Ensure the connector supports refresh tokens.
-		//
-		// Connectors like `saml` do not implement RefreshConnector.
-		conn, err := s.getConnector(authCode.ConnectorID)
-		if err != nil {
-			s.logger.Errorf("connector with ID %q not found: %v", authCode.ConnectorID, err)
-			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
-			return false
+// pushedAuthRequestResponse is returned by the pushed authorization request
+// endpoint.
+//
+// https://tools.ietf.org/html/rfc9126#section-2.2
+type pushedAuthRequestResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int    `json:"expires_in"`
+}
+
+// handlePushedAuthRequest lets clients push the parameters of an authorization
+// request before redirecting the end user. The redirect then only carries the
+// returned "request_uri", keeping the parameters out of the browser.
+//
+// https://tools.ietf.org/html/rfc9126
+func (s *Server) handlePushedAuthRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.tokenErrHelper(w, errInvalidRequest, "Pushed authorization requests must use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	client, ok := s.authenticateClient(w, r, true)
+	if !ok {
+		return
+	}
+
+	params := url.Values{}
+	for name, values := range r.PostForm {
+		switch name {
+		case "client_secret", "client_assertion", "client_assertion_type":
+			// Client credentials aren't part of the authorization request.
+		case "request_uri":
+			s.tokenErrHelper(w, errInvalidRequest, "Pushed authorization requests can't contain a request_uri.", http.StatusBadRequest)
+			return
+		default:
+			params[name] = values
 		}
+	}
+	if clientID := params.Get("client_id"); clientID != "" && clientID != client.ID {
+		s.tokenErrHelper(w, errInvalidRequest, "client_id doesn't match the authenticated client.", http.StatusBadRequest)
+		return
+	}
+	params.Set("client_id", client.ID)
 
-		_, ok := conn.Connector.(connector.RefreshConnector)
-		if !ok {
-			return false
+	// Validate the request now, so the client learns about errors before
+	// redirecting the end user.
+	if _, _, err := s.parseAuthorizationParams(params, true); err != nil {
+		status := http.StatusBadRequest
+		if err.Type == errServerError {
+			status = http.StatusInternalServerError
 		}
+		s.tokenErrHelper(w, err.Type, err.Description, status)
+		return
+	}
 
-		for _, scope := range authCode.Scopes {
-			if scope == scopeOfflineAccess {
-				return true
+	req := storage.PushedAuthRequest{
+		ID:       storage.NewID(),
+		ClientID: client.ID,
+		Params:   params,
+		Expiry:   s.now().Add(pushedAuthRequestValidFor),
+	}
+	if err := s.storage.CreatePushedAuthRequest(req); err != nil {
+		s.logger.Errorf("failed to create pushed auth request: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	resp := pushedAuthRequestResponse{
+		RequestURI: requestURIPrefix + req.ID,
+		ExpiresIn:  int(pushedAuthRequestValidFor.Seconds()),
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal pushed auth request response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+}
+
+// handleDevice renders the page where end users enter the code shown on
+// their device. Once a valid code is entered the end user goes through the
+// normal connector login and approval screens.
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	postURL := s.absPath("/device")
+
+	switch r.Method {
+	case "GET":
+		if err := s.templates.device(w, postURL, r.FormValue("user_code"), false); err != nil {
+			s.logger.Errorf("Server template error: %v", err)
+		}
+	case "POST":
+		userCode := r.FormValue("user_code")
+
+		req, err := s.storage.GetDeviceRequest(normalizeUserCode(userCode))
+		if err != nil || s.now().After(req.Expiry) || req.AuthCode != "" || req.Denied {
+			if err != nil && err != storage.ErrNotFound {
+				s.logger.Errorf("Failed to get device request: %v", err)
+				s.renderError(w, http.StatusInternalServerError, "Database error.")
+				return
+			}
+			if err := s.templates.device(w, postURL, userCode, true); err != nil {
+				s.logger.Errorf("Server template error: %v", err)
 			}
+			return
 		}
-		return false
-	}()
-	var refreshToken string
-	if reqRefresh {
-		refresh := storage.RefreshToken{
+
+		// The device request's user code is carried as the "state" so the
+		// device callback knows which request to complete.
+		authReq := storage.AuthRequest{
 			ID:            storage.NewID(),
-			Token:         storage.NewID(),
-			ClientID:      authCode.ClientID,
-			ConnectorID:   authCode.ConnectorID,
-			Scopes:        authCode.Scopes,
-			Claims:        authCode.Claims,
-			Nonce:         authCode.Nonce,
-			ConnectorData: authCode.ConnectorData,
-			CreatedAt:     s.now(),
-			LastUsed:      s.now(),
-		}
-		token := &internal.RefreshToken{
-			RefreshId: refresh.ID,
-			Token:     refresh.Token,
+			ClientID:      req.ClientID,
+			ResponseTypes: []string{responseTypeCode},
+			Scopes:        req.Scopes,
+			RedirectURI:   s.absURL(deviceCallbackPath),
+			State:         req.UserCode,
+			Expiry:        req.Expiry,
 		}
-		if refreshToken, err = internal.Marshal(token); err != nil {
-			s.logger.Errorf("failed to marshal refresh token: %v", err)
-			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		if err := s.storage.CreateAuthRequest(authReq); err != nil {
+			s.logger.Errorf("Failed to create authorization request: %v", err)
+			s.renderError(w, http.StatusInternalServerError, "Failed to connect to the database.")
 			return
 		}
 
-		if err := s.storage.CreateRefresh(refresh); err != nil {
-			s.logger.Errorf("failed to create refresh token: %v", err)
-			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
-			return
+		s.renderLogin(w, r, authReq)
+	default:
+		s.renderError(w, http.StatusBadRequest, "Unsupported request method.")
+	}
+}
+
+// handleDeviceCallback receives the authorization code issued once the end
+// user has approved a device authorization request, and records it so the
+// device can redeem it the next time it polls the token endpoint.
+func (s *Server) handleDeviceCallback(w http.ResponseWriter, r *http.Request) {
+	authCode, err := s.storage.GetAuthCode(r.FormValue("code"))
+	if err != nil || s.now().After(authCode.Expiry) || authCode.RedirectURI != s.absURL(deviceCallbackPath) {
+		if err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("Failed to get auth code: %v", err)
+			s.renderError(w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+		s.renderError(w, http.StatusBadRequest, "Invalid or expired code.")
+		return
+	}
+
+	updater := func(d storage.DeviceRequest) (storage.DeviceRequest, error) {
+		if d.ClientID != authCode.ClientID {
+			return d, errors.New("device request was started by a different client")
+		}
+		if d.AuthCode != "" || d.Denied {
+			return d, errors.New("device request already completed")
+		}
+		d.AuthCode = authCode.ID
+		return d, nil
+	}
+	if err := s.storage.UpdateDeviceRequest(r.FormValue("state"), updater); err != nil {
+		s.logger.Errorf("Failed to complete device request: %v", err)
+		if err == storage.ErrNotFound {
+			s.renderError(w, http.StatusBadRequest, "Device login session expired.")
+		} else {
+			s.renderError(w, http.StatusBadRequest, "Device login error.")
+		}
+		return
+	}
+
+	client, err := s.storage.GetClient(authCode.ClientID)
+	if err != nil {
+		s.logger.Errorf("Failed to get client %q: %v", authCode.ClientID, err)
+		s.renderError(w, http.StatusInternalServerError, "Failed to retrieve client.")
+		return
+	}
+	if err := s.templates.deviceSuccess(w, client.Name); err != nil {
+		s.logger.Errorf("Server template error: %v", err)
+	}
+}
+
+// handleDeviceToken handles a device polling the token endpoint with the
+// device_code returned by the device authorization endpoint.
+//
+// https://tools.ietf.org/html/rfc8628#section-3.4
+func (s *Server) handleDeviceToken(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	userCode, secret, ok := parseDeviceCode(r.PostFormValue("device_code"))
+	if !ok {
+		s.tokenErrHelper(w, errInvalidRequest, "Missing or malformed device_code parameter.", http.StatusBadRequest)
+		return
+	}
+
+	req, err := s.storage.GetDeviceRequest(userCode)
+	if err != nil || req.ClientID != client.ID || subtle.ConstantTimeCompare([]byte(secret), []byte(req.DeviceCode)) != 1 {
+		if err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get device request: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		} else {
+			s.tokenErrHelper(w, errInvalidGrant, "Invalid device code.", http.StatusBadRequest)
+		}
+		return
+	}
+	if s.now().After(req.Expiry) {
+		s.tokenErrHelper(w, errExpiredToken, "Device code has expired.", http.StatusBadRequest)
+		return
+	}
+	if req.Denied {
+		s.tokenErrHelper(w, errAccessDenied, "The end user denied the authorization request.", http.StatusBadRequest)
+		return
+	}
+
+	if req.AuthCode == "" {
+		// Still waiting on the end user. Ask clients which poll faster than
+		// the interval to back off.
+		now := s.now()
+		slowDown := false
+		updater := func(d storage.DeviceRequest) (storage.DeviceRequest, error) {
+			slowDown = now.Before(d.LastPoll.Add(time.Duration(d.Interval) * time.Second))
+			if slowDown {
+				d.Interval += deviceCodePollInterval
+			}
+			d.LastPoll = now
+			return d, nil
+		}
+		if err := s.storage.UpdateDeviceRequest(userCode, updater); err != nil {
+			s.logger.Errorf("failed to update device request: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		if slowDown {
+			s.tokenErrHelper(w, errSlowDown, "", http.StatusBadRequest)
+		} else {
+			s.tokenErrHelper(w, errAuthorizationPending, "", http.StatusBadRequest)
+		}
+		return
+	}
+
+	// Deleting the device request ensures the tokens are only handed out once.
+	if err := s.storage.DeleteDeviceRequest(userCode); err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("failed to delete device request: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		} else {
+			s.tokenErrHelper(w, errInvalidGrant, "Invalid device code.", http.StatusBadRequest)
+		}
+		return
+	}
+
+	authCode, err := s.storage.GetAuthCode(req.AuthCode)
+	if err != nil || s.now().After(authCode.Expiry) || authCode.ClientID != client.ID {
+		if err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get auth code: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		} else {
+			s.tokenErrHelper(w, errExpiredToken, "Device code has expired.", http.StatusBadRequest)
+		}
+		return
+	}
+	if err := s.storage.DeleteAuthCode(authCode.ID); err != nil {
+		s.logger.Errorf("failed to delete auth code: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	s.issueTokens(w, client, authCode.Claims, authCode.Scopes, authCode.Resources, authCode.Resources, authCode.Nonce, authCode.ConnectorID, authCode.ConnectorData, authCode.AuthTime)
+}
+
+// handleRevoke implements the token revocation endpoint.
+//
+// https://tools.ietf.org/html/rfc7009
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	// Clients may only revoke their own tokens, so public clients can use this
+	// endpoint too.
+	//
+	// https://tools.ietf.org/html/rfc7009#section-2.1
+	client, ok := s.authenticateClient(w, r, true)
+	if !ok {
+		return
+	}
+
+	code := r.PostFormValue("token")
+	if code == "" {
+		s.tokenErrHelper(w, errInvalidRequest, "No token in request.", http.StatusBadRequest)
+		return
+	}
+
+	// Per the spec, invalid tokens, tokens that have already been revoked, and
+	// any token_type_hint are all treated as a successful revocation.
+	//
+	// https://tools.ietf.org/html/rfc7009#section-2.2
+	accessToken, err := s.getAccessToken(code)
+	switch {
+	case err == nil:
+		if accessToken.ClientID != client.ID {
+			s.logger.Errorf("client %s trying to revoke token for client %s", client.ID, accessToken.ClientID)
+			s.tokenErrHelper(w, errInvalidRequest, "Token was not issued to this client.", http.StatusBadRequest)
+			return
+		}
+		if err := s.storage.DeleteAccessToken(accessToken.ID); err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to delete access token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	case err != storage.ErrNotFound:
+		s.logger.Errorf("failed to get access token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	token := new(internal.RefreshToken)
+	if err := internal.Unmarshal(code, token); err != nil {
+		// Refresh tokens issued by older servers are raw refresh token IDs.
+		token = &internal.RefreshToken{RefreshId: code, Token: ""}
+	}
+
+	refresh, err := s.storage.GetRefresh(token.RefreshId)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get refresh token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if refresh.Token != token.Token {
+		// A stale token can't be used to revoke the current one.
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if refresh.ClientID != client.ID {
+		s.logger.Errorf("client %s trying to revoke token for client %s", client.ID, refresh.ClientID)
+		s.tokenErrHelper(w, errInvalidRequest, "Token was not issued to this client.", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.deleteRefreshToken(refresh); err != nil {
+		s.logger.Errorf("failed to delete refresh token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleClientCredentials issues tokens to a client acting on its own behalf.
+// The subject of the tokens is the client itself.
+//
+// https://tools.ietf.org/html/rfc6749#section-4.4
+func (s *Server) handleClientCredentials(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	if client.Public {
+		s.tokenErrHelper(w, errUnauthorizedClient, "Public clients can't use the client credentials grant.", http.StatusBadRequest)
+		return
+	}
+
+	scopes := strings.Fields(r.PostFormValue("scope"))
+	unrecognized, invalidScopes, err := s.validateScopes(client, scopes)
+	if err != nil {
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if hasScope(scopes, scopeOfflineAccess) {
+		// Refresh tokens aren't issued for this grant.
+		//
+		// https://tools.ietf.org/html/rfc6749#section-4.4.3
+		invalidScopes = append(invalidScopes, scopeOfflineAccess)
+	}
+	if len(unrecognized) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Unrecognized scope(s) %q", unrecognized), http.StatusBadRequest)
+		return
+	}
+	if len(invalidScopes) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Client can't request scope(s) %q", invalidScopes), http.StatusBadRequest)
+		return
+	}
+
+	resources, ok := s.tokenResources(w, r, nil)
+	if !ok {
+		return
+	}
+
+	claims := storage.Claims{
+		UserID:   client.ID,
+		Username: client.Name,
+	}
+
+	accessToken, expiry, err := s.newAccessToken(client, claims, scopes, resources, "")
+	if err != nil {
+		s.logger.Errorf("failed to create access token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	var idToken string
+	if hasScope(scopes, scopeOpenID) {
+		idToken, expiry, err = s.newIDToken(client, claims, scopes, "", accessToken, "", time.Time{})
+		if err != nil {
+			s.logger.Errorf("failed to create ID token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s.writeAccessToken(w, idToken, accessToken, "", expiry)
+}
+
+// handlePasswordGrant logs in an end user with their username and password
+// through the configured password connector.
+//
+// https://tools.ietf.org/html/rfc6749#section-4.3
+func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	if s.passwordConnector == "" {
+		s.tokenErrHelper(w, errUnsupportedGrantType, "", http.StatusBadRequest)
+		return
+	}
+	if !clientAllowsConnector(client, s.passwordConnector) {
+		s.tokenErrHelper(w, errUnauthorizedClient, "Client is not allowed to use the password connector.", http.StatusBadRequest)
+		return
+	}
+
+	username := r.PostFormValue("username")
+	password := r.PostFormValue("password")
+	if username == "" || password == "" {
+		s.tokenErrHelper(w, errInvalidRequest, "Missing username or password.", http.StatusBadRequest)
+		return
+	}
+
+	scopes := strings.Fields(r.PostFormValue("scope"))
+	unrecognized, invalidScopes, err := s.validateScopes(client, scopes)
+	if err != nil {
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if !hasScope(scopes, scopeOpenID) {
+		s.tokenErrHelper(w, errInvalidScope, `Missing required scope(s) ["openid"].`, http.StatusBadRequest)
+		return
+	}
+	if len(unrecognized) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Unrecognized scope(s) %q", unrecognized), http.StatusBadRequest)
+		return
+	}
+	if len(invalidScopes) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Client can't request scope(s) %q", invalidScopes), http.StatusBadRequest)
+		return
+	}
+	resources, ok := s.tokenResources(w, r, nil)
+	if !ok {
+		return
+	}
+
+	conn, err := s.getConnector(s.passwordConnector)
+	if err != nil {
+		s.logger.Errorf("password connector with ID %q not found: %v", s.passwordConnector, err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	passwordConnector, ok := conn.Connector.(connector.PasswordConnector)
+	if !ok {
+		s.logger.Errorf("connector with ID %q is not a password connector", s.passwordConnector)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	identity, ok, err := passwordConnector.Login(r.Context(), parseScopes(scopes), username, password)
+	if err != nil {
+		s.logger.Errorf("failed to login user: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		s.tokenErrHelper(w, errInvalidGrant, "Invalid username or password.", http.StatusBadRequest)
+		return
+	}
+
+	claims := storage.Claims{
+		UserID:        identity.UserID,
+		Username:      identity.Username,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Groups:        identity.Groups,
+	}
+	s.issueTokens(w, client, claims, scopes, resources, resources, "", s.passwordConnector, identity.ConnectorData, s.now())
+}
+
+// tokenExchangeResponse is the response of a token exchange request.
+//
+// https://tools.ietf.org/html/rfc8693#section-2.2.1
+type tokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int    `json:"expires_in"`
+}
+
+// handleTokenExchange exchanges an ID Token issued by this server for one
+// audienced to other clients, without a browser round trip. Like the
+// "audience:server:client_id:" scope, every target client must list the
+// requesting client as a trusted peer.
+//
+// https://tools.ietf.org/html/rfc8693
+func (s *Server) handleTokenExchange(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	if client.Public {
+		s.tokenErrHelper(w, errUnauthorizedClient, "Public clients can't use the token exchange grant.", http.StatusBadRequest)
+		return
+	}
+
+	switch r.PostFormValue("subject_token_type") {
+	case tokenTypeIDToken, tokenTypeJWT:
+	default:
+		s.tokenErrHelper(w, errInvalidRequest, "Unsupported subject_token_type, expected an ID Token.", http.StatusBadRequest)
+		return
+	}
+	switch r.PostFormValue("requested_token_type") {
+	case "", tokenTypeIDToken, tokenTypeJWT:
+	default:
+		s.tokenErrHelper(w, errInvalidRequest, "Unsupported requested_token_type, only ID Tokens can be issued.", http.StatusBadRequest)
+		return
+	}
+	if r.PostFormValue("actor_token") != "" {
+		s.tokenErrHelper(w, errInvalidRequest, "Delegation through actor_token is not supported.", http.StatusBadRequest)
+		return
+	}
+
+	subject, err := s.verifyIDToken(r.PostFormValue("subject_token"))
+	if err != nil {
+		s.logger.Infof("token exchange: invalid subject token: %v", err)
+		s.tokenErrHelper(w, errInvalidGrant, "Invalid or expired subject_token.", http.StatusBadRequest)
+		return
+	}
+	// Clients may only exchange tokens that were issued to them.
+	if !subject.Audience.contains(client.ID) && subject.AuthorizingParty != client.ID {
+		s.tokenErrHelper(w, errInvalidGrant, "subject_token was not issued to this client.", http.StatusBadRequest)
+		return
+	}
+
+	local, err := s.localSubjectFor(subject.issuedTo(), subject.Subject)
+	if err != nil {
+		s.logger.Infof("token exchange: invalid subject: %v", err)
+		s.tokenErrHelper(w, errInvalidGrant, "Invalid subject_token.", http.StatusBadRequest)
+		return
+	}
+	userID, connID, err := parseIDTokenSubject(local)
+	if err != nil {
+		s.logger.Infof("token exchange: invalid subject: %v", err)
+		s.tokenErrHelper(w, errInvalidGrant, "Invalid subject_token.", http.StatusBadRequest)
+		return
+	}
+	if !clientAllowsConnector(client, connID) {
+		s.tokenErrHelper(w, errInvalidGrant, "subject_token was issued through a connector this client can't use.", http.StatusBadRequest)
+		return
+	}
+	claims := storage.Claims{
+		UserID:   userID,
+		Username: subject.Name,
+		Email:    subject.Email,
+		Groups:   subject.Groups,
+	}
+	if subject.EmailVerified != nil {
+		claims.EmailVerified = *subject.EmailVerified
+	}
+
+	var scopes []string
+	if scope := r.PostFormValue("scope"); scope != "" {
+		scopes = strings.Fields(scope)
+	} else {
+		// Carry over the claims present in the subject token.
+		scopes = []string{scopeOpenID}
+		if subject.Email != "" {
+			scopes = append(scopes, scopeEmail)
+		}
+		if len(subject.Groups) > 0 {
+			scopes = append(scopes, scopeGroups)
+		}
+		if subject.Name != "" {
+			scopes = append(scopes, scopeProfile)
+		}
+	}
+
+	unrecognized, invalidScopes, err := s.validateScopes(client, scopes)
+	if err != nil {
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if hasScope(scopes, scopeOfflineAccess) {
+		// Refresh tokens aren't issued for this grant.
+		invalidScopes = append(invalidScopes, scopeOfflineAccess)
+	}
+	if len(unrecognized) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Unrecognized scope(s) %q", unrecognized), http.StatusBadRequest)
+		return
+	}
+	if len(invalidScopes) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Client can't request scope(s) %q", invalidScopes), http.StatusBadRequest)
+		return
+	}
+
+	for _, aud := range r.PostForm["audience"] {
+		isTrusted, err := s.validateCrossClientTrust(client.ID, aud)
+		if err != nil {
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		if !isTrusted {
+			s.tokenErrHelper(w, errInvalidTarget, fmt.Sprintf("Client can't request tokens for audience %q.", aud), http.StatusBadRequest)
+			return
+		}
+		scopes = append(scopes, scopeCrossClientPrefix+aud)
+	}
+
+	idToken, expiry, err := s.newIDToken(client, claims, scopes, "", "", connID, time.Time{})
+	if err != nil {
+		s.logger.Errorf("failed to create ID token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	// The issued token isn't an access token, so RFC 8693 asks for a
+	// token_type of "N_A".
+	resp := tokenExchangeResponse{
+		AccessToken:     idToken,
+		IssuedTokenType: tokenTypeIDToken,
+		TokenType:       "N_A",
+		ExpiresIn:       int(expiry.Sub(s.now()).Seconds()),
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal token exchange response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// introspection is the response of the token introspection endpoint.
+//
+// https://tools.ietf.org/html/rfc7662#section-2.2
+type introspection struct {
+	Active bool `json:"active"`
+
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Expiry    int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+
+	Email         string   `json:"email,omitempty"`
+	EmailVerified *bool    `json:"email_verified,omitempty"`
+	Groups        []string `json:"groups,omitempty"`
+}
+
+// handleIntrospect implements the token introspection endpoint. Any
+// authenticated client may ask if an access or refresh token is active.
+//
+// https://tools.ietf.org/html/rfc7662
+func (s *Server) handleIntrospect(w http.ResponseWriter, r *http.Request) {
+	client, ok := s.authenticateClient(w, r, false)
+	if !ok {
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		s.tokenErrHelper(w, errInvalidRequest, "No token in request.", http.StatusBadRequest)
+		return
+	}
+
+	lookups := []func(string) (introspection, error){s.introspectAccessToken, s.introspectRefreshToken}
+	if r.PostFormValue("token_type_hint") == "refresh_token" {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	var resp introspection
+	for _, lookup := range lookups {
+		var err error
+		if resp, err = lookup(token); err != nil {
+			s.logger.Errorf("failed to introspect token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		if resp.Active {
+			break
+		}
+	}
+	// Clients can only learn about other clients' tokens if they're trusted
+	// to, so a client holding a stolen token can't read its claims.
+	//
+	// https://tools.ietf.org/html/rfc7662#section-4
+	if resp.Active && resp.ClientID != client.ID && !s.introspectionClients[client.ID] {
+		resp = introspection{}
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal introspection response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// introspectAccessToken returns an inactive response if the token isn't a known,
+// unexpired access token.
+func (s *Server) introspectAccessToken(token string) (introspection, error) {
+	accessToken, err := s.getAccessToken(token)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return introspection{}, nil
+		}
+		return introspection{}, fmt.Errorf("get access token: %v", err)
+	}
+	if s.now().After(accessToken.Expiry) {
+		return introspection{}, nil
+	}
+
+	resp, err := s.newIntrospection(accessToken.ClientID, accessToken.Claims, accessToken.Scopes, accessToken.ConnectorID)
+	if err != nil {
+		return introspection{}, err
+	}
+	resp.TokenType = "bearer"
+	if len(accessToken.Resources) > 0 {
+		resp.Audience = audience(accessToken.Resources)
+	}
+	resp.Expiry = accessToken.Expiry.Unix()
+	resp.IssuedAt = accessToken.CreatedAt.Unix()
+	return resp, nil
+}
+
+// introspectRefreshToken returns an inactive response if the token isn't the
+// current value of a refresh token.
+func (s *Server) introspectRefreshToken(code string) (introspection, error) {
+	token := new(internal.RefreshToken)
+	if err := internal.Unmarshal(code, token); err != nil {
+		// Refresh tokens issued by older servers are raw refresh token IDs.
+		token = &internal.RefreshToken{RefreshId: code, Token: ""}
+	}
+
+	refresh, err := s.storage.GetRefresh(token.RefreshId)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return introspection{}, nil
+		}
+		return introspection{}, fmt.Errorf("get refresh token: %v", err)
+	}
+	if refresh.Token != token.Token {
+		return introspection{}, nil
+	}
+
+	resp, err := s.newIntrospection(refresh.ClientID, refresh.Claims, refresh.Scopes, refresh.ConnectorID)
+	if err != nil {
+		return introspection{}, err
+	}
+	resp.IssuedAt = refresh.CreatedAt.Unix()
+	return resp, nil
+}
+
+// newIntrospection builds an active introspection response. Claims are only
+// included if the matching scope was granted, the same as the ID Token.
+func (s *Server) newIntrospection(clientID string, claims storage.Claims, scopes []string, connID string) (introspection, error) {
+	subjectString, err := idTokenSubject(claims.UserID, connID)
+	if err != nil {
+		return introspection{}, fmt.Errorf("failed to marshal subject: %v", err)
+	}
+	if subjectString, err = s.clientSubjectFor(clientID, subjectString); err != nil {
+		return introspection{}, fmt.Errorf("failed to compute client subject: %v", err)
+	}
+
+	resp := introspection{
+		Active:   true,
+		Scope:    strings.Join(scopes, " "),
+		ClientID: clientID,
+		Subject:  subjectString,
+		Audience: audience{clientID},
+		Issuer:   s.issuerURL.String(),
+	}
+	for _, scope := range scopes {
+		switch scope {
+		case scopeEmail:
+			resp.Email = claims.Email
+			resp.EmailVerified = &claims.EmailVerified
+		case scopeGroups:
+			resp.Groups = claims.Groups
+		case scopeProfile:
+			resp.Username = claims.Username
+		}
+	}
+	return resp, nil
+}
+
+// userInfo is the response of the UserInfo endpoint. Claims are only included
+// if the matching scope was granted, the same as the ID Token.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
+type userInfo struct {
+	Subject string `json:"sub"`
+
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+
+	Groups []string `json:"groups,omitempty"`
+
+	Name string `json:"name,omitempty"`
+}
+
+// handleUserInfo returns the claims of the end user an access token was issued for.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfo
+func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	var token string
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		parts := strings.SplitN(auth, " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+			s.bearerErrHelper(w, errInvalidRequest, "Authorization header must use the bearer scheme.", http.StatusBadRequest)
+			return
+		}
+		token = parts[1]
+	} else if r.Method == "POST" {
+		// Form-encoded body parameter.
+		//
+		// https://tools.ietf.org/html/rfc6750#section-2.2
+		token = r.PostFormValue("access_token")
+	}
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	accessToken, err := s.getAccessToken(token)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get access token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		s.bearerErrHelper(w, errInvalidToken, "Invalid access token.", http.StatusUnauthorized)
+		return
+	}
+	if s.now().After(accessToken.Expiry) {
+		s.bearerErrHelper(w, errInvalidToken, "Access token has expired.", http.StatusUnauthorized)
+		return
+	}
+
+	subjectString, err := idTokenSubject(accessToken.Claims.UserID, accessToken.ConnectorID)
+	if err != nil {
+		s.logger.Errorf("failed to marshal subject: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if subjectString, err = s.clientSubjectFor(accessToken.ClientID, subjectString); err != nil {
+		s.logger.Errorf("failed to compute client subject: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	info := userInfo{Subject: subjectString}
+	for _, scope := range accessToken.Scopes {
+		switch scope {
+		case scopeEmail:
+			info.Email = accessToken.Claims.Email
+			info.EmailVerified = &accessToken.Claims.EmailVerified
+		case scopeGroups:
+			info.Groups = accessToken.Claims.Groups
+		case scopeProfile:
+			info.Name = accessToken.Claims.Username
+		}
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		s.logger.Errorf("failed to marshal user info response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// handle an access token request https://tools.ietf.org/html/rfc6749#section-4.1.3
+func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	code := r.PostFormValue("code")
+	redirectURI := r.PostFormValue("redirect_uri")
+
+	authCode, err := s.storage.GetAuthCode(code)
+	if err != nil || s.now().After(authCode.Expiry) || authCode.ClientID != client.ID {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get auth code: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		} else {
+			s.tokenErrHelper(w, errInvalidRequest, "Invalid or expired code parameter.", http.StatusBadRequest)
+		}
+		return
+	}
+
+	if authCode.RedirectURI != redirectURI {
+		s.tokenErrHelper(w, errInvalidRequest, "redirect_uri did not match URI from initial request.", http.StatusBadRequest)
+		return
+	}
+	if authCode.RedirectURI == s.absURL(deviceCallbackPath) {
+		// Codes issued for a device authorization request can only be redeemed
+		// by the device polling with its device_code.
+		s.tokenErrHelper(w, errInvalidGrant, "Code was issued for a device authorization request.", http.StatusBadRequest)
+		return
+	}
+
+	codeVerifier := r.PostFormValue("code_verifier")
+	switch {
+	case authCode.PKCE.CodeChallenge != "":
+		if codeVerifier == "" {
+			s.tokenErrHelper(w, errInvalidRequest, "Expecting parameter code_verifier in PKCE flow.", http.StatusBadRequest)
+			return
+		}
+		if !verifyCodeVerifier(authCode.PKCE, codeVerifier) {
+			s.tokenErrHelper(w, errInvalidGrant, "Invalid code_verifier.", http.StatusBadRequest)
+			return
+		}
+	case codeVerifier != "":
+		s.tokenErrHelper(w, errInvalidRequest, "No PKCE flow started. Cannot check code_verifier.", http.StatusBadRequest)
+		return
+	}
+
+	resources, ok := s.tokenResources(w, r, authCode.Resources)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.DeleteAuthCode(code); err != nil {
+		s.logger.Errorf("failed to delete auth code: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	s.issueTokens(w, client, authCode.Claims, authCode.Scopes, authCode.Resources, resources, authCode.Nonce, authCode.ConnectorID, authCode.ConnectorData, authCode.AuthTime)
+}
+
+// issueTokens creates an ID Token, an access token and, if requested by the
+// "offline_access" scope, a refresh token for an authenticated end user, then
+// writes the token response.
+//
+// grantedResources are the resource servers the end user authorized access
+// to, which a refresh token remains limited to. resources are the ones the
+// access token is issued for.
+func (s *Server) issueTokens(w http.ResponseWriter, client storage.Client, claims storage.Claims, scopes, grantedResources, resources []string, nonce, connID string, connectorData []byte, authTime time.Time) {
+	accessToken, _, err := s.newAccessToken(client, claims, scopes, resources, connID)
+	if err != nil {
+		s.logger.Errorf("failed to create access token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	idToken, expiry, err := s.newIDToken(client, claims, scopes, nonce, accessToken, connID, authTime)
+	if err != nil {
+		s.logger.Errorf("failed to create ID token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	reqRefresh := func() bool {
+		// Ensure the connector supports refresh tokens.
+		//
+		// Connectors like `saml` do not implement RefreshConnector.
+		conn, err := s.getConnector(connID)
+		if err != nil {
+			s.logger.Errorf("connector with ID %q not found: %v", connID, err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return false
+		}
+
+		_, ok := conn.Connector.(connector.RefreshConnector)
+		if !ok {
+			return false
+		}
+
+		for _, scope := range scopes {
+			if scope == scopeOfflineAccess {
+				return true
+			}
+		}
+		return false
+	}()
+	var refreshToken string
+	if reqRefresh {
+		refresh := storage.RefreshToken{
+			ID:            storage.NewID(),
+			Token:         storage.NewID(),
+			ClientID:      client.ID,
+			ConnectorID:   connID,
+			Scopes:        scopes,
+			Claims:        claims,
+			Nonce:         nonce,
+			ConnectorData: connectorData,
+			CreatedAt:     s.now(),
+			LastUsed:      s.now(),
+			AuthTime:      authTime,
+			Resources:     grantedResources,
+		}
+		refresh.Expiry = s.refreshTokenExpiry(client, refresh.CreatedAt, refresh.LastUsed)
+		token := &internal.RefreshToken{
+			RefreshId: refresh.ID,
+			Token:     refresh.Token,
+		}
+		if refreshToken, err = internal.Marshal(token); err != nil {
+			s.logger.Errorf("failed to marshal refresh token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.storage.CreateRefresh(refresh); err != nil {
+			s.logger.Errorf("failed to create refresh token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
 		}
 
 		// deleteToken determines if we need to delete the newly created refresh token
@@ -813,11 +2138,34 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		s.tokenErrHelper(w, errInvalidRequest, "Refresh token is invalid or has already been claimed by another client.", http.StatusBadRequest)
 		return
 	}
-	if refresh.Token != token.Token {
-		s.logger.Errorf("refresh token with id %s claimed twice", refresh.ID)
+	if refresh.Token != token.Token && !s.refreshTokenReusable(refresh, token.Token) {
+		// The token was already rotated. Either the client or an attacker
+		// holds a leaked copy, so revoke the refresh token altogether.
+		//
+		// https://tools.ietf.org/html/draft-ietf-oauth-security-topics-16#section-4.13.2
+		s.logger.Errorf("refresh token with id %s claimed twice, revoking it: client=%q user=%q connector=%q",
+			refresh.ID, refresh.ClientID, refresh.Claims.UserID, refresh.ConnectorID)
+		if err := s.deleteRefreshToken(refresh); err != nil {
+			s.logger.Errorf("failed to revoke refresh token: %v", err)
+		}
 		s.tokenErrHelper(w, errInvalidRequest, "Refresh token is invalid or has already been claimed by another client.", http.StatusBadRequest)
 		return
 	}
+	// The stored expiry is when garbage collection removes the refresh token.
+	// Lifetimes may also have been shortened since it was last used.
+	if s.refreshTokenExpired(client, refresh) {
+		if err := s.deleteRefreshToken(refresh); err != nil {
+			s.logger.Errorf("failed to delete expired refresh token: %v", err)
+		}
+		s.tokenErrHelper(w, errInvalidGrant, "Refresh token has expired.", http.StatusBadRequest)
+		return
+	}
+	// The client's restrictions may have been tightened since the refresh
+	// token was issued.
+	if !clientAllowsConnector(client, refresh.ConnectorID) {
+		s.tokenErrHelper(w, errInvalidGrant, "Refresh token was issued through a connector this client can't use.", http.StatusBadRequest)
+		return
+	}
 
 	// Per the OAuth2 spec, if the client has omitted the scopes, default to the original
 	// authorized scopes.
@@ -849,6 +2197,21 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		}
 		scopes = requestedScopes
 	}
+	var disallowedScopes []string
+	for _, scope := range scopes {
+		if !clientAllowsScope(client, scope) {
+			disallowedScopes = append(disallowedScopes, scope)
+		}
+	}
+	if len(disallowedScopes) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Client can't request scope(s) %q", disallowedScopes), http.StatusBadRequest)
+		return
+	}
+
+	resources, ok := s.tokenResources(w, r, refresh.Resources)
+	if !ok {
+		return
+	}
 
 	conn, err := s.getConnector(refresh.ConnectorID)
 	if err != nil {
@@ -888,18 +2251,31 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		Groups:        ident.Groups,
 	}
 
-	accessToken := storage.NewID()
-	idToken, expiry, err := s.newIDToken(client.ID, claims, scopes, refresh.Nonce, accessToken, refresh.ConnectorID)
+	accessToken, _, err := s.newAccessToken(client, claims, scopes, resources, refresh.ConnectorID)
+	if err != nil {
+		s.logger.Errorf("failed to create access token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	idToken, expiry, err := s.newIDToken(client, claims, scopes, refresh.Nonce, accessToken, refresh.ConnectorID, refresh.AuthTime)
 	if err != nil {
 		s.logger.Errorf("failed to create ID token: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		return
 	}
 
+	// A client presenting the previous token within the reuse interval gets
+	// the current one. The token isn't rotated again, and the interval isn't
+	// extended.
+	reused := refresh.Token != token.Token
 	newToken := &internal.RefreshToken{
 		RefreshId: refresh.ID,
 		Token:     storage.NewID(),
 	}
+	if reused {
+		newToken.Token = refresh.Token
+	}
 	rawNewToken, err := internal.Marshal(newToken)
 	if err != nil {
 		s.logger.Errorf("failed to marshal refresh token: %v", err)
@@ -908,11 +2284,17 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 	}
 
 	lastUsed := s.now()
+	if reused {
+		lastUsed = refresh.LastUsed
+	}
 	updater := func(old storage.RefreshToken) (storage.RefreshToken, error) {
 		if old.Token != refresh.Token {
 			return old, errors.New("refresh token claimed twice")
 		}
-		old.Token = newToken.Token
+		if !reused {
+			old.ObsoleteToken = old.Token
+			old.Token = newToken.Token
+		}
 		// Update the claims of the refresh token.
 		//
 		// UserID intentionally ignored for now.
@@ -922,6 +2304,7 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		old.Claims.Groups = ident.Groups
 		old.ConnectorData = ident.ConnectorData
 		old.LastUsed = lastUsed
+		old.Expiry = s.refreshTokenExpiry(client, old.CreatedAt, lastUsed)
 		return old, nil
 	}
 
@@ -949,16 +2332,47 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 	s.writeAccessToken(w, idToken, accessToken, rawNewToken, expiry)
 }
 
+// tokenResources returns the resource servers a token request asks for an
+// access token for. If the end user only authorized access to certain resource
+// servers, requests may narrow these down but not add others. Without any
+// "resource" parameters, all granted resource servers are used.
+//
+// https://tools.ietf.org/html/rfc8707#section-2.2
+func (s *Server) tokenResources(w http.ResponseWriter, r *http.Request, granted []string) ([]string, bool) {
+	requested := r.PostForm["resource"]
+	if len(requested) == 0 {
+		return granted, true
+	}
+	for _, resource := range requested {
+		if !validResource(resource) {
+			s.tokenErrHelper(w, errInvalidTarget, fmt.Sprintf("Invalid resource %q, expected an absolute URI without a fragment.", resource), http.StatusBadRequest)
+			return nil, false
+		}
+		if len(granted) == 0 {
+			continue
+		}
+		isGranted := false
+		for _, g := range granted {
+			if resource == g {
+				isGranted = true
+				break
+			}
+		}
+		if !isGranted {
+			s.tokenErrHelper(w, errInvalidTarget, fmt.Sprintf("Resource %q wasn't authorized by the end user.", resource), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	return requested, true
+}
+
 func (s *Server) writeAccessToken(w http.ResponseWriter, idToken, accessToken, refreshToken string, expiry time.Time) {
-	// TODO(ericchiang): figure out an access token story and support the user info
-	// endpoint. For now use a random value so no one depends on the access_token
-	// holding a specific structure.
 	resp := struct {
 		AccessToken  string `json:"access_token"`
 		TokenType    string `json:"token_type"`
 		ExpiresIn    int    `json:"expires_in"`
 		RefreshToken string `json:"refresh_token,omitempty"`
-		IDToken      string `json:"id_token"`
+		IDToken      string `json:"id_token,omitempty"`
 	}{
 		accessToken,
 		"bearer",
@@ -988,3 +2402,11 @@ func (s *Server) tokenErrHelper(w http.ResponseWriter, typ string, description s
 		s.logger.Errorf("token error response: %v", err)
 	}
 }
+
+// bearerErrHelper responds to a request which presented a bad bearer token.
+//
+// https://tools.ietf.org/html/rfc6750#section-3
+func (s *Server) bearerErrHelper(w http.ResponseWriter, typ string, description string, statusCode int) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=%q, error_description=%q", typ, description))
+	s.tokenErrHelper(w, typ, description, statusCode)
+}
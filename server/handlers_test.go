@@ -2,9 +2,22 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	"golang.org/x/crypto/bcrypt"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/coreos/dex/server/internal"
+	"github.com/coreos/dex/storage"
 )
 
 func TestHandleHealth(t *testing.T) {
@@ -21,3 +34,1467 @@ func TestHandleHealth(t *testing.T) {
 	}
 
 }
+
+func TestHandleAuthCodePKCE(t *testing.T) {
+	const (
+		verifier  = "dBjftJeZ4CVP-mJ92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "ngF5GsXcbwljx6u133FFr3Xht9xooA_DuaX_3QwODtc"
+	)
+
+	tests := []struct {
+		name string
+		// PKCE challenge stored with the auth code.
+		pkce storage.PKCE
+		// Form values sent to the token endpoint alongside the code.
+		secret       string
+		codeVerifier string
+
+		wantCode int
+	}{
+		{
+			name:         "public client with valid verifier",
+			pkce:         storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: "S256"},
+			codeVerifier: verifier,
+			wantCode:     http.StatusOK,
+		},
+		{
+			name:         "public client with wrong verifier",
+			pkce:         storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: "S256"},
+			codeVerifier: strings.Repeat("a", 43),
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			name:     "public client missing verifier",
+			pkce:     storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: "S256"},
+			secret:   "secret",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:         "verifier without PKCE flow",
+			secret:       "secret",
+			codeVerifier: verifier,
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			name:     "public client without PKCE must authenticate",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			client := storage.Client{
+				ID:           "testclient",
+				Secret:       "secret",
+				RedirectURIs: []string{"https://example.com/callback"},
+				Public:       true,
+			}
+			httpServer, server := newTestServer(ctx, t, func(c *Config) {
+				c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{client})
+			})
+			defer httpServer.Close()
+
+			code := storage.AuthCode{
+				ID:          storage.NewID(),
+				ClientID:    client.ID,
+				RedirectURI: client.RedirectURIs[0],
+				Scopes:      []string{"openid"},
+				ConnectorID: "mock",
+				Claims: storage.Claims{
+					UserID:   "1",
+					Username: "jane",
+					Email:    "jane.doe@example.com",
+				},
+				PKCE:   tc.pkce,
+				Expiry: time.Now().Add(time.Minute),
+			}
+			if err := server.storage.CreateAuthCode(code); err != nil {
+				t.Fatalf("%s: create auth code: %v", tc.name, err)
+			}
+
+			v := url.Values{}
+			v.Set("grant_type", "authorization_code")
+			v.Set("code", code.ID)
+			v.Set("redirect_uri", code.RedirectURI)
+			v.Set("client_id", client.ID)
+			if tc.secret != "" {
+				v.Set("client_secret", tc.secret)
+			}
+			if tc.codeVerifier != "" {
+				v.Set("code_verifier", tc.codeVerifier)
+			}
+
+			req := httptest.NewRequest("POST", "/token", strings.NewReader(v.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+			if rr.Code != tc.wantCode {
+				t.Errorf("%s: expected %d got %d: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			}
+		}()
+	}
+}
+
+func TestSendCodeResponseModes(t *testing.T) {
+	const redirectURI = "https://example.com/callback"
+
+	tests := []struct {
+		name          string
+		responseTypes []string
+		responseMode  string
+
+		// Where the response parameters are expected to end up.
+		wantMode string
+	}{
+		{
+			name:          "code flow defaults to the query",
+			responseTypes: []string{responseTypeCode},
+			wantMode:      responseModeQuery,
+		},
+		{
+			name:          "hybrid flow defaults to the fragment",
+			responseTypes: []string{responseTypeCode, responseTypeIDToken},
+			wantMode:      responseModeFragment,
+		},
+		{
+			name:          "code flow with fragment",
+			responseTypes: []string{responseTypeCode},
+			responseMode:  responseModeFragment,
+			wantMode:      responseModeFragment,
+		},
+		{
+			name:          "code flow with form_post",
+			responseTypes: []string{responseTypeCode},
+			responseMode:  responseModeFormPost,
+			wantMode:      responseModeFormPost,
+		},
+		{
+			name:          "implicit flow with form_post",
+			responseTypes: []string{responseTypeIDToken, responseTypeToken},
+			responseMode:  responseModeFormPost,
+			wantMode:      responseModeFormPost,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{ID: "testclient", Secret: "secret", RedirectURIs: []string{redirectURI}},
+		})
+		c.SkipApprovalScreen = true
+	})
+	defer httpServer.Close()
+
+	for _, tc := range tests {
+		authReq := storage.AuthRequest{
+			ID:            storage.NewID(),
+			ClientID:      "testclient",
+			ResponseTypes: tc.responseTypes,
+			ResponseMode:  tc.responseMode,
+			Scopes:        []string{"openid"},
+			RedirectURI:   redirectURI,
+			Nonce:         "nonce",
+			State:         "state",
+			LoggedIn:      true,
+			ConnectorID:   "mock",
+			Claims:        storage.Claims{UserID: "1", Username: "jane"},
+			Expiry:        time.Now().Add(time.Minute),
+		}
+		if err := server.storage.CreateAuthRequest(authReq); err != nil {
+			t.Fatalf("%s: create auth request: %v", tc.name, err)
+		}
+
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", "/approval?req="+authReq.ID, nil))
+
+		var params url.Values
+		switch tc.wantMode {
+		case responseModeFormPost:
+			if rr.Code != http.StatusOK {
+				t.Errorf("%s: expected form to be rendered, got %d", tc.name, rr.Code)
+				continue
+			}
+			body := rr.Body.String()
+			if !strings.Contains(body, `action="`+redirectURI+`"`) {
+				t.Errorf("%s: expected form to post to the redirect URI: %s", tc.name, body)
+			}
+			if !strings.Contains(body, `name="state" value="state"`) {
+				t.Errorf("%s: expected form to hold the state: %s", tc.name, body)
+			}
+			if rr.Header().Get("Location") != "" {
+				t.Errorf("%s: expected no redirect", tc.name)
+			}
+			continue
+		case responseModeFragment, responseModeQuery:
+			if rr.Code != http.StatusSeeOther {
+				t.Errorf("%s: expected redirect, got %d", tc.name, rr.Code)
+				continue
+			}
+			u, err := url.Parse(rr.Header().Get("Location"))
+			if err != nil {
+				t.Errorf("%s: invalid redirect: %v", tc.name, err)
+				continue
+			}
+			if tc.wantMode == responseModeQuery {
+				params = u.Query()
+				if u.Fragment != "" {
+					t.Errorf("%s: expected no fragment, got %q", tc.name, u.Fragment)
+				}
+			} else {
+				if params, err = url.ParseQuery(u.Fragment); err != nil {
+					t.Errorf("%s: invalid fragment: %v", tc.name, err)
+					continue
+				}
+				if u.RawQuery != "" {
+					t.Errorf("%s: expected no query, got %q", tc.name, u.RawQuery)
+				}
+			}
+		}
+		if params.Get("state") != "state" || params.Get("code") == "" {
+			t.Errorf("%s: expected code and state in response, got %v", tc.name, params)
+		}
+	}
+
+	// Error responses honor the response mode too.
+	v := url.Values{
+		"client_id":     {"testclient"},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"response_mode": {"form_post"},
+		"scope":         {"openid"},
+		"state":         {"state"},
+		"prompt":        {"none"},
+	}
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/auth?"+v.Encode(), nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `name="error" value="login_required"`) {
+		t.Errorf("expected error to be posted to the client, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandleClientCredentials(t *testing.T) {
+	clients := []storage.Client{
+		{
+			ID:                "service",
+			Secret:            "secret",
+			Name:              "Service",
+			AllowedGrantTypes: []string{"client_credentials"},
+		},
+		{
+			ID:           "webapp",
+			Secret:       "secret",
+			RedirectURIs: []string{"https://example.com/callback"},
+		},
+		{
+			ID:           "api",
+			Secret:       "secret",
+			TrustedPeers: []string{"service"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		clientID string
+		secret   string
+		scope    string
+
+		wantCode int
+		// If set, the expected audience of the ID Token.
+		wantAud string
+	}{
+		{
+			name:     "access token only",
+			clientID: "service",
+			secret:   "secret",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "ID token for the client",
+			clientID: "service",
+			secret:   "secret",
+			scope:    "openid",
+			wantCode: http.StatusOK,
+			wantAud:  "service",
+		},
+		{
+			name:     "ID token for a trusting peer",
+			clientID: "service",
+			secret:   "secret",
+			scope:    "openid audience:server:client_id:api",
+			wantCode: http.StatusOK,
+			wantAud:  "api",
+		},
+		{
+			name:     "peer doesn't trust client",
+			clientID: "service",
+			secret:   "secret",
+			scope:    "openid audience:server:client_id:webapp",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "refresh tokens not allowed",
+			clientID: "service",
+			secret:   "secret",
+			scope:    "openid offline_access",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "grant type not allowed for client",
+			clientID: "webapp",
+			secret:   "secret",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid secret",
+			clientID: "service",
+			secret:   "wrong",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+	})
+	defer httpServer.Close()
+
+	p, err := oidc.NewProvider(ctx, httpServer.URL)
+	if err != nil {
+		t.Fatalf("failed to get provider: %v", err)
+	}
+
+	for _, tc := range tests {
+		v := url.Values{}
+		v.Set("grant_type", "client_credentials")
+		v.Set("scope", tc.scope)
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(tc.clientID, tc.secret)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode {
+			t.Errorf("%s: expected %d got %d: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if rr.Code != http.StatusOK {
+			continue
+		}
+
+		var resp struct {
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
+			IDToken      string `json:"id_token"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s: failed to decode response: %v", tc.name, err)
+			continue
+		}
+		if resp.AccessToken == "" {
+			t.Errorf("%s: no access token in response", tc.name)
+		}
+		if resp.RefreshToken != "" {
+			t.Errorf("%s: unexpected refresh token in response", tc.name)
+		}
+		if tc.wantAud == "" {
+			if resp.IDToken != "" {
+				t.Errorf("%s: unexpected ID token in response", tc.name)
+			}
+			continue
+		}
+
+		verifier := p.Verifier(&oidc.Config{ClientID: tc.wantAud})
+		idToken, err := verifier.Verify(ctx, resp.IDToken)
+		if err != nil {
+			t.Errorf("%s: failed to verify ID token: %v", tc.name, err)
+			continue
+		}
+		if userID, connID, err := parseIDTokenSubject(idToken.Subject); err != nil || userID != tc.clientID || connID != "" {
+			t.Errorf("%s: expected subject of client %q got %q", tc.name, tc.clientID, idToken.Subject)
+		}
+		// Client subjects can't be mistaken for end users' subjects.
+		if sub, err := idTokenSubject(tc.clientID, "mock"); err != nil || sub == idToken.Subject {
+			t.Errorf("%s: client subject %q matches end user subject", tc.name, idToken.Subject)
+		}
+	}
+}
+
+func TestJWTAccessTokens(t *testing.T) {
+	clients := []storage.Client{
+		{
+			ID:                "service",
+			Secret:            "secret",
+			AllowedGrantTypes: []string{"client_credentials", "refresh_token"},
+		},
+		{
+			ID:                "legacy",
+			Secret:            "secret",
+			AllowedGrantTypes: []string{"client_credentials"},
+			AccessTokenFormat: accessTokenFormatOpaque,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now().UTC().Round(time.Second)
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+		c.AccessTokenFormat = accessTokenFormatJWT
+		c.Now = func() time.Time { return now }
+	})
+	defer httpServer.Close()
+
+	post := func(path, clientID string, v url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, "secret")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	accessToken := func(clientID string, v url.Values) string {
+		rr := post("/token", clientID, v)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("token request failed: %d %s", rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode token response: %v", err)
+		}
+		return resp.AccessToken
+	}
+	introspect := func(clientID, token string) map[string]interface{} {
+		rr := post("/token/introspect", clientID, url.Values{"token": {token}})
+		var result map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to decode introspection response: %v", err)
+		}
+		return result
+	}
+	userInfoStatus := func(token string) int {
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Resource servers verify access tokens with the published keys.
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/keys", nil))
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(rr.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("failed to decode keys: %v", err)
+	}
+	verify := func(token string) accessTokenClaims {
+		var claims accessTokenClaims
+		jws, err := jose.ParseSigned(token)
+		if err != nil {
+			t.Fatalf("failed to parse access token %q: %v", token, err)
+		}
+		payload, err := jws.Verify(&jwks.Keys[0])
+		if err != nil {
+			t.Fatalf("failed to verify access token: %v", err)
+		}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			t.Fatalf("failed to decode access token claims: %v", err)
+		}
+
+		header, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+		if err != nil {
+			t.Fatalf("failed to decode header: %v", err)
+		}
+		var h struct {
+			Typ string `json:"typ"`
+		}
+		if err := json.Unmarshal(header, &h); err != nil {
+			t.Fatalf("failed to decode header: %v", err)
+		}
+		if h.Typ != accessTokenType {
+			t.Errorf("expected typ %q got %q", accessTokenType, h.Typ)
+		}
+		return claims
+	}
+
+	resources := []string{"https://api.example.com", "https://other.example.com"}
+	token := accessToken("service", url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"openid"},
+		"resource":   resources,
+	})
+	claims := verify(token)
+	serviceSubject, err := idTokenSubject("service", "")
+	if err != nil {
+		t.Fatalf("failed to marshal subject: %v", err)
+	}
+	if claims.Issuer != httpServer.URL || claims.Subject != serviceSubject || claims.ClientID != "service" || claims.Scope != "openid" {
+		t.Errorf("unexpected access token claims %#v", claims)
+	}
+	if !reflect.DeepEqual([]string(claims.Audience), resources) {
+		t.Errorf("expected audience %q got %q", resources, claims.Audience)
+	}
+	if claims.Expiry != now.Add(server.idTokensValidFor).Unix() || claims.ID == "" {
+		t.Errorf("unexpected access token claims %#v", claims)
+	}
+
+	result := introspect("service", token)
+	if result["active"] != true {
+		t.Fatalf("expected JWT access token to be active: %v", result)
+	}
+	if aud, ok := result["aud"].([]interface{}); !ok || len(aud) != 2 {
+		t.Errorf("expected resources as audience, got %v", result["aud"])
+	}
+	if code := userInfoStatus(token); code != http.StatusOK {
+		t.Errorf("expected user info request with JWT access token to succeed, got %d", code)
+	}
+
+	// Revoking the access token invalidates it for dex's endpoints.
+	if rr := post("/token/revoke", "service", url.Values{"token": {token}}); rr.Code != http.StatusOK {
+		t.Fatalf("failed to revoke access token: %d %s", rr.Code, rr.Body.String())
+	}
+	if result := introspect("service", token); result["active"] != false {
+		t.Errorf("expected revoked access token to be inactive: %v", result)
+	}
+	if code := userInfoStatus(token); code != http.StatusUnauthorized {
+		t.Errorf("expected user info request with revoked access token to fail, got %d", code)
+	}
+
+	// Without resource indicators, the client is the audience.
+	claims = verify(accessToken("service", url.Values{"grant_type": {"client_credentials"}}))
+	if len(claims.Audience) != 1 || claims.Audience[0] != "service" {
+		t.Errorf("expected audience [service] got %q", claims.Audience)
+	}
+
+	// Clients can keep receiving opaque access tokens.
+	if token := accessToken("legacy", url.Values{"grant_type": {"client_credentials"}}); strings.Contains(token, ".") {
+		t.Errorf("expected opaque access token, got %q", token)
+	} else if result := introspect("legacy", token); result["active"] != true {
+		t.Errorf("expected opaque access token to be active: %v", result)
+	}
+
+	rr = post("/token", "service", url.Values{
+		"grant_type": {"client_credentials"},
+		"resource":   {"api.example.com"},
+	})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), errInvalidTarget) {
+		t.Errorf("expected invalid resource to be rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Refreshing may narrow down the resources the end user authorized, but
+	// not add others.
+	refresh := storage.RefreshToken{
+		ID:          storage.NewID(),
+		Token:       storage.NewID(),
+		ClientID:    "service",
+		ConnectorID: "mock",
+		Scopes:      []string{"openid", "offline_access"},
+		Claims:      storage.Claims{UserID: "user", Username: "jane"},
+		CreatedAt:   now,
+		LastUsed:    now,
+		Resources:   resources,
+	}
+	if err := server.storage.CreateRefresh(refresh); err != nil {
+		t.Fatal(err)
+	}
+	offlineSessions := storage.OfflineSessions{
+		UserID: "user",
+		ConnID: "mock",
+		Refresh: map[string]*storage.RefreshTokenRef{
+			"service": {ID: refresh.ID, ClientID: "service", CreatedAt: now, LastUsed: now},
+		},
+	}
+	if err := server.storage.CreateOfflineSessions(offlineSessions); err != nil {
+		t.Fatal(err)
+	}
+	rawRefresh, err := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: refresh.Token})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = post("/token", "service", url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {rawRefresh},
+		"resource":      {"https://unauthorized.example.com"},
+	})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), errInvalidTarget) {
+		t.Errorf("expected unauthorized resource to be rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	claims = verify(accessToken("service", url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {rawRefresh},
+		"resource":      {resources[1]},
+	}))
+	if len(claims.Audience) != 1 || claims.Audience[0] != resources[1] {
+		t.Errorf("expected audience [%s] got %q", resources[1], claims.Audience)
+	}
+	if claims.Subject == "user" || claims.Subject == "" {
+		t.Errorf("expected subject encoding the user and connector, got %q", claims.Subject)
+	}
+}
+
+func TestHandlePasswordGrant(t *testing.T) {
+	clients := []storage.Client{
+		{
+			ID:                "cli",
+			Secret:            "secret",
+			AllowedGrantTypes: []string{"password", "refresh_token"},
+		},
+		{
+			ID:           "webapp",
+			Secret:       "secret",
+			RedirectURIs: []string{"https://example.com/callback"},
+		},
+		{
+			ID:                "limited-scopes",
+			Secret:            "secret",
+			AllowedGrantTypes: []string{"password"},
+			AllowedScopes:     []string{"email"},
+		},
+		{
+			ID:                "limited-connectors",
+			Secret:            "secret",
+			AllowedGrantTypes: []string{"password"},
+			AllowedConnectors: []string{"github"},
+		},
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw := storage.Password{
+		Email:    "jane@example.com",
+		Username: "jane",
+		UserID:   "foobar",
+		Hash:     hash,
+	}
+
+	tests := []struct {
+		name     string
+		clientID string
+		username string
+		password string
+		scope    string
+
+		wantCode    int
+		wantRefresh bool
+	}{
+		{
+			name:     "valid password",
+			clientID: "cli",
+			username: pw.Email,
+			password: "password",
+			scope:    "openid email",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:        "valid password with refresh token",
+			clientID:    "cli",
+			username:    pw.Email,
+			password:    "password",
+			scope:       "openid email offline_access",
+			wantCode:    http.StatusOK,
+			wantRefresh: true,
+		},
+		{
+			name:     "invalid password",
+			clientID: "cli",
+			username: pw.Email,
+			password: "wrong",
+			scope:    "openid email",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing openid scope",
+			clientID: "cli",
+			username: pw.Email,
+			password: "password",
+			scope:    "email",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "grant type not allowed for client",
+			clientID: "webapp",
+			username: pw.Email,
+			password: "password",
+			scope:    "openid email",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "allowed scopes",
+			clientID: "limited-scopes",
+			username: pw.Email,
+			password: "password",
+			scope:    "openid email",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "scope not allowed for client",
+			clientID: "limited-scopes",
+			username: pw.Email,
+			password: "password",
+			scope:    "openid email groups",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "password connector not allowed for client",
+			clientID: "limited-connectors",
+			username: pw.Email,
+			password: "password",
+			scope:    "openid email",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		if err := c.Storage.CreatePassword(pw); err != nil {
+			t.Fatalf("create password: %v", err)
+		}
+		if err := c.Storage.CreateConnector(storage.Connector{ID: LocalConnector, Type: LocalConnector}); err != nil {
+			t.Fatalf("create connector: %v", err)
+		}
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+		c.PasswordConnector = LocalConnector
+	})
+	defer httpServer.Close()
+
+	for _, tc := range tests {
+		v := url.Values{}
+		v.Set("grant_type", "password")
+		v.Set("username", tc.username)
+		v.Set("password", tc.password)
+		v.Set("scope", tc.scope)
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(tc.clientID, "secret")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode {
+			t.Errorf("%s: expected %d got %d: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if rr.Code != http.StatusOK {
+			continue
+		}
+
+		var resp struct {
+			IDToken      string `json:"id_token"`
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s: failed to decode response: %v", tc.name, err)
+			continue
+		}
+		if resp.IDToken == "" {
+			t.Errorf("%s: no ID token in response", tc.name)
+		}
+		if gotRefresh := resp.RefreshToken != ""; gotRefresh != tc.wantRefresh {
+			t.Errorf("%s: expected refresh token %t got %t", tc.name, tc.wantRefresh, gotRefresh)
+		}
+		if !tc.wantRefresh {
+			continue
+		}
+		session, err := server.storage.GetOfflineSessions(pw.UserID, LocalConnector)
+		if err != nil {
+			t.Errorf("%s: failed to get offline session: %v", tc.name, err)
+			continue
+		}
+		if _, ok := session.Refresh[tc.clientID]; !ok {
+			t.Errorf("%s: no refresh token reference in offline session", tc.name)
+		}
+	}
+}
+
+// newRefreshTestServer returns a server whose clients may log in a user with
+// the password grant and refresh tokens. Its clock is controlled through now.
+func newRefreshTestServer(ctx context.Context, t *testing.T, clients []storage.Client, now *time.Time, updateConfig func(c *Config)) (*httptest.Server, *Server) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestServer(ctx, t, func(c *Config) {
+		pw := storage.Password{Email: "jane@example.com", Username: "jane", UserID: "foobar", Hash: hash}
+		if err := c.Storage.CreatePassword(pw); err != nil {
+			t.Fatalf("create password: %v", err)
+		}
+		if err := c.Storage.CreateConnector(storage.Connector{ID: LocalConnector, Type: LocalConnector}); err != nil {
+			t.Fatalf("create connector: %v", err)
+		}
+		for i := range clients {
+			clients[i].Secret = "secret"
+			clients[i].AllowedGrantTypes = []string{"password", "refresh_token"}
+		}
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+		c.PasswordConnector = LocalConnector
+		c.Now = func() time.Time { return *now }
+		updateConfig(c)
+	})
+}
+
+// requestRefreshToken makes a token request, returning the status code and the
+// refresh token in the response.
+func requestRefreshToken(t *testing.T, s *Server, clientID string, v url.Values) (int, string) {
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(v.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, "secret")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	var resp struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return rr.Code, resp.RefreshToken
+}
+
+// loginForRefreshToken uses the password grant to get a refresh token.
+func loginForRefreshToken(t *testing.T, s *Server, clientID string) string {
+	code, refreshToken := requestRefreshToken(t, s, clientID, url.Values{
+		"grant_type": {"password"},
+		"username":   {"jane@example.com"},
+		"password":   {"password"},
+		"scope":      {"openid offline_access"},
+	})
+	if code != http.StatusOK || refreshToken == "" {
+		t.Fatalf("password grant failed: %d", code)
+	}
+	return refreshToken
+}
+
+// refreshTokenRevoked reports if the client no longer has a refresh token for
+// the user.
+func refreshTokenRevoked(t *testing.T, s *Server, clientID string) bool {
+	session, err := s.storage.GetOfflineSessions("foobar", LocalConnector)
+	if err != nil {
+		t.Fatalf("failed to get offline session: %v", err)
+	}
+	_, ok := session.Refresh[clientID]
+	return !ok
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	httpServer, server := newRefreshTestServer(ctx, t, []storage.Client{{ID: "cli"}}, &now, func(c *Config) {
+		c.RefreshTokens.ReuseInterval = time.Minute
+	})
+	defer httpServer.Close()
+
+	refresh := func(refreshToken string) (int, string) {
+		return requestRefreshToken(t, server, "cli", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+	}
+
+	first := loginForRefreshToken(t, server, "cli")
+	code, second := refresh(first)
+	if code != http.StatusOK || second == "" || second == first {
+		t.Fatalf("expected refresh token to be rotated, got %d", code)
+	}
+
+	// Within the reuse interval the previous token returns the current one.
+	now = now.Add(30 * time.Second)
+	if code, got := refresh(first); code != http.StatusOK || got != second {
+		t.Fatalf("expected previous refresh token to be reusable, got %d", code)
+	}
+
+	// Afterwards, replaying it revokes the refresh token altogether.
+	now = now.Add(time.Minute)
+	if code, _ := refresh(first); code != http.StatusBadRequest {
+		t.Fatalf("expected reused refresh token to be rejected, got %d", code)
+	}
+	if !refreshTokenRevoked(t, server, "cli") {
+		t.Errorf("expected reused refresh token to be revoked")
+	}
+	if code, _ := refresh(second); code != http.StatusBadRequest {
+		t.Errorf("expected current refresh token to be revoked, got %d", code)
+	}
+
+	// Without a reuse interval, replays are never accepted.
+	server.refreshTokens.ReuseInterval = 0
+	first = loginForRefreshToken(t, server, "cli")
+	if code, _ := refresh(first); code != http.StatusOK {
+		t.Fatalf("refresh failed: %d", code)
+	}
+	if code, _ := refresh(first); code != http.StatusBadRequest {
+		t.Errorf("expected reused refresh token to be rejected, got %d", code)
+	}
+	if !refreshTokenRevoked(t, server, "cli") {
+		t.Errorf("expected reused refresh token to be revoked")
+	}
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := []storage.Client{
+		{ID: "cli"},
+		{ID: "short", RefreshTokenAbsoluteLifetime: "1h"},
+	}
+	now := time.Now()
+	httpServer, server := newRefreshTestServer(ctx, t, clients, &now, func(c *Config) {
+		c.RefreshTokens.IdleTimeout = 24 * time.Hour
+		c.RefreshTokens.AbsoluteLifetime = 48 * time.Hour
+	})
+	defer httpServer.Close()
+
+	refresh := func(clientID, refreshToken string) (int, string) {
+		return requestRefreshToken(t, server, clientID, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+	}
+
+	// Using the refresh token pushes back its idle timeout.
+	refreshToken := loginForRefreshToken(t, server, "cli")
+	for i := 0; i < 2; i++ {
+		now = now.Add(23 * time.Hour)
+		var code int
+		if code, refreshToken = refresh("cli", refreshToken); code != http.StatusOK {
+			t.Fatalf("expected refresh to succeed, got %d", code)
+		}
+	}
+	token := new(internal.RefreshToken)
+	if err := internal.Unmarshal(refreshToken, token); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := server.storage.GetRefresh(token.RefreshId)
+	if err != nil {
+		t.Fatalf("failed to get refresh token: %v", err)
+	}
+	if want := now.Add(2 * time.Hour); !stored.Expiry.Equal(want) {
+		t.Errorf("expected refresh token to expire at %v got %v", want, stored.Expiry)
+	}
+
+	// Even when in use, it expires after the absolute lifetime.
+	now = now.Add(time.Hour)
+	var code int
+	if code, refreshToken = refresh("cli", refreshToken); code != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d", code)
+	}
+	now = now.Add(90 * time.Minute)
+	if code, _ := refresh("cli", refreshToken); code != http.StatusBadRequest {
+		t.Errorf("expected refresh token to expire, got %d", code)
+	}
+	if !refreshTokenRevoked(t, server, "cli") {
+		t.Errorf("expected expired refresh token to be revoked")
+	}
+
+	// Unused refresh tokens expire after the idle timeout.
+	refreshToken = loginForRefreshToken(t, server, "cli")
+	now = now.Add(25 * time.Hour)
+	if code, _ := refresh("cli", refreshToken); code != http.StatusBadRequest {
+		t.Errorf("expected idle refresh token to expire, got %d", code)
+	}
+
+	// Clients may override the server's lifetimes.
+	refreshToken = loginForRefreshToken(t, server, "short")
+	now = now.Add(30 * time.Minute)
+	if code, refreshToken = refresh("short", refreshToken); code != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d", code)
+	}
+	now = now.Add(31 * time.Minute)
+	if code, _ := refresh("short", refreshToken); code != http.StatusBadRequest {
+		t.Errorf("expected refresh token to expire after the client's lifetime, got %d", code)
+	}
+
+	// Lengthening the lifetimes doesn't revive refresh tokens past their
+	// stored expiry, which garbage collection would remove.
+	refreshToken = loginForRefreshToken(t, server, "cli")
+	server.refreshTokens.IdleTimeout = 72 * time.Hour
+	now = now.Add(25 * time.Hour)
+	if code, _ := refresh("cli", refreshToken); code != http.StatusBadRequest {
+		t.Errorf("expected refresh token past its stored expiry to be rejected, got %d", code)
+	}
+}
+
+func TestHandleDeviceFlow(t *testing.T) {
+	clients := []storage.Client{
+		{
+			ID:                "tv",
+			Name:              "TV",
+			Public:            true,
+			AllowedGrantTypes: []string{grantTypeDeviceCode},
+		},
+		{
+			ID:           "webapp",
+			Secret:       "secret",
+			RedirectURIs: []string{"https://example.com/callback"},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+		c.Now = func() time.Time { return now }
+	})
+	defer httpServer.Close()
+
+	postForm := func(path, clientID string, v url.Values) *httptest.ResponseRecorder {
+		v.Set("client_id", clientID)
+		req := httptest.NewRequest("POST", path, strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	checkErr := func(rr *httptest.ResponseRecorder, wantErr string) {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected %d got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode error response: %v", err)
+		}
+		if resp.Error != wantErr {
+			t.Fatalf("expected error %q got %q", wantErr, resp.Error)
+		}
+	}
+
+	// Clients must be explicitly allowed to use the device grant.
+	rr := postForm("/device/code", "webapp", url.Values{"scope": {"openid"}, "client_secret": {"secret"}})
+	checkErr(rr, errUnauthorizedClient)
+
+	rr = postForm("/device/code", "tv", url.Values{"scope": {"openid email"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("device authorization request failed %d: %s", rr.Code, rr.Body.String())
+	}
+	var auth deviceAuthorization
+	if err := json.Unmarshal(rr.Body.Bytes(), &auth); err != nil {
+		t.Fatalf("failed to decode device authorization response: %v", err)
+	}
+	if auth.VerificationURI != httpServer.URL+"/device" {
+		t.Errorf("unexpected verification_uri %q", auth.VerificationURI)
+	}
+
+	poll := func() *httptest.ResponseRecorder {
+		return postForm("/token", "tv", url.Values{
+			"grant_type":  {grantTypeDeviceCode},
+			"device_code": {auth.DeviceCode},
+		})
+	}
+
+	checkErr(poll(), errAuthorizationPending)
+	// Polling again without waiting for the interval.
+	checkErr(poll(), errSlowDown)
+
+	now = now.Add(time.Minute)
+	checkErr(poll(), errAuthorizationPending)
+
+	// The end user enters the code on the verification page, logs in through
+	// the mock connector and is redirected to the device callback.
+	resp, err := http.PostForm(httpServer.URL+"/device", url.Values{"user_code": {strings.ToLower(auth.UserCode)}})
+	if err != nil {
+		t.Fatalf("failed to submit user code: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected device login to succeed, got %s", resp.Status)
+	}
+	if resp.Request.URL.Path != deviceCallbackPath {
+		t.Fatalf("expected to end up at %q, got %q", deviceCallbackPath, resp.Request.URL.Path)
+	}
+
+	rr = poll()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected token response, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &token); err != nil {
+		t.Fatalf("failed to decode token response: %v", err)
+	}
+	if token.IDToken == "" {
+		t.Errorf("no ID token in response")
+	}
+
+	// Tokens are only handed out once.
+	checkErr(poll(), errInvalidGrant)
+}
+
+func TestHandleTokenExchange(t *testing.T) {
+	clients := []storage.Client{
+		{
+			ID:                "backend",
+			Secret:            "secret",
+			AllowedGrantTypes: []string{grantTypeTokenExchange},
+		},
+		{
+			ID:           "downstream",
+			Secret:       "secret",
+			TrustedPeers: []string{"backend"},
+		},
+		{
+			ID:     "other",
+			Secret: "secret",
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+	})
+	defer httpServer.Close()
+
+	claims := storage.Claims{
+		UserID:        "1",
+		Username:      "jane",
+		Email:         "jane.doe@example.com",
+		EmailVerified: true,
+	}
+	subjectToken := func(clientID string) string {
+		idToken, _, err := server.newIDToken(storage.Client{ID: clientID}, claims, []string{"openid", "email"}, "", "", "mock", time.Time{})
+		if err != nil {
+			t.Fatalf("failed to create subject token: %v", err)
+		}
+		return idToken
+	}
+
+	tests := []struct {
+		name         string
+		clientID     string
+		subjectToken string
+		audience     string
+
+		wantCode     int
+		wantErr      string
+		wantAudience string
+	}{
+		{
+			name:         "exchange for trusting audience",
+			clientID:     "backend",
+			subjectToken: subjectToken("backend"),
+			audience:     "downstream",
+			wantCode:     http.StatusOK,
+			wantAudience: "downstream",
+		},
+		{
+			name:         "exchange without audience",
+			clientID:     "backend",
+			subjectToken: subjectToken("backend"),
+			wantCode:     http.StatusOK,
+			wantAudience: "backend",
+		},
+		{
+			name:         "audience doesn't trust client",
+			clientID:     "backend",
+			subjectToken: subjectToken("backend"),
+			audience:     "other",
+			wantCode:     http.StatusBadRequest,
+			wantErr:      errInvalidTarget,
+		},
+		{
+			name:         "subject token issued to another client",
+			clientID:     "backend",
+			subjectToken: subjectToken("other"),
+			audience:     "downstream",
+			wantCode:     http.StatusBadRequest,
+			wantErr:      errInvalidGrant,
+		},
+		{
+			name:         "subject token not signed by dex",
+			clientID:     "backend",
+			subjectToken: "foo.bar.baz",
+			audience:     "downstream",
+			wantCode:     http.StatusBadRequest,
+			wantErr:      errInvalidGrant,
+		},
+		{
+			name:         "grant type not allowed for client",
+			clientID:     "other",
+			subjectToken: subjectToken("other"),
+			wantCode:     http.StatusBadRequest,
+			wantErr:      errUnauthorizedClient,
+		},
+	}
+
+	for _, tc := range tests {
+		v := url.Values{}
+		v.Set("grant_type", grantTypeTokenExchange)
+		v.Set("subject_token", tc.subjectToken)
+		v.Set("subject_token_type", tokenTypeIDToken)
+		if tc.audience != "" {
+			v.Set("audience", tc.audience)
+		}
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(tc.clientID, "secret")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode {
+			t.Errorf("%s: expected %d got %d: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			continue
+		}
+
+		var resp struct {
+			Error           string `json:"error"`
+			AccessToken     string `json:"access_token"`
+			IssuedTokenType string `json:"issued_token_type"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s: failed to decode response: %v", tc.name, err)
+			continue
+		}
+		if resp.Error != tc.wantErr {
+			t.Errorf("%s: expected error %q got %q", tc.name, tc.wantErr, resp.Error)
+		}
+		if rr.Code != http.StatusOK {
+			continue
+		}
+		if resp.IssuedTokenType != tokenTypeIDToken {
+			t.Errorf("%s: unexpected issued_token_type %q", tc.name, resp.IssuedTokenType)
+		}
+
+		tok, err := server.verifyIDToken(resp.AccessToken)
+		if err != nil {
+			t.Errorf("%s: failed to verify issued token: %v", tc.name, err)
+			continue
+		}
+		if len(tok.Audience) != 1 || tok.Audience[0] != tc.wantAudience {
+			t.Errorf("%s: expected audience %q got %q", tc.name, tc.wantAudience, tok.Audience)
+		}
+		if tok.Email != claims.Email {
+			t.Errorf("%s: expected email %q got %q", tc.name, claims.Email, tok.Email)
+		}
+		if userID, connID, err := parseIDTokenSubject(tok.Subject); err != nil || userID != claims.UserID || connID != "mock" {
+			t.Errorf("%s: unexpected subject user=%q connector=%q", tc.name, userID, connID)
+		}
+	}
+}
+
+func TestClientAllowedConnectors(t *testing.T) {
+	const redirectURI = "https://example.com/callback"
+	clients := []storage.Client{
+		{ID: "any", Secret: "secret", RedirectURIs: []string{redirectURI}},
+		{ID: "limited", Secret: "secret", RedirectURIs: []string{redirectURI}, AllowedConnectors: []string{"other"}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		if err := c.Storage.CreateConnector(storage.Connector{ID: "other", Type: "mockCallback", Name: "Other"}); err != nil {
+			t.Fatalf("create connector: %v", err)
+		}
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+	})
+	defer httpServer.Close()
+
+	authorize := func(clientID string) *httptest.ResponseRecorder {
+		v := url.Values{
+			"client_id":     {clientID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+			"state":         {"state"},
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", "/auth?"+v.Encode(), nil))
+		return rr
+	}
+
+	// Clients that aren't restricted can pick any connector.
+	rr := authorize("any")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected login page, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, name := range []string{"Mock", "Other"} {
+		if !strings.Contains(rr.Body.String(), name) {
+			t.Errorf("expected login page to list connector %q", name)
+		}
+	}
+
+	// With only one connector allowed, the end user is sent straight to it.
+	rr = authorize("limited")
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected redirect to the only allowed connector, got %d: %s", rr.Code, rr.Body.String())
+	}
+	u, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse redirect: %v", err)
+	}
+	if !strings.HasSuffix(u.Path, "/auth/other") {
+		t.Fatalf("expected redirect to connector %q, got %q", "other", u.Path)
+	}
+
+	// Other connectors can't be used by picking them directly.
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/auth/mock?"+u.RawQuery, nil))
+	if !strings.Contains(rr.Body.String(), "Connector not allowed for this client.") {
+		t.Errorf("expected connector to be rejected, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/auth/other?"+u.RawQuery, nil))
+	if rr.Code != http.StatusFound {
+		t.Errorf("expected allowed connector to start the login, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestIntrospectPublicClient(t *testing.T) {
+	clients := []storage.Client{
+		{ID: "service", Secret: "secret", AllowedGrantTypes: []string{"client_credentials"}},
+		{ID: "public", Public: true, RedirectURIs: []string{"https://example.com/callback"}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+	})
+	defer httpServer.Close()
+
+	post := func(path string, v url.Values, auth func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		auth(req)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("/token", url.Values{"grant_type": {"client_credentials"}}, func(r *http.Request) { r.SetBasicAuth("service", "secret") })
+	if rr.Code != http.StatusOK {
+		t.Fatalf("token request failed: %d %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode token response: %v", err)
+	}
+
+	// Public clients have no secret, so anyone knowing their ID could use them
+	// to read other clients' tokens.
+	for name, auth := range map[string]func(r *http.Request){
+		"client_id only":   func(r *http.Request) {},
+		"empty basic auth": func(r *http.Request) { r.SetBasicAuth("public", "") },
+	} {
+		rr := post("/token/introspect", url.Values{"token": {resp.AccessToken}, "client_id": {"public"}}, auth)
+		if rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), errInvalidClient) {
+			t.Errorf("%s: expected public client to be rejected, got %d %s", name, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestIntrospectOtherClientsTokens(t *testing.T) {
+	clients := []storage.Client{
+		{ID: "service", Secret: "secret", AllowedGrantTypes: []string{"client_credentials"}},
+		{ID: "other", Secret: "secret", AllowedGrantTypes: []string{"client_credentials"}},
+		{ID: "resource-server", Secret: "secret"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+		c.IntrospectionClients = []string{"resource-server"}
+	})
+	defer httpServer.Close()
+
+	post := func(path, clientID string, v url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, "secret")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("/token", "service", url.Values{"grant_type": {"client_credentials"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("token request failed: %d %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode token response: %v", err)
+	}
+
+	for clientID, wantActive := range map[string]bool{
+		"service":         true,
+		"other":           false,
+		"resource-server": true,
+	} {
+		rr := post("/token/introspect", clientID, url.Values{"token": {resp.AccessToken}})
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: introspection failed: %d %s", clientID, rr.Code, rr.Body.String())
+			continue
+		}
+		var result map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Errorf("%s: failed to decode introspection response: %v", clientID, err)
+			continue
+		}
+		if result["active"] != wantActive {
+			t.Errorf("%s: expected active=%t, got %v", clientID, wantActive, result)
+		}
+		if !wantActive && len(result) != 1 {
+			t.Errorf("%s: expected no claims for an inactive token, got %v", clientID, result)
+		}
+	}
+}
+
+func TestPublicClientRefreshRequiresSecret(t *testing.T) {
+	clients := []storage.Client{
+		{ID: "public", Public: true, RedirectURIs: []string{"https://example.com/callback"}},
+		{ID: "native", Secret: "secret", Public: true, RedirectURIs: []string{"https://example.com/callback"}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+	})
+	defer httpServer.Close()
+
+	tests := []struct {
+		clientID, secret string
+		wantCode         int
+	}{
+		{"public", "", http.StatusUnauthorized},
+		{"native", "", http.StatusUnauthorized},
+		{"native", "wrong", http.StatusUnauthorized},
+		// Authenticated, but the refresh token is invalid.
+		{"native", "secret", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		v := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"invalid"}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(tc.clientID, tc.secret)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode {
+			t.Errorf("%s with secret %q: expected %d got %d: %s", tc.clientID, tc.secret, tc.wantCode, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"time"
+
+	"github.com/coreos/dex/storage"
+)
+
+// How long authorization codes are valid for if the client doesn't configure
+// its own lifetime.
+const defaultAuthCodeValidFor = 30 * time.Minute
+
+// idTokenLifetime returns how long ID tokens issued to the client are valid for.
+func (s *Server) idTokenLifetime(client storage.Client) time.Duration {
+	return s.signedTokenLifetime(client.ID, "ID token", client.IDTokenLifetime)
+}
+
+// accessTokenLifetime returns how long access tokens issued to the client are
+// valid for. Unless the client overrides it, the same as its ID tokens.
+func (s *Server) accessTokenLifetime(client storage.Client) time.Duration {
+	if client.AccessTokenLifetime == "" {
+		return s.idTokenLifetime(client)
+	}
+	return s.signedTokenLifetime(client.ID, "access token", client.AccessTokenLifetime)
+}
+
+// authCodeLifetime returns how long authorization codes issued to the client
+// can be redeemed for.
+func (s *Server) authCodeLifetime(client storage.Client) time.Duration {
+	return s.clientLifetime(client.ID, "authorization code", client.AuthCodeLifetime, defaultAuthCodeValidFor)
+}
+
+// signedTokenLifetime parses the lifetime of a token signed with the server's
+// keys. Clients can't configure lifetimes longer than the server keeps the
+// keys to verify the tokens around.
+func (s *Server) signedTokenLifetime(clientID, kind, lifetime string) time.Duration {
+	d := s.clientLifetime(clientID, kind, lifetime, s.idTokensValidFor)
+	if d > s.maxTokensValidFor {
+		s.logger.Warnf("client %s %s lifetime %v exceeds the maximum of %v", clientID, kind, d, s.maxTokensValidFor)
+		return s.maxTokensValidFor
+	}
+	return d
+}
+
+// clientLifetime parses a lifetime configured for a client, falling back to the
+// server's if the client doesn't configure one.
+func (s *Server) clientLifetime(clientID, kind, lifetime string, defaultLifetime time.Duration) time.Duration {
+	if lifetime == "" {
+		return defaultLifetime
+	}
+	d, err := time.ParseDuration(lifetime)
+	if err != nil || d <= 0 {
+		s.logger.Errorf("client %s has invalid %s lifetime %q", clientID, kind, lifetime)
+		return defaultLifetime
+	}
+	return d
+}
+
+// maxTokensValidFor returns the longest lifetime of tokens signed by the
+// server, which is how long it must keep the keys to verify them around.
+func maxTokensValidFor(c Config) time.Duration {
+	idTokensValidFor := value(c.IDTokensValidFor, 24*time.Hour)
+	if c.MaxTokensValidFor < idTokensValidFor {
+		return idTokensValidFor
+	}
+	return c.MaxTokensValidFor
+}
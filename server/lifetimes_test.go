@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coreos/dex/storage"
+)
+
+func TestClientTokenLifetimes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.IDTokensValidFor = time.Hour
+		c.MaxTokensValidFor = 3 * time.Hour
+		c.Now = func() time.Time { return now }
+	})
+	defer httpServer.Close()
+
+	tests := []struct {
+		name   string
+		client storage.Client
+
+		wantIDToken     time.Duration
+		wantAccessToken time.Duration
+		wantAuthCode    time.Duration
+	}{
+		{
+			name:            "server defaults",
+			client:          storage.Client{ID: "default"},
+			wantIDToken:     time.Hour,
+			wantAccessToken: time.Hour,
+			wantAuthCode:    30 * time.Minute,
+		},
+		{
+			name:            "short lived tokens",
+			client:          storage.Client{ID: "short", IDTokenLifetime: "10m", AuthCodeLifetime: "1m"},
+			wantIDToken:     10 * time.Minute,
+			wantAccessToken: 10 * time.Minute,
+			wantAuthCode:    time.Minute,
+		},
+		{
+			name:            "access token override",
+			client:          storage.Client{ID: "access", AccessTokenLifetime: "2h"},
+			wantIDToken:     time.Hour,
+			wantAccessToken: 2 * time.Hour,
+			wantAuthCode:    30 * time.Minute,
+		},
+		{
+			name:            "capped at the maximum",
+			client:          storage.Client{ID: "long", IDTokenLifetime: "48h"},
+			wantIDToken:     3 * time.Hour,
+			wantAccessToken: 3 * time.Hour,
+			wantAuthCode:    30 * time.Minute,
+		},
+		{
+			name:            "invalid lifetimes",
+			client:          storage.Client{ID: "invalid", IDTokenLifetime: "forever", AuthCodeLifetime: "-1m"},
+			wantIDToken:     time.Hour,
+			wantAccessToken: time.Hour,
+			wantAuthCode:    30 * time.Minute,
+		},
+	}
+
+	for _, tc := range tests {
+		_, expiry, err := server.newIDToken(tc.client, storage.Claims{UserID: "user"}, []string{"openid"}, "", "", "mock", time.Time{})
+		if err != nil {
+			t.Errorf("%s: failed to create ID token: %v", tc.name, err)
+			continue
+		}
+		if got := expiry.Sub(now); got != tc.wantIDToken {
+			t.Errorf("%s: expected ID token to be valid for %v, got %v", tc.name, tc.wantIDToken, got)
+		}
+		_, expiry, err = server.newAccessToken(tc.client, storage.Claims{UserID: "user"}, []string{"openid"}, nil, "mock")
+		if err != nil {
+			t.Errorf("%s: failed to create access token: %v", tc.name, err)
+			continue
+		}
+		if got := expiry.Sub(now); got != tc.wantAccessToken {
+			t.Errorf("%s: expected access token to be valid for %v, got %v", tc.name, tc.wantAccessToken, got)
+		}
+		if got := server.authCodeLifetime(tc.client); got != tc.wantAuthCode {
+			t.Errorf("%s: expected authorization code to be valid for %v, got %v", tc.name, tc.wantAuthCode, got)
+		}
+	}
+}
+
+func TestMaxTokensValidFor(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   time.Duration
+	}{
+		{Config{}, 24 * time.Hour},
+		{Config{IDTokensValidFor: time.Hour}, time.Hour},
+		{Config{IDTokensValidFor: time.Hour, MaxTokensValidFor: 72 * time.Hour}, 72 * time.Hour},
+		{Config{IDTokensValidFor: 48 * time.Hour, MaxTokensValidFor: time.Hour}, 48 * time.Hour},
+	}
+	for _, tc := range tests {
+		if got := maxTokensValidFor(tc.config); got != tc.want {
+			t.Errorf("%+v: expected signing keys to be kept for %v, got %v", tc.config, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+
+	"github.com/coreos/dex/storage"
+)
+
+// LogoutConfig holds the options for clients logging end users out.
+type LogoutConfig struct {
+	// If true, logging out through a client also revokes the refresh token the
+	// client holds for the end user.
+	RevokeRefreshTokens bool
+
+	// How many times to try delivering a back-channel logout notification to a
+	// client before dropping it. Defaults to 5.
+	BackchannelMaxAttempts int
+}
+
+// logoutParams are the request parameters carried over when the end user is
+// asked to confirm they want to log out.
+var logoutParams = []string{"id_token_hint", "client_id", "post_logout_redirect_uri", "state"}
+
+// logoutConfirmation returns the value the logout confirmation form posts back.
+// It's derived from the session ID, so other sites can't forge the form.
+func logoutConfirmation(sessionID string) string {
+	h := sha256.Sum256([]byte("dex logout confirmation\x00" + sessionID))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// handleLogout lets a client log the end user out of dex, ending their browser
+// session.
+//
+// Since anyone can send the end user here, the session is only ended if the
+// id_token_hint names the session's end user, or if the end user confirms the
+// logout. Without a session there's nothing to end, and no clients are told of
+// the logout.
+//
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		s.renderError(w, http.StatusBadRequest, "Unsupported request method.")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.renderError(w, http.StatusBadRequest, "Failed to parse request.")
+		return
+	}
+	clientID := r.Form.Get("client_id")
+	redirectURI := r.Form.Get("post_logout_redirect_uri")
+
+	var subject string
+	if hint := r.Form.Get("id_token_hint"); hint != "" {
+		// The end user may well log out after their ID Token expired.
+		tok, err := s.parseIDToken(hint)
+		if err != nil {
+			s.logger.Infof("invalid id_token_hint: %v", err)
+			s.renderError(w, http.StatusBadRequest, "Invalid id_token_hint.")
+			return
+		}
+		tokClientID := tok.issuedTo()
+		switch {
+		case clientID == "":
+			clientID = tokClientID
+		case clientID != tokClientID && !tok.Audience.contains(clientID):
+			s.renderError(w, http.StatusBadRequest, "The id_token_hint wasn't issued to this client.")
+			return
+		}
+		if subject, err = s.localSubjectFor(tokClientID, tok.Subject); err != nil {
+			s.logger.Infof("invalid id_token_hint subject: %v", err)
+			s.renderError(w, http.StatusBadRequest, "Invalid id_token_hint.")
+			return
+		}
+	}
+
+	if redirectURI != "" {
+		if clientID == "" {
+			s.renderError(w, http.StatusBadRequest, "A post_logout_redirect_uri requires an id_token_hint or client_id.")
+			return
+		}
+		client, err := s.storage.GetClient(clientID)
+		if err != nil {
+			if err == storage.ErrNotFound {
+				s.renderError(w, http.StatusBadRequest, "Invalid client_id.")
+			} else {
+				s.logger.Errorf("failed to get client %q: %v", clientID, err)
+				s.renderError(w, http.StatusInternalServerError, "Database error.")
+			}
+			return
+		}
+		registered := false
+		for _, uri := range client.PostLogoutRedirectURIs {
+			if uri == redirectURI {
+				registered = true
+				break
+			}
+		}
+		if !registered {
+			s.renderError(w, http.StatusBadRequest, "Unregistered post_logout_redirect_uri.")
+			return
+		}
+	}
+
+	if session, ok := s.currentSession(r); ok {
+		sessionSubject, err := idTokenSubject(session.Claims.UserID, session.ConnectorID)
+		if err != nil {
+			s.logger.Errorf("failed to marshal session subject: %v", err)
+			s.renderError(w, http.StatusInternalServerError, "Internal server error.")
+			return
+		}
+
+		confirmation := logoutConfirmation(session.ID)
+		confirmed := subject == sessionSubject ||
+			(r.Method == "POST" && subtle.ConstantTimeCompare([]byte(r.PostForm.Get("confirm")), []byte(confirmation)) == 1)
+		if !confirmed {
+			params := url.Values{}
+			for _, name := range logoutParams {
+				if v := r.Form.Get(name); v != "" {
+					params.Set(name, v)
+				}
+			}
+			if err := s.templates.logoutConfirm(w, s.absPath("/logout"), params, confirmation); err != nil {
+				s.logger.Errorf("Server template error: %v", err)
+			}
+			return
+		}
+		s.endSession(w, r)
+		s.notifyLogout(session, sessionSubject, clientID)
+	}
+
+	if redirectURI == "" {
+		if err := s.templates.logout(w); err != nil {
+			s.logger.Errorf("Server template error: %v", err)
+		}
+		return
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		s.renderError(w, http.StatusBadRequest, "Invalid post_logout_redirect_uri.")
+		return
+	}
+	if state := r.Form.Get("state"); state != "" {
+		q := u.Query()
+		q.Set("state", state)
+		u.RawQuery = q.Encode()
+	}
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// notifyLogout tells every client the end user authorized through the session,
+// or which holds a refresh token for them, of the logout over the back-channel.
+// If configured to, it also revokes the refresh token of the client logging the
+// end user out.
+func (s *Server) notifyLogout(session storage.Session, subject, clientID string) {
+	notifyClients := append([]string(nil), session.ClientIDs...)
+	if o, err := s.storage.GetOfflineSessions(session.Claims.UserID, session.ConnectorID); err == nil {
+		for id := range o.Refresh {
+			notifyClients = append(notifyClients, id)
+		}
+	} else if err != storage.ErrNotFound {
+		s.logger.Errorf("failed to get offline sessions: %v", err)
+	}
+	if err := queueLogoutNotifications(s.storage, s.now(), subject, notifyClients); err != nil {
+		s.logger.Errorf("failed to queue logout notifications: %v", err)
+	}
+
+	if s.logout.RevokeRefreshTokens && clientID != "" {
+		if err := s.revokeRefreshToken(session.Claims.UserID, session.ConnectorID, clientID); err != nil {
+			s.logger.Errorf("failed to revoke refresh token on logout: %v", err)
+		}
+	}
+}
+
+// revokeRefreshToken deletes the refresh token a client holds for an end user,
+// if any.
+func (s *Server) revokeRefreshToken(userID, connID, clientID string) error {
+	var refreshID string
+	updater := func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+		if ref, ok := old.Refresh[clientID]; ok {
+			refreshID = ref.ID
+			delete(old.Refresh, clientID)
+		}
+		return old, nil
+	}
+	if err := s.storage.UpdateOfflineSessions(userID, connID, updater); err != nil {
+		if err == storage.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	if refreshID == "" {
+		return nil
+	}
+	if err := s.storage.DeleteRefresh(refreshID); err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	return nil
+}
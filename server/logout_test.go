@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/dex/storage"
+)
+
+func TestLogout(t *testing.T) {
+	clients := []storage.Client{
+		{
+			ID:                     "app",
+			Secret:                 "secret",
+			RedirectURIs:           []string{"https://app.example.com/callback"},
+			PostLogoutRedirectURIs: []string{"https://app.example.com/logged-out"},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+		c.Sessions = SessionConfig{Enabled: true}
+		c.Logout = LogoutConfig{RevokeRefreshTokens: true}
+	})
+	defer httpServer.Close()
+
+	claims := storage.Claims{UserID: "user"}
+	idToken, _, err := server.newIDToken(storage.Client{ID: "app"}, claims, []string{"openid"}, "", "", "mock", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+	otherIDToken, _, err := server.newIDToken(storage.Client{ID: "app"}, storage.Claims{UserID: "other"}, []string{"openid"}, "", "", "mock", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+
+	now := server.now()
+	session := storage.Session{
+		ID:          storage.NewID(),
+		ConnectorID: "mock",
+		Claims:      claims,
+		CreatedAt:   now,
+		LastUsed:    now,
+		Expiry:      now.Add(time.Hour),
+	}
+	if err := server.storage.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	refresh := storage.RefreshToken{
+		ID:          storage.NewID(),
+		Token:       storage.NewID(),
+		ClientID:    "app",
+		ConnectorID: "mock",
+		Claims:      claims,
+	}
+	if err := server.storage.CreateRefresh(refresh); err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+	offlineSessions := storage.OfflineSessions{
+		UserID:  "user",
+		ConnID:  "mock",
+		Refresh: map[string]*storage.RefreshTokenRef{"app": {ID: refresh.ID, ClientID: "app"}},
+	}
+	if err := server.storage.CreateOfflineSessions(offlineSessions); err != nil {
+		t.Fatalf("failed to create offline sessions: %v", err)
+	}
+
+	logout := func(params url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/logout?"+params.Encode(), nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session.ID})
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	postLogout := func(params url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/logout", strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session.ID})
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	badRequests := []struct {
+		name   string
+		params url.Values
+	}{
+		{
+			name: "unregistered post logout redirect URI",
+			params: url.Values{
+				"id_token_hint":            {idToken},
+				"post_logout_redirect_uri": {"https://evil.com/logged-out"},
+			},
+		},
+		{
+			name:   "post logout redirect URI without client",
+			params: url.Values{"post_logout_redirect_uri": {"https://app.example.com/logged-out"}},
+		},
+		{
+			name:   "invalid id_token_hint",
+			params: url.Values{"id_token_hint": {"not-a-token"}},
+		},
+		{
+			name:   "id_token_hint for another client",
+			params: url.Values{"id_token_hint": {idToken}, "client_id": {"other-app"}},
+		},
+	}
+	for _, tc := range badRequests {
+		// Errors are rendered to the end user rather than redirecting.
+		rr := logout(tc.params)
+		if location := rr.Header().Get("Location"); location != "" {
+			t.Errorf("%s: expected request to be rejected, got redirect to %q", tc.name, location)
+		}
+		if !strings.Contains(rr.Body.String(), http.StatusText(http.StatusBadRequest)) {
+			t.Errorf("%s: expected error page, got %s", tc.name, rr.Body.String())
+		}
+	}
+	if _, err := server.storage.GetSession(session.ID); err != nil {
+		t.Fatalf("rejected logout requests ended the session: %v", err)
+	}
+
+	// Requests which don't show they come from the end user ask them to
+	// confirm the logout instead.
+	unconfirmed := []struct {
+		name string
+		rr   *httptest.ResponseRecorder
+	}{
+		{"no id_token_hint", logout(url.Values{})},
+		{"id_token_hint of another user", logout(url.Values{"id_token_hint": {otherIDToken}})},
+		{"POST without confirmation", postLogout(url.Values{})},
+		{"POST with wrong confirmation", postLogout(url.Values{"confirm": {logoutConfirmation("other-session")}})},
+	}
+	for _, tc := range unconfirmed {
+		if rr := tc.rr; rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), logoutConfirmation(session.ID)) {
+			t.Errorf("%s: expected confirmation page, got %d: %s", tc.name, rr.Code, rr.Body.String())
+		}
+	}
+	if _, err := server.storage.GetSession(session.ID); err != nil {
+		t.Fatalf("unconfirmed logout requests ended the session: %v", err)
+	}
+	if _, err := server.storage.GetRefresh(refresh.ID); err != nil {
+		t.Fatalf("unconfirmed logout requests revoked the refresh token: %v", err)
+	}
+
+	rr := logout(url.Values{
+		"id_token_hint":            {idToken},
+		"post_logout_redirect_uri": {"https://app.example.com/logged-out"},
+		"state":                    {"state"},
+	})
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected %d got %d: %s", http.StatusFound, rr.Code, rr.Body.String())
+	}
+	if got, want := rr.Header().Get("Location"), "https://app.example.com/logged-out?state=state"; got != want {
+		t.Errorf("expected redirect to %q got %q", want, got)
+	}
+
+	if _, err := server.storage.GetSession(session.ID); err != storage.ErrNotFound {
+		t.Errorf("expected session to be deleted, got %v", err)
+	}
+	if _, err := server.storage.GetRefresh(refresh.ID); err != storage.ErrNotFound {
+		t.Errorf("expected refresh token to be revoked, got %v", err)
+	}
+	cleared := false
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == sessionCookieName && cookie.MaxAge < 0 {
+			cleared = true
+		}
+	}
+	if !cleared {
+		t.Errorf("expected session cookie to be cleared")
+	}
+
+	// Without a session, an old ID token can't be used to revoke the end
+	// user's refresh tokens.
+	refresh.ID = storage.NewID()
+	if err := server.storage.CreateRefresh(refresh); err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+	updater := func(o storage.OfflineSessions) (storage.OfflineSessions, error) {
+		o.Refresh["app"] = &storage.RefreshTokenRef{ID: refresh.ID, ClientID: "app"}
+		return o, nil
+	}
+	if err := server.storage.UpdateOfflineSessions("user", "mock", updater); err != nil {
+		t.Fatalf("failed to update offline sessions: %v", err)
+	}
+	if rr := logout(url.Values{"id_token_hint": {idToken}}); rr.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, rr.Code)
+	}
+	if _, err := server.storage.GetRefresh(refresh.ID); err != nil {
+		t.Errorf("logout without a session revoked the refresh token: %v", err)
+	}
+
+	// Without a redirect URI, the end user is shown a page instead.
+	if rr := logout(url.Values{}); rr.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, rr.Code)
+	}
+}
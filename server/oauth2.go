@@ -3,9 +3,11 @@ package server
 import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -19,6 +21,7 @@ import (
 	"strings"
 	"time"
 
+	"golang.org/x/crypto/ed25519"
 	jose "gopkg.in/square/go-jose.v2"
 
 	"github.com/coreos/dex/connector"
@@ -31,10 +34,11 @@ import (
 // authErr is an error response to an authorization request.
 // See: https://tools.ietf.org/html/rfc6749#section-4.1.2.1
 type authErr struct {
-	State       string
-	RedirectURI string
-	Type        string
-	Description string
+	State        string
+	RedirectURI  string
+	Type         string
+	Description  string
+	ResponseMode string
 }
 
 func (err *authErr) Status() int {
@@ -48,7 +52,7 @@ func (err *authErr) Error() string {
 	return err.Description
 }
 
-func (err *authErr) Handle() (http.Handler, bool) {
+func (err *authErr) Handle(s *Server) (http.Handler, bool) {
 	// Didn't get a valid redirect URI.
 	if err.RedirectURI == "" {
 		return nil, false
@@ -61,17 +65,45 @@ func (err *authErr) Handle() (http.Handler, bool) {
 		if err.Description != "" {
 			v.Add("error_description", err.Description)
 		}
-		var redirectURI string
-		if strings.Contains(err.RedirectURI, "?") {
-			redirectURI = err.RedirectURI + "&" + v.Encode()
-		} else {
-			redirectURI = err.RedirectURI + "?" + v.Encode()
-		}
-		http.Redirect(w, r, redirectURI, http.StatusSeeOther)
+		s.sendAuthResponse(w, r, err.RedirectURI, err.ResponseMode, v)
 	}
 	return http.HandlerFunc(hf), true
 }
 
+// sendAuthResponse returns the parameters of an authorization response to the
+// client's redirect URI, in the way the response mode asks for. Without a
+// response mode, the parameters are added to the query.
+func (s *Server) sendAuthResponse(w http.ResponseWriter, r *http.Request, redirectURI, responseMode string, v url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		s.renderError(w, http.StatusInternalServerError, "Invalid redirect URI.")
+		return
+	}
+
+	switch responseMode {
+	case responseModeFormPost:
+		// The user agent POSTs the parameters to the redirect URI, so they never
+		// show up in URLs or the browser history.
+		//
+		// https://openid.net/specs/oauth-v2-form-post-response-mode-1_0.html
+		w.Header().Set("Cache-Control", "no-cache, no-store")
+		w.Header().Set("Pragma", "no-cache")
+		if err := s.templates.formPost(w, redirectURI, v); err != nil {
+			s.logger.Errorf("Server template error: %v", err)
+		}
+		return
+	case responseModeFragment:
+		u.Fragment = v.Encode()
+	default:
+		q := u.Query()
+		for name, values := range v {
+			q[name] = values
+		}
+		u.RawQuery = q.Encode()
+	}
+	http.Redirect(w, r, u.String(), http.StatusSeeOther)
+}
+
 func tokenErr(w http.ResponseWriter, typ, description string, statusCode int) error {
 	data := struct {
 		Error       string `json:"error"`
@@ -99,6 +131,50 @@ const (
 	errUnsupportedGrantType    = "unsupported_grant_type"
 	errInvalidGrant            = "invalid_grant"
 	errInvalidClient           = "invalid_client"
+
+	// Returned by resource endpoints such as the UserInfo endpoint.
+	//
+	// https://tools.ietf.org/html/rfc6750#section-3.1
+	errInvalidToken = "invalid_token"
+
+	// Returned while a client polls for the result of a device authorization grant.
+	//
+	// https://tools.ietf.org/html/rfc8628#section-3.5
+	errAuthorizationPending = "authorization_pending"
+	errSlowDown             = "slow_down"
+	errExpiredToken         = "expired_token"
+
+	// Returned when a token exchange request names an audience the client
+	// can't get tokens for, or a request names an invalid resource.
+	//
+	// https://tools.ietf.org/html/rfc8693#section-2.2.2
+	// https://tools.ietf.org/html/rfc8707#section-2
+	errInvalidTarget = "invalid_target"
+
+	// Returned by the auth endpoint when the client asked for no pages to be
+	// displayed, but the end user would have to log in or approve the request.
+	//
+	// https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+	errLoginRequired   = "login_required"
+	errConsentRequired = "consent_required"
+
+	// Returned by the auth endpoint when a pushed authorization request can't
+	// be found, or a signed request object is invalid.
+	//
+	// https://tools.ietf.org/html/rfc9126#section-4
+	// https://tools.ietf.org/html/rfc9101#section-7
+	errInvalidRequestURI    = "invalid_request_uri"
+	errInvalidRequestObject = "invalid_request_object"
+)
+
+// Values of the "prompt" parameter of authorization requests.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+const (
+	promptNone          = "none"
+	promptLogin         = "login"
+	promptConsent       = "consent"
+	promptSelectAccount = "select_account"
 )
 
 const (
@@ -117,6 +193,149 @@ const (
 const (
 	grantTypeAuthorizationCode = "authorization_code"
 	grantTypeRefreshToken      = "refresh_token"
+	grantTypeClientCredentials = "client_credentials"
+	grantTypePassword          = "password"
+	grantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	grantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// Token type identifiers used by the token exchange grant.
+//
+// https://tools.ietf.org/html/rfc8693#section-3
+const (
+	tokenTypeIDToken = "urn:ietf:params:oauth:token-type:id_token"
+	tokenTypeJWT     = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// defaultGrantTypes are the grant types a client may use if it doesn't list
+// any allowed grant types.
+var defaultGrantTypes = []string{grantTypeAuthorizationCode, grantTypeRefreshToken}
+
+// clientAllowsGrantType reports if the client may use the grant type at the
+// token endpoint.
+func clientAllowsGrantType(client storage.Client, grantType string) bool {
+	allowed := client.AllowedGrantTypes
+	if len(allowed) == 0 {
+		allowed = defaultGrantTypes
+	}
+	for _, g := range allowed {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAllowsConnector reports if end users may log in to the client through
+// the connector.
+func clientAllowsConnector(client storage.Client, connID string) bool {
+	return allowedBy(client.AllowedConnectors, connID)
+}
+
+// clientAllowsScope reports if the client may request the scope. Every client
+// may request the "openid" scope.
+func clientAllowsScope(client storage.Client, scope string) bool {
+	return scope == scopeOpenID || allowedBy(client.AllowedScopes, scope)
+}
+
+// clientAllowsResponseType reports if the client may use the response type at
+// the authorization endpoint.
+func clientAllowsResponseType(client storage.Client, responseType string) bool {
+	return allowedBy(client.AllowedResponseTypes, responseType)
+}
+
+// allowedBy reports if value is in the allowed list. An empty list allows
+// everything.
+func allowedBy(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// How long a device code may be used to poll the token endpoint, and the
+	// minimum number of seconds a client must wait between polling requests.
+	deviceCodeValidFor     = 5 * time.Minute
+	deviceCodePollInterval = 5
+
+	// The path end users are redirected to after approving a device
+	// authorization request.
+	deviceCallbackPath = "/device/callback"
+
+	// User codes are drawn from consonants only to avoid spelling words and
+	// to be easy to type on constrained keyboards.
+	//
+	// https://tools.ietf.org/html/rfc8628#section-6.1
+	userCodeCharset = "bcdfghjklmnpqrstvwxz"
+	userCodeLength  = 8
+)
+
+// newUserCode returns a random user code for a device authorization request.
+// The code is returned in its normalized form, see normalizeUserCode.
+func newUserCode() string {
+	code := make([]byte, 0, userCodeLength)
+	b := make([]byte, 1)
+	for len(code) < userCodeLength {
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			panic(err)
+		}
+		// Discard values that would bias the modulo below.
+		if int(b[0]) >= 256-256%len(userCodeCharset) {
+			continue
+		}
+		code = append(code, userCodeCharset[int(b[0])%len(userCodeCharset)])
+	}
+	return string(code)
+}
+
+// normalizeUserCode maps a user code typed by an end user, such as "BCDF-GHJK",
+// to the form used to store it.
+func normalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r
+		case 'A' <= r && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return -1
+		}
+	}, code)
+}
+
+// formatUserCode formats a normalized user code for display, e.g. "BCDF-GHJK".
+func formatUserCode(code string) string {
+	code = strings.ToUpper(code)
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// The "device_code" handed to the client is the user code followed by a
+// secret. The user code is used to look up the device request and the secret
+// proves the client is the one which started the flow.
+func newDeviceCode(userCode, secret string) string {
+	return userCode + "." + secret
+}
+
+func parseDeviceCode(deviceCode string) (userCode, secret string, ok bool) {
+	parts := strings.SplitN(deviceCode, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+const (
+	codeChallengeMethodPlain = "plain"
+	codeChallengeMethodS256  = "S256"
 )
 
 const (
@@ -125,6 +344,16 @@ const (
 	responseTypeIDToken = "id_token" // ID Token in url fragment
 )
 
+// Response modes define how the parameters of an authorization response are
+// returned to the client.
+//
+// https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#ResponseModes
+const (
+	responseModeQuery    = "query"
+	responseModeFragment = "fragment"
+	responseModeFormPost = "form_post"
+)
+
 func parseScopes(scopes []string) connector.Scopes {
 	var s connector.Scopes
 	for _, scope := range scopes {
@@ -147,14 +376,11 @@ func signatureAlgorithm(jwk *jose.JSONWebKey) (alg jose.SignatureAlgorithm, err
 	case *rsa.PrivateKey:
 		// Because OIDC mandates that we support RS256, we always return that
 		// value. In the future, we might want to make this configurable on a
-		// per client basis. For example allowing PS256 or ECDSA variants.
+		// per client basis. For example allowing PS256.
 		//
 		// See https://github.com/coreos/dex/issues/692
 		return jose.RS256, nil
 	case *ecdsa.PrivateKey:
-		// We don't actually support ECDSA keys yet, but they're tested for
-		// in case we want to in the future.
-		//
 		// These values are prescribed depending on the ECDSA key type. We
 		// can't return different values.
 		switch key.Params() {
@@ -167,15 +393,21 @@ func signatureAlgorithm(jwk *jose.JSONWebKey) (alg jose.SignatureAlgorithm, err
 		default:
 			return alg, errors.New("unsupported ecdsa curve")
 		}
+	case ed25519.PrivateKey:
+		return jose.EdDSA, nil
 	default:
 		return alg, fmt.Errorf("unsupported signing key type %T", key)
 	}
 }
 
-func signPayload(key *jose.JSONWebKey, alg jose.SignatureAlgorithm, payload []byte) (jws string, err error) {
+func signPayload(key *jose.JSONWebKey, alg jose.SignatureAlgorithm, payload []byte, typ string) (jws string, err error) {
 	signingKey := jose.SigningKey{Key: key, Algorithm: alg}
 
-	signer, err := jose.NewSigner(signingKey, &jose.SignerOptions{})
+	opts := &jose.SignerOptions{}
+	if typ != "" {
+		opts = opts.WithType(jose.ContentType(typ))
+	}
+	signer, err := jose.NewSigner(signingKey, opts)
 	if err != nil {
 		return "", fmt.Errorf("new signier: %v", err)
 	}
@@ -201,6 +433,9 @@ var hashForSigAlg = map[jose.SignatureAlgorithm]func() hash.Hash{
 	jose.ES256: sha256.New,
 	jose.ES384: sha512.New384,
 	jose.ES512: sha512.New,
+	// EdDSA names no hash of its own. Ed25519 uses SHA-512 internally, so
+	// that's what's used for the at_hash, as other providers do.
+	jose.EdDSA: sha512.New,
 }
 
 // Compute an at_hash from a raw access token and a signature algorithm
@@ -229,6 +464,29 @@ func (a audience) MarshalJSON() ([]byte, error) {
 	return json.Marshal([]string(a))
 }
 
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var aud string
+	if err := json.Unmarshal(b, &aud); err == nil {
+		*a = audience{aud}
+		return nil
+	}
+	var auds []string
+	if err := json.Unmarshal(b, &auds); err != nil {
+		return err
+	}
+	*a = auds
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
 type idTokenClaims struct {
 	Issuer           string   `json:"iss"`
 	Subject          string   `json:"sub"`
@@ -237,6 +495,7 @@ type idTokenClaims struct {
 	IssuedAt         int64    `json:"iat"`
 	AuthorizingParty string   `json:"azp,omitempty"`
 	Nonce            string   `json:"nonce,omitempty"`
+	AuthTime         int64    `json:"auth_time,omitempty"`
 
 	AccessTokenHash string `json:"at_hash,omitempty"`
 
@@ -248,35 +507,198 @@ type idTokenClaims struct {
 	Name string `json:"name,omitempty"`
 }
 
-func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []string, nonce, accessToken, connID string) (idToken string, expiry time.Time, err error) {
-	keys, err := s.storage.GetKeys()
+// issuedTo returns the client an ID Token was issued to. Tokens with cross
+// client audiences name it as the authorizing party.
+func (t idTokenClaims) issuedTo() string {
+	if t.AuthorizingParty != "" {
+		return t.AuthorizingParty
+	}
+	if len(t.Audience) == 1 {
+		return t.Audience[0]
+	}
+	return ""
+}
+
+// clientSubjectConnID is the connector ID encoded in the subjects of tokens
+// that weren't issued through a connector, such as client credentials grants,
+// so clients' IDs can't collide with the IDs of end users. Connectors can't be
+// configured with this ID.
+const clientSubjectConnID = "dex:client"
+
+// idTokenSubject returns the "sub" claim for a user, which encodes both the
+// user's ID and the connector they logged in with.
+//
+// Tokens that weren't issued through a connector, such as client credentials
+// grants, have an empty connector ID.
+func idTokenSubject(userID, connID string) (string, error) {
+	if connID == "" {
+		connID = clientSubjectConnID
+	}
+	return internal.Marshal(&internal.IDTokenSubject{
+		UserId: userID,
+		ConnId: connID,
+	})
+}
+
+// Formats access tokens can be issued in.
+const (
+	// Random strings only meaningful to dex.
+	accessTokenFormatOpaque = "opaque"
+	// Signed JWTs resource servers can verify on their own.
+	//
+	// https://tools.ietf.org/html/rfc9068
+	accessTokenFormatJWT = "jwt"
+)
+
+// The "typ" header of JWT access tokens.
+const accessTokenType = "at+jwt"
+
+type accessTokenClaims struct {
+	Issuer   string   `json:"iss"`
+	Subject  string   `json:"sub"`
+	Audience audience `json:"aud"`
+	Expiry   int64    `json:"exp"`
+	IssuedAt int64    `json:"iat"`
+	ID       string   `json:"jti"`
+	ClientID string   `json:"client_id"`
+	Scope    string   `json:"scope,omitempty"`
+
+	Groups []string `json:"groups,omitempty"`
+}
+
+// newAccessToken issues an access token to a client, in the format configured
+// for the client. Either way the access token is recorded in the storage, so it
+// can later be introspected, exchanged for user info or revoked.
+//
+// resources are the resource servers the access token is intended for. If
+// empty, the access token is audienced to the client itself.
+func (s *Server) newAccessToken(client storage.Client, claims storage.Claims, scopes, resources []string, connID string) (accessToken string, expiry time.Time, err error) {
+	issuedAt := s.now()
+	expiry = issuedAt.Add(s.accessTokenLifetime(client))
+
+	t := storage.AccessToken{
+		ID:          storage.NewID(),
+		ClientID:    client.ID,
+		Claims:      claims,
+		Scopes:      scopes,
+		ConnectorID: connID,
+		Resources:   resources,
+		CreatedAt:   issuedAt,
+		Expiry:      expiry,
+	}
+	if err := s.storage.CreateAccessToken(t); err != nil {
+		return "", expiry, fmt.Errorf("create access token: %v", err)
+	}
+
+	format := client.AccessTokenFormat
+	if format == "" {
+		format = s.accessTokenFormat
+	}
+	if format != accessTokenFormatJWT {
+		return t.ID, expiry, nil
+	}
+
+	subjectString, err := idTokenSubject(claims.UserID, connID)
 	if err != nil {
-		s.logger.Errorf("Failed to get keys: %v", err)
-		return "", expiry, err
+		return "", expiry, fmt.Errorf("failed to marshal subject: %v", err)
+	}
+	if subjectString, err = s.clientSubject(client, subjectString); err != nil {
+		return "", expiry, fmt.Errorf("failed to compute subject: %v", err)
+	}
+	tok := accessTokenClaims{
+		Issuer:   s.issuerURL.String(),
+		Subject:  subjectString,
+		Audience: audience(resources),
+		Expiry:   expiry.Unix(),
+		IssuedAt: issuedAt.Unix(),
+		ID:       t.ID,
+		ClientID: client.ID,
+		Scope:    strings.Join(scopes, " "),
+	}
+	if len(tok.Audience) == 0 {
+		tok.Audience = audience{client.ID}
+	}
+	if hasScope(scopes, scopeGroups) {
+		tok.Groups = claims.Groups
 	}
 
-	signingKey := keys.SigningKey
-	if signingKey == nil {
-		return "", expiry, fmt.Errorf("no key to sign payload with")
+	payload, err := json.Marshal(tok)
+	if err != nil {
+		return "", expiry, fmt.Errorf("could not serialize claims: %v", err)
 	}
-	signingAlg, err := signatureAlgorithm(signingKey)
+	if accessToken, err = s.signer.Sign(payload, accessTokenType); err != nil {
+		return "", expiry, fmt.Errorf("failed to sign payload: %v", err)
+	}
+	return accessToken, expiry, nil
+}
+
+// getAccessToken looks up the storage record of an access token presented by a
+// client, whether it was issued as an opaque string or a JWT. JWTs that weren't
+// signed by this server are reported as not found.
+func (s *Server) getAccessToken(token string) (storage.AccessToken, error) {
+	if strings.Count(token, ".") != 2 {
+		return s.storage.GetAccessToken(token)
+	}
+
+	jws, err := jose.ParseSigned(token)
 	if err != nil {
-		return "", expiry, err
+		return storage.AccessToken{}, storage.ErrNotFound
+	}
+	pubKeys, _, err := s.signer.ValidationKeys()
+	if err != nil {
+		return storage.AccessToken{}, fmt.Errorf("get keys: %v", err)
+	}
+	var payload []byte
+	for _, key := range pubKeys {
+		if key == nil {
+			continue
+		}
+		if p, err := jws.Verify(key); err == nil {
+			payload = p
+			break
+		}
+	}
+	if payload == nil {
+		return storage.AccessToken{}, storage.ErrNotFound
 	}
 
-	issuedAt := s.now()
-	expiry = issuedAt.Add(s.idTokensValidFor)
+	var tok accessTokenClaims
+	if err := json.Unmarshal(payload, &tok); err != nil || tok.ID == "" || tok.Issuer != s.issuerURL.String() {
+		return storage.AccessToken{}, storage.ErrNotFound
+	}
+	return s.storage.GetAccessToken(tok.ID)
+}
 
-	sub := &internal.IDTokenSubject{
-		UserId: claims.UserID,
-		ConnId: connID,
+// validResource reports if a "resource" parameter is an absolute URI without a
+// fragment, as resource indicators must be.
+//
+// https://tools.ietf.org/html/rfc8707#section-2
+func validResource(resource string) bool {
+	u, err := url.Parse(resource)
+	return err == nil && u.IsAbs() && u.Fragment == ""
+}
+
+// newIDToken signs an ID Token for an end user. authTime is when the end user
+// logged in, and is zero for tokens not issued through a login.
+func (s *Server) newIDToken(client storage.Client, claims storage.Claims, scopes []string, nonce, accessToken, connID string, authTime time.Time) (idToken string, expiry time.Time, err error) {
+	signingAlg, err := s.signer.Algorithm()
+	if err != nil {
+		s.logger.Errorf("Failed to get signing algorithm: %v", err)
+		return "", expiry, err
 	}
 
-	subjectString, err := internal.Marshal(sub)
+	issuedAt := s.now()
+	expiry = issuedAt.Add(s.idTokenLifetime(client))
+
+	subjectString, err := idTokenSubject(claims.UserID, connID)
 	if err != nil {
 		s.logger.Errorf("failed to marshal offline session ID: %v", err)
 		return "", expiry, fmt.Errorf("failed to marshal offline session ID: %v", err)
 	}
+	if subjectString, err = s.clientSubject(client, subjectString); err != nil {
+		s.logger.Errorf("failed to compute subject for client %s: %v", client.ID, err)
+		return "", expiry, fmt.Errorf("failed to compute subject: %v", err)
+	}
 
 	tok := idTokenClaims{
 		Issuer:   s.issuerURL.String(),
@@ -285,6 +707,9 @@ func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []str
 		Expiry:   expiry.Unix(),
 		IssuedAt: issuedAt.Unix(),
 	}
+	if !authTime.IsZero() {
+		tok.AuthTime = authTime.Unix()
+	}
 
 	if accessToken != "" {
 		atHash, err := accessTokenHash(signingAlg, accessToken)
@@ -311,7 +736,7 @@ func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []str
 				// initial auth request.
 				continue
 			}
-			isTrusted, err := s.validateCrossClientTrust(clientID, peerID)
+			isTrusted, err := s.validateCrossClientTrust(client.ID, peerID)
 			if err != nil {
 				return "", expiry, err
 			}
@@ -326,11 +751,11 @@ func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []str
 	if len(tok.Audience) == 0 {
 		// Client didn't ask for cross client audience. Set the current
 		// client as the audience.
-		tok.Audience = audience{clientID}
+		tok.Audience = audience{client.ID}
 	} else {
 		// Client asked for cross client audience. The current client
 		// becomes the authorizing party.
-		tok.AuthorizingParty = clientID
+		tok.AuthorizingParty = client.ID
 	}
 
 	payload, err := json.Marshal(tok)
@@ -338,84 +763,231 @@ func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []str
 		return "", expiry, fmt.Errorf("could not serialize claims: %v", err)
 	}
 
-	if idToken, err = signPayload(signingKey, signingAlg, payload); err != nil {
+	if idToken, err = s.signer.Sign(payload, ""); err != nil {
 		return "", expiry, fmt.Errorf("failed to sign payload: %v", err)
 	}
 	return idToken, expiry, nil
 }
 
+// verifyIDToken checks that an ID Token was issued by this server and hasn't
+// expired, returning its claims.
+func (s *Server) verifyIDToken(rawIDToken string) (idTokenClaims, error) {
+	tok, err := s.parseIDToken(rawIDToken)
+	if err != nil {
+		return tok, err
+	}
+	if !s.now().Before(time.Unix(tok.Expiry, 0)) {
+		return tok, errors.New("token has expired")
+	}
+	return tok, nil
+}
+
+// nonIDTokenClaims are claims of other tokens dex signs which ID Tokens never
+// have: the "events" of logout tokens, and the "client_id" and "scope" of
+// access tokens.
+var nonIDTokenClaims = []string{"events", "client_id", "scope"}
+
+// parseIDToken checks that an ID Token was issued by this server, returning its
+// claims. Unlike verifyIDToken, it accepts expired tokens.
+func (s *Server) parseIDToken(rawIDToken string) (idTokenClaims, error) {
+	var tok idTokenClaims
+
+	jws, err := jose.ParseSigned(rawIDToken)
+	if err != nil {
+		return tok, fmt.Errorf("malformed token: %v", err)
+	}
+
+	pubKeys, _, err := s.signer.ValidationKeys()
+	if err != nil {
+		s.logger.Errorf("Failed to get keys: %v", err)
+		return tok, err
+	}
+
+	var payload []byte
+	for _, key := range pubKeys {
+		if key == nil {
+			continue
+		}
+		if p, err := jws.Verify(key); err == nil {
+			payload = p
+			break
+		}
+	}
+	if payload == nil {
+		return tok, errors.New("failed to verify token signature")
+	}
+
+	// Access and logout tokens are signed with the same keys, so they must not
+	// be mistaken for ID Tokens.
+	if typ, _ := jws.Signatures[0].Header.ExtraHeaders[jose.HeaderType].(string); typ != "" {
+		typ = strings.TrimPrefix(strings.ToLower(typ), "application/")
+		if typ == accessTokenType || typ == logoutTokenType {
+			return tok, fmt.Errorf("token of type %q isn't an ID Token", typ)
+		}
+	}
+	var claims map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return tok, fmt.Errorf("failed to decode token claims: %v", err)
+	}
+	for _, claim := range nonIDTokenClaims {
+		if _, ok := claims[claim]; ok {
+			return tok, fmt.Errorf("token has a %q claim, it isn't an ID Token", claim)
+		}
+	}
+
+	if err := json.Unmarshal(payload, &tok); err != nil {
+		return tok, fmt.Errorf("failed to decode token claims: %v", err)
+	}
+	if tok.Issuer != s.issuerURL.String() {
+		return tok, fmt.Errorf("token issued by %q, expected %q", tok.Issuer, s.issuerURL.String())
+	}
+	return tok, nil
+}
+
+// parseIDTokenSubject reverses idTokenSubject.
+func parseIDTokenSubject(sub string) (userID, connID string, err error) {
+	id := new(internal.IDTokenSubject)
+	if err := internal.Unmarshal(sub, id); err != nil {
+		return "", "", fmt.Errorf("failed to decode subject: %v", err)
+	}
+	if id.UserId == "" {
+		return "", "", errors.New("subject has no user ID")
+	}
+	if id.ConnId == clientSubjectConnID {
+		return id.UserId, "", nil
+	}
+	return id.UserId, id.ConnId, nil
+}
+
+// loginRequirements holds the constraints an authorization request places on
+// how the end user logs in. They only apply while handling the initial request,
+// so unlike the rest of the request they aren't persisted.
+type loginRequirements struct {
+	// Set by "prompt=none". The end user must not be shown any pages.
+	noPrompt bool
+	// Set by "prompt=login". The end user must log in even if they already have
+	// a session.
+	forceLogin bool
+	// Set by "max_age". The end user must log in if they last did so longer ago
+	// than maxAge.
+	hasMaxAge bool
+	maxAge    time.Duration
+	// Set by "id_token_hint". The "sub" claim of the end user the client expects
+	// to be logged in.
+	subject string
+	// The connectors the client allows end users to log in with. If empty, any
+	// connector.
+	connectors []string
+}
+
+// allowsSession reports if the end user's existing browser session satisfies
+// the requirements, or if they have to log in again.
+func (l loginRequirements) allowsSession(session storage.Session, now time.Time) bool {
+	if l.forceLogin {
+		return false
+	}
+	if !allowedBy(l.connectors, session.ConnectorID) {
+		return false
+	}
+	if l.hasMaxAge && now.Sub(session.CreatedAt) > l.maxAge {
+		return false
+	}
+	if l.subject != "" {
+		sub, err := idTokenSubject(session.Claims.UserID, session.ConnectorID)
+		if err != nil || sub != l.subject {
+			return false
+		}
+	}
+	return true
+}
+
 // parse the initial request from the OAuth2 client.
-func (s *Server) parseAuthorizationRequest(r *http.Request) (req storage.AuthRequest, oauth2Err *authErr) {
+func (s *Server) parseAuthorizationRequest(r *http.Request) (req storage.AuthRequest, login loginRequirements, oauth2Err *authErr) {
 	if err := r.ParseForm(); err != nil {
-		return req, &authErr{"", "", errInvalidRequest, "Failed to parse request body."}
+		return req, login, &authErr{"", "", errInvalidRequest, "Failed to parse request body.", ""}
 	}
 	q := r.Form
-	redirectURI, err := url.QueryUnescape(q.Get("redirect_uri"))
-	if err != nil {
-		return req, &authErr{"", "", errInvalidRequest, "No redirect_uri provided."}
+
+	// The parameters of a pushed authorization request replace the request's.
+	pushed := false
+	if requestURI := q.Get("request_uri"); requestURI != "" {
+		params, err := s.pushedAuthRequestParams(q.Get("client_id"), requestURI)
+		if err != nil {
+			return req, login, err
+		}
+		q, pushed = params, true
 	}
+	return s.parseAuthorizationParams(q, pushed)
+}
 
+// parseAuthorizationParams validates the parameters of an authorization request.
+// pushed reports if the client pushed the parameters to the server beforehand.
+func (s *Server) parseAuthorizationParams(q url.Values, pushed bool) (req storage.AuthRequest, login loginRequirements, oauth2Err *authErr) {
 	clientID := q.Get("client_id")
-	state := q.Get("state")
-	nonce := q.Get("nonce")
-	// Some clients, like the old go-oidc, provide extra whitespace. Tolerate this.
-	scopes := strings.Fields(q.Get("scope"))
-	responseTypes := strings.Fields(q.Get("response_type"))
-
 	client, err := s.storage.GetClient(clientID)
 	if err != nil {
 		if err == storage.ErrNotFound {
 			description := fmt.Sprintf("Invalid client_id (%q).", clientID)
-			return req, &authErr{"", "", errUnauthorizedClient, description}
+			return req, login, &authErr{"", "", errUnauthorizedClient, description, ""}
 		}
 		s.logger.Errorf("Failed to get client: %v", err)
-		return req, &authErr{"", "", errServerError, ""}
+		return req, login, &authErr{"", "", errServerError, "", ""}
+	}
+
+	// The parameters of a signed request object replace the request's.
+	signed := false
+	if requestObject := q.Get("request"); requestObject != "" {
+		params, err := s.parseRequestObject(client, requestObject)
+		if err != nil {
+			s.logger.Infof("invalid request object from client %q: %v", client.ID, err)
+			return req, login, &authErr{"", "", errInvalidRequestObject, "Invalid request object.", ""}
+		}
+		q, signed = params, true
 	}
 
+	redirectURI, err := url.QueryUnescape(q.Get("redirect_uri"))
+	if err != nil {
+		return req, login, &authErr{"", "", errInvalidRequest, "No redirect_uri provided.", ""}
+	}
+
+	state := q.Get("state")
+	nonce := q.Get("nonce")
+	// Some clients, like the old go-oidc, provide extra whitespace. Tolerate this.
+	scopes := strings.Fields(q.Get("scope"))
+	responseTypes := strings.Fields(q.Get("response_type"))
+
 	if !validateRedirectURI(client, redirectURI) {
 		description := fmt.Sprintf("Unregistered redirect_uri (%q).", redirectURI)
-		return req, &authErr{"", "", errInvalidRequest, description}
+		return req, login, &authErr{"", "", errInvalidRequest, description, ""}
 	}
 
 	// From here on out, we want to redirect back to the client with an error.
+	// Until the response mode has been validated, errors are added to the query.
+	var responseMode string
 	newErr := func(typ, format string, a ...interface{}) *authErr {
-		return &authErr{state, redirectURI, typ, fmt.Sprintf(format, a...)}
+		return &authErr{state, redirectURI, typ, fmt.Sprintf(format, a...), responseMode}
 	}
 
-	var (
-		unrecognized  []string
-		invalidScopes []string
-	)
-	hasOpenIDScope := false
-	for _, scope := range scopes {
-		switch scope {
-		case scopeOpenID:
-			hasOpenIDScope = true
-		case scopeOfflineAccess, scopeEmail, scopeProfile, scopeGroups:
-		default:
-			peerID, ok := parseCrossClientScope(scope)
-			if !ok {
-				unrecognized = append(unrecognized, scope)
-				continue
-			}
+	if client.RequirePushedAuthRequests && !pushed {
+		return req, login, newErr(errInvalidRequest, "Client must push its authorization requests.")
+	}
+	if client.RequireSignedRequestObject && !signed {
+		return req, login, newErr(errInvalidRequest, "Client must pass authorization request parameters in a signed request object.")
+	}
+	login.connectors = client.AllowedConnectors
 
-			isTrusted, err := s.validateCrossClientTrust(clientID, peerID)
-			if err != nil {
-				return req, newErr(errServerError, "Internal server error.")
-			}
-			if !isTrusted {
-				invalidScopes = append(invalidScopes, scope)
-			}
-		}
+	unrecognized, invalidScopes, err := s.validateScopes(client, scopes)
+	if err != nil {
+		return req, login, newErr(errServerError, "Internal server error.")
 	}
-	if !hasOpenIDScope {
-		return req, newErr("invalid_scope", `Missing required scope(s) ["openid"].`)
+	if !hasScope(scopes, scopeOpenID) {
+		return req, login, newErr("invalid_scope", `Missing required scope(s) ["openid"].`)
 	}
 	if len(unrecognized) > 0 {
-		return req, newErr("invalid_scope", "Unrecognized scope(s) %q", unrecognized)
+		return req, login, newErr("invalid_scope", "Unrecognized scope(s) %q", unrecognized)
 	}
 	if len(invalidScopes) > 0 {
-		return req, newErr("invalid_scope", "Client can't request scope(s) %q", invalidScopes)
+		return req, login, newErr("invalid_scope", "Client can't request scope(s) %q", invalidScopes)
 	}
 
 	var rt struct {
@@ -433,23 +1005,26 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (req storage.AuthReq
 		case responseTypeToken:
 			rt.token = true
 		default:
-			return req, newErr("invalid_request", "Invalid response type %q", responseType)
+			return req, login, newErr("invalid_request", "Invalid response type %q", responseType)
 		}
 
 		if !s.supportedResponseTypes[responseType] {
-			return req, newErr(errUnsupportedResponseType, "Unsupported response type %q", responseType)
+			return req, login, newErr(errUnsupportedResponseType, "Unsupported response type %q", responseType)
+		}
+		if !clientAllowsResponseType(client, responseType) {
+			return req, login, newErr(errUnauthorizedClient, "Client is not allowed to use response type %q", responseType)
 		}
 	}
 
 	if len(responseTypes) == 0 {
-		return req, newErr("invalid_requests", "No response_type provided")
+		return req, login, newErr("invalid_requests", "No response_type provided")
 	}
 
 	if rt.token && !rt.code && !rt.idToken {
 		// "token" can't be provided by its own.
 		//
 		// https://openid.net/specs/openid-connect-core-1_0.html#Authentication
-		return req, newErr("invalid_request", "Response type 'token' must be provided with type 'id_token' and/or 'code'")
+		return req, login, newErr("invalid_request", "Response type 'token' must be provided with type 'id_token' and/or 'code'")
 	}
 	if !rt.code {
 		// Either "id_token code" or "id_token" has been provided which implies the
@@ -457,13 +1032,116 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (req storage.AuthReq
 		//
 		// https://openid.net/specs/openid-connect-core-1_0.html#ImplicitAuthRequest
 		if nonce == "" {
-			return req, newErr("invalid_request", "Response type 'token' requires a 'nonce' value.")
+			return req, login, newErr("invalid_request", "Response type 'token' requires a 'nonce' value.")
 		}
 	}
 	if rt.token {
 		if redirectURI == redirectURIOOB {
 			err := fmt.Sprintf("Cannot use response type 'token' with redirect_uri '%s'.", redirectURIOOB)
-			return req, newErr("invalid_request", err)
+			return req, login, newErr("invalid_request", err)
+		}
+	}
+
+	// Responses carrying tokens default to the fragment, and must never be
+	// added to the query, which ends up in server logs and Referer headers.
+	//
+	// https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#Combinations
+	switch mode := q.Get("response_mode"); mode {
+	case "":
+		if rt.token || rt.idToken {
+			responseMode = responseModeFragment
+		} else {
+			responseMode = responseModeQuery
+		}
+	case responseModeQuery:
+		if rt.token || rt.idToken {
+			return req, login, newErr("invalid_request", "Response mode 'query' can't be used with response types returning tokens.")
+		}
+		responseMode = mode
+	case responseModeFragment, responseModeFormPost:
+		if redirectURI == redirectURIOOB {
+			return req, login, newErr("invalid_request", "Cannot use response mode %q with redirect_uri '%s'.", mode, redirectURIOOB)
+		}
+		responseMode = mode
+	default:
+		return req, login, newErr("invalid_request", "Unsupported response mode %q.", mode)
+	}
+
+	codeChallenge := q.Get("code_challenge")
+	codeChallengeMethod := q.Get("code_challenge_method")
+	if codeChallenge != "" {
+		if !rt.code {
+			return req, login, newErr("invalid_request", "PKCE can only be used with response type 'code'.")
+		}
+		// https://tools.ietf.org/html/rfc7636#section-4.3
+		if codeChallengeMethod == "" {
+			codeChallengeMethod = codeChallengeMethodPlain
+		}
+		if codeChallengeMethod != codeChallengeMethodPlain && codeChallengeMethod != codeChallengeMethodS256 {
+			return req, login, newErr("invalid_request", "Unsupported code_challenge_method %q.", codeChallengeMethod)
+		}
+	} else if codeChallengeMethod != "" {
+		return req, login, newErr("invalid_request", "Parameter code_challenge_method provided without code_challenge.")
+	}
+
+	// "approval_prompt=force" predates the prompt parameter and is kept as an
+	// alias for "prompt=consent".
+	forceApproval := q.Get("approval_prompt") == "force"
+	prompts := strings.Fields(q.Get("prompt"))
+	for _, prompt := range prompts {
+		switch prompt {
+		case promptNone:
+			if len(prompts) > 1 {
+				return req, login, newErr("invalid_request", "Prompt 'none' can't be combined with other values.")
+			}
+			login.noPrompt = true
+		case promptLogin:
+			login.forceLogin = true
+		case promptConsent:
+			forceApproval = true
+		case promptSelectAccount:
+			// Sessions only hold a single account. There's nothing to select.
+		default:
+			return req, login, newErr("invalid_request", "Invalid prompt %q.", prompt)
+		}
+	}
+
+	if maxAge := q.Get("max_age"); maxAge != "" {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil || seconds < 0 {
+			return req, login, newErr("invalid_request", "Invalid max_age %q.", maxAge)
+		}
+		login.hasMaxAge = true
+		login.maxAge = time.Duration(seconds) * time.Second
+		if seconds == 0 {
+			login.forceLogin = true
+		}
+	}
+
+	if hint := q.Get("id_token_hint"); hint != "" {
+		// The hint is usually a previously issued ID Token, which may well
+		// have expired by now.
+		tok, err := s.parseIDToken(hint)
+		if err != nil {
+			s.logger.Infof("invalid id_token_hint: %v", err)
+			return req, login, newErr("invalid_request", "Invalid id_token_hint.")
+		}
+		if !tok.Audience.contains(client.ID) && tok.AuthorizingParty != client.ID {
+			return req, login, newErr("invalid_request", "The id_token_hint wasn't issued to this client.")
+		}
+		if login.subject, err = s.localSubjectFor(tok.issuedTo(), tok.Subject); err != nil {
+			s.logger.Infof("invalid id_token_hint subject: %v", err)
+			return req, login, newErr("invalid_request", "Invalid id_token_hint.")
+		}
+	}
+
+	// "acr_values" is accepted but ignored. Connectors don't report how the
+	// end user authenticated.
+
+	resources := q["resource"]
+	for _, resource := range resources {
+		if !validResource(resource) {
+			return req, login, newErr(errInvalidTarget, "Invalid resource %q, expected an absolute URI without a fragment.", resource)
 		}
 	}
 
@@ -472,11 +1150,81 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (req storage.AuthReq
 		ClientID:            client.ID,
 		State:               state,
 		Nonce:               nonce,
-		ForceApprovalPrompt: q.Get("approval_prompt") == "force",
+		ForceApprovalPrompt: forceApproval,
 		Scopes:              scopes,
 		RedirectURI:         redirectURI,
 		ResponseTypes:       responseTypes,
-	}, nil
+		ResponseMode:        responseMode,
+		PKCE: storage.PKCE{
+			CodeChallenge:       codeChallenge,
+			CodeChallengeMethod: codeChallengeMethod,
+		},
+		LoginHint: q.Get("login_hint"),
+		Resources: resources,
+	}, login, nil
+}
+
+// verifyCodeVerifier checks a code_verifier presented at the token endpoint
+// against the code challenge of the initial authorization request.
+//
+// https://tools.ietf.org/html/rfc7636#section-4.6
+func verifyCodeVerifier(pkce storage.PKCE, codeVerifier string) bool {
+	// A code_verifier is between 43 and 128 characters long.
+	//
+	// https://tools.ietf.org/html/rfc7636#section-4.1
+	if len(codeVerifier) < 43 || len(codeVerifier) > 128 {
+		return false
+	}
+
+	var challenge string
+	switch pkce.CodeChallengeMethod {
+	case codeChallengeMethodS256:
+		sum := sha256.Sum256([]byte(codeVerifier))
+		challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	case codeChallengeMethodPlain:
+		challenge = codeVerifier
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(pkce.CodeChallenge)) == 1
+}
+
+// validateScopes returns the requested scopes that aren't recognized, and the
+// ones the client can't request: scopes outside its allowed scopes and
+// cross-client scopes for peers that don't trust the client.
+func (s *Server) validateScopes(client storage.Client, scopes []string) (unrecognized, invalid []string, err error) {
+	for _, scope := range scopes {
+		switch scope {
+		case scopeOpenID, scopeOfflineAccess, scopeEmail, scopeProfile, scopeGroups:
+			if !clientAllowsScope(client, scope) {
+				invalid = append(invalid, scope)
+			}
+		default:
+			peerID, ok := parseCrossClientScope(scope)
+			if !ok {
+				unrecognized = append(unrecognized, scope)
+				continue
+			}
+
+			isTrusted, err := s.validateCrossClientTrust(client.ID, peerID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !isTrusted || !clientAllowsScope(client, scope) {
+				invalid = append(invalid, scope)
+			}
+		}
+	}
+	return unrecognized, invalid, nil
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
 }
 
 func parseCrossClientScope(scope string) (peerID string, ok bool) {
@@ -2,11 +2,13 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	jose "gopkg.in/square/go-jose.v2"
 
@@ -23,7 +25,8 @@ func TestParseAuthorizationRequest(t *testing.T) {
 
 		queryParams map[string]string
 
-		wantErr bool
+		wantErr          bool
+		wantResponseMode string
 	}{
 		{
 			name: "normal request",
@@ -58,6 +61,61 @@ func TestParseAuthorizationRequest(t *testing.T) {
 			},
 			usePOST: true,
 		},
+		{
+			name: "allowed scopes and response types",
+			clients: []storage.Client{
+				{
+					ID:                   "foo",
+					RedirectURIs:         []string{"https://example.com/foo"},
+					AllowedScopes:        []string{"email"},
+					AllowedResponseTypes: []string{"code"},
+				},
+			},
+			supportedResponseTypes: []string{"code", "id_token"},
+			queryParams: map[string]string{
+				"client_id":     "foo",
+				"redirect_uri":  "https://example.com/foo",
+				"response_type": "code",
+				"scope":         "openid email",
+			},
+		},
+		{
+			name: "scope not allowed for client",
+			clients: []storage.Client{
+				{
+					ID:            "foo",
+					RedirectURIs:  []string{"https://example.com/foo"},
+					AllowedScopes: []string{"email"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":     "foo",
+				"redirect_uri":  "https://example.com/foo",
+				"response_type": "code",
+				"scope":         "openid email profile",
+			},
+			wantErr: true,
+		},
+		{
+			name: "response type not allowed for client",
+			clients: []storage.Client{
+				{
+					ID:                   "foo",
+					RedirectURIs:         []string{"https://example.com/foo"},
+					AllowedResponseTypes: []string{"code"},
+				},
+			},
+			supportedResponseTypes: []string{"code", "id_token"},
+			queryParams: map[string]string{
+				"client_id":     "foo",
+				"redirect_uri":  "https://example.com/foo",
+				"response_type": "id_token",
+				"scope":         "openid",
+				"nonce":         "nonce",
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid client id",
 			clients: []storage.Client{
@@ -142,6 +200,135 @@ func TestParseAuthorizationRequest(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "PKCE code challenge",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":             "bar",
+				"redirect_uri":          "https://example.com/bar",
+				"response_type":         "code",
+				"scope":                 "openid email profile",
+				"code_challenge":        "ngF5GsXcbwljx6u133FFr3Xht9xooA_DuaX_3QwODtc",
+				"code_challenge_method": "S256",
+			},
+		},
+		{
+			name: "PKCE unsupported code challenge method",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":             "bar",
+				"redirect_uri":          "https://example.com/bar",
+				"response_type":         "code",
+				"scope":                 "openid email profile",
+				"code_challenge":        "ngF5GsXcbwljx6u133FFr3Xht9xooA_DuaX_3QwODtc",
+				"code_challenge_method": "S512",
+			},
+			wantErr: true,
+		},
+		{
+			name: "PKCE code challenge method without challenge",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":             "bar",
+				"redirect_uri":          "https://example.com/bar",
+				"response_type":         "code",
+				"scope":                 "openid email profile",
+				"code_challenge_method": "S256",
+			},
+			wantErr: true,
+		},
+		{
+			name: "default response mode of implicit flow",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"id_token"},
+			queryParams: map[string]string{
+				"client_id":     "bar",
+				"redirect_uri":  "https://example.com/bar",
+				"response_type": "id_token",
+				"scope":         "openid",
+				"nonce":         "abc",
+			},
+			wantResponseMode: "fragment",
+		},
+		{
+			name: "form_post response mode",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"code", "id_token"},
+			queryParams: map[string]string{
+				"client_id":     "bar",
+				"redirect_uri":  "https://example.com/bar",
+				"response_type": "code id_token",
+				"response_mode": "form_post",
+				"scope":         "openid",
+				"nonce":         "abc",
+			},
+			wantResponseMode: "form_post",
+		},
+		{
+			name: "query response mode with tokens",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"code", "id_token"},
+			queryParams: map[string]string{
+				"client_id":     "bar",
+				"redirect_uri":  "https://example.com/bar",
+				"response_type": "code id_token",
+				"response_mode": "query",
+				"scope":         "openid",
+				"nonce":         "abc",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported response mode",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":     "bar",
+				"redirect_uri":  "https://example.com/bar",
+				"response_type": "code",
+				"response_mode": "web_message",
+				"scope":         "openid",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -168,13 +355,16 @@ func TestParseAuthorizationRequest(t *testing.T) {
 			} else {
 				req = httptest.NewRequest("GET", httpServer.URL+"/auth?"+params.Encode(), nil)
 			}
-			_, err := server.parseAuthorizationRequest(req)
+			authReq, _, err := server.parseAuthorizationRequest(req)
 			if err != nil && !tc.wantErr {
 				t.Errorf("%s: %v", tc.name, err)
 			}
 			if err == nil && tc.wantErr {
 				t.Errorf("%s: expected error", tc.name)
 			}
+			if tc.wantResponseMode != "" && authReq.ResponseMode != tc.wantResponseMode {
+				t.Errorf("%s: expected response mode %q got %q", tc.name, tc.wantResponseMode, authReq.ResponseMode)
+			}
 		}()
 	}
 }
@@ -196,6 +386,30 @@ func TestAccessTokenHash(t *testing.T) {
 	}
 }
 
+func TestVerifyCodeVerifier(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mJ92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "ngF5GsXcbwljx6u133FFr3Xht9xooA_DuaX_3QwODtc"
+
+	tests := []struct {
+		name     string
+		pkce     storage.PKCE
+		verifier string
+		want     bool
+	}{
+		{"S256", storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: "S256"}, verifier, true},
+		{"S256 mismatch", storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: "S256"}, verifier[1:] + "a", false},
+		{"plain", storage.PKCE{CodeChallenge: verifier, CodeChallengeMethod: "plain"}, verifier, true},
+		{"plain mismatch", storage.PKCE{CodeChallenge: verifier, CodeChallengeMethod: "plain"}, challenge, false},
+		{"verifier too short", storage.PKCE{CodeChallenge: "abc", CodeChallengeMethod: "plain"}, "abc", false},
+		{"unknown method", storage.PKCE{CodeChallenge: verifier, CodeChallengeMethod: "S512"}, verifier, false},
+	}
+	for _, tc := range tests {
+		if got := verifyCodeVerifier(tc.pkce, tc.verifier); got != tc.want {
+			t.Errorf("%s: verifyCodeVerifier() = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
 func TestValidRedirectURI(t *testing.T) {
 	tests := []struct {
 		client      storage.Client
@@ -259,3 +473,80 @@ func TestValidRedirectURI(t *testing.T) {
 		}
 	}
 }
+
+func TestParseIDTokenRejectsOtherTokens(t *testing.T) {
+	const redirectURI = "https://app.example.com/callback"
+	client := storage.Client{
+		ID:                "app",
+		Secret:            "secret",
+		RedirectURIs:      []string{redirectURI},
+		AccessTokenFormat: accessTokenFormatJWT,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{client})
+	})
+	defer httpServer.Close()
+
+	claims := storage.Claims{UserID: "user"}
+	idToken, _, err := server.newIDToken(client, claims, []string{"openid"}, "", "", "mock", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+	if _, err := server.parseIDToken(idToken); err != nil {
+		t.Fatalf("failed to parse ID token: %v", err)
+	}
+
+	accessToken, _, err := server.newAccessToken(client, claims, []string{"openid"}, nil, "mock")
+	if err != nil {
+		t.Fatalf("failed to create access token: %v", err)
+	}
+	logoutToken, err := server.newLogoutToken(client.ID, "subject")
+	if err != nil {
+		t.Fatalf("failed to create logout token: %v", err)
+	}
+	// Tokens carrying access token claims are rejected even without a "typ".
+	payload, err := json.Marshal(map[string]interface{}{
+		"iss":       server.issuerURL.String(),
+		"sub":       "subject",
+		"aud":       client.ID,
+		"exp":       server.now().Add(time.Hour).Unix(),
+		"client_id": client.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	untyped, err := server.signer.Sign(payload, "")
+	if err != nil {
+		t.Fatalf("failed to sign payload: %v", err)
+	}
+
+	for name, token := range map[string]string{
+		"access token":                   accessToken,
+		"logout token":                   logoutToken,
+		"token with access token claims": untyped,
+	} {
+		if _, err := server.parseIDToken(token); err == nil {
+			t.Errorf("%s: expected token to be rejected as an ID Token", name)
+		}
+	}
+
+	// Access tokens can't be passed off as an id_token_hint.
+	v := url.Values{
+		"client_id":     {client.ID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {"openid"},
+		"state":         {"state"},
+		"id_token_hint": {accessToken},
+	}
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/auth?"+v.Encode(), nil))
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil || location.Query().Get("error") != errInvalidRequest {
+		t.Errorf("expected access token as id_token_hint to be rejected, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+}
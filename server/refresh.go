@@ -0,0 +1,79 @@
+package server
+
+import (
+	"time"
+
+	"github.com/coreos/dex/storage"
+)
+
+// RefreshTokenConfig holds the options for refresh tokens.
+type RefreshTokenConfig struct {
+	// Refresh tokens are rotated every time they're used, and presenting an
+	// already rotated token revokes the refresh token. For this long after a
+	// rotation the previous token is still accepted, so clients refreshing
+	// concurrently or retrying after a network failure aren't logged out.
+	// Disabled by default.
+	ReuseInterval time.Duration
+
+	// Refresh tokens expire if unused for this long, or this long after the end
+	// user authorized the client, even if in use. Clients may override either.
+	// Refresh tokens never expire by default.
+	IdleTimeout      time.Duration
+	AbsoluteLifetime time.Duration
+}
+
+// refreshTokenExpiry returns when a client's refresh token expires, or the zero
+// time if it never expires.
+func (s *Server) refreshTokenExpiry(client storage.Client, createdAt, lastUsed time.Time) time.Time {
+	idleTimeout := s.clientLifetime(client.ID, "refresh token idle", client.RefreshTokenIdleTimeout, s.refreshTokens.IdleTimeout)
+	absoluteLifetime := s.clientLifetime(client.ID, "refresh token absolute", client.RefreshTokenAbsoluteLifetime, s.refreshTokens.AbsoluteLifetime)
+
+	var expiry time.Time
+	if idleTimeout > 0 {
+		expiry = lastUsed.Add(idleTimeout)
+	}
+	if absoluteLifetime > 0 {
+		if e := createdAt.Add(absoluteLifetime); expiry.IsZero() || e.Before(expiry) {
+			expiry = e
+		}
+	}
+	return expiry
+}
+
+// refreshTokenExpired reports if a refresh token has passed either its stored
+// expiry or the one computed from the current lifetimes.
+func (s *Server) refreshTokenExpired(client storage.Client, refresh storage.RefreshToken) bool {
+	now := s.now()
+	if !refresh.Expiry.IsZero() && now.After(refresh.Expiry) {
+		return true
+	}
+	expiry := s.refreshTokenExpiry(client, refresh.CreatedAt, refresh.LastUsed)
+	return !expiry.IsZero() && now.After(expiry)
+}
+
+// refreshTokenReusable reports if a rotated refresh token may still be
+// presented in place of the current one.
+func (s *Server) refreshTokenReusable(refresh storage.RefreshToken, token string) bool {
+	if s.refreshTokens.ReuseInterval <= 0 || refresh.ObsoleteToken == "" || token != refresh.ObsoleteToken {
+		return false
+	}
+	return s.now().Before(refresh.LastUsed.Add(s.refreshTokens.ReuseInterval))
+}
+
+// deleteRefreshToken revokes a refresh token, removing it from the end user's
+// offline sessions.
+func (s *Server) deleteRefreshToken(refresh storage.RefreshToken) error {
+	updater := func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+		if ref, ok := old.Refresh[refresh.ClientID]; ok && ref.ID == refresh.ID {
+			delete(old.Refresh, refresh.ClientID)
+		}
+		return old, nil
+	}
+	if err := s.storage.UpdateOfflineSessions(refresh.Claims.UserID, refresh.ConnectorID, updater); err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	if err := s.storage.DeleteRefresh(refresh.ID); err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,554 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/coreos/dex/storage"
+)
+
+// RegistrationConfig holds the options for dynamic client registration.
+//
+// https://tools.ietf.org/html/rfc7591
+type RegistrationConfig struct {
+	// Bearer tokens which must be presented to register a client. If none are
+	// configured, the registration endpoint is disabled.
+	InitialAccessTokens []string
+
+	// Patterns the redirect URIs of registered clients must match. For example
+	// "https://*.example.com/callback". Wildcards, using the syntax of
+	// path.Match, are only allowed in the labels of the host; the scheme, port
+	// and path must match exactly. URIs with user info, a query or a fragment
+	// never match.
+	//
+	// If empty, registered clients can't have any redirect URIs.
+	AllowedRedirectURIs []string
+
+	// Patterns the "jwks_uri" and "backchannel_logout_uri" of registered
+	// clients must match, using the same syntax as AllowedRedirectURIs. The
+	// server sends requests to these URIs, so they're restricted separately.
+	//
+	// If empty, registered clients can't set them.
+	AllowedJWKSURIs              []string
+	AllowedBackchannelLogoutURIs []string
+}
+
+// Errors returned by the registration endpoints.
+//
+// https://tools.ietf.org/html/rfc7591#section-3.2.2
+const (
+	errInvalidRedirectURI    = "invalid_redirect_uri"
+	errInvalidClientMetadata = "invalid_client_metadata"
+)
+
+// Grant types dynamically registered clients may use. Grants that let a client
+// act on behalf of users without their approval, such as the password grant
+// and token exchange, must be configured by an administrator.
+var registrableGrantTypes = map[string]bool{
+	grantTypeAuthorizationCode: true,
+	grantTypeRefreshToken:      true,
+	grantTypeClientCredentials: true,
+	grantTypeDeviceCode:        true,
+}
+
+// The "token_endpoint_auth_method" of a public client.
+const authMethodNone = "none"
+
+// clientMetadata is the metadata a client registers with.
+//
+// https://tools.ietf.org/html/rfc7591#section-2
+type clientMetadata struct {
+	RedirectURIs            []string            `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod string              `json:"token_endpoint_auth_method"`
+	GrantTypes              []string            `json:"grant_types"`
+	ClientName              string              `json:"client_name,omitempty"`
+	LogoURI                 string              `json:"logo_uri,omitempty"`
+	JWKS                    *jose.JSONWebKeySet `json:"jwks,omitempty"`
+	JWKSURI                 string              `json:"jwks_uri,omitempty"`
+
+	// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
+	SubjectType string `json:"subject_type,omitempty"`
+
+	// https://openid.net/specs/openid-connect-rpinitiated-1_0.html#ClientMetadata
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris,omitempty"`
+
+	// https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRegistration
+	BackchannelLogoutURI string `json:"backchannel_logout_uri,omitempty"`
+}
+
+// clientInformation is returned to the client after registering and when
+// reading or updating its registration.
+//
+// https://tools.ietf.org/html/rfc7591#section-3.2.1
+// https://tools.ietf.org/html/rfc7592#section-3
+type clientInformation struct {
+	ClientID              string `json:"client_id"`
+	ClientSecret          string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt      int64  `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt int64  `json:"client_secret_expires_at"`
+
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+
+	clientMetadata
+}
+
+// registrationError is returned when a client's metadata violates the
+// registration policy.
+type registrationError struct {
+	typ         string
+	description string
+}
+
+func (e *registrationError) Error() string { return e.description }
+
+func newRegistrationErr(typ, format string, a ...interface{}) *registrationError {
+	return &registrationError{typ, fmt.Sprintf(format, a...)}
+}
+
+// validateRegistrationConfig checks the URI patterns are well formed.
+func validateRegistrationConfig(c RegistrationConfig) error {
+	for _, patterns := range [][]string{c.AllowedRedirectURIs, c.AllowedJWKSURIs, c.AllowedBackchannelLogoutURIs} {
+		for _, pattern := range patterns {
+			if err := validateURIPattern(pattern); err != nil {
+				return fmt.Errorf("invalid URI pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateURIPattern checks a pattern is an absolute URI which only has
+// wildcards in the labels of its host.
+func validateURIPattern(pattern string) error {
+	p, err := url.Parse(pattern)
+	if err != nil {
+		return err
+	}
+	if !p.IsAbs() || p.Host == "" || p.Opaque != "" {
+		return errors.New("pattern must be an absolute URI")
+	}
+	if p.User != nil || p.RawQuery != "" || p.ForceQuery || p.Fragment != "" {
+		return errors.New("pattern can't have user info, a query or a fragment")
+	}
+	if strings.ContainsAny(p.Scheme+p.Path, "*?[") {
+		return errors.New("wildcards are only allowed in the host")
+	}
+	host, port := splitHostPort(p.Host)
+	if strings.ContainsAny(port, "*?[") {
+		return errors.New("wildcards are only allowed in the host")
+	}
+	for _, label := range strings.Split(host, ".") {
+		if _, err := path.Match(label, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hostnameRE matches the host names wildcards in URI patterns may match.
+var hostnameRE = regexp.MustCompile(`^[a-z0-9-]+(\.[a-z0-9-]+)*$`)
+
+// splitHostPort splits the host of a URL into the host name and the port,
+// including the leading ":".
+func splitHostPort(hostport string) (host, port string) {
+	i := strings.LastIndex(hostport, ":")
+	if i < 0 || strings.HasSuffix(hostport, "]") {
+		return hostport, ""
+	}
+	return hostport[:i], hostport[i:]
+}
+
+// uriMatches reports if the URI matches a pattern checked by
+// validateURIPattern. The scheme, port and path must match exactly, and each
+// label of the host must match the label of the pattern.
+func uriMatches(pattern, uri string) bool {
+	p, err := url.Parse(pattern)
+	if err != nil {
+		return false
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	if !u.IsAbs() || u.Opaque != "" || u.User != nil || u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+		return false
+	}
+	if !strings.EqualFold(p.Scheme, u.Scheme) || p.EscapedPath() != u.EscapedPath() {
+		return false
+	}
+
+	pHost, pPort := splitHostPort(strings.ToLower(p.Host))
+	uHost, uPort := splitHostPort(strings.ToLower(u.Host))
+	if pPort != uPort {
+		return false
+	}
+	if !strings.ContainsAny(pHost, "*?[") {
+		return pHost == uHost
+	}
+	if !hostnameRE.MatchString(uHost) {
+		return false
+	}
+	pLabels, uLabels := strings.Split(pHost, "."), strings.Split(uHost, ".")
+	if len(pLabels) != len(uLabels) {
+		return false
+	}
+	for i, label := range pLabels {
+		if ok, _ := path.Match(label, uLabels[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// validateClientMetadata fills in defaults for the metadata and checks it
+// against the registration policy.
+func (s *Server) validateClientMetadata(md *clientMetadata) *registrationError {
+	if md.TokenEndpointAuthMethod == "" {
+		md.TokenEndpointAuthMethod = authMethodClientSecretBasic
+	}
+	if len(md.GrantTypes) == 0 {
+		md.GrantTypes = []string{grantTypeAuthorizationCode}
+	}
+
+	switch md.TokenEndpointAuthMethod {
+	case authMethodNone, authMethodClientSecretBasic, authMethodClientSecretPost, authMethodClientSecretJWT:
+	case authMethodPrivateKeyJWT:
+		if md.JWKS == nil && md.JWKSURI == "" {
+			return newRegistrationErr(errInvalidClientMetadata, `The "private_key_jwt" method requires "jwks" or "jwks_uri".`)
+		}
+	default:
+		return newRegistrationErr(errInvalidClientMetadata, "Unsupported token_endpoint_auth_method %q.", md.TokenEndpointAuthMethod)
+	}
+	if md.JWKS != nil && md.JWKSURI != "" {
+		return newRegistrationErr(errInvalidClientMetadata, `Only one of "jwks" and "jwks_uri" may be set.`)
+	}
+
+	hasCodeGrant := false
+	for _, grantType := range md.GrantTypes {
+		if !registrableGrantTypes[grantType] {
+			return newRegistrationErr(errInvalidClientMetadata, "Grant type %q can't be registered.", grantType)
+		}
+		if grantType == grantTypeClientCredentials && md.TokenEndpointAuthMethod == authMethodNone {
+			return newRegistrationErr(errInvalidClientMetadata, "Public clients can't use the client credentials grant.")
+		}
+		if grantType == grantTypeAuthorizationCode {
+			hasCodeGrant = true
+		}
+	}
+
+	// Public clients may only redirect to localhost or the out-of-band URI,
+	// whatever redirect URIs they register.
+	if md.TokenEndpointAuthMethod == authMethodNone {
+		if len(md.RedirectURIs) > 0 {
+			return newRegistrationErr(errInvalidRedirectURI, "Public clients can't register redirect_uris. They may redirect to localhost or %q.", redirectURIOOB)
+		}
+	} else if hasCodeGrant && len(md.RedirectURIs) == 0 {
+		return newRegistrationErr(errInvalidRedirectURI, "No redirect_uris provided.")
+	}
+	for _, redirectURI := range md.RedirectURIs {
+		if !uriAllowed(s.registration.AllowedRedirectURIs, redirectURI) {
+			return newRegistrationErr(errInvalidRedirectURI, "Redirect URI %q isn't allowed.", redirectURI)
+		}
+	}
+	for _, redirectURI := range md.PostLogoutRedirectURIs {
+		if !uriAllowed(s.registration.AllowedRedirectURIs, redirectURI) {
+			return newRegistrationErr(errInvalidClientMetadata, "Post logout redirect URI %q isn't allowed.", redirectURI)
+		}
+	}
+	if md.JWKSURI != "" && !uriAllowed(s.registration.AllowedJWKSURIs, md.JWKSURI) {
+		return newRegistrationErr(errInvalidClientMetadata, "jwks_uri %q isn't allowed.", md.JWKSURI)
+	}
+	if md.BackchannelLogoutURI != "" && !uriAllowed(s.registration.AllowedBackchannelLogoutURIs, md.BackchannelLogoutURI) {
+		return newRegistrationErr(errInvalidClientMetadata, "backchannel_logout_uri %q isn't allowed.", md.BackchannelLogoutURI)
+	}
+
+	if md.LogoURI != "" {
+		if u, err := url.Parse(md.LogoURI); err != nil || !u.IsAbs() {
+			return newRegistrationErr(errInvalidClientMetadata, "Invalid logo_uri %q.", md.LogoURI)
+		}
+	}
+
+	switch md.SubjectType {
+	case "", subjectTypePublic:
+	case subjectTypePairwise:
+		if s.pairwiseSubjectSalt == "" {
+			return newRegistrationErr(errInvalidClientMetadata, "Pairwise subjects aren't supported.")
+		}
+	default:
+		return newRegistrationErr(errInvalidClientMetadata, "Unsupported subject_type %q.", md.SubjectType)
+	}
+	return nil
+}
+
+// uriAllowed reports if the URI matches any of the patterns.
+func uriAllowed(patterns []string, uri string) bool {
+	for _, pattern := range patterns {
+		if uriMatches(pattern, uri) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyClientMetadata updates a client with its registered metadata. Clients
+// are given a secret if their authentication method requires one.
+func applyClientMetadata(c *storage.Client, md clientMetadata) {
+	c.RedirectURIs = md.RedirectURIs
+	c.Name = md.ClientName
+	c.LogoURL = md.LogoURI
+	c.AllowedGrantTypes = md.GrantTypes
+	c.JWKS = md.JWKS
+	c.JWKSURI = md.JWKSURI
+	c.PostLogoutRedirectURIs = md.PostLogoutRedirectURIs
+	c.BackchannelLogoutURI = md.BackchannelLogoutURI
+	c.SubjectType = md.SubjectType
+	c.Public = md.TokenEndpointAuthMethod == authMethodNone
+
+	// Registered clients can never act as trusted peers.
+	c.TrustedPeers = nil
+
+	switch md.TokenEndpointAuthMethod {
+	case authMethodNone, authMethodPrivateKeyJWT:
+		c.Secret = ""
+	default:
+		if c.Secret == "" {
+			c.Secret = storage.NewID() + storage.NewID()
+		}
+	}
+}
+
+func (s *Server) clientInformation(c storage.Client, md clientMetadata) clientInformation {
+	return clientInformation{
+		ClientID:              c.ID,
+		ClientSecret:          c.Secret,
+		RegistrationClientURI: s.absURL("/register", c.ID),
+		clientMetadata:        md,
+	}
+}
+
+// clientMetadataFromStorage reconstructs the registered metadata of a client.
+// The authentication method isn't stored, clients with a secret may use any of
+// the secret based methods.
+func clientMetadataFromStorage(c storage.Client) clientMetadata {
+	md := clientMetadata{
+		RedirectURIs: c.RedirectURIs,
+		GrantTypes:   c.AllowedGrantTypes,
+		ClientName:   c.Name,
+		LogoURI:      c.LogoURL,
+		JWKS:         c.JWKS,
+		JWKSURI:      c.JWKSURI,
+		SubjectType:  c.SubjectType,
+
+		PostLogoutRedirectURIs: c.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:   c.BackchannelLogoutURI,
+	}
+	switch {
+	case c.Public:
+		md.TokenEndpointAuthMethod = authMethodNone
+	case c.Secret == "":
+		md.TokenEndpointAuthMethod = authMethodPrivateKeyJWT
+	default:
+		md.TokenEndpointAuthMethod = authMethodClientSecretBasic
+	}
+	return md
+}
+
+func hashRegistrationToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// bearerToken returns the token from the request's "Authorization" header.
+func bearerToken(r *http.Request) (string, bool) {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// clientRegistrationRequest is the body of a request to register a client or
+// update its registration. Updates must identify the client being updated.
+type clientRegistrationRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+
+	clientMetadata
+}
+
+// decodeClientMetadata reads the JSON metadata from a request body.
+func decodeClientMetadata(w http.ResponseWriter, r *http.Request) (clientRegistrationRequest, *registrationError) {
+	var req clientRegistrationRequest
+	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		return req, newRegistrationErr(errInvalidClientMetadata, "Content-Type must be application/json.")
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		return req, newRegistrationErr(errInvalidClientMetadata, "Failed to decode client metadata: %v", err)
+	}
+	return req, nil
+}
+
+// handleRegister registers a new client.
+//
+// https://tools.ietf.org/html/rfc7591#section-3
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.tokenErrHelper(w, errInvalidRequest, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	authorized := false
+	for _, t := range s.registration.InitialAccessTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			authorized = true
+		}
+	}
+	if !authorized {
+		s.bearerErrHelper(w, errInvalidToken, "Invalid initial access token.", http.StatusUnauthorized)
+		return
+	}
+
+	req, rerr := decodeClientMetadata(w, r)
+	md := req.clientMetadata
+	if rerr == nil {
+		rerr = s.validateClientMetadata(&md)
+	}
+	if rerr != nil {
+		s.tokenErrHelper(w, rerr.typ, rerr.description, http.StatusBadRequest)
+		return
+	}
+
+	registrationToken := storage.NewID() + storage.NewID()
+	client := storage.Client{
+		ID:                    storage.NewID(),
+		RegistrationTokenHash: hashRegistrationToken(registrationToken),
+	}
+	applyClientMetadata(&client, md)
+
+	if err := s.storage.CreateClient(client); err != nil {
+		s.logger.Errorf("failed to create registered client: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Infof("registered client %q (%s)", client.ID, client.Name)
+
+	info := s.clientInformation(client, md)
+	info.ClientIDIssuedAt = s.now().Unix()
+	info.RegistrationAccessToken = registrationToken
+	s.writeClientInformation(w, info, http.StatusCreated)
+}
+
+// handleClientConfiguration lets a registered client read, update or delete
+// its registration.
+//
+// https://tools.ietf.org/html/rfc7592#section-2
+func (s *Server) handleClientConfiguration(w http.ResponseWriter, r *http.Request) {
+	clientID := mux.Vars(r)["client"]
+
+	token, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	client, err := s.storage.GetClient(clientID)
+	if err != nil && err != storage.ErrNotFound {
+		s.logger.Errorf("failed to get client: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	// Don't reveal if the client exists to callers without its token.
+	hash := hashRegistrationToken(token)
+	if err == storage.ErrNotFound || client.RegistrationTokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(client.RegistrationTokenHash), []byte(hash)) != 1 {
+		s.bearerErrHelper(w, errInvalidToken, "Invalid registration access token.", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.writeClientInformation(w, s.clientInformation(client, clientMetadataFromStorage(client)), http.StatusOK)
+	case "PUT":
+		s.updateRegisteredClient(w, r, client)
+	case "DELETE":
+		if err := s.storage.DeleteClient(client.ID); err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to delete registered client: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		s.logger.Infof("deleted registered client %q", client.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.tokenErrHelper(w, errInvalidRequest, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateRegisteredClient replaces the metadata of a registered client.
+//
+// https://tools.ietf.org/html/rfc7592#section-2.2
+func (s *Server) updateRegisteredClient(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	req, rerr := decodeClientMetadata(w, r)
+	if rerr == nil && req.ClientID != client.ID {
+		rerr = newRegistrationErr(errInvalidRequest, "client_id must match the client being updated.")
+	}
+	if rerr == nil && req.ClientSecret != "" && req.ClientSecret != client.Secret {
+		rerr = newRegistrationErr(errInvalidRequest, "client_secret doesn't match the client's current secret.")
+	}
+	md := req.clientMetadata
+	if rerr == nil {
+		rerr = s.validateClientMetadata(&md)
+	}
+	if rerr != nil {
+		s.tokenErrHelper(w, rerr.typ, rerr.description, http.StatusBadRequest)
+		return
+	}
+
+	var updated storage.Client
+	err := s.storage.UpdateClient(client.ID, func(old storage.Client) (storage.Client, error) {
+		applyClientMetadata(&old, md)
+		updated = old
+		return old, nil
+	})
+	if err != nil {
+		s.logger.Errorf("failed to update registered client: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	s.writeClientInformation(w, s.clientInformation(updated, md), http.StatusOK)
+}
+
+func (s *Server) writeClientInformation(w http.ResponseWriter, info clientInformation, status int) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		s.logger.Errorf("failed to marshal client information: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	w.Write(data)
+}
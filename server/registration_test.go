@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coreos/dex/storage"
+)
+
+func TestClientRegistration(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Registration = RegistrationConfig{
+			InitialAccessTokens:          []string{"initial-token"},
+			AllowedRedirectURIs:          []string{"https://*.example.com/callback"},
+			AllowedJWKSURIs:              []string{"https://*.example.com/jwks.json"},
+			AllowedBackchannelLogoutURIs: []string{"https://*.example.com/logout"},
+		}
+	})
+	defer httpServer.Close()
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	policyTests := []struct {
+		name     string
+		token    string
+		metadata string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "missing initial access token",
+			metadata: `{"redirect_uris":["https://app.example.com/callback"]}`,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "wrong initial access token",
+			token:    "wrong-token",
+			metadata: `{"redirect_uris":["https://app.example.com/callback"]}`,
+			wantCode: http.StatusUnauthorized,
+			wantErr:  errInvalidToken,
+		},
+		{
+			name:     "redirect URI not matching the policy",
+			token:    "initial-token",
+			metadata: `{"redirect_uris":["https://evil.com/callback"]}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  errInvalidRedirectURI,
+		},
+		{
+			name:     "wildcard can't match a path",
+			token:    "initial-token",
+			metadata: `{"redirect_uris":["https://evil.com/.example.com/callback"]}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  errInvalidRedirectURI,
+		},
+		{
+			name:     "wildcard can't match a query",
+			token:    "initial-token",
+			metadata: `{"redirect_uris":["https://evil.com?.example.com/callback"]}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  errInvalidRedirectURI,
+		},
+		{
+			name:     "wildcard can't match a fragment",
+			token:    "initial-token",
+			metadata: `{"redirect_uris":["https://evil.com#.example.com/callback"]}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  errInvalidRedirectURI,
+		},
+		{
+			name:     "wildcard can't match user info",
+			token:    "initial-token",
+			metadata: `{"redirect_uris":["https://app.example.com@evil.com/callback"]}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  errInvalidRedirectURI,
+		},
+		{
+			name:     "post logout redirect URI not matching the policy",
+			token:    "initial-token",
+			metadata: `{"redirect_uris":["https://app.example.com/callback"],"post_logout_redirect_uris":["https://evil.com?.example.com/callback"]}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  errInvalidClientMetadata,
+		},
+		{
+			name:     "jwks_uri not matching the policy",
+			token:    "initial-token",
+			metadata: `{"redirect_uris":["https://app.example.com/callback"],"token_endpoint_auth_method":"private_key_jwt","jwks_uri":"http://169.254.169.254/latest/meta-data"}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  errInvalidClientMetadata,
+		},
+		{
+			name:     "backchannel_logout_uri not matching the policy",
+			token:    "initial-token",
+			metadata: `{"redirect_uris":["https://app.example.com/callback"],"backchannel_logout_uri":"http://localhost:8080/admin"}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  errInvalidClientMetadata,
+		},
+		{
+			name:     "public client with redirect URIs",
+			token:    "initial-token",
+			metadata: `{"redirect_uris":["https://app.example.com/callback"],"token_endpoint_auth_method":"none"}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  errInvalidRedirectURI,
+		},
+		{
+			name:     "privileged grant type",
+			token:    "initial-token",
+			metadata: `{"redirect_uris":["https://app.example.com/callback"],"grant_types":["authorization_code","password"]}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  errInvalidClientMetadata,
+		},
+	}
+	for _, tc := range policyTests {
+		rr := do("POST", "/register", tc.token, tc.metadata)
+		if rr.Code != tc.wantCode {
+			t.Errorf("%s: expected %d got %d: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if tc.wantErr != "" && !strings.Contains(rr.Body.String(), tc.wantErr) {
+			t.Errorf("%s: expected error %q got %s", tc.name, tc.wantErr, rr.Body.String())
+		}
+	}
+
+	// Public clients redirect to localhost, so don't need redirect URIs.
+	if rr := do("POST", "/register", "initial-token", `{"token_endpoint_auth_method":"none"}`); rr.Code != http.StatusCreated {
+		t.Errorf("register public client: expected %d got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	// Register a client.
+	rr := do("POST", "/register", "initial-token", `{
+		"redirect_uris": ["https://app.example.com/callback"],
+		"client_name": "App",
+		"grant_types": ["authorization_code", "refresh_token"],
+		"trusted_peers": ["other-client"]
+	}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("register client: expected %d got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var info clientInformation
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatalf("decode client information: %v", err)
+	}
+	if info.ClientID == "" || info.ClientSecret == "" || info.RegistrationAccessToken == "" {
+		t.Fatalf("expected client ID, secret and registration access token, got %s", rr.Body.String())
+	}
+	if info.RegistrationClientURI != httpServer.URL+"/register/"+info.ClientID {
+		t.Errorf("unexpected registration_client_uri %q", info.RegistrationClientURI)
+	}
+
+	client, err := server.storage.GetClient(info.ClientID)
+	if err != nil {
+		t.Fatalf("get registered client: %v", err)
+	}
+	if client.Secret != info.ClientSecret || client.Name != "App" || len(client.TrustedPeers) != 0 {
+		t.Errorf("unexpected registered client %#v", client)
+	}
+	if client.RegistrationTokenHash == info.RegistrationAccessToken {
+		t.Errorf("registration access token stored in plain text")
+	}
+
+	configPath := "/register/" + info.ClientID
+
+	// Read the registration.
+	if rr := do("GET", configPath, "wrong-token", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("read with wrong token: expected %d got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if rr := do("GET", configPath, info.RegistrationAccessToken, ""); rr.Code != http.StatusOK {
+		t.Errorf("read client: expected %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// Update the registration.
+	if rr := do("PUT", configPath, info.RegistrationAccessToken, `{
+		"client_id": "another-client",
+		"redirect_uris": ["https://app.example.com/callback"]
+	}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("update with wrong client_id: expected %d got %d", http.StatusBadRequest, rr.Code)
+	}
+	rr = do("PUT", configPath, info.RegistrationAccessToken, `{
+		"client_id": "`+info.ClientID+`",
+		"redirect_uris": ["https://new.example.com/callback"],
+		"client_name": "New App"
+	}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update client: expected %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	client, err = server.storage.GetClient(info.ClientID)
+	if err != nil {
+		t.Fatalf("get registered client: %v", err)
+	}
+	if client.Name != "New App" || client.RedirectURIs[0] != "https://new.example.com/callback" {
+		t.Errorf("client wasn't updated: %#v", client)
+	}
+	if client.Secret != info.ClientSecret {
+		t.Errorf("update changed the client's secret")
+	}
+
+	// Delete the registration.
+	if rr := do("DELETE", configPath, info.RegistrationAccessToken, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("delete client: expected %d got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if _, err := server.storage.GetClient(info.ClientID); err != storage.ErrNotFound {
+		t.Errorf("expected client to be deleted, got %v", err)
+	}
+	if rr := do("GET", configPath, info.RegistrationAccessToken, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("read deleted client: expected %d got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+func TestClientRegistrationCannotManageOtherClients(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Registration = RegistrationConfig{InitialAccessTokens: []string{"initial-token"}}
+	})
+	defer httpServer.Close()
+
+	// Clients created through the API have no registration access token.
+	if err := server.storage.CreateClient(storage.Client{ID: "api-client", Secret: "secret"}); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	req := httptest.NewRequest("DELETE", "/register/api-client", nil)
+	req.Header.Set("Authorization", "Bearer guessed-token")
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if _, err := server.storage.GetClient("api-client"); err != nil {
+		t.Errorf("get client: %v", err)
+	}
+}
+
+func TestURIMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		uri     string
+		want    bool
+	}{
+		{"https://*.example.com/callback", "https://app.example.com/callback", true},
+		{"https://*.example.com/callback", "https://APP.example.com/callback", true},
+		{"https://*.example.com/callback", "https://app.example.com:443/callback", false},
+		{"https://*.example.com/callback", "http://app.example.com/callback", false},
+		{"https://*.example.com/callback", "https://a.b.example.com/callback", false},
+		{"https://*.example.com/callback", "https://app.example.com/callback/other", false},
+		{"https://*.example.com/callback", "https://app.example.com/callback?x=1", false},
+		{"https://*.example.com/callback", "https://evil.com?.example.com/callback", false},
+		{"https://*.example.com/callback", "https://evil.com#.example.com/callback", false},
+		{"https://*.example.com/callback", "https://evil.com%2f.example.com/callback", false},
+		{"https://*.example.com/callback", "https://user@app.example.com/callback", false},
+		{"https://app.example.com/callback", "https://app.example.com/callback", true},
+		{"https://app.example.com:8443/callback", "https://app.example.com:8443/callback", true},
+		{"https://app.example.com:8443/callback", "https://app.example.com/callback", false},
+		{"http://localhost/callback", "http://localhost/callback", true},
+	}
+	for _, tc := range tests {
+		if err := validateURIPattern(tc.pattern); err != nil {
+			t.Errorf("pattern %q: %v", tc.pattern, err)
+			continue
+		}
+		if got := uriMatches(tc.pattern, tc.uri); got != tc.want {
+			t.Errorf("pattern %q, URI %q: expected %t got %t", tc.pattern, tc.uri, tc.want, got)
+		}
+	}
+
+	for _, pattern := range []string{
+		"https://*.example.com/*",
+		"https://app.example.com:*/callback",
+		"*://app.example.com/callback",
+		"https://app.example.com/callback?x=*",
+		"https://*@app.example.com/callback",
+		"/callback",
+	} {
+		if err := validateURIPattern(pattern); err == nil {
+			t.Errorf("expected pattern %q to be rejected", pattern)
+		}
+	}
+}
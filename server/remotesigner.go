@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"google.golang.org/grpc"
+	jose "gopkg.in/square/go-jose.v2"
+
+	signerapi "github.com/coreos/dex/api/signer"
+)
+
+const (
+	// How long to wait for the remote signer to answer.
+	remoteSignerTimeout = 10 * time.Second
+
+	// How long public keys fetched from the remote signer are used before
+	// fetching them again.
+	remoteSignerKeysValidFor = 30 * time.Second
+)
+
+// RemoteSignerConfig configures a signer which has a separate process, holding
+// the private keys, sign tokens. The process must implement the gRPC service
+// defined in api/signer/signer.proto.
+type RemoteSignerConfig struct {
+	// Path of the unix socket the signer listens on.
+	Socket string `json:"socket"`
+}
+
+// Open connects to the remote signer. The connection is closed once the
+// context is canceled.
+func (c *RemoteSignerConfig) Open(ctx context.Context, logger logrus.FieldLogger) (Signer, error) {
+	if c.Socket == "" {
+		return nil, errors.New("no socket specified")
+	}
+	dialer := func(addr string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout("unix", addr, timeout)
+	}
+	conn, err := grpc.Dial(c.Socket, grpc.WithInsecure(), grpc.WithDialer(dialer))
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %v", c.Socket, err)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return &remoteSigner{client: signerapi.NewSignerClient(conn), now: time.Now}, nil
+}
+
+type remoteSigner struct {
+	client signerapi.SignerClient
+	now    func() time.Time
+
+	mu sync.Mutex
+	// Public keys last fetched from the signer.
+	keys      *remoteSignerKeys
+	fetchedAt time.Time
+}
+
+type remoteSignerKeys struct {
+	alg          jose.SignatureAlgorithm
+	pubKeys      []*jose.JSONWebKey
+	nextRotation time.Time
+}
+
+// getKeys returns the signer's public keys, fetching them if the cached ones are
+// stale.
+func (s *remoteSigner) getKeys() (*remoteSignerKeys, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.keys != nil && now.Before(s.fetchedAt.Add(remoteSignerKeysValidFor)) &&
+		(s.keys.nextRotation.IsZero() || now.Before(s.keys.nextRotation)) {
+		return s.keys, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+	defer cancel()
+	resp, err := s.client.GetKeys(ctx, &signerapi.GetKeysReq{})
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: get keys: %v", err)
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(resp.Jwks, &jwks); err != nil {
+		return nil, fmt.Errorf("remote signer: parse keys: %v", err)
+	}
+	if len(jwks.Keys) == 0 {
+		return nil, errors.New("remote signer: no public keys found")
+	}
+	if resp.Algorithm == "" {
+		return nil, errors.New("remote signer: no signing algorithm")
+	}
+	keys := &remoteSignerKeys{alg: jose.SignatureAlgorithm(resp.Algorithm)}
+	for i := range jwks.Keys {
+		keys.pubKeys = append(keys.pubKeys, &jwks.Keys[i])
+	}
+	if resp.NextRotation != 0 {
+		keys.nextRotation = time.Unix(resp.NextRotation, 0)
+	}
+
+	s.keys = keys
+	s.fetchedAt = now
+	return keys, nil
+}
+
+func (s *remoteSigner) Algorithm() (jose.SignatureAlgorithm, error) {
+	keys, err := s.getKeys()
+	if err != nil {
+		return "", err
+	}
+	return keys.alg, nil
+}
+
+func (s *remoteSigner) Sign(payload []byte, typ string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+	defer cancel()
+	resp, err := s.client.Sign(ctx, &signerapi.SignReq{Payload: payload, Type: typ})
+	if err != nil {
+		return "", fmt.Errorf("remote signer: sign: %v", err)
+	}
+	return resp.Jws, nil
+}
+
+func (s *remoteSigner) ValidationKeys() ([]*jose.JSONWebKey, time.Time, error) {
+	keys, err := s.getKeys()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return keys.pubKeys, keys.nextRotation, nil
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/dex/storage"
+)
+
+const (
+	// Prefix of the "request_uri" returned for pushed authorization requests.
+	//
+	// https://tools.ietf.org/html/rfc9126#section-2.2
+	requestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+	// How long clients have to redirect the end user after pushing an
+	// authorization request.
+	pushedAuthRequestValidFor = time.Minute
+
+	// Request objects must expire within this duration, so one which leaks
+	// can't be replayed indefinitely.
+	//
+	// https://openid.net/specs/openid-financial-api-part-2-1_0.html
+	maxRequestObjectLifetime = time.Hour
+)
+
+// requestObjectClaims are the JWT claims of a signed request object. The other
+// claims are authorization request parameters.
+//
+// https://tools.ietf.org/html/rfc9101#section-4
+type requestObjectClaims struct {
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	Expiry    int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	ClientID  string   `json:"client_id"`
+}
+
+// parseRequestObject verifies a request object passed in the "request"
+// parameter was signed by the client, and returns the authorization request
+// parameters it holds.
+//
+// https://tools.ietf.org/html/rfc9101
+func (s *Server) parseRequestObject(client storage.Client, requestObject string) (url.Values, error) {
+	jws, payload, err := parseUnverifiedJWT(requestObject)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyClientSignature(client, jws); err != nil {
+		return nil, err
+	}
+
+	var claims requestObjectClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to decode request object claims: %v", err)
+	}
+	if claims.ClientID != "" && claims.ClientID != client.ID {
+		return nil, fmt.Errorf("request object is for client %q", claims.ClientID)
+	}
+	if claims.Issuer != "" && claims.Issuer != client.ID {
+		return nil, fmt.Errorf("request object issued by %q", claims.Issuer)
+	}
+	// Require an audience and expiry so request objects can't be replayed
+	// to another server or indefinitely.
+	//
+	// https://tools.ietf.org/html/rfc9101#section-6.3
+	if !claims.Audience.contains(s.issuerURL.String()) {
+		return nil, fmt.Errorf("request object audience %q doesn't include the issuer", claims.Audience)
+	}
+	if claims.Expiry == 0 {
+		return nil, errors.New(`request object has no "exp" claim`)
+	}
+	now := s.now()
+	expiry := time.Unix(claims.Expiry, 0)
+	if !now.Before(expiry) {
+		return nil, errors.New("request object has expired")
+	}
+	if expiry.Sub(now) > maxRequestObjectLifetime {
+		return nil, fmt.Errorf("request object must expire within %s", maxRequestObjectLifetime)
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, errors.New("request object isn't valid yet")
+	}
+
+	// Decode numbers as written, so parameters like "max_age" aren't
+	// reformatted as floats.
+	var values map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(payload))
+	d.UseNumber()
+	if err := d.Decode(&values); err != nil {
+		return nil, fmt.Errorf("failed to decode request object claims: %v", err)
+	}
+
+	params := url.Values{}
+	for name, value := range values {
+		switch name {
+		case "iss", "aud", "exp", "nbf", "iat", "jti":
+			// JWT claims rather than authorization request parameters.
+			continue
+		case "request", "request_uri":
+			return nil, fmt.Errorf("request object can't contain the %q parameter", name)
+		}
+
+		switch v := value.(type) {
+		case nil:
+		case string:
+			params.Set(name, v)
+		case json.Number:
+			params.Set(name, v.String())
+		case bool:
+			params.Set(name, strconv.FormatBool(v))
+		case []interface{}:
+			// Parameters which may be repeated, such as "resource".
+			for _, e := range v {
+				str, ok := e.(string)
+				if !ok {
+					return nil, fmt.Errorf("request object parameter %q must be an array of strings", name)
+				}
+				params.Add(name, str)
+			}
+		default:
+			// JSON objects, such as the "claims" parameter.
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode request object parameter %q: %v", name, err)
+			}
+			params.Set(name, string(data))
+		}
+	}
+	params.Set("client_id", client.ID)
+	return params, nil
+}
+
+// pushedAuthRequestParams looks up the parameters a client pushed ahead of an
+// authorization request. Pushed requests can only be used once.
+//
+// https://tools.ietf.org/html/rfc9126#section-4
+func (s *Server) pushedAuthRequestParams(clientID, requestURI string) (url.Values, *authErr) {
+	invalid := &authErr{"", "", errInvalidRequestURI, "Invalid or expired request_uri.", ""}
+	if !strings.HasPrefix(requestURI, requestURIPrefix) {
+		return nil, invalid
+	}
+	id := strings.TrimPrefix(requestURI, requestURIPrefix)
+
+	p, err := s.storage.GetPushedAuthRequest(id)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("Failed to get pushed auth request: %v", err)
+			return nil, &authErr{"", "", errServerError, "", ""}
+		}
+		return nil, invalid
+	}
+	if p.ClientID != clientID || s.now().After(p.Expiry) {
+		return nil, invalid
+	}
+	if err := s.storage.DeletePushedAuthRequest(id); err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("Failed to delete pushed auth request: %v", err)
+			return nil, &authErr{"", "", errServerError, "", ""}
+		}
+		// Another request used it first.
+		return nil, invalid
+	}
+	return url.Values(p.Params), nil
+}
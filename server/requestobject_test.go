@@ -0,0 +1,321 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/coreos/dex/storage"
+)
+
+func TestRequestObjects(t *testing.T) {
+	const redirectURI = "https://example.com/callback"
+
+	pub := jose.JSONWebKey{Key: testKey.Public(), KeyID: "client-key", Algorithm: string(jose.RS256), Use: "sig"}
+	jwks := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{pub}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{ID: "keys", JWKS: jwks, RedirectURIs: []string{redirectURI}},
+			{ID: "hmac", Secret: "secret", RedirectURIs: []string{redirectURI}},
+			{ID: "signed", JWKS: jwks, RedirectURIs: []string{redirectURI}, RequireSignedRequestObject: true},
+		})
+	})
+	defer httpServer.Close()
+
+	sign := func(key jose.SigningKey, claims map[string]interface{}) string {
+		signer, err := jose.NewSigner(key, nil)
+		if err != nil {
+			t.Fatalf("failed to create signer: %v", err)
+		}
+		payload, err := json.Marshal(claims)
+		if err != nil {
+			t.Fatalf("failed to marshal claims: %v", err)
+		}
+		jws, err := signer.Sign(payload)
+		if err != nil {
+			t.Fatalf("failed to sign request object: %v", err)
+		}
+		requestObject, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatalf("failed to serialize request object: %v", err)
+		}
+		return requestObject
+	}
+	rsaKey := jose.SigningKey{Algorithm: jose.RS256, Key: &jose.JSONWebKey{Key: testKey, KeyID: "client-key"}}
+	hmacKey := jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")}
+	claimsFor := func(clientID string, extra map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"iss":           clientID,
+			"aud":           server.issuerURL.String(),
+			"exp":           time.Now().Add(time.Minute).Unix(),
+			"client_id":     clientID,
+			"response_type": "code",
+			"redirect_uri":  redirectURI,
+			"scope":         "openid email",
+			"state":         "state",
+			"max_age":       300,
+			"resource":      []string{"https://api.example.com", "https://other.example.com"},
+		}
+		for k, v := range extra {
+			if v == nil {
+				delete(claims, k)
+				continue
+			}
+			claims[k] = v
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		params url.Values
+
+		wantErr string
+	}{
+		{
+			name: "signed with client keys",
+			params: url.Values{
+				"client_id": {"keys"},
+				"request":   {sign(rsaKey, claimsFor("keys", nil))},
+			},
+		},
+		{
+			name: "signed with client secret",
+			params: url.Values{
+				"client_id": {"hmac"},
+				"request":   {sign(hmacKey, claimsFor("hmac", nil))},
+			},
+		},
+		{
+			name: "parameters outside the request object are ignored",
+			params: url.Values{
+				"client_id": {"keys"},
+				"request":   {sign(rsaKey, claimsFor("keys", nil))},
+				"state":     {"other"},
+				"scope":     {"openid groups"},
+			},
+		},
+		{
+			name: "signed with the wrong key",
+			params: url.Values{
+				"client_id": {"keys"},
+				"request":   {sign(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")}, claimsFor("keys", nil))},
+			},
+			wantErr: errInvalidRequestObject,
+		},
+		{
+			name: "for another client",
+			params: url.Values{
+				"client_id": {"keys"},
+				"request":   {sign(rsaKey, claimsFor("hmac", nil))},
+			},
+			wantErr: errInvalidRequestObject,
+		},
+		{
+			name: "for another issuer",
+			params: url.Values{
+				"client_id": {"keys"},
+				"request":   {sign(rsaKey, claimsFor("keys", map[string]interface{}{"aud": "https://example.com"}))},
+			},
+			wantErr: errInvalidRequestObject,
+		},
+		{
+			name: "expired",
+			params: url.Values{
+				"client_id": {"keys"},
+				"request":   {sign(rsaKey, claimsFor("keys", map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}))},
+			},
+			wantErr: errInvalidRequestObject,
+		},
+		{
+			name: "without an audience",
+			params: url.Values{
+				"client_id": {"keys"},
+				"request":   {sign(rsaKey, claimsFor("keys", map[string]interface{}{"aud": nil}))},
+			},
+			wantErr: errInvalidRequestObject,
+		},
+		{
+			name: "without an expiry",
+			params: url.Values{
+				"client_id": {"keys"},
+				"request":   {sign(rsaKey, claimsFor("keys", map[string]interface{}{"exp": nil}))},
+			},
+			wantErr: errInvalidRequestObject,
+		},
+		{
+			name: "expires too late",
+			params: url.Values{
+				"client_id": {"keys"},
+				"request":   {sign(rsaKey, claimsFor("keys", map[string]interface{}{"exp": time.Now().Add(2 * time.Hour).Unix()}))},
+			},
+			wantErr: errInvalidRequestObject,
+		},
+		{
+			name: "nested request",
+			params: url.Values{
+				"client_id": {"keys"},
+				"request":   {sign(rsaKey, claimsFor("keys", map[string]interface{}{"request": "foo"}))},
+			},
+			wantErr: errInvalidRequestObject,
+		},
+		{
+			name: "required and signed",
+			params: url.Values{
+				"client_id": {"signed"},
+				"request":   {sign(rsaKey, claimsFor("signed", nil))},
+			},
+		},
+		{
+			name: "required but missing",
+			params: url.Values{
+				"client_id":     {"signed"},
+				"response_type": {"code"},
+				"redirect_uri":  {redirectURI},
+				"scope":         {"openid"},
+				"state":         {"state"},
+			},
+			wantErr: errInvalidRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", "/auth?"+tc.params.Encode(), nil)
+		authReq, login, err := server.parseAuthorizationRequest(req)
+		if tc.wantErr != "" {
+			if err == nil {
+				t.Errorf("%s: expected error %q", tc.name, tc.wantErr)
+			} else if err.Type != tc.wantErr {
+				t.Errorf("%s: expected error %q, got %q", tc.name, tc.wantErr, err.Type)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if authReq.State != "state" {
+			t.Errorf("%s: expected state from request object, got %q", tc.name, authReq.State)
+		}
+		if strings.Join(authReq.Scopes, " ") != "openid email" {
+			t.Errorf("%s: expected scopes from request object, got %q", tc.name, authReq.Scopes)
+		}
+		if len(authReq.Resources) != 2 {
+			t.Errorf("%s: expected resources from request object, got %q", tc.name, authReq.Resources)
+		}
+		if !login.hasMaxAge || login.maxAge != 300*time.Second {
+			t.Errorf("%s: expected max_age from request object, got %v", tc.name, login.maxAge)
+		}
+	}
+}
+
+func TestPushedAuthRequests(t *testing.T) {
+	const redirectURI = "https://example.com/callback"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{ID: "testclient", Secret: "secret", RedirectURIs: []string{redirectURI}},
+			{ID: "otherclient", Secret: "secret", RedirectURIs: []string{redirectURI}},
+			{ID: "pushed", Secret: "secret", RedirectURIs: []string{redirectURI}, RequirePushedAuthRequests: true},
+		})
+		c.Now = func() time.Time { return now }
+	})
+	defer httpServer.Close()
+
+	authParams := func(clientID string) url.Values {
+		return url.Values{
+			"client_id":     {clientID},
+			"response_type": {"code"},
+			"redirect_uri":  {redirectURI},
+			"scope":         {"openid"},
+			"state":         {"state"},
+		}
+	}
+
+	// push sends the parameters to the pushed authorization request endpoint,
+	// returning the request URI.
+	push := func(clientID string, params url.Values, wantCode int) string {
+		req := httptest.NewRequest("POST", "/par", strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, "secret")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != wantCode {
+			t.Errorf("expected status %d pushing request, got %d: %s", wantCode, rr.Code, rr.Body.String())
+			return ""
+		}
+		if rr.Code != http.StatusCreated {
+			return ""
+		}
+		var resp pushedAuthRequestResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if !strings.HasPrefix(resp.RequestURI, requestURIPrefix) || resp.ExpiresIn != 60 {
+			t.Errorf("unexpected response: %s", rr.Body.String())
+		}
+		return resp.RequestURI
+	}
+	authorize := func(clientID, requestURI string) (storage.AuthRequest, *authErr) {
+		v := url.Values{"client_id": {clientID}, "request_uri": {requestURI}}
+		authReq, _, err := server.parseAuthorizationRequest(httptest.NewRequest("GET", "/auth?"+v.Encode(), nil))
+		return authReq, err
+	}
+
+	requestURI := push("testclient", authParams("testclient"), http.StatusCreated)
+	authReq, err := authorize("testclient", requestURI)
+	if err != nil {
+		t.Fatalf("failed to use pushed request: %v", err)
+	}
+	if authReq.State != "state" || authReq.RedirectURI != redirectURI {
+		t.Errorf("expected pushed parameters, got %#v", authReq)
+	}
+
+	// Request URIs can only be used once.
+	if _, err := authorize("testclient", requestURI); err == nil || err.Type != errInvalidRequestURI {
+		t.Errorf("expected reused request_uri to be rejected, got %v", err)
+	}
+
+	// Only the client that pushed the request can use it.
+	requestURI = push("testclient", authParams("testclient"), http.StatusCreated)
+	if _, err := authorize("otherclient", requestURI); err == nil || err.Type != errInvalidRequestURI {
+		t.Errorf("expected request_uri of another client to be rejected, got %v", err)
+	}
+
+	// Request URIs expire.
+	now = now.Add(2 * pushedAuthRequestValidFor)
+	if _, err := authorize("testclient", requestURI); err == nil || err.Type != errInvalidRequestURI {
+		t.Errorf("expected expired request_uri to be rejected, got %v", err)
+	}
+
+	// Invalid requests are rejected when pushed.
+	invalid := authParams("testclient")
+	invalid.Set("redirect_uri", "https://evil.example.com/callback")
+	push("testclient", invalid, http.StatusBadRequest)
+	push("testclient", authParams("otherclient"), http.StatusBadRequest)
+
+	// Clients can be required to push their requests.
+	v := authParams("pushed")
+	if _, _, err := server.parseAuthorizationRequest(httptest.NewRequest("GET", "/auth?"+v.Encode(), nil)); err == nil || err.Type != errInvalidRequest {
+		t.Errorf("expected request which wasn't pushed to be rejected, got %v", err)
+	}
+	requestURI = push("pushed", authParams("pushed"), http.StatusCreated)
+	if _, err := authorize("pushed", requestURI); err != nil {
+		t.Errorf("failed to use pushed request: %v", err)
+	}
+}
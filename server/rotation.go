@@ -2,13 +2,18 @@ package server
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"time"
 
+	"golang.org/x/crypto/ed25519"
 	"gopkg.in/square/go-jose.v2"
 
 	"github.com/Sirupsen/logrus"
@@ -25,9 +30,12 @@ type rotationStrategy struct {
 	// signatues?
 	idTokenValidFor time.Duration
 
-	// Keys are always RSA keys. Though cryptopasta recommends ECDSA keys, not every
-	// client may support these (e.g. github.com/coreos/go-oidc/oidc).
-	key func() (*rsa.PrivateKey, error)
+	// The algorithm keys are generated for. If the current signing key is for a
+	// different algorithm, a key for this one is published straight away and
+	// replaces the signing key once clients' cached keys have expired.
+	algorithm jose.SignatureAlgorithm
+
+	key func() (crypto.Signer, error)
 }
 
 // staticRotationStrategy returns a strategy which never rotates keys.
@@ -36,22 +44,63 @@ func staticRotationStrategy(key *rsa.PrivateKey) rotationStrategy {
 		// Setting these values to 100 years is easier than having a flag indicating no rotation.
 		rotationFrequency: time.Hour * 8760 * 100,
 		idTokenValidFor:   time.Hour * 8760 * 100,
-		key:               func() (*rsa.PrivateKey, error) { return key, nil },
+		algorithm:         jose.RS256,
+		key:               func() (crypto.Signer, error) { return key, nil },
 	}
 }
 
-// defaultRotationStrategy returns a strategy which rotates keys every provided period,
-// holding onto the public parts for some specified amount of time.
-func defaultRotationStrategy(rotationFrequency, idTokenValidFor time.Duration) rotationStrategy {
+// defaultRotationStrategy returns a strategy which rotates keys for the given
+// algorithm every provided period, holding onto the public parts for some
+// specified amount of time.
+func defaultRotationStrategy(rotationFrequency, idTokenValidFor time.Duration, alg jose.SignatureAlgorithm) (rotationStrategy, error) {
+	key, err := keyGenerator(alg)
+	if err != nil {
+		return rotationStrategy{}, err
+	}
 	return rotationStrategy{
 		rotationFrequency: rotationFrequency,
 		idTokenValidFor:   idTokenValidFor,
-		key: func() (*rsa.PrivateKey, error) {
+		algorithm:         alg,
+		key:               key,
+	}, nil
+}
+
+// keyGenerator returns a function generating signing keys for an algorithm.
+//
+// RS256 is the default, since OIDC mandates that clients support it. Not every
+// client supports ECDSA or Ed25519 keys (e.g. github.com/coreos/go-oidc/oidc).
+func keyGenerator(alg jose.SignatureAlgorithm) (func() (crypto.Signer, error), error) {
+	switch alg {
+	case jose.RS256:
+		return func() (crypto.Signer, error) {
 			return rsa.GenerateKey(rand.Reader, 2048)
-		},
+		}, nil
+	case jose.ES256:
+		return func() (crypto.Signer, error) {
+			return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}, nil
+	case jose.ES384:
+		return func() (crypto.Signer, error) {
+			return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		}, nil
+	case jose.EdDSA:
+		return func() (crypto.Signer, error) {
+			_, priv, err := ed25519.GenerateKey(rand.Reader)
+			return priv, err
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
 	}
 }
 
+// minKeysCacheAge is the shortest time clients are told to cache the public
+// keys for, even if the signing key is about to rotate.
+const minKeysCacheAge = 2 * time.Minute
+
+// errAlreadyRotated is returned by the keys updater if another instance
+// rotated the keys in the meantime.
+var errAlreadyRotated = errors.New("keys already rotated")
+
 type keyRotater struct {
 	storage.Storage
 
@@ -93,10 +142,14 @@ func (k keyRotater) rotate() error {
 	if err != nil && err != storage.ErrNotFound {
 		return fmt.Errorf("get keys: %v", err)
 	}
-	if k.now().Before(keys.NextRotation) {
+	if k.now().Before(keys.NextRotation) && !k.needsNextKey(keys) {
 		return nil
 	}
-	k.logger.Infof("keys expired, rotating")
+	if k.needsNextKey(keys) {
+		k.logger.Infof("signing algorithm changed from %s to %s, publishing new key", keys.SigningKey.Algorithm, k.strategy.algorithm)
+	} else {
+		k.logger.Infof("keys expired, rotating")
+	}
 
 	// Generate the key outside of a storage transaction.
 	key, err := k.strategy.key()
@@ -111,13 +164,13 @@ func (k keyRotater) rotate() error {
 	priv := &jose.JSONWebKey{
 		Key:       key,
 		KeyID:     keyID,
-		Algorithm: "RS256",
+		Algorithm: string(k.strategy.algorithm),
 		Use:       "sig",
 	}
 	pub := &jose.JSONWebKey{
 		Key:       key.Public(),
 		KeyID:     keyID,
-		Algorithm: "RS256",
+		Algorithm: string(k.strategy.algorithm),
 		Use:       "sig",
 	}
 
@@ -125,10 +178,25 @@ func (k keyRotater) rotate() error {
 	err = k.Storage.UpdateKeys(func(keys storage.Keys) (storage.Keys, error) {
 		tNow := k.now()
 
+		if k.needsNextKey(keys) {
+			// Publish a key for the new algorithm without signing with it yet.
+			// Clients may have cached the public keys until the next rotation,
+			// or for a couple of minutes if it's due, so delay the rotation
+			// until those caches have expired.
+			keys.NextSigningKey = priv
+			keys.NextSigningKeyPub = pub
+			if keys.NextRotation.Before(tNow) {
+				keys.NextRotation = tNow
+			}
+			keys.NextRotation = keys.NextRotation.Add(minKeysCacheAge)
+			nextRotation = keys.NextRotation
+			return keys, nil
+		}
+
 		// if you are running multiple instances of dex, another instance
 		// could have already rotated the keys.
 		if tNow.Before(keys.NextRotation) {
-			return storage.Keys{}, nil
+			return storage.Keys{}, errAlreadyRotated
 		}
 
 		expired := func(key storage.VerificationKey) bool {
@@ -147,7 +215,8 @@ func (k keyRotater) rotate() error {
 
 		if keys.SigningKeyPub != nil {
 			// Move current signing key to a verification only key, throwing
-			// away the private part.
+			// away the private part. When migrating between algorithms, this
+			// keeps the old key published alongside the new one.
 			verificationKey := storage.VerificationKey{
 				PublicKey: keys.SigningKeyPub,
 				// After demoting the signing key, keep the token around for at least
@@ -159,15 +228,39 @@ func (k keyRotater) rotate() error {
 			keys.VerificationKeys = append(keys.VerificationKeys, verificationKey)
 		}
 
+		// Sign with the key published ahead of time, if it's for the right
+		// algorithm.
+		if k.nextKeyPublished(keys) {
+			priv, pub = keys.NextSigningKey, keys.NextSigningKeyPub
+		}
+
 		nextRotation = k.now().Add(k.strategy.rotationFrequency)
 		keys.SigningKey = priv
 		keys.SigningKeyPub = pub
+		keys.NextSigningKey = nil
+		keys.NextSigningKeyPub = nil
 		keys.NextRotation = nextRotation
 		return keys, nil
 	})
 	if err != nil {
+		if err == errAlreadyRotated {
+			return nil
+		}
 		return err
 	}
-	k.logger.Infof("keys rotated, next rotation: %s", nextRotation)
+	k.logger.Infof("keys updated, next rotation: %s", nextRotation)
 	return nil
 }
+
+// needsNextKey reports whether the current signing key was generated for a
+// different algorithm than the strategy's, and no key for the strategy's
+// algorithm has been published yet.
+func (k keyRotater) needsNextKey(keys storage.Keys) bool {
+	return keys.SigningKey != nil && keys.SigningKey.Algorithm != string(k.strategy.algorithm) && !k.nextKeyPublished(keys)
+}
+
+// nextKeyPublished reports whether the key published ahead of the next
+// rotation is for the strategy's algorithm.
+func (k keyRotater) nextKeyPublished(keys storage.Keys) bool {
+	return keys.NextSigningKey != nil && keys.NextSigningKey.Algorithm == string(k.strategy.algorithm)
+}
@@ -2,11 +2,14 @@ package server
 
 import (
 	"os"
+	"reflect"
 	"sort"
 	"testing"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	jose "gopkg.in/square/go-jose.v2"
+
 	"github.com/coreos/dex/storage"
 	"github.com/coreos/dex/storage/memory"
 )
@@ -72,9 +75,13 @@ func TestKeyRotater(t *testing.T) {
 		Level:     logrus.DebugLevel,
 	}
 
+	strategy, err := defaultRotationStrategy(rotationFrequency, validFor, jose.RS256)
+	if err != nil {
+		t.Fatal(err)
+	}
 	r := &keyRotater{
 		Storage:  memory.New(l),
-		strategy: defaultRotationStrategy(rotationFrequency, validFor),
+		strategy: strategy,
 		now:      func() time.Time { return now },
 		logger:   l,
 	}
@@ -99,3 +106,113 @@ func TestKeyRotater(t *testing.T) {
 		}
 	}
 }
+
+func TestKeyRotaterAlgorithmChange(t *testing.T) {
+	now := time.Now()
+
+	l := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: &logrus.TextFormatter{DisableColors: true},
+		Level:     logrus.DebugLevel,
+	}
+
+	newStrategy := func(alg jose.SignatureAlgorithm) rotationStrategy {
+		strategy, err := defaultRotationStrategy(time.Hour, 24*time.Hour, alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strategy
+	}
+
+	r := &keyRotater{
+		Storage:  memory.New(l),
+		strategy: newStrategy(jose.RS256),
+		now:      func() time.Time { return now },
+		logger:   l,
+	}
+	if err := r.rotate(); err != nil {
+		t.Fatal(err)
+	}
+	rsaKeyID := signingKeyID(t, r.Storage)
+
+	// Changing the algorithm publishes a key for it straight away, but only
+	// signs with it once clients' cached keys have expired.
+	for _, alg := range []jose.SignatureAlgorithm{jose.ES256, jose.ES384, jose.EdDSA} {
+		before, err := r.Storage.GetKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r.strategy = newStrategy(alg)
+		if err := r.rotate(); err != nil {
+			t.Fatal(err)
+		}
+		keys, err := r.Storage.GetKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys.SigningKey.KeyID != before.SigningKey.KeyID {
+			t.Fatalf("%s: expected signing key not to change before the new key was published", alg)
+		}
+		if keys.NextSigningKeyPub == nil || keys.NextSigningKeyPub.Algorithm != string(alg) {
+			t.Fatalf("%s: expected key for the new algorithm to be published, got %v", alg, keys.NextSigningKeyPub)
+		}
+		if min := before.NextRotation.Add(minKeysCacheAge); keys.NextRotation.Before(min) {
+			t.Errorf("%s: expected rotation to wait until cached keys expire at %s, got %s", alg, min, keys.NextRotation)
+		}
+		pubKeys, _, err := storageSigner{r.Storage}.ValidationKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := pubKeys[len(pubKeys)-1]; last.KeyID != keys.NextSigningKeyPub.KeyID {
+			t.Errorf("%s: expected next signing key in validation keys", alg)
+		}
+		nextKeyID := keys.NextSigningKeyPub.KeyID
+
+		// Rotating again before then doesn't change anything.
+		if err := r.rotate(); err != nil {
+			t.Fatal(err)
+		}
+		if again, err := r.Storage.GetKeys(); err != nil {
+			t.Fatal(err)
+		} else if again.SigningKey.KeyID != before.SigningKey.KeyID || again.NextSigningKeyPub.KeyID != nextKeyID {
+			t.Fatalf("%s: expected keys not to change before the next rotation", alg)
+		}
+
+		now = keys.NextRotation.Add(time.Second)
+		if err := r.rotate(); err != nil {
+			t.Fatal(err)
+		}
+		if keys, err = r.Storage.GetKeys(); err != nil {
+			t.Fatal(err)
+		}
+		if keys.SigningKey.KeyID != nextKeyID || keys.NextSigningKey != nil {
+			t.Errorf("%s: expected published key to become the signing key", alg)
+		}
+		gotAlg, err := signatureAlgorithm(keys.SigningKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotAlg != alg || keys.SigningKeyPub.Algorithm != string(alg) {
+			t.Errorf("expected %s signing key, got %s key published as %s", alg, gotAlg, keys.SigningKeyPub.Algorithm)
+		}
+	}
+
+	// Keys for the previous algorithms stay published until the tokens they
+	// signed expire.
+	if ids := verificationKeyIDs(t, r.Storage); len(ids) != 3 || ids[0] != rsaKeyID {
+		t.Errorf("expected previous keys to be kept for verification, got %q", ids)
+	}
+	s := &Server{signer: storageSigner{r.Storage}}
+	algs, err := s.publishedSigningAlgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"EdDSA", "RS256", "ES256", "ES384"}; !reflect.DeepEqual(algs, want) {
+		t.Errorf("expected published algorithms %q, got %q", want, algs)
+	}
+
+	if _, err := defaultRotationStrategy(time.Hour, 24*time.Hour, jose.HS256); err == nil {
+		t.Errorf("expected HS256 signing keys to be rejected")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
+	jose "gopkg.in/square/go-jose.v2"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/handlers"
@@ -60,9 +61,49 @@ type Config struct {
 	// Logging in implies approval.
 	SkipApprovalScreen bool
 
+	// ID of the password connector used to log in end users with the resource owner
+	// password credentials grant. If empty, the grant is disabled.
+	PasswordConnector string
+
+	// Options for dynamic client registration. Disabled by default.
+	Registration RegistrationConfig
+
+	// Options for end users' browser sessions. Disabled by default.
+	Sessions SessionConfig
+
+	// Options for clients logging end users out.
+	Logout LogoutConfig
+
+	// Options for refresh tokens.
+	RefreshTokens RefreshTokenConfig
+
 	RotateKeysAfter  time.Duration // Defaults to 6 hours.
 	IDTokensValidFor time.Duration // Defaults to 24 hours
 
+	// The longest ID and access token lifetime clients may configure, which is
+	// how long signing keys are kept to verify tokens. Defaults to IDTokensValidFor.
+	MaxTokensValidFor time.Duration
+
+	// Algorithm used to sign tokens: "RS256", "ES256", "ES384" or "EdDSA".
+	// Defaults to "RS256".
+	SigningAlgorithm string
+
+	// If specified, tokens are signed by this signer rather than with keys
+	// rotated through the storage.
+	Signer Signer
+
+	// Format of access tokens issued to clients that don't configure their own:
+	// "opaque" random strings or signed "jwt"s. Defaults to "opaque".
+	AccessTokenFormat string
+
+	// Secret salt pairwise subjects are derived from. Required for clients using
+	// pairwise subjects, and must not change or those clients' subjects change too.
+	PairwiseSubjectSalt string
+
+	// IDs of clients, such as resource servers, which may introspect tokens
+	// issued to any client. Other clients can only introspect their own tokens.
+	IntrospectionClients []string
+
 	GCFrequency time.Duration // Defaults to 5 minutes
 
 	// If specified, the server will use this function for determining time.
@@ -123,21 +164,68 @@ type Server struct {
 	// If enabled, don't prompt user for approval after logging in through connector.
 	skipApproval bool
 
+	// ID of the connector used for the password grant. Empty if the grant is disabled.
+	passwordConnector string
+
+	// Clients which may introspect any client's tokens.
+	introspectionClients map[string]bool
+
+	// Policy for dynamically registered clients.
+	registration RegistrationConfig
+
+	// Browser session options, with defaults applied.
+	sessions SessionConfig
+
+	// Logout options, with defaults applied.
+	logout LogoutConfig
+
+	refreshTokens RefreshTokenConfig
+
+	// Client used to deliver back-channel logout notifications.
+	logoutClient *http.Client
+
 	supportedResponseTypes map[string]bool
 
+	// Keys fetched from clients' JWKS URLs to verify client assertions.
+	clientKeys *jwksCache
+
 	now func() time.Time
 
-	idTokensValidFor time.Duration
+	idTokensValidFor  time.Duration
+	maxTokensValidFor time.Duration
+
+	// Algorithm new signing keys are generated for.
+	signingAlg jose.SignatureAlgorithm
+
+	// Signs tokens and provides the keys to verify them.
+	signer Signer
+
+	// Default format of access tokens.
+	accessTokenFormat string
+
+	pairwiseSubjectSalt string
 
 	logger logrus.FieldLogger
 }
 
 // NewServer constructs a server from the provided config.
 func NewServer(ctx context.Context, c Config) (*Server, error) {
-	return newServer(ctx, c, defaultRotationStrategy(
+	alg := jose.RS256
+	if c.SigningAlgorithm != "" {
+		if c.Signer != nil {
+			return nil, errors.New("server: signing algorithm can't be configured with a custom signer")
+		}
+		alg = jose.SignatureAlgorithm(c.SigningAlgorithm)
+	}
+	strategy, err := defaultRotationStrategy(
 		value(c.RotateKeysAfter, 6*time.Hour),
-		value(c.IDTokensValidFor, 24*time.Hour),
-	))
+		maxTokensValidFor(c),
+		alg,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("server: %v", err)
+	}
+	return newServer(ctx, c, strategy)
 }
 
 func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy) (*Server, error) {
@@ -153,6 +241,10 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 		c.SupportedResponseTypes = []string{responseTypeCode}
 	}
 
+	if err := validateRegistrationConfig(c.Registration); err != nil {
+		return nil, fmt.Errorf("server: %v", err)
+	}
+
 	supported := make(map[string]bool)
 	for _, respType := range c.SupportedResponseTypes {
 		switch respType {
@@ -163,6 +255,15 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 		supported[respType] = true
 	}
 
+	accessTokenFormat := c.AccessTokenFormat
+	switch accessTokenFormat {
+	case "":
+		accessTokenFormat = accessTokenFormatOpaque
+	case accessTokenFormatOpaque, accessTokenFormatJWT:
+	default:
+		return nil, fmt.Errorf("server: unsupported access token format %q", accessTokenFormat)
+	}
+
 	web := webConfig{
 		dir:       c.Web.Dir,
 		logoURL:   c.Web.LogoURL,
@@ -181,18 +282,49 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 		now = time.Now
 	}
 
+	sessions := c.Sessions
+	sessions.IdleTimeout = value(sessions.IdleTimeout, time.Hour)
+	sessions.AbsoluteLifetime = value(sessions.AbsoluteLifetime, 24*time.Hour)
+
+	logout := c.Logout
+	if logout.BackchannelMaxAttempts <= 0 {
+		logout.BackchannelMaxAttempts = 5
+	}
+
+	introspectionClients := make(map[string]bool)
+	for _, id := range c.IntrospectionClients {
+		introspectionClients[id] = true
+	}
+
 	s := &Server{
 		issuerURL:              *issuerURL,
 		connectors:             make(map[string]Connector),
 		storage:                newKeyCacher(c.Storage, now),
 		supportedResponseTypes: supported,
 		idTokensValidFor:       value(c.IDTokensValidFor, 24*time.Hour),
+		maxTokensValidFor:      maxTokensValidFor(c),
+		signingAlg:             rotationStrategy.algorithm,
+		accessTokenFormat:      accessTokenFormat,
+		pairwiseSubjectSalt:    c.PairwiseSubjectSalt,
 		skipApproval:           c.SkipApprovalScreen,
+		passwordConnector:      c.PasswordConnector,
+		introspectionClients:   introspectionClients,
+		registration:           c.Registration,
+		sessions:               sessions,
+		logout:                 logout,
+		refreshTokens:          c.RefreshTokens,
+		logoutClient:           &http.Client{Timeout: 10 * time.Second},
+		clientKeys:             newJWKSCache(now),
 		now:                    now,
 		templates:              tmpls,
 		logger:                 c.Logger,
 	}
 
+	s.signer = c.Signer
+	if s.signer == nil {
+		s.signer = storageSigner{s.storage}
+	}
+
 	// Retrieves connector objects in backend storage. This list includes the static connectors
 	// defined in the ConfigMap and dynamic connectors retrieved from the storage.
 	storageConnectors, err := c.Storage.ListConnectors()
@@ -236,18 +368,33 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 
 	// TODO(ericchiang): rate limit certain paths based on IP.
 	handleWithCORS("/token", s.handleToken)
+	handleWithCORS("/token/revoke", s.handleRevoke)
+	handleWithCORS("/token/introspect", s.handleIntrospect)
 	handleWithCORS("/keys", s.handlePublicKeys)
+	handleWithCORS("/userinfo", s.handleUserInfo)
+	handleWithCORS("/device/code", s.handleDeviceCode)
+	handleWithCORS("/par", s.handlePushedAuthRequest)
+	if len(c.Registration.InitialAccessTokens) > 0 {
+		handleFunc("/register", s.handleRegister)
+		handleFunc("/register/{client}", s.handleClientConfiguration)
+	}
+	handleFunc("/device", s.handleDevice)
+	handleFunc(deviceCallbackPath, s.handleDeviceCallback)
 	handleFunc("/auth", s.handleAuthorization)
 	handleFunc("/auth/{connector}", s.handleConnectorLogin)
 	handleFunc("/callback", s.handleConnectorCallback)
 	handleFunc("/approval", s.handleApproval)
+	handleFunc("/logout", s.handleLogout)
 	handleFunc("/healthz", s.handleHealth)
 	handlePrefix("/static", static)
 	handlePrefix("/theme", theme)
 	s.mux = r
 
-	s.startKeyRotation(ctx, rotationStrategy, now)
+	if c.Signer == nil {
+		s.startKeyRotation(ctx, rotationStrategy, now)
+	}
 	s.startGarbageCollection(ctx, value(c.GCFrequency, 5*time.Minute), now)
+	s.startLogoutNotifications(ctx, 10*time.Second)
 
 	return s, nil
 }
@@ -365,8 +512,9 @@ func (s *Server) startGarbageCollection(ctx context.Context, frequency time.Dura
 			case <-time.After(frequency):
 				if r, err := s.storage.GarbageCollect(now()); err != nil {
 					s.logger.Errorf("garbage collection failed: %v", err)
-				} else if r.AuthRequests > 0 || r.AuthCodes > 0 {
-					s.logger.Infof("garbage collection run, delete auth requests=%d, auth codes=%d", r.AuthRequests, r.AuthCodes)
+				} else if r.AuthRequests > 0 || r.AuthCodes > 0 || r.AccessTokens > 0 || r.DeviceRequests > 0 || r.ClientAssertions > 0 || r.Sessions > 0 || r.LogoutNotifications > 0 || r.RefreshTokens > 0 || r.PushedAuthRequests > 0 {
+					s.logger.Infof("garbage collection run, delete auth requests=%d, auth codes=%d, access tokens=%d, device requests=%d, client assertions=%d, sessions=%d, logout notifications=%d, refresh tokens=%d, pushed auth requests=%d",
+						r.AuthRequests, r.AuthCodes, r.AccessTokens, r.DeviceRequests, r.ClientAssertions, r.Sessions, r.LogoutNotifications, r.RefreshTokens, r.PushedAuthRequests)
 				}
 			}
 		}
@@ -420,6 +568,10 @@ func openConnector(logger logrus.FieldLogger, conn storage.Connector) (connector
 
 // OpenConnector updates server connector map with specified connector object.
 func (s *Server) OpenConnector(conn storage.Connector) (Connector, error) {
+	if conn.ID == clientSubjectConnID {
+		return Connector{}, fmt.Errorf("connector ID %q is reserved", conn.ID)
+	}
+
 	var c connector.Connector
 
 	if conn.Type == LocalConnector {
@@ -123,6 +123,19 @@ func TestNewTestServer(t *testing.T) {
 	newTestServer(ctx, t, nil)
 }
 
+func TestReservedConnectorID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	conn := storage.Connector{ID: clientSubjectConnID, Type: "mockCallback", Name: "Mock"}
+	if _, err := server.OpenConnector(conn); err == nil {
+		t.Errorf("expected connector with reserved ID %q to be rejected", clientSubjectConnID)
+	}
+}
+
 func TestDiscovery(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -146,6 +159,10 @@ func TestDiscovery(t *testing.T) {
 		"issuer",
 		"authorization_endpoint",
 		"token_endpoint",
+		"revocation_endpoint",
+		"introspection_endpoint",
+		"userinfo_endpoint",
+		"device_authorization_endpoint",
 		"jwks_uri",
 	}
 	for _, field := range required {
@@ -306,6 +323,142 @@ func TestOAuth2CodeFlow(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name: "user info",
+			handleToken: func(ctx context.Context, p *oidc.Provider, config *oauth2.Config, token *oauth2.Token) error {
+				rawIDToken, ok := token.Extra("id_token").(string)
+				if !ok {
+					return fmt.Errorf("no id token found")
+				}
+				idToken, err := p.Verifier(oidcConfig).Verify(ctx, rawIDToken)
+				if err != nil {
+					return fmt.Errorf("failed to verify id token: %v", err)
+				}
+
+				info, err := p.UserInfo(ctx, oauth2.StaticTokenSource(token))
+				if err != nil {
+					return fmt.Errorf("failed to get user info: %v", err)
+				}
+				if info.Subject != idToken.Subject {
+					return fmt.Errorf("expected subject %q got %q", idToken.Subject, info.Subject)
+				}
+				if info.Email != conn.Identity.Email {
+					return fmt.Errorf("expected email %q got %q", conn.Identity.Email, info.Email)
+				}
+				var claims struct {
+					Groups []string `json:"groups"`
+				}
+				if err := info.Claims(&claims); err != nil {
+					return fmt.Errorf("failed to decode claims: %v", err)
+				}
+				if !reflect.DeepEqual(claims.Groups, conn.Identity.Groups) {
+					return fmt.Errorf("expected groups %q got %q", conn.Identity.Groups, claims.Groups)
+				}
+
+				badToken := &oauth2.Token{AccessToken: "bad token", TokenType: "Bearer"}
+				if _, err := p.UserInfo(ctx, oauth2.StaticTokenSource(badToken)); err == nil {
+					return errors.New("expected user info request with unknown access token to fail")
+				}
+				return nil
+			},
+		},
+		{
+			name: "introspect tokens",
+			handleToken: func(ctx context.Context, p *oidc.Provider, config *oauth2.Config, token *oauth2.Token) error {
+				introspect := func(tok, hint string) (map[string]interface{}, error) {
+					v := url.Values{}
+					v.Add("client_id", clientID)
+					v.Add("client_secret", clientSecret)
+					v.Add("token", tok)
+					v.Add("token_type_hint", hint)
+					resp, err := http.PostForm(p.Endpoint().TokenURL+"/introspect", v)
+					if err != nil {
+						return nil, err
+					}
+					defer resp.Body.Close()
+					if resp.StatusCode != http.StatusOK {
+						return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+					}
+					var result map[string]interface{}
+					if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+						return nil, fmt.Errorf("decode response: %v", err)
+					}
+					return result, nil
+				}
+
+				result, err := introspect(token.AccessToken, "")
+				if err != nil {
+					return err
+				}
+				if result["active"] != true {
+					return fmt.Errorf("expected access token to be active: %v", result)
+				}
+				if result["client_id"] != clientID {
+					return fmt.Errorf("expected client_id %q got %v", clientID, result["client_id"])
+				}
+				if result["scope"] != strings.Join(requestedScopes, " ") {
+					return fmt.Errorf("expected scope %q got %v", strings.Join(requestedScopes, " "), result["scope"])
+				}
+				if result["email"] != conn.Identity.Email {
+					return fmt.Errorf("expected email %q got %v", conn.Identity.Email, result["email"])
+				}
+
+				result, err = introspect(token.RefreshToken, "refresh_token")
+				if err != nil {
+					return err
+				}
+				if result["active"] != true {
+					return fmt.Errorf("expected refresh token to be active: %v", result)
+				}
+
+				result, err = introspect("bad token", "")
+				if err != nil {
+					return err
+				}
+				if result["active"] != false {
+					return fmt.Errorf("expected unknown token to be inactive: %v", result)
+				}
+				return nil
+			},
+		},
+		{
+			name: "revoke refresh token",
+			handleToken: func(ctx context.Context, p *oidc.Provider, config *oauth2.Config, token *oauth2.Token) error {
+				v := url.Values{}
+				v.Add("client_id", clientID)
+				v.Add("client_secret", clientSecret)
+				v.Add("token", token.RefreshToken)
+				v.Add("token_type_hint", "refresh_token")
+				resp, err := http.PostForm(p.Endpoint().TokenURL+"/revoke", v)
+				if err != nil {
+					return err
+				}
+				defer resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					dump, err := httputil.DumpResponse(resp, true)
+					if err != nil {
+						panic(err)
+					}
+					return fmt.Errorf("unexpected response: %s", dump)
+				}
+
+				token.Expiry = time.Now().Add(time.Second * -10)
+				if _, err := config.TokenSource(ctx, token).Token(); err == nil {
+					return errors.New("was able to redeem a revoked refresh token")
+				}
+
+				// Revoking an unknown token must also succeed.
+				resp, err = http.PostForm(p.Endpoint().TokenURL+"/revoke", v)
+				if err != nil {
+					return err
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("revoking a revoked token returned %s", resp.Status)
+				}
+				return nil
+			},
+		},
 		{
 			name: "refresh with extra spaces",
 			handleToken: func(ctx context.Context, p *oidc.Provider, config *oauth2.Config, token *oauth2.Token) error {
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/coreos/dex/connector"
+	"github.com/coreos/dex/storage"
+)
+
+// SessionConfig holds the options for end users' browser sessions.
+//
+// While a session is valid, the end user can authorize further clients without
+// picking a connector and logging in again.
+type SessionConfig struct {
+	// If false, end users must log in for every authorization request.
+	Enabled bool
+
+	// Sessions expire if they aren't used for this long. Defaults to 1 hour.
+	IdleTimeout time.Duration
+
+	// Sessions expire this long after the end user logged in, even if they're
+	// in use. Defaults to 24 hours.
+	AbsoluteLifetime time.Duration
+}
+
+// sessionCookieName is the name of the cookie holding the session ID.
+const sessionCookieName = "dex_session"
+
+// sessionCookiePath scopes the session cookie to the issuer's URL.
+func (s *Server) sessionCookiePath() string {
+	if s.issuerURL.Path == "" {
+		return "/"
+	}
+	return s.issuerURL.Path
+}
+
+// sessionExpiry returns when a session expires if it's not used again.
+func (s *Server) sessionExpiry(createdAt, lastUsed time.Time) time.Time {
+	idle := lastUsed.Add(s.sessions.IdleTimeout)
+	absolute := createdAt.Add(s.sessions.AbsoluteLifetime)
+	if idle.Before(absolute) {
+		return idle
+	}
+	return absolute
+}
+
+// createSession starts a browser session for an end user who has just logged
+// in to authorize a client, replacing any session the browser already had.
+func (s *Server) createSession(w http.ResponseWriter, r *http.Request, identity connector.Identity, connID string, claims storage.Claims, clientID string) error {
+	if !s.sessions.Enabled {
+		return nil
+	}
+
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := s.storage.DeleteSession(cookie.Value); err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to delete previous session: %v", err)
+		}
+	}
+
+	now := s.now()
+	session := storage.Session{
+		ID:            storage.NewID(),
+		ConnectorID:   connID,
+		ConnectorData: identity.ConnectorData,
+		Claims:        claims,
+		CreatedAt:     now,
+		LastUsed:      now,
+		Expiry:        s.sessionExpiry(now, now),
+		ClientIDs:     []string{clientID},
+	}
+	if err := s.storage.CreateSession(session); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session.ID,
+		Path:     s.sessionCookiePath(),
+		MaxAge:   int(s.sessions.AbsoluteLifetime.Seconds()),
+		Secure:   s.issuerURL.Scheme == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// currentSession returns the end user's session if the request carries the
+// cookie of a valid one. Using the session pushes back its idle expiry.
+func (s *Server) currentSession(r *http.Request) (storage.Session, bool) {
+	if !s.sessions.Enabled {
+		return storage.Session{}, false
+	}
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return storage.Session{}, false
+	}
+
+	now := s.now()
+	var session storage.Session
+	updater := func(old storage.Session) (storage.Session, error) {
+		if !now.Before(old.Expiry) {
+			return old, storage.ErrNotFound
+		}
+		old.LastUsed = now
+		old.Expiry = s.sessionExpiry(old.CreatedAt, now)
+		session = old
+		return old, nil
+	}
+	if err := s.storage.UpdateSession(cookie.Value, updater); err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("failed to update session: %v", err)
+		}
+		return storage.Session{}, false
+	}
+
+	// The connector may have been removed since the user logged in.
+	if _, err := s.getConnector(session.ConnectorID); err != nil {
+		return storage.Session{}, false
+	}
+	return session, true
+}
+
+// addSessionClient records that the end user authorized a client through their
+// session.
+func (s *Server) addSessionClient(sessionID, clientID string) {
+	updater := func(old storage.Session) (storage.Session, error) {
+		for _, id := range old.ClientIDs {
+			if id == clientID {
+				return old, nil
+			}
+		}
+		old.ClientIDs = append(old.ClientIDs, clientID)
+		return old, nil
+	}
+	if err := s.storage.UpdateSession(sessionID, updater); err != nil {
+		s.logger.Errorf("failed to update session: %v", err)
+	}
+}
+
+// endSession deletes the browser's session, if it has one, and clears the
+// session cookie. It returns the deleted session.
+func (s *Server) endSession(w http.ResponseWriter, r *http.Request) (session storage.Session, ok bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return session, false
+	}
+	if session, err = s.storage.GetSession(cookie.Value); err == nil {
+		ok = true
+		if err := s.storage.DeleteSession(cookie.Value); err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to delete session: %v", err)
+		}
+	} else if err != storage.ErrNotFound {
+		s.logger.Errorf("failed to get session: %v", err)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     s.sessionCookiePath(),
+		MaxAge:   -1,
+		Secure:   s.issuerURL.Scheme == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return session, ok
+}
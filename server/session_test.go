@@ -0,0 +1,259 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/dex/storage"
+)
+
+func TestBrowserSession(t *testing.T) {
+	clients := []storage.Client{
+		{
+			ID:           "app1",
+			Secret:       "secret",
+			RedirectURIs: []string{"https://app1.example.com/callback"},
+		},
+		{
+			ID:           "app2",
+			Secret:       "secret",
+			RedirectURIs: []string{"https://app2.example.com/callback"},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	httpServer, _ := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+		c.Now = func() time.Time { return now }
+		c.Sessions = SessionConfig{
+			Enabled:          true,
+			IdleTimeout:      time.Hour,
+			AbsoluteLifetime: 3 * time.Hour,
+		}
+	})
+	defer httpServer.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("failed to create cookie jar: %v", err)
+	}
+
+	// authorize runs an authorization request in the browser and reports if
+	// the end user had to log in through the connector.
+	authorize := func(clientID string) (loggedIn bool) {
+		var paths []string
+		browser := &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if !strings.HasPrefix(req.URL.String(), httpServer.URL) {
+					// Redirect back to the client.
+					return http.ErrUseLastResponse
+				}
+				paths = append(paths, req.URL.Path)
+				return nil
+			},
+		}
+		v := url.Values{
+			"client_id":     {clientID},
+			"redirect_uri":  {"https://" + clientID + ".example.com/callback"},
+			"response_type": {"code"},
+			"scope":         {"openid email"},
+			"state":         {"state"},
+		}
+		resp, err := browser.Get(httpServer.URL + "/auth?" + v.Encode())
+		if err != nil {
+			t.Fatalf("authorization request failed: %v", err)
+		}
+		resp.Body.Close()
+
+		location, err := resp.Location()
+		if err != nil {
+			t.Fatalf("expected redirect to the client, got %s", resp.Status)
+		}
+		if location.Query().Get("code") == "" {
+			t.Fatalf("expected code in redirect, got %s", location)
+		}
+		for _, p := range paths {
+			if p == "/callback" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !authorize("app1") {
+		t.Errorf("expected first authorization to require a login")
+	}
+	if authorize("app2") {
+		t.Errorf("expected session to be reused for second client")
+	}
+
+	// Using the session pushes back its idle expiry.
+	now = now.Add(50 * time.Minute)
+	if authorize("app1") {
+		t.Errorf("expected session to be reused before idle timeout")
+	}
+	now = now.Add(50 * time.Minute)
+	if authorize("app2") {
+		t.Errorf("expected session to be reused before idle timeout")
+	}
+
+	// Sessions expire if left unused.
+	now = now.Add(61 * time.Minute)
+	if !authorize("app1") {
+		t.Errorf("expected idle session to require a login")
+	}
+
+	// Sessions expire after their absolute lifetime even if used.
+	for i := 0; i < 3; i++ {
+		now = now.Add(50 * time.Minute)
+		if authorize("app1") {
+			t.Errorf("expected session to be reused before idle timeout")
+		}
+	}
+	now = now.Add(50 * time.Minute)
+	if !authorize("app2") {
+		t.Errorf("expected session past its lifetime to require a login")
+	}
+}
+
+func TestAuthorizationPrompt(t *testing.T) {
+	clients := []storage.Client{
+		{
+			ID:           "app",
+			Secret:       "secret",
+			RedirectURIs: []string{"https://app.example.com/callback"},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, clients)
+		c.Now = func() time.Time { return now }
+		c.Sessions = SessionConfig{Enabled: true}
+	})
+	defer httpServer.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("failed to create cookie jar: %v", err)
+	}
+
+	// authorize runs an authorization request in the browser, returning the
+	// response that didn't redirect within the server and if the end user had
+	// to log in through the connector.
+	authorize := func(params url.Values) (resp *http.Response, loggedIn bool) {
+		browser := &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if !strings.HasPrefix(req.URL.String(), httpServer.URL) {
+					return http.ErrUseLastResponse
+				}
+				if req.URL.Path == "/callback" {
+					loggedIn = true
+				}
+				return nil
+			},
+		}
+		v := url.Values{
+			"client_id":     {"app"},
+			"redirect_uri":  {"https://app.example.com/callback"},
+			"response_type": {"code"},
+			"scope":         {"openid email"},
+			"state":         {"state"},
+		}
+		for k, vals := range params {
+			v[k] = vals
+		}
+		resp, err := browser.Get(httpServer.URL + "/auth?" + v.Encode())
+		if err != nil {
+			t.Fatalf("authorization request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp, loggedIn
+	}
+
+	redirectParam := func(resp *http.Response, key string) string {
+		location, err := resp.Location()
+		if err != nil {
+			t.Fatalf("expected redirect to the client, got %s", resp.Status)
+		}
+		return location.Query().Get(key)
+	}
+
+	resp, _ := authorize(url.Values{"prompt": {"none"}})
+	if got := redirectParam(resp, "error"); got != errLoginRequired {
+		t.Errorf("prompt=none without a session: expected error %q got %q", errLoginRequired, got)
+	}
+
+	loginTime := now
+	resp, loggedIn := authorize(nil)
+	if !loggedIn {
+		t.Errorf("expected first authorization to require a login")
+	}
+	code, err := server.storage.GetAuthCode(redirectParam(resp, "code"))
+	if err != nil {
+		t.Fatalf("failed to get auth code: %v", err)
+	}
+	if !code.AuthTime.Equal(loginTime) {
+		t.Errorf("expected auth time %s got %s", loginTime, code.AuthTime)
+	}
+
+	now = now.Add(10 * time.Minute)
+
+	resp, loggedIn = authorize(url.Values{"prompt": {"none"}})
+	if loggedIn || redirectParam(resp, "code") == "" {
+		t.Errorf("prompt=none with a session: expected code without login, got %s", resp.Header.Get("Location"))
+	}
+	code, err = server.storage.GetAuthCode(redirectParam(resp, "code"))
+	if err != nil {
+		t.Fatalf("failed to get auth code: %v", err)
+	}
+	if !code.AuthTime.Equal(loginTime) {
+		t.Errorf("session reuse: expected auth time %s got %s", loginTime, code.AuthTime)
+	}
+
+	if _, loggedIn = authorize(url.Values{"max_age": {"3600"}}); loggedIn {
+		t.Errorf("expected session within max_age to be reused")
+	}
+	if _, loggedIn = authorize(url.Values{"max_age": {"300"}}); !loggedIn {
+		t.Errorf("expected session older than max_age to require a login")
+	}
+	if _, loggedIn = authorize(url.Values{"prompt": {"login"}}); !loggedIn {
+		t.Errorf("expected prompt=login to require a login")
+	}
+
+	// The client expects a different end user to be logged in.
+	hint, _, err := server.newIDToken(storage.Client{ID: "app"}, storage.Claims{UserID: "someone-else"}, []string{"openid"}, "", "", "mock", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+	if _, loggedIn = authorize(url.Values{"id_token_hint": {hint}}); !loggedIn {
+		t.Errorf("expected id_token_hint for another user to require a login")
+	}
+
+	resp, loggedIn = authorize(url.Values{"prompt": {"consent"}})
+	if loggedIn || resp.StatusCode != http.StatusOK {
+		t.Errorf("expected prompt=consent to render the approval page, got %s", resp.Status)
+	}
+	resp, loggedIn = authorize(url.Values{"approval_prompt": {"force"}})
+	if loggedIn || resp.StatusCode != http.StatusOK {
+		t.Errorf("expected approval_prompt=force to render the approval page, got %s", resp.Status)
+	}
+
+	resp, _ = authorize(url.Values{"prompt": {"none login"}})
+	if got := redirectParam(resp, "error"); got != errInvalidRequest {
+		t.Errorf("prompt=none combined with login: expected error %q got %q", errInvalidRequest, got)
+	}
+}
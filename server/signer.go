@@ -0,0 +1,121 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/coreos/dex/storage"
+)
+
+// Signer signs the tokens dex issues and provides the public keys to verify
+// them with.
+//
+// By default, dex generates signing keys and rotates them through its storage.
+// Other signers let the private keys live outside of the storage.
+type Signer interface {
+	// Algorithm returns the algorithm payloads are currently signed with.
+	Algorithm() (jose.SignatureAlgorithm, error)
+
+	// Sign signs a payload with the current signing key, returning a compact
+	// JWS. If typ isn't empty, it's set as the JWS's "typ" header.
+	Sign(payload []byte, typ string) (string, error)
+
+	// ValidationKeys returns the public keys tokens can be verified with,
+	// starting with the current signing key's, and when they're next expected
+	// to change. The time is zero if unknown.
+	ValidationKeys() (keys []*jose.JSONWebKey, nextRotation time.Time, err error)
+}
+
+// storageSigner signs payloads with the keys rotated through the storage.
+type storageSigner struct {
+	storage storage.Storage
+}
+
+func (s storageSigner) signingKey() (*jose.JSONWebKey, jose.SignatureAlgorithm, error) {
+	keys, err := s.storage.GetKeys()
+	if err != nil {
+		return nil, "", fmt.Errorf("get keys: %v", err)
+	}
+	if keys.SigningKey == nil {
+		return nil, "", errors.New("no key to sign payload with")
+	}
+	alg, err := signatureAlgorithm(keys.SigningKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return keys.SigningKey, alg, nil
+}
+
+func (s storageSigner) Algorithm() (jose.SignatureAlgorithm, error) {
+	_, alg, err := s.signingKey()
+	return alg, err
+}
+
+func (s storageSigner) Sign(payload []byte, typ string) (string, error) {
+	key, alg, err := s.signingKey()
+	if err != nil {
+		return "", err
+	}
+	return signPayload(key, alg, payload, typ)
+}
+
+func (s storageSigner) ValidationKeys() ([]*jose.JSONWebKey, time.Time, error) {
+	keys, err := s.storage.GetKeys()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("get keys: %v", err)
+	}
+	if keys.SigningKeyPub == nil {
+		return nil, time.Time{}, errors.New("no public keys found")
+	}
+	pubKeys := []*jose.JSONWebKey{keys.SigningKeyPub}
+	for _, key := range keys.VerificationKeys {
+		pubKeys = append(pubKeys, key.PublicKey)
+	}
+	if keys.NextSigningKeyPub != nil {
+		pubKeys = append(pubKeys, keys.NextSigningKeyPub)
+	}
+	return pubKeys, keys.NextRotation, nil
+}
+
+// publicKeyAlgorithm determines the signature algorithm for tokens verified with
+// a public key.
+func publicKeyAlgorithm(key crypto.PublicKey) (jose.SignatureAlgorithm, error) {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return jose.RS256, nil
+	case *ecdsa.PublicKey:
+		switch key.Params() {
+		case elliptic.P256().Params():
+			return jose.ES256, nil
+		case elliptic.P384().Params():
+			return jose.ES384, nil
+		case elliptic.P521().Params():
+			return jose.ES512, nil
+		default:
+			return "", errors.New("unsupported ecdsa curve")
+		}
+	case ed25519.PublicKey:
+		return jose.EdDSA, nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// publicKeyID derives a key ID from a public key's RFC 7638 thumbprint, so
+// every dex instance loading the same key agrees on its ID.
+func publicKeyID(key crypto.PublicKey) (string, error) {
+	thumbprint, err := (&jose.JSONWebKey{Key: key}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+	// go-grpc doesn't use the standard library's context.
+	// https://github.com/grpc/grpc-go/issues/711
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	jose "gopkg.in/square/go-jose.v2"
+
+	signerapi "github.com/coreos/dex/api/signer"
+	"github.com/coreos/dex/storage"
+	"github.com/coreos/dex/storage/memory"
+)
+
+func writePEM(t *testing.T, path, blockType string, data []byte, modTime time.Time) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkSigner signs a payload and verifies it with the signer's current key.
+func checkSigner(t *testing.T, s Signer, wantAlg jose.SignatureAlgorithm) (keyID string) {
+	alg, err := s.Algorithm()
+	if err != nil {
+		t.Fatalf("failed to get algorithm: %v", err)
+	}
+	if alg != wantAlg {
+		t.Errorf("expected algorithm %s got %s", wantAlg, alg)
+	}
+
+	jws, err := s.Sign([]byte("payload"), "")
+	if err != nil {
+		t.Fatalf("failed to sign payload: %v", err)
+	}
+	keys, _, err := s.ValidationKeys()
+	if err != nil {
+		t.Fatalf("failed to get validation keys: %v", err)
+	}
+	sig, err := jose.ParseSigned(jws)
+	if err != nil {
+		t.Fatalf("failed to parse JWS: %v", err)
+	}
+	if got := sig.Signatures[0].Header.Algorithm; got != string(wantAlg) {
+		t.Errorf("expected payload signed with %s got %s", wantAlg, got)
+	}
+	payload, err := sig.Verify(keys[0])
+	if err != nil {
+		t.Fatalf("payload wasn't signed with the first validation key: %v", err)
+	}
+	if string(payload) != "payload" {
+		t.Errorf("unexpected payload %q", payload)
+	}
+
+	// Payloads with a "typ" header are signed without go-jose.
+	jws, err = s.Sign([]byte("payload"), accessTokenType)
+	if err != nil {
+		t.Fatalf("failed to sign payload with typ: %v", err)
+	}
+	if sig, err = jose.ParseSigned(jws); err != nil {
+		t.Fatalf("failed to parse JWS: %v", err)
+	}
+	if got := sig.Signatures[0].Header.KeyID; got != keys[0].KeyID {
+		t.Errorf("expected key ID %q got %q", keys[0].KeyID, got)
+	}
+	if payload, err = sig.Verify(keys[0]); err != nil || string(payload) != "payload" {
+		t.Fatalf("payload with typ wasn't signed with the first validation key: %v", err)
+	}
+	return keys[0].KeyID
+}
+
+func TestStaticSigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dex-signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPub, err := x509.MarshalPKIXPublicKey(ecKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPriv, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Now().Add(-time.Hour)
+	keyFile := filepath.Join(dir, "key.pem")
+	verificationKeyFile := filepath.Join(dir, "previous.pem")
+	writePEM(t, keyFile, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testKey), modTime)
+	writePEM(t, verificationKeyFile, "PUBLIC KEY", ecPub, modTime)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := StaticSignerConfig{KeyFile: keyFile, VerificationKeyFiles: []string{verificationKeyFile}}
+	s, err := config.Open(ctx, logger)
+	if err != nil {
+		t.Fatalf("failed to open signer: %v", err)
+	}
+	rsaKeyID := checkSigner(t, s, jose.RS256)
+
+	keys, _, err := s.ValidationKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[1].Algorithm != string(jose.ES256) {
+		t.Fatalf("expected verification key to be published, got %v", keys)
+	}
+
+	// Replacing the key file rotates the signing key.
+	writePEM(t, keyFile, "EC PRIVATE KEY", ecPriv, modTime.Add(time.Minute))
+	if err := s.(*staticSigner).reload(); err != nil {
+		t.Fatalf("failed to reload keys: %v", err)
+	}
+	if ecKeyID := checkSigner(t, s, jose.ES256); ecKeyID == rsaKeyID {
+		t.Errorf("expected new key ID after reloading")
+	}
+
+	// Broken files don't replace the keys in use.
+	if err := ioutil.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(keyFile, modTime.Add(2*time.Minute), modTime.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.(*staticSigner).reload(); err == nil {
+		t.Errorf("expected invalid key file to fail to load")
+	}
+	checkSigner(t, s, jose.ES256)
+
+	// Ed25519 keys are PKCS #8 encoded.
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPriv, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, keyFile, "PRIVATE KEY", edPriv, modTime.Add(3*time.Minute))
+	if err := s.(*staticSigner).reload(); err != nil {
+		t.Fatalf("failed to reload keys: %v", err)
+	}
+	checkSigner(t, s, jose.EdDSA)
+}
+
+func TestEdDSASigningKeys(t *testing.T) {
+	s := memory.New(logger)
+	strategy, err := defaultRotationStrategy(time.Hour, 24*time.Hour, jose.EdDSA)
+	if err != nil {
+		t.Fatalf("failed to create strategy: %v", err)
+	}
+	r := keyRotater{s, strategy, time.Now, logger}
+	if err := r.rotate(); err != nil {
+		t.Fatalf("failed to rotate keys: %v", err)
+	}
+	checkSigner(t, storageSigner{s}, jose.EdDSA)
+
+	// Storages persist keys as JSON.
+	keys, err := s.GetKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(keys.SigningKey)
+	if err != nil {
+		t.Fatalf("failed to marshal signing key: %v", err)
+	}
+	var key jose.JSONWebKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		t.Fatalf("failed to unmarshal signing key: %v", err)
+	}
+	if alg, err := signatureAlgorithm(&key); err != nil || alg != jose.EdDSA {
+		t.Errorf("expected EdDSA signing key after unmarshaling, got %s: %v", alg, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Signer = storageSigner{s}
+	})
+	defer httpServer.Close()
+
+	idToken, _, err := server.newIDToken(storage.Client{ID: "client"}, storage.Claims{UserID: "user"}, []string{"openid"}, "", "access-token", "mock", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+	tok, err := server.verifyIDToken(idToken)
+	if err != nil {
+		t.Fatalf("failed to verify ID token: %v", err)
+	}
+	if want, err := accessTokenHash(jose.EdDSA, "access-token"); err != nil || tok.AccessTokenHash != want {
+		t.Errorf("expected at_hash %q got %q: %v", want, tok.AccessTokenHash, err)
+	}
+}
+
+// signerServer serves a Signer over the remote signer protocol.
+type signerServer struct {
+	signer Signer
+}
+
+func (s signerServer) Sign(ctx context.Context, req *signerapi.SignReq) (*signerapi.SignResp, error) {
+	jws, err := s.signer.Sign(req.Payload, req.Type)
+	if err != nil {
+		return nil, err
+	}
+	return &signerapi.SignResp{Jws: jws}, nil
+}
+
+func (s signerServer) GetKeys(ctx context.Context, req *signerapi.GetKeysReq) (*signerapi.GetKeysResp, error) {
+	alg, err := s.signer.Algorithm()
+	if err != nil {
+		return nil, err
+	}
+	keys, nextRotation, err := s.signer.ValidationKeys()
+	if err != nil {
+		return nil, err
+	}
+	var jwks jose.JSONWebKeySet
+	for _, key := range keys {
+		jwks.Keys = append(jwks.Keys, *key)
+	}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		return nil, err
+	}
+	return &signerapi.GetKeysResp{Algorithm: string(alg), Jwks: data, NextRotation: nextRotation.Unix()}, nil
+}
+
+func TestRemoteSigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dex-signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Run the remote signer with keys from the storage.
+	keyStorage := newTestSignerStorage(t)
+	socket := filepath.Join(dir, "signer.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv := grpc.NewServer()
+	signerapi.RegisterSignerServer(serv, signerServer{storageSigner{keyStorage}})
+	go serv.Serve(l)
+	defer serv.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := RemoteSignerConfig{Socket: socket}
+	s, err := config.Open(ctx, logger)
+	if err != nil {
+		t.Fatalf("failed to open signer: %v", err)
+	}
+	checkSigner(t, s, jose.RS256)
+
+	// A server using the signer never touches the storage's keys.
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Signer = s
+	})
+	defer httpServer.Close()
+
+	idToken, _, err := server.newIDToken(storage.Client{ID: "app"}, storage.Claims{UserID: "user"}, []string{"openid"}, "", "", "mock", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+	if _, err := server.verifyIDToken(idToken); err != nil {
+		t.Errorf("failed to verify ID token: %v", err)
+	}
+	if keys, err := server.storage.GetKeys(); err == nil && keys.SigningKey != nil {
+		t.Errorf("expected no signing key in the server's storage")
+	}
+}
+
+// newTestSignerStorage returns a storage holding a signing key.
+func newTestSignerStorage(t *testing.T) storage.Storage {
+	r := &keyRotater{
+		Storage:  memory.New(logger),
+		strategy: staticRotationStrategy(testKey),
+		now:      time.Now,
+		logger:   logger,
+	}
+	if err := r.rotate(); err != nil {
+		t.Fatal(err)
+	}
+	return r.Storage
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/crypto/ed25519"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// How often the static signer checks its key files for changes.
+const staticSignerReloadInterval = 10 * time.Second
+
+// StaticSignerConfig configures a signer using keys read from PEM files. The
+// files are reloaded whenever they change, so keys can be rotated by replacing
+// them.
+type StaticSignerConfig struct {
+	// PEM encoded RSA, ECDSA or Ed25519 private key tokens are signed with.
+	KeyFile string `json:"keyFile"`
+
+	// PEM encoded public keys published alongside the signing key, such as
+	// previous signing keys, so tokens they signed can still be verified.
+	VerificationKeyFiles []string `json:"verificationKeyFiles"`
+}
+
+// Open loads the keys and reloads them whenever the files change, until the
+// context is canceled.
+func (c *StaticSignerConfig) Open(ctx context.Context, logger logrus.FieldLogger) (Signer, error) {
+	if c.KeyFile == "" {
+		return nil, errors.New("no key file specified")
+	}
+	s := &staticSigner{
+		keyFile:              c.KeyFile,
+		verificationKeyFiles: c.VerificationKeyFiles,
+		logger:               logger,
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(staticSignerReloadInterval):
+				if err := s.reload(); err != nil {
+					s.logger.Errorf("failed to reload signing keys, still using previous keys: %v", err)
+				}
+			}
+		}
+	}()
+	return s, nil
+}
+
+type staticSigner struct {
+	keyFile              string
+	verificationKeyFiles []string
+
+	logger logrus.FieldLogger
+
+	mu sync.RWMutex
+	// Modification times of the files when they were last loaded.
+	modTimes map[string]time.Time
+
+	signingKey *jose.JSONWebKey
+	alg        jose.SignatureAlgorithm
+	// Public keys, starting with the signing key's.
+	pubKeys []*jose.JSONWebKey
+}
+
+func (s *staticSigner) Algorithm() (jose.SignatureAlgorithm, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.alg, nil
+}
+
+func (s *staticSigner) Sign(payload []byte, typ string) (string, error) {
+	s.mu.RLock()
+	key, alg := s.signingKey, s.alg
+	s.mu.RUnlock()
+	return signPayload(key, alg, payload, typ)
+}
+
+func (s *staticSigner) ValidationKeys() ([]*jose.JSONWebKey, time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pubKeys, time.Time{}, nil
+}
+
+// reload loads the keys again if any of the files changed since they were last
+// loaded. If they can't be loaded, the previous keys stay in use.
+func (s *staticSigner) reload() error {
+	files := append([]string{s.keyFile}, s.verificationKeyFiles...)
+
+	modTimes := make(map[string]time.Time, len(files))
+	changed := false
+	s.mu.RLock()
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			s.mu.RUnlock()
+			return err
+		}
+		modTimes[file] = info.ModTime()
+		if last, ok := s.modTimes[file]; !ok || !last.Equal(info.ModTime()) {
+			changed = true
+		}
+	}
+	s.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(s.keyFile)
+	if err != nil {
+		return err
+	}
+	key, err := parsePrivateKeyPEM(data)
+	if err != nil {
+		return fmt.Errorf("parse %s: %v", s.keyFile, err)
+	}
+	alg, err := publicKeyAlgorithm(key.Public())
+	if err != nil {
+		return fmt.Errorf("parse %s: %v", s.keyFile, err)
+	}
+	keyID, err := publicKeyID(key.Public())
+	if err != nil {
+		return fmt.Errorf("parse %s: %v", s.keyFile, err)
+	}
+	signingKey := &jose.JSONWebKey{Key: key, KeyID: keyID, Algorithm: string(alg), Use: "sig"}
+	pubKeys := []*jose.JSONWebKey{
+		{Key: key.Public(), KeyID: keyID, Algorithm: string(alg), Use: "sig"},
+	}
+
+	for _, file := range s.verificationKeyFiles {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		pub, err := parsePublicKeyPEM(data)
+		if err != nil {
+			return fmt.Errorf("parse %s: %v", file, err)
+		}
+		alg, err := publicKeyAlgorithm(pub)
+		if err != nil {
+			return fmt.Errorf("parse %s: %v", file, err)
+		}
+		keyID, err := publicKeyID(pub)
+		if err != nil {
+			return fmt.Errorf("parse %s: %v", file, err)
+		}
+		pubKeys = append(pubKeys, &jose.JSONWebKey{Key: pub, KeyID: keyID, Algorithm: string(alg), Use: "sig"})
+	}
+
+	s.mu.Lock()
+	s.modTimes = modTimes
+	s.signingKey = signingKey
+	s.alg = alg
+	s.pubKeys = pubKeys
+	s.mu.Unlock()
+
+	s.logger.Infof("loaded signing key %s", keyID)
+	return nil
+}
+
+// parsePrivateKeyPEM parses a PEM encoded RSA, ECDSA or Ed25519 private key.
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case *ecdsa.PrivateKey:
+			return key, nil
+		case ed25519.PrivateKey:
+			return key, nil
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// parsePublicKeyPEM parses a PEM encoded public key or certificate. Private keys
+// are accepted too, in which case their public part is returned.
+func parsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		key, err := parsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return key.Public(), nil
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/coreos/dex/storage"
+)
+
+// Subject types clients can be configured with.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#SubjectIDTypes
+const (
+	subjectTypePublic   = "public"
+	subjectTypePairwise = "pairwise"
+)
+
+// clientSubject maps a subject returned by idTokenSubject to the "sub" claim
+// issued to a client.
+//
+// Clients using pairwise subjects see a different subject for the same end user
+// than clients in other sectors, so they can't correlate end users. The subject
+// is the end user's public subject encrypted with a key derived from the
+// server's salt, using a nonce derived from the sector and subject. This keeps
+// it stable across signing key rotations and storages, while still letting the
+// server map it back, for instance when a client passes it as a hint.
+func (s *Server) clientSubject(client storage.Client, subject string) (string, error) {
+	if client.SubjectType != subjectTypePairwise {
+		return subject, nil
+	}
+	aead, nonceKey, err := s.pairwiseKeys()
+	if err != nil {
+		return "", err
+	}
+	sector := sectorIdentifier(client)
+
+	h := hmac.New(sha256.New, nonceKey)
+	h.Write([]byte(sector))
+	h.Write([]byte{0})
+	h.Write([]byte(subject))
+	nonce := h.Sum(nil)[:aead.NonceSize()]
+
+	sealed := aead.Seal(nonce, nonce, []byte(subject), []byte(sector))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// clientSubjectFor is like clientSubject, looking up the client by ID. Subjects
+// for clients which no longer exist are returned as is.
+func (s *Server) clientSubjectFor(clientID, subject string) (string, error) {
+	client, err := s.storage.GetClient(clientID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return subject, nil
+		}
+		return "", fmt.Errorf("get client: %v", err)
+	}
+	return s.clientSubject(client, subject)
+}
+
+// localSubject reverses clientSubject.
+func (s *Server) localSubject(client storage.Client, sub string) (string, error) {
+	if client.SubjectType != subjectTypePairwise {
+		return sub, nil
+	}
+	aead, _, err := s.pairwiseKeys()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(sub)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed pairwise subject")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	subject, err := aead.Open(nil, nonce, ciphertext, []byte(sectorIdentifier(client)))
+	if err != nil {
+		return "", fmt.Errorf("invalid pairwise subject: %v", err)
+	}
+	return string(subject), nil
+}
+
+// localSubjectFor is like localSubject, looking up the client by ID. Subjects of
+// clients which no longer exist are returned as is.
+func (s *Server) localSubjectFor(clientID, sub string) (string, error) {
+	client, err := s.storage.GetClient(clientID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return sub, nil
+		}
+		return "", fmt.Errorf("get client: %v", err)
+	}
+	return s.localSubject(client, sub)
+}
+
+// pairwiseKeys derives the keys used for pairwise subjects from the salt.
+func (s *Server) pairwiseKeys() (cipher.AEAD, []byte, error) {
+	if s.pairwiseSubjectSalt == "" {
+		return nil, nil, errors.New("pairwise subjects require a salt to be configured")
+	}
+	derive := func(label string) []byte {
+		h := hmac.New(sha256.New, []byte(s.pairwiseSubjectSalt))
+		h.Write([]byte(label))
+		return h.Sum(nil)
+	}
+	block, err := aes.NewCipher(derive("dex pairwise subject encryption"))
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, derive("dex pairwise subject nonce"), nil
+}
+
+// Sector identifier documents larger than this are rejected rather than read
+// into memory.
+const maxSectorIdentifierSize = 1 << 20
+
+// sectorIdentifierClient fetches clients' sector identifier documents.
+var sectorIdentifierClient = &http.Client{Timeout: 10 * time.Second}
+
+// ValidateSectorIdentifier checks a client may use its sector identifier URI.
+// The URI must use https, and the JSON array of redirect URIs it points to must
+// include every one of the client's redirect URIs. Otherwise a client could
+// claim another sector's host and see the same pairwise subjects as the
+// clients in that sector.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#PairwiseAlg
+func ValidateSectorIdentifier(client storage.Client) error {
+	if client.SectorIdentifierURI == "" {
+		return nil
+	}
+	u, err := url.Parse(client.SectorIdentifierURI)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("sector identifier URI %q must be an https URL", client.SectorIdentifierURI)
+	}
+
+	resp, err := sectorIdentifierClient.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("fetch sector identifier: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSectorIdentifierSize+1))
+	if err != nil {
+		return fmt.Errorf("read sector identifier: %v", err)
+	}
+	if len(body) > maxSectorIdentifierSize {
+		return fmt.Errorf("sector identifier exceeds %d bytes", maxSectorIdentifierSize)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch sector identifier: %s", resp.Status)
+	}
+
+	var redirectURIs []string
+	if err := json.Unmarshal(body, &redirectURIs); err != nil {
+		return fmt.Errorf("sector identifier must be a JSON array of redirect URIs: %v", err)
+	}
+	listed := make(map[string]bool, len(redirectURIs))
+	for _, uri := range redirectURIs {
+		listed[uri] = true
+	}
+	for _, uri := range client.RedirectURIs {
+		if !listed[uri] {
+			return fmt.Errorf("redirect URI %q isn't listed by sector identifier %q", uri, client.SectorIdentifierURI)
+		}
+	}
+	return nil
+}
+
+// sectorIdentifier returns the sector a client's pairwise subjects are scoped
+// to: the host of its sector identifier URI, or the client itself.
+func sectorIdentifier(client storage.Client) string {
+	if client.SectorIdentifierURI != "" {
+		if u, err := url.Parse(client.SectorIdentifierURI); err == nil && u.Host != "" {
+			return "sector:" + u.Host
+		}
+	}
+	return "client:" + client.ID
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/dex/storage"
+)
+
+func TestPairwiseSubjects(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.PairwiseSubjectSalt = "salt"
+	})
+	defer httpServer.Close()
+
+	const subject = "CgR1c2VyEgRtb2Nr"
+	public := storage.Client{ID: "public"}
+	pairwise := storage.Client{ID: "pairwise", SubjectType: subjectTypePairwise}
+	other := storage.Client{ID: "other", SubjectType: subjectTypePairwise}
+	sectorA := storage.Client{ID: "a", SubjectType: subjectTypePairwise, SectorIdentifierURI: "https://example.com/a.json"}
+	sectorB := storage.Client{ID: "b", SubjectType: subjectTypePairwise, SectorIdentifierURI: "https://example.com/b.json"}
+
+	clientSubject := func(client storage.Client) string {
+		sub, err := server.clientSubject(client, subject)
+		if err != nil {
+			t.Fatalf("failed to compute subject for client %q: %v", client.ID, err)
+		}
+		return sub
+	}
+
+	if sub := clientSubject(public); sub != subject {
+		t.Errorf("expected public client to see subject %q, got %q", subject, sub)
+	}
+	sub := clientSubject(pairwise)
+	if sub == subject {
+		t.Errorf("expected pairwise client to see a pairwise subject")
+	}
+	if again := clientSubject(pairwise); again != sub {
+		t.Errorf("expected pairwise subject to be stable, got %q and %q", sub, again)
+	}
+	if clientSubject(other) == sub {
+		t.Errorf("expected clients to see different pairwise subjects")
+	}
+	if a, b := clientSubject(sectorA), clientSubject(sectorB); a != b {
+		t.Errorf("expected clients in the same sector to see the same subject, got %q and %q", a, b)
+	}
+
+	local, err := server.localSubject(pairwise, sub)
+	if err != nil {
+		t.Fatalf("failed to map pairwise subject back: %v", err)
+	}
+	if local != subject {
+		t.Errorf("expected pairwise subject to map back to %q, got %q", subject, local)
+	}
+	if _, err := server.localSubject(other, sub); err == nil {
+		t.Errorf("expected subject of another client to be rejected")
+	}
+
+	// Pairwise subjects only depend on the salt.
+	httpServer2, restarted := newTestServer(ctx, t, func(c *Config) {
+		c.PairwiseSubjectSalt = "salt"
+	})
+	defer httpServer2.Close()
+	sub2, err := restarted.clientSubject(pairwise, subject)
+	if err != nil {
+		t.Fatalf("failed to compute subject: %v", err)
+	}
+	if sub2 != sub {
+		t.Errorf("expected pairwise subject to be stable across servers, got %q and %q", sub, sub2)
+	}
+
+	idToken, _, err := server.newIDToken(pairwise, storage.Claims{UserID: "user"}, []string{"openid"}, "", "", "mock", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+	tok, err := server.verifyIDToken(idToken)
+	if err != nil {
+		t.Fatalf("failed to verify ID token: %v", err)
+	}
+	if tok.Subject != sub {
+		t.Errorf("expected ID token subject %q, got %q", sub, tok.Subject)
+	}
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/.well-known/openid-configuration", nil))
+	var d discovery
+	if err := json.Unmarshal(rr.Body.Bytes(), &d); err != nil {
+		t.Fatalf("failed to decode discovery: %v", err)
+	}
+	if len(d.Subjects) != 2 || d.Subjects[1] != subjectTypePairwise {
+		t.Errorf("expected discovery to advertise pairwise subjects, got %q", d.Subjects)
+	}
+}
+
+func TestPairwiseSubjectsWithoutSalt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	pairwise := storage.Client{ID: "pairwise", SubjectType: subjectTypePairwise}
+	if _, _, err := server.newIDToken(pairwise, storage.Claims{UserID: "user"}, []string{"openid"}, "", "", "mock", time.Time{}); err == nil {
+		t.Errorf("expected pairwise subjects to require a salt")
+	}
+}
+
+func TestValidateSectorIdentifier(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sector.json":
+			json.NewEncoder(w).Encode([]string{"https://a.example.com/callback", "https://b.example.com/callback"})
+		case "/object.json":
+			json.NewEncoder(w).Encode(map[string]string{"redirect_uri": "https://a.example.com/callback"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	defer func(c *http.Client) { sectorIdentifierClient = c }(sectorIdentifierClient)
+	sectorIdentifierClient = ts.Client()
+
+	tests := []struct {
+		name         string
+		uri          string
+		redirectURIs []string
+		wantErr      bool
+	}{
+		{name: "no sector identifier", redirectURIs: []string{"https://evil.example.com/callback"}},
+		{
+			name:         "listed redirect URIs",
+			uri:          ts.URL + "/sector.json",
+			redirectURIs: []string{"https://a.example.com/callback", "https://b.example.com/callback"},
+		},
+		{
+			name:         "unlisted redirect URI",
+			uri:          ts.URL + "/sector.json",
+			redirectURIs: []string{"https://a.example.com/callback", "https://evil.example.com/callback"},
+			wantErr:      true,
+		},
+		{
+			name:         "http URL",
+			uri:          "http" + strings.TrimPrefix(ts.URL, "https") + "/sector.json",
+			redirectURIs: []string{"https://a.example.com/callback"},
+			wantErr:      true,
+		},
+		{
+			name:         "not an array",
+			uri:          ts.URL + "/object.json",
+			redirectURIs: []string{"https://a.example.com/callback"},
+			wantErr:      true,
+		},
+		{
+			name:         "not found",
+			uri:          ts.URL + "/missing.json",
+			redirectURIs: []string{"https://a.example.com/callback"},
+			wantErr:      true,
+		},
+	}
+	for _, tc := range tests {
+		client := storage.Client{
+			ID:                  "client",
+			RedirectURIs:        tc.redirectURIs,
+			SubjectType:         subjectTypePairwise,
+			SectorIdentifierURI: tc.uri,
+		}
+		err := ValidateSectorIdentifier(client)
+		if tc.wantErr && err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+		if !tc.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+	}
+}
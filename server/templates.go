@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -18,6 +19,11 @@ const (
 	tmplPassword = "password.html"
 	tmplOOB      = "oob.html"
 	tmplError    = "error.html"
+
+	tmplDevice        = "device.html"
+	tmplDeviceSuccess = "device_success.html"
+	tmplLogout        = "logout.html"
+	tmplFormPost      = "form_post.html"
 )
 
 var requiredTmpls = []string{
@@ -26,6 +32,10 @@ var requiredTmpls = []string{
 	tmplPassword,
 	tmplOOB,
 	tmplError,
+	tmplDevice,
+	tmplDeviceSuccess,
+	tmplLogout,
+	tmplFormPost,
 }
 
 type templates struct {
@@ -34,6 +44,11 @@ type templates struct {
 	passwordTmpl *template.Template
 	oobTmpl      *template.Template
 	errorTmpl    *template.Template
+
+	deviceTmpl        *template.Template
+	deviceSuccessTmpl *template.Template
+	logoutTmpl        *template.Template
+	formPostTmpl      *template.Template
 }
 
 type webConfig struct {
@@ -160,6 +175,11 @@ func loadTemplates(c webConfig, templatesDir string) (*templates, error) {
 		passwordTmpl: tmpls.Lookup(tmplPassword),
 		oobTmpl:      tmpls.Lookup(tmplOOB),
 		errorTmpl:    tmpls.Lookup(tmplError),
+
+		deviceTmpl:        tmpls.Lookup(tmplDevice),
+		deviceSuccessTmpl: tmpls.Lookup(tmplDeviceSuccess),
+		logoutTmpl:        tmpls.Lookup(tmplLogout),
+		formPostTmpl:      tmpls.Lookup(tmplFormPost),
 	}, nil
 }
 
@@ -223,6 +243,48 @@ func (t *templates) oob(w http.ResponseWriter, code string) error {
 	return renderTemplate(w, t.oobTmpl, data)
 }
 
+func (t *templates) device(w http.ResponseWriter, postURL, userCode string, lastWasInvalid bool) error {
+	data := struct {
+		PostURL  string
+		UserCode string
+		Invalid  bool
+	}{postURL, userCode, lastWasInvalid}
+	return renderTemplate(w, t.deviceTmpl, data)
+}
+
+func (t *templates) deviceSuccess(w http.ResponseWriter, clientName string) error {
+	data := struct {
+		Client string
+	}{clientName}
+	return renderTemplate(w, t.deviceSuccessTmpl, data)
+}
+
+func (t *templates) logout(w http.ResponseWriter) error {
+	return renderTemplate(w, t.logoutTmpl, nil)
+}
+
+// logoutConfirm renders a form asking the end user to confirm they want to log
+// out, which POSTs the logout request's parameters back to postURL.
+func (t *templates) logoutConfirm(w http.ResponseWriter, postURL string, params url.Values, confirmation string) error {
+	data := struct {
+		Confirm      bool
+		PostURL      string
+		Params       url.Values
+		Confirmation string
+	}{true, postURL, params, confirmation}
+	return renderTemplate(w, t.logoutTmpl, data)
+}
+
+// formPost renders a form POSTing an authorization response's parameters to the
+// client's redirect URI, which the page submits as soon as it's loaded.
+func (t *templates) formPost(w http.ResponseWriter, redirectURI string, params url.Values) error {
+	data := struct {
+		RedirectURI string
+		Params      url.Values
+	}{redirectURI, params}
+	return renderTemplate(w, t.formPostTmpl, data)
+}
+
 func (t *templates) err(w http.ResponseWriter, errType string, errMsg string) error {
 	data := struct {
 		ErrType string
@@ -49,6 +49,13 @@ func RunTests(t *testing.T, newStorage func() storage.Storage) {
 		{"KeysCRUD", testKeysCRUD},
 		{"OfflineSessionCRUD", testOfflineSessionCRUD},
 		{"ConnectorCRUD", testConnectorCRUD},
+		{"AccessTokenCRUD", testAccessTokenCRUD},
+		{"DeviceRequestCRUD", testDeviceRequestCRUD},
+		{"ClientAssertionCreate", testClientAssertionCreate},
+		{"SessionCRUD", testSessionCRUD},
+		{"LogoutNotificationCRUD", testLogoutNotificationCRUD},
+		{"PushedAuthRequestCRUD", testPushedAuthRequestCRUD},
+		{"ConsentsCRUD", testConsentsCRUD},
 		{"GarbageCollection", testGC},
 		{"TimezoneSupport", testTimezones},
 	})
@@ -101,9 +108,17 @@ func testAuthRequestCRUD(t *testing.T, s storage.Storage) {
 			EmailVerified: true,
 			Groups:        []string{"a", "b"},
 		},
+		PKCE: storage.PKCE{
+			CodeChallenge:       "ngF5GsXcbwljx6u133FFr3Xht9xooA_DuaX_3QwODtc",
+			CodeChallengeMethod: "S256",
+		},
+		LoginHint:    "jane.doe@example.com",
+		Resources:    []string{"https://api.example.com"},
+		ResponseMode: "form_post",
 	}
 
 	identity := storage.Claims{Email: "foobar"}
+	authTime := time.Now().UTC().Round(time.Millisecond)
 
 	if err := s.CreateAuthRequest(a1); err != nil {
 		t.Fatalf("failed creating auth request: %v", err)
@@ -142,6 +157,7 @@ func testAuthRequestCRUD(t *testing.T, s storage.Storage) {
 	if err := s.UpdateAuthRequest(a1.ID, func(old storage.AuthRequest) (storage.AuthRequest, error) {
 		old.Claims = identity
 		old.ConnectorID = "connID"
+		old.AuthTime = authTime
 		return old, nil
 	}); err != nil {
 		t.Fatalf("failed to update auth request: %v", err)
@@ -154,6 +170,18 @@ func testAuthRequestCRUD(t *testing.T, s storage.Storage) {
 	if !reflect.DeepEqual(got.Claims, identity) {
 		t.Fatalf("update failed, wanted identity=%#v got %#v", identity, got.Claims)
 	}
+	if got.PKCE != a1.PKCE {
+		t.Errorf("auth request PKCE did not match, wanted=%#v got %#v", a1.PKCE, got.PKCE)
+	}
+	if got.LoginHint != a1.LoginHint {
+		t.Errorf("auth request login hint did not match, wanted=%q got %q", a1.LoginHint, got.LoginHint)
+	}
+	if !got.AuthTime.Equal(authTime) {
+		t.Errorf("auth request auth time did not match, wanted=%s got %s", authTime, got.AuthTime)
+	}
+	if !reflect.DeepEqual(got.Resources, a1.Resources) {
+		t.Errorf("auth request resources did not match, wanted=%q got %q", a1.Resources, got.Resources)
+	}
 
 	if err := s.DeleteAuthRequest(a1.ID); err != nil {
 		t.Fatalf("failed to delete auth request: %v", err)
@@ -182,6 +210,12 @@ func testAuthCodeCRUD(t *testing.T, s storage.Storage) {
 			EmailVerified: true,
 			Groups:        []string{"a", "b"},
 		},
+		PKCE: storage.PKCE{
+			CodeChallenge:       "ngF5GsXcbwljx6u133FFr3Xht9xooA_DuaX_3QwODtc",
+			CodeChallengeMethod: "S256",
+		},
+		AuthTime:  time.Now().UTC().Round(time.Second),
+		Resources: []string{"https://api.example.com"},
 	}
 
 	if err := s.CreateAuthCode(a1); err != nil {
@@ -221,7 +255,11 @@ func testAuthCodeCRUD(t *testing.T, s storage.Storage) {
 	if a1.Expiry.Unix() != got.Expiry.Unix() {
 		t.Errorf("auth code expiry did not match want=%s vs got=%s", a1.Expiry, got.Expiry)
 	}
+	if a1.AuthTime.Unix() != got.AuthTime.Unix() {
+		t.Errorf("auth code auth time did not match want=%s vs got=%s", a1.AuthTime, got.AuthTime)
+	}
 	got.Expiry = a1.Expiry // time fields do not compare well
+	got.AuthTime = a1.AuthTime
 	if diff := pretty.Compare(a1, got); diff != "" {
 		t.Errorf("auth code retrieved from storage did not match: %s", diff)
 	}
@@ -238,14 +276,354 @@ func testAuthCodeCRUD(t *testing.T, s storage.Storage) {
 	mustBeErrNotFound(t, "auth code", err)
 }
 
+func testAccessTokenCRUD(t *testing.T, s storage.Storage) {
+	t1 := storage.AccessToken{
+		ID:          storage.NewID(),
+		ClientID:    "client1",
+		Scopes:      []string{"openid", "email"},
+		ConnectorID: "ldap",
+		Claims: storage.Claims{
+			UserID:        "1",
+			Username:      "jane",
+			Email:         "jane.doe@example.com",
+			EmailVerified: true,
+			Groups:        []string{"a", "b"},
+		},
+		Resources: []string{"https://api.example.com"},
+		CreatedAt: time.Now().UTC().Round(time.Millisecond),
+		Expiry:    neverExpire,
+	}
+
+	if err := s.CreateAccessToken(t1); err != nil {
+		t.Fatalf("failed creating access token: %v", err)
+	}
+
+	// Attempt to create same AccessToken twice.
+	err := s.CreateAccessToken(t1)
+	mustBeErrAlreadyExists(t, "access token", err)
+
+	got, err := s.GetAccessToken(t1.ID)
+	if err != nil {
+		t.Fatalf("failed to get access token: %v", err)
+	}
+	if t1.Expiry.Unix() != got.Expiry.Unix() {
+		t.Errorf("access token expiry did not match want=%s vs got=%s", t1.Expiry, got.Expiry)
+	}
+	if t1.CreatedAt.Unix() != got.CreatedAt.Unix() {
+		t.Errorf("access token created at did not match want=%s vs got=%s", t1.CreatedAt, got.CreatedAt)
+	}
+	// time fields do not compare well
+	got.Expiry = t1.Expiry
+	got.CreatedAt = t1.CreatedAt
+	if diff := pretty.Compare(t1, got); diff != "" {
+		t.Errorf("access token retrieved from storage did not match: %s", diff)
+	}
+
+	if err := s.DeleteAccessToken(t1.ID); err != nil {
+		t.Fatalf("delete access token: %v", err)
+	}
+
+	_, err = s.GetAccessToken(t1.ID)
+	mustBeErrNotFound(t, "access token", err)
+}
+
+func testDeviceRequestCRUD(t *testing.T, s storage.Storage) {
+	d1 := storage.DeviceRequest{
+		UserCode:   "bcdfghjk",
+		DeviceCode: storage.NewID(),
+		ClientID:   "client1",
+		Scopes:     []string{"openid", "email"},
+		Interval:   5,
+		LastPoll:   time.Now().UTC().Round(time.Millisecond),
+		Expiry:     neverExpire,
+	}
+
+	if err := s.CreateDeviceRequest(d1); err != nil {
+		t.Fatalf("failed creating device request: %v", err)
+	}
+
+	// Attempt to create same DeviceRequest twice.
+	err := s.CreateDeviceRequest(d1)
+	mustBeErrAlreadyExists(t, "device request", err)
+
+	getAndCompare := func(want storage.DeviceRequest) {
+		got, err := s.GetDeviceRequest(want.UserCode)
+		if err != nil {
+			t.Errorf("failed to get device request: %v", err)
+			return
+		}
+		if want.Expiry.Unix() != got.Expiry.Unix() {
+			t.Errorf("device request expiry did not match want=%s vs got=%s", want.Expiry, got.Expiry)
+		}
+		if want.LastPoll.Unix() != got.LastPoll.Unix() {
+			t.Errorf("device request last poll did not match want=%s vs got=%s", want.LastPoll, got.LastPoll)
+		}
+		// time fields do not compare well
+		got.Expiry = want.Expiry
+		got.LastPoll = want.LastPoll
+		if diff := pretty.Compare(want, got); diff != "" {
+			t.Errorf("device request retrieved from storage did not match: %s", diff)
+		}
+	}
+
+	getAndCompare(d1)
+
+	lastPoll := d1.LastPoll.Add(time.Minute)
+	updater := func(old storage.DeviceRequest) (storage.DeviceRequest, error) {
+		old.AuthCode = "code1"
+		old.Interval = 10
+		old.LastPoll = lastPoll
+		return old, nil
+	}
+	if err := s.UpdateDeviceRequest(d1.UserCode, updater); err != nil {
+		t.Fatalf("failed to update device request: %v", err)
+	}
+
+	d1.AuthCode = "code1"
+	d1.Interval = 10
+	d1.LastPoll = lastPoll
+	getAndCompare(d1)
+
+	if err := s.DeleteDeviceRequest(d1.UserCode); err != nil {
+		t.Fatalf("delete device request: %v", err)
+	}
+
+	_, err = s.GetDeviceRequest(d1.UserCode)
+	mustBeErrNotFound(t, "device request", err)
+}
+
+func testClientAssertionCreate(t *testing.T, s storage.Storage) {
+	a := storage.ClientAssertion{
+		ID:       storage.NewID(),
+		ClientID: "client1",
+		Expiry:   neverExpire,
+	}
+
+	if err := s.CreateClientAssertion(a); err != nil {
+		t.Fatalf("failed creating client assertion: %v", err)
+	}
+
+	// Assertions can only be recorded once.
+	err := s.CreateClientAssertion(a)
+	mustBeErrAlreadyExists(t, "client assertion", err)
+}
+
+func testSessionCRUD(t *testing.T, s storage.Storage) {
+	createdAt := time.Now().UTC().Round(time.Millisecond)
+	s1 := storage.Session{
+		ID:            storage.NewID(),
+		ConnectorID:   "ldap",
+		ConnectorData: []byte(`{"some":"data"}`),
+		Claims: storage.Claims{
+			UserID:        "1",
+			Username:      "jane",
+			Email:         "jane.doe@example.com",
+			EmailVerified: true,
+			Groups:        []string{"a", "b"},
+		},
+		CreatedAt: createdAt,
+		LastUsed:  createdAt,
+		Expiry:    neverExpire,
+		ClientIDs: []string{"client1"},
+	}
+
+	if err := s.CreateSession(s1); err != nil {
+		t.Fatalf("failed creating session: %v", err)
+	}
+
+	// Attempt to create same Session twice.
+	err := s.CreateSession(s1)
+	mustBeErrAlreadyExists(t, "session", err)
+
+	getAndCompare := func(want storage.Session) {
+		got, err := s.GetSession(want.ID)
+		if err != nil {
+			t.Errorf("failed to get session: %v", err)
+			return
+		}
+		if want.CreatedAt.Unix() != got.CreatedAt.Unix() {
+			t.Errorf("session created at did not match want=%s vs got=%s", want.CreatedAt, got.CreatedAt)
+		}
+		if want.LastUsed.Unix() != got.LastUsed.Unix() {
+			t.Errorf("session last used did not match want=%s vs got=%s", want.LastUsed, got.LastUsed)
+		}
+		if want.Expiry.Unix() != got.Expiry.Unix() {
+			t.Errorf("session expiry did not match want=%s vs got=%s", want.Expiry, got.Expiry)
+		}
+		// time fields do not compare well
+		got.CreatedAt = want.CreatedAt
+		got.LastUsed = want.LastUsed
+		got.Expiry = want.Expiry
+		if diff := pretty.Compare(want, got); diff != "" {
+			t.Errorf("session retrieved from storage did not match: %s", diff)
+		}
+	}
+
+	getAndCompare(s1)
+
+	lastUsed := createdAt.Add(time.Minute)
+	updater := func(old storage.Session) (storage.Session, error) {
+		old.LastUsed = lastUsed
+		old.Claims.Groups = []string{"c"}
+		old.ClientIDs = append(old.ClientIDs, "client2")
+		return old, nil
+	}
+	if err := s.UpdateSession(s1.ID, updater); err != nil {
+		t.Fatalf("failed to update session: %v", err)
+	}
+
+	s1.LastUsed = lastUsed
+	s1.Claims.Groups = []string{"c"}
+	s1.ClientIDs = []string{"client1", "client2"}
+	getAndCompare(s1)
+
+	if err := s.DeleteSession(s1.ID); err != nil {
+		t.Fatalf("delete session: %v", err)
+	}
+
+	_, err = s.GetSession(s1.ID)
+	mustBeErrNotFound(t, "session", err)
+}
+
+type byClientID []storage.LogoutNotification
+
+func (n byClientID) Len() int           { return len(n) }
+func (n byClientID) Less(i, j int) bool { return n[i].ClientID < n[j].ClientID }
+func (n byClientID) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+
+func testLogoutNotificationCRUD(t *testing.T, s storage.Storage) {
+	n1 := storage.LogoutNotification{
+		ID:          storage.NewID(),
+		ClientID:    "client1",
+		Subject:     "CgExEgRsZGFw",
+		NextAttempt: time.Now().UTC().Round(time.Millisecond),
+		Expiry:      neverExpire,
+	}
+	n2 := storage.LogoutNotification{
+		ID:          storage.NewID(),
+		ClientID:    "client2",
+		Subject:     "CgExEgRsZGFw",
+		NextAttempt: time.Now().UTC().Round(time.Millisecond),
+		Expiry:      neverExpire,
+	}
+
+	if err := s.CreateLogoutNotification(n1); err != nil {
+		t.Fatalf("failed creating logout notification: %v", err)
+	}
+
+	// Attempt to create same LogoutNotification twice.
+	err := s.CreateLogoutNotification(n1)
+	mustBeErrAlreadyExists(t, "logout notification", err)
+
+	if err := s.CreateLogoutNotification(n2); err != nil {
+		t.Fatalf("failed creating logout notification: %v", err)
+	}
+
+	listAndCompare := func(want []storage.LogoutNotification) {
+		got, err := s.ListLogoutNotifications()
+		if err != nil {
+			t.Errorf("failed to list logout notifications: %v", err)
+			return
+		}
+		sort.Sort(byClientID(got))
+		if len(got) != len(want) {
+			t.Errorf("expected %d logout notifications, got %d", len(want), len(got))
+			return
+		}
+		for i := range want {
+			if want[i].NextAttempt.Unix() != got[i].NextAttempt.Unix() {
+				t.Errorf("logout notification next attempt did not match want=%s vs got=%s", want[i].NextAttempt, got[i].NextAttempt)
+			}
+			if want[i].Expiry.Unix() != got[i].Expiry.Unix() {
+				t.Errorf("logout notification expiry did not match want=%s vs got=%s", want[i].Expiry, got[i].Expiry)
+			}
+			// time fields do not compare well
+			got[i].NextAttempt = want[i].NextAttempt
+			got[i].Expiry = want[i].Expiry
+		}
+		if diff := pretty.Compare(want, got); diff != "" {
+			t.Errorf("logout notifications retrieved from storage did not match: %s", diff)
+		}
+	}
+
+	listAndCompare([]storage.LogoutNotification{n1, n2})
+
+	nextAttempt := n1.NextAttempt.Add(time.Minute)
+	updater := func(old storage.LogoutNotification) (storage.LogoutNotification, error) {
+		old.Attempts++
+		old.NextAttempt = nextAttempt
+		return old, nil
+	}
+	if err := s.UpdateLogoutNotification(n1.ID, updater); err != nil {
+		t.Fatalf("failed to update logout notification: %v", err)
+	}
+	n1.Attempts = 1
+	n1.NextAttempt = nextAttempt
+	listAndCompare([]storage.LogoutNotification{n1, n2})
+
+	if err := s.DeleteLogoutNotification(n1.ID); err != nil {
+		t.Fatalf("delete logout notification: %v", err)
+	}
+	listAndCompare([]storage.LogoutNotification{n2})
+
+	err = s.DeleteLogoutNotification(n1.ID)
+	mustBeErrNotFound(t, "logout notification", err)
+
+	if err := s.DeleteLogoutNotification(n2.ID); err != nil {
+		t.Fatalf("delete logout notification: %v", err)
+	}
+}
+
+func testPushedAuthRequestCRUD(t *testing.T, s storage.Storage) {
+	p := storage.PushedAuthRequest{
+		ID:       storage.NewID(),
+		ClientID: "client1",
+		Params: map[string][]string{
+			"response_type": {"code"},
+			"scope":         {"openid email"},
+			"resource":      {"https://api.example.com", "https://other.example.com"},
+		},
+		Expiry: neverExpire,
+	}
+
+	if err := s.CreatePushedAuthRequest(p); err != nil {
+		t.Fatalf("failed creating pushed auth request: %v", err)
+	}
+
+	// Attempt to create same PushedAuthRequest twice.
+	err := s.CreatePushedAuthRequest(p)
+	mustBeErrAlreadyExists(t, "pushed auth request", err)
+
+	got, err := s.GetPushedAuthRequest(p.ID)
+	if err != nil {
+		t.Fatalf("failed to get pushed auth request: %v", err)
+	}
+	if p.Expiry.Unix() != got.Expiry.Unix() {
+		t.Errorf("pushed auth request expiry did not match want=%s vs got=%s", p.Expiry, got.Expiry)
+	}
+	// time fields do not compare well
+	got.Expiry = p.Expiry
+	if diff := pretty.Compare(p, got); diff != "" {
+		t.Errorf("pushed auth request retrieved from storage did not match: %s", diff)
+	}
+
+	if err := s.DeletePushedAuthRequest(p.ID); err != nil {
+		t.Fatalf("delete pushed auth request: %v", err)
+	}
+
+	_, err = s.GetPushedAuthRequest(p.ID)
+	mustBeErrNotFound(t, "pushed auth request", err)
+}
+
 func testClientCRUD(t *testing.T, s storage.Storage) {
 	id1 := storage.NewID()
 	c1 := storage.Client{
-		ID:           id1,
-		Secret:       "foobar",
-		RedirectURIs: []string{"foo://bar.com/", "https://auth.example.com"},
-		Name:         "dex client",
-		LogoURL:      "https://goo.gl/JIyzIC",
+		ID:                id1,
+		Secret:            "foobar",
+		RedirectURIs:      []string{"foo://bar.com/", "https://auth.example.com"},
+		Name:              "dex client",
+		LogoURL:           "https://goo.gl/JIyzIC",
+		AllowedGrantTypes: []string{"authorization_code", "refresh_token"},
 	}
 	err := s.DeleteClient(id1)
 	mustBeErrNotFound(t, "client", err)
@@ -285,14 +663,59 @@ func testClientCRUD(t *testing.T, s storage.Storage) {
 	getAndCompare(id1, c1)
 
 	newSecret := "barfoo"
+	newGrantTypes := []string{"client_credentials"}
+	newJWKS := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*jsonWebKeys[0].Public}}
+	newJWKSURI := "https://auth.example.com/keys"
+	newRegistrationTokenHash := "a3f1b2c4"
+	newPostLogoutRedirectURIs := []string{"https://auth.example.com/logged-out"}
+	newBackchannelLogoutURI := "https://auth.example.com/backchannel-logout"
+	newAccessTokenFormat := "jwt"
 	err = s.UpdateClient(id1, func(old storage.Client) (storage.Client, error) {
 		old.Secret = newSecret
+		old.AllowedGrantTypes = newGrantTypes
+		old.JWKS = newJWKS
+		old.JWKSURI = newJWKSURI
+		old.RegistrationTokenHash = newRegistrationTokenHash
+		old.PostLogoutRedirectURIs = newPostLogoutRedirectURIs
+		old.BackchannelLogoutURI = newBackchannelLogoutURI
+		old.AccessTokenFormat = newAccessTokenFormat
+		old.RefreshTokenIdleTimeout = "24h"
+		old.RefreshTokenAbsoluteLifetime = "720h"
+		old.RequirePushedAuthRequests = true
+		old.RequireSignedRequestObject = true
+		old.SubjectType = "pairwise"
+		old.SectorIdentifierURI = "https://auth.example.com/sector.json"
+		old.AllowedConnectors = []string{"github", "ldap"}
+		old.AllowedScopes = []string{"email", "groups"}
+		old.AllowedResponseTypes = []string{"code"}
+		old.IDTokenLifetime = "10m"
+		old.AccessTokenLifetime = "5m"
+		old.AuthCodeLifetime = "1m"
 		return old, nil
 	})
 	if err != nil {
 		t.Errorf("update client: %v", err)
 	}
 	c1.Secret = newSecret
+	c1.AllowedGrantTypes = newGrantTypes
+	c1.JWKS = newJWKS
+	c1.JWKSURI = newJWKSURI
+	c1.RegistrationTokenHash = newRegistrationTokenHash
+	c1.PostLogoutRedirectURIs = newPostLogoutRedirectURIs
+	c1.BackchannelLogoutURI = newBackchannelLogoutURI
+	c1.AccessTokenFormat = newAccessTokenFormat
+	c1.RefreshTokenIdleTimeout = "24h"
+	c1.RefreshTokenAbsoluteLifetime = "720h"
+	c1.RequirePushedAuthRequests = true
+	c1.RequireSignedRequestObject = true
+	c1.SubjectType = "pairwise"
+	c1.SectorIdentifierURI = "https://auth.example.com/sector.json"
+	c1.AllowedConnectors = []string{"github", "ldap"}
+	c1.AllowedScopes = []string{"email", "groups"}
+	c1.AllowedResponseTypes = []string{"code"}
+	c1.IDTokenLifetime = "10m"
+	c1.AccessTokenLifetime = "5m"
+	c1.AuthCodeLifetime = "1m"
 	getAndCompare(id1, c1)
 
 	if err := s.DeleteClient(id1); err != nil {
@@ -318,6 +741,7 @@ func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 		Scopes:      []string{"openid", "email", "profile"},
 		CreatedAt:   time.Now().UTC().Round(time.Millisecond),
 		LastUsed:    time.Now().UTC().Round(time.Millisecond),
+		AuthTime:    time.Now().UTC().Round(time.Millisecond),
 		Claims: storage.Claims{
 			UserID:        "1",
 			Username:      "jane",
@@ -326,6 +750,7 @@ func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 			Groups:        []string{"a", "b"},
 		},
 		ConnectorData: []byte(`{"some":"data"}`),
+		Resources:     []string{"https://api.example.com"},
 	}
 	if err := s.CreateRefresh(refresh); err != nil {
 		t.Fatalf("create refresh token: %v", err)
@@ -375,17 +800,22 @@ func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 	getAndCompare(id2, refresh2)
 
 	updatedAt := time.Now().UTC().Round(time.Millisecond)
+	expiry := updatedAt.Add(time.Hour)
 
 	updater := func(r storage.RefreshToken) (storage.RefreshToken, error) {
+		r.ObsoleteToken = r.Token
 		r.Token = "spam"
 		r.LastUsed = updatedAt
+		r.Expiry = expiry
 		return r, nil
 	}
 	if err := s.UpdateRefreshToken(id, updater); err != nil {
 		t.Errorf("failed to udpate refresh token: %v", err)
 	}
+	refresh.ObsoleteToken = refresh.Token
 	refresh.Token = "spam"
 	refresh.LastUsed = updatedAt
+	refresh.Expiry = expiry
 	getAndCompare(id, refresh)
 
 	// Ensure that updating the first token doesn't impact the second. Issue #847.
@@ -572,6 +1002,58 @@ func testOfflineSessionCRUD(t *testing.T, s storage.Storage) {
 	mustBeErrNotFound(t, "offline session", err)
 }
 
+func testConsentsCRUD(t *testing.T, s storage.Storage) {
+	consents := storage.Consents{
+		UserID:  storage.NewID(),
+		ConnID:  "Conn1",
+		Clients: make(map[string]*storage.Consent),
+	}
+
+	if err := s.CreateConsents(consents); err != nil {
+		t.Fatalf("create consents: %v", err)
+	}
+
+	// Attempt to create same Consents twice.
+	err := s.CreateConsents(consents)
+	mustBeErrAlreadyExists(t, "consents", err)
+
+	getAndCompare := func(want storage.Consents) {
+		got, err := s.GetConsents(want.UserID, want.ConnID)
+		if err != nil {
+			t.Errorf("get consents: %v", err)
+			return
+		}
+		if diff := pretty.Compare(want, got); diff != "" {
+			t.Errorf("consents retrieved from storage did not match: %s", diff)
+		}
+	}
+
+	getAndCompare(consents)
+
+	consent := storage.Consent{
+		Scopes:      []string{"openid", "email"},
+		CreatedAt:   time.Now().UTC().Round(time.Millisecond),
+		LastUpdated: time.Now().UTC().Round(time.Millisecond),
+	}
+	consents.Clients["client_id"] = &consent
+
+	if err := s.UpdateConsents(consents.UserID, consents.ConnID, func(old storage.Consents) (storage.Consents, error) {
+		old.Clients["client_id"] = &consent
+		return old, nil
+	}); err != nil {
+		t.Fatalf("failed to update consents: %v", err)
+	}
+
+	getAndCompare(consents)
+
+	if err := s.DeleteConsents(consents.UserID, consents.ConnID); err != nil {
+		t.Fatalf("failed to delete consents: %v", err)
+	}
+
+	_, err = s.GetConsents(consents.UserID, consents.ConnID)
+	mustBeErrNotFound(t, "consents", err)
+}
+
 func testConnectorCRUD(t *testing.T, s storage.Storage) {
 	id1 := storage.NewID()
 	config1 := []byte(`{"issuer": "https://accounts.google.com"}`)
@@ -674,9 +1156,11 @@ func testKeysCRUD(t *testing.T, s storage.Storage) {
 	}
 
 	keys2 := storage.Keys{
-		SigningKey:    jsonWebKeys[2].Private,
-		SigningKeyPub: jsonWebKeys[2].Public,
-		NextRotation:  n.Add(time.Hour),
+		SigningKey:        jsonWebKeys[2].Private,
+		SigningKeyPub:     jsonWebKeys[2].Public,
+		NextSigningKey:    jsonWebKeys[1].Private,
+		NextSigningKeyPub: jsonWebKeys[1].Public,
+		NextRotation:      n.Add(time.Hour),
 		VerificationKeys: []storage.VerificationKey{
 			{
 				PublicKey: jsonWebKeys[0].Public,
@@ -803,6 +1287,322 @@ func testGC(t *testing.T, s storage.Storage) {
 	} else if err != storage.ErrNotFound {
 		t.Errorf("expected storage.ErrNotFound, got %v", err)
 	}
+
+	at := storage.AccessToken{
+		ID:          storage.NewID(),
+		ClientID:    "foobar",
+		Scopes:      []string{"openid", "email"},
+		ConnectorID: "ldap",
+		Claims: storage.Claims{
+			UserID:        "1",
+			Username:      "jane",
+			Email:         "jane.doe@example.com",
+			EmailVerified: true,
+			Groups:        []string{"a", "b"},
+		},
+		CreatedAt: expiry.Add(-time.Hour),
+		Expiry:    expiry,
+	}
+
+	if err := s.CreateAccessToken(at); err != nil {
+		t.Fatalf("failed creating access token: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else {
+			if result.AccessTokens != 0 {
+				t.Errorf("expected no garbage collection results, got %#v", result)
+			}
+		}
+		if _, err := s.GetAccessToken(at.ID); err != nil {
+			t.Errorf("expected to be able to get access token after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.AccessTokens != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.AccessTokens)
+	}
+
+	if _, err := s.GetAccessToken(at.ID); err == nil {
+		t.Errorf("expected access token to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	d := storage.DeviceRequest{
+		UserCode:   "mnpqrstv",
+		DeviceCode: storage.NewID(),
+		ClientID:   "foobar",
+		Scopes:     []string{"openid", "email"},
+		Interval:   5,
+		LastPoll:   expiry.Add(-time.Hour),
+		Expiry:     expiry,
+	}
+
+	if err := s.CreateDeviceRequest(d); err != nil {
+		t.Fatalf("failed creating device request: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else {
+			if result.DeviceRequests != 0 {
+				t.Errorf("expected no garbage collection results, got %#v", result)
+			}
+		}
+		if _, err := s.GetDeviceRequest(d.UserCode); err != nil {
+			t.Errorf("expected to be able to get device request after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.DeviceRequests != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.DeviceRequests)
+	}
+
+	if _, err := s.GetDeviceRequest(d.UserCode); err == nil {
+		t.Errorf("expected device request to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	ca := storage.ClientAssertion{
+		ID:       storage.NewID(),
+		ClientID: "foobar",
+		Expiry:   expiry,
+	}
+
+	if err := s.CreateClientAssertion(ca); err != nil {
+		t.Fatalf("failed creating client assertion: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else {
+			if result.ClientAssertions != 0 {
+				t.Errorf("expected no garbage collection results, got %#v", result)
+			}
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.ClientAssertions != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.ClientAssertions)
+	}
+
+	// Once collected the assertion can be recorded again.
+	if err := s.CreateClientAssertion(ca); err != nil {
+		t.Errorf("expected client assertion to be GC'd: %v", err)
+	}
+
+	session := storage.Session{
+		ID:          storage.NewID(),
+		ConnectorID: "ldap",
+		Claims: storage.Claims{
+			UserID: "1",
+			Email:  "jane.doe@example.com",
+		},
+		CreatedAt: expiry.Add(-time.Hour),
+		LastUsed:  expiry.Add(-time.Hour),
+		Expiry:    expiry,
+	}
+
+	if err := s.CreateSession(session); err != nil {
+		t.Fatalf("failed creating session: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else {
+			if result.Sessions != 0 {
+				t.Errorf("expected no garbage collection results, got %#v", result)
+			}
+		}
+		if _, err := s.GetSession(session.ID); err != nil {
+			t.Errorf("expected to be able to get session after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.Sessions != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.Sessions)
+	}
+
+	if _, err := s.GetSession(session.ID); err == nil {
+		t.Errorf("expected session to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	notification := storage.LogoutNotification{
+		ID:          storage.NewID(),
+		ClientID:    "client1",
+		Subject:     "CgExEgRsZGFw",
+		NextAttempt: expiry.Add(-time.Hour),
+		Expiry:      expiry,
+	}
+
+	if err := s.CreateLogoutNotification(notification); err != nil {
+		t.Fatalf("failed creating logout notification: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else {
+			if result.LogoutNotifications != 0 {
+				t.Errorf("expected no garbage collection results, got %#v", result)
+			}
+		}
+		if notifications, err := s.ListLogoutNotifications(); err != nil {
+			t.Errorf("failed to list logout notifications: %v", err)
+		} else if len(notifications) != 1 {
+			t.Errorf("expected logout notification to survive GC, got %d notifications", len(notifications))
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.LogoutNotifications != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.LogoutNotifications)
+	}
+
+	if notifications, err := s.ListLogoutNotifications(); err != nil {
+		t.Errorf("failed to list logout notifications: %v", err)
+	} else if len(notifications) != 0 {
+		t.Errorf("expected logout notification to be GC'd")
+	}
+
+	pushedAuthReq := storage.PushedAuthRequest{
+		ID:       storage.NewID(),
+		ClientID: "client1",
+		Params:   map[string][]string{"response_type": {"code"}},
+		Expiry:   expiry,
+	}
+
+	if err := s.CreatePushedAuthRequest(pushedAuthReq); err != nil {
+		t.Fatalf("failed creating pushed auth request: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else {
+			if result.PushedAuthRequests != 0 {
+				t.Errorf("expected no garbage collection results, got %#v", result)
+			}
+		}
+		if _, err := s.GetPushedAuthRequest(pushedAuthReq.ID); err != nil {
+			t.Errorf("expected to be able to get pushed auth request after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.PushedAuthRequests != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.PushedAuthRequests)
+	}
+
+	if _, err := s.GetPushedAuthRequest(pushedAuthReq.ID); err == nil {
+		t.Errorf("expected pushed auth request to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	// Refresh tokens with a zero expiry never expire.
+	refresh := storage.RefreshToken{
+		ID:          storage.NewID(),
+		Token:       "bar",
+		ClientID:    "client1",
+		ConnectorID: "conn1",
+		Claims:      storage.Claims{UserID: "user1"},
+		CreatedAt:   expiry.Add(-time.Hour),
+		LastUsed:    expiry.Add(-time.Hour),
+		Expiry:      expiry,
+	}
+	forever := storage.RefreshToken{
+		ID:          storage.NewID(),
+		Token:       "bar",
+		ClientID:    "client2",
+		ConnectorID: "conn1",
+		Claims:      storage.Claims{UserID: "user1"},
+		CreatedAt:   expiry.Add(-time.Hour),
+		LastUsed:    expiry.Add(-time.Hour),
+	}
+	for _, r := range []storage.RefreshToken{refresh, forever} {
+		if err := s.CreateRefresh(r); err != nil {
+			t.Fatalf("failed creating refresh token: %v", err)
+		}
+	}
+	offlineSessions := storage.OfflineSessions{
+		UserID: "user1",
+		ConnID: "conn1",
+		Refresh: map[string]*storage.RefreshTokenRef{
+			refresh.ClientID: {ID: refresh.ID, ClientID: refresh.ClientID},
+			forever.ClientID: {ID: forever.ID, ClientID: forever.ClientID},
+			// A reference to a refresh token that was deleted elsewhere.
+			"client3": {ID: storage.NewID(), ClientID: "client3"},
+		},
+	}
+	if err := s.CreateOfflineSessions(offlineSessions); err != nil {
+		t.Fatalf("failed creating offline sessions: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if result.RefreshTokens != 0 {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+		if _, err := s.GetRefresh(refresh.ID); err != nil {
+			t.Errorf("expected refresh token to not be GC'd")
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.RefreshTokens != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.RefreshTokens)
+	}
+
+	if _, err := s.GetRefresh(refresh.ID); err == nil {
+		t.Errorf("expected refresh token to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+	if _, err := s.GetRefresh(forever.ID); err != nil {
+		t.Errorf("expected refresh token without expiry to survive GC: %v", err)
+	}
+	if o, err := s.GetOfflineSessions("user1", "conn1"); err != nil {
+		t.Errorf("failed to get offline sessions: %v", err)
+	} else {
+		if _, ok := o.Refresh[refresh.ClientID]; ok {
+			t.Errorf("expected offline session reference to GC'd refresh token to be removed")
+		}
+		if _, ok := o.Refresh["client3"]; ok {
+			t.Errorf("expected offline session reference to missing refresh token to be removed")
+		}
+		if _, ok := o.Refresh[forever.ClientID]; !ok {
+			t.Errorf("expected offline session reference to refresh token without expiry to be kept")
+		}
+	}
 }
 
 // testTimezones tests that backends either fully support timezones or
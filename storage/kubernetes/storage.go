@@ -21,6 +21,14 @@ const (
 	kindPassword        = "Password"
 	kindOfflineSessions = "OfflineSessions"
 	kindConnector       = "Connector"
+	kindAccessToken     = "AccessToken"
+	kindDeviceRequest   = "DeviceRequest"
+	kindClientAssertion = "ClientAssertion"
+	kindSession         = "Session"
+
+	kindLogoutNotification = "LogoutNotification"
+	kindPushedAuthRequest  = "PushedAuthRequest"
+	kindConsents           = "Consents"
 )
 
 const (
@@ -32,6 +40,14 @@ const (
 	resourcePassword        = "passwords"
 	resourceOfflineSessions = "offlinesessionses" // Again attempts to pluralize.
 	resourceConnector       = "connectors"
+	resourceAccessToken     = "accesstokens"
+	resourceDeviceRequest   = "devicerequests"
+	resourceClientAssertion = "clientassertions"
+	resourceSession         = "sessions"
+
+	resourceLogoutNotification = "logoutnotifications"
+	resourcePushedAuthRequest  = "pushedauthrequests"
+	resourceConsents           = "consentses" // Again attempts to pluralize.
 )
 
 // Config values for the Kubernetes storage type.
@@ -175,10 +191,18 @@ func (cli *client) CreateOfflineSessions(o storage.OfflineSessions) error {
 	return cli.post(resourceOfflineSessions, cli.fromStorageOfflineSessions(o))
 }
 
+func (cli *client) CreateConsents(c storage.Consents) error {
+	return cli.post(resourceConsents, cli.fromStorageConsents(c))
+}
+
 func (cli *client) CreateConnector(c storage.Connector) error {
 	return cli.post(resourceConnector, cli.fromStorageConnector(c))
 }
 
+func (cli *client) CreateAccessToken(t storage.AccessToken) error {
+	return cli.post(resourceAccessToken, cli.fromStorageAccessToken(t))
+}
+
 func (cli *client) GetAuthRequest(id string) (storage.AuthRequest, error) {
 	var req AuthRequest
 	if err := cli.get(resourceAuthRequest, id, &req); err != nil {
@@ -266,6 +290,25 @@ func (cli *client) GetOfflineSessions(userID string, connID string) (storage.Off
 	return toStorageOfflineSessions(o), nil
 }
 
+func (cli *client) GetConsents(userID string, connID string) (storage.Consents, error) {
+	c, err := cli.getConsents(userID, connID)
+	if err != nil {
+		return storage.Consents{}, err
+	}
+	return toStorageConsents(c), nil
+}
+
+func (cli *client) getConsents(userID string, connID string) (c Consents, err error) {
+	name := cli.offlineTokenName(userID, connID)
+	if err = cli.get(resourceConsents, name, &c); err != nil {
+		return Consents{}, err
+	}
+	if userID != c.UserID || connID != c.ConnID {
+		return Consents{}, fmt.Errorf("get consents: wrong consents retrieved")
+	}
+	return c, nil
+}
+
 func (cli *client) getOfflineSessions(userID string, connID string) (o OfflineSessions, err error) {
 	name := cli.offlineTokenName(userID, connID)
 	if err = cli.get(resourceOfflineSessions, name, &o); err != nil {
@@ -285,6 +328,134 @@ func (cli *client) GetConnector(id string) (storage.Connector, error) {
 	return toStorageConnector(c), nil
 }
 
+func (cli *client) CreateDeviceRequest(d storage.DeviceRequest) error {
+	return cli.post(resourceDeviceRequest, cli.fromStorageDeviceRequest(d))
+}
+
+func (cli *client) CreateClientAssertion(a storage.ClientAssertion) error {
+	return cli.post(resourceClientAssertion, cli.fromStorageClientAssertion(a))
+}
+
+func (cli *client) CreateSession(s storage.Session) error {
+	return cli.post(resourceSession, cli.fromStorageSession(s))
+}
+
+func (cli *client) CreateLogoutNotification(n storage.LogoutNotification) error {
+	return cli.post(resourceLogoutNotification, cli.fromStorageLogoutNotification(n))
+}
+
+func (cli *client) CreatePushedAuthRequest(p storage.PushedAuthRequest) error {
+	return cli.post(resourcePushedAuthRequest, cli.fromStoragePushedAuthRequest(p))
+}
+
+func (cli *client) GetDeviceRequest(userCode string) (storage.DeviceRequest, error) {
+	var d DeviceRequest
+	if err := cli.get(resourceDeviceRequest, userCode, &d); err != nil {
+		return storage.DeviceRequest{}, err
+	}
+	return toStorageDeviceRequest(d), nil
+}
+
+func (cli *client) DeleteDeviceRequest(userCode string) error {
+	return cli.delete(resourceDeviceRequest, userCode)
+}
+
+func (cli *client) UpdateDeviceRequest(userCode string, updater func(d storage.DeviceRequest) (storage.DeviceRequest, error)) error {
+	var d DeviceRequest
+	if err := cli.get(resourceDeviceRequest, userCode, &d); err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageDeviceRequest(d))
+	if err != nil {
+		return err
+	}
+
+	newReq := cli.fromStorageDeviceRequest(updated)
+	newReq.ObjectMeta = d.ObjectMeta
+	return cli.put(resourceDeviceRequest, userCode, newReq)
+}
+
+func (cli *client) GetSession(id string) (storage.Session, error) {
+	var s Session
+	if err := cli.get(resourceSession, id, &s); err != nil {
+		return storage.Session{}, err
+	}
+	return toStorageSession(s), nil
+}
+
+func (cli *client) DeleteSession(id string) error {
+	return cli.delete(resourceSession, id)
+}
+
+func (cli *client) GetPushedAuthRequest(id string) (storage.PushedAuthRequest, error) {
+	var p PushedAuthRequest
+	if err := cli.get(resourcePushedAuthRequest, id, &p); err != nil {
+		return storage.PushedAuthRequest{}, err
+	}
+	return toStoragePushedAuthRequest(p), nil
+}
+
+func (cli *client) DeletePushedAuthRequest(id string) error {
+	return cli.delete(resourcePushedAuthRequest, id)
+}
+
+func (cli *client) UpdateSession(id string, updater func(s storage.Session) (storage.Session, error)) error {
+	var s Session
+	if err := cli.get(resourceSession, id, &s); err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageSession(s))
+	if err != nil {
+		return err
+	}
+
+	newSession := cli.fromStorageSession(updated)
+	newSession.ObjectMeta = s.ObjectMeta
+	return cli.put(resourceSession, id, newSession)
+}
+
+func (cli *client) ListLogoutNotifications() ([]storage.LogoutNotification, error) {
+	var list LogoutNotificationList
+	if err := cli.list(resourceLogoutNotification, &list); err != nil {
+		return nil, fmt.Errorf("failed to list logout notifications: %v", err)
+	}
+	notifications := make([]storage.LogoutNotification, len(list.LogoutNotifications))
+	for i, n := range list.LogoutNotifications {
+		notifications[i] = toStorageLogoutNotification(n)
+	}
+	return notifications, nil
+}
+
+func (cli *client) DeleteLogoutNotification(id string) error {
+	return cli.delete(resourceLogoutNotification, id)
+}
+
+func (cli *client) UpdateLogoutNotification(id string, updater func(n storage.LogoutNotification) (storage.LogoutNotification, error)) error {
+	var n LogoutNotification
+	if err := cli.get(resourceLogoutNotification, id, &n); err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageLogoutNotification(n))
+	if err != nil {
+		return err
+	}
+
+	newNotification := cli.fromStorageLogoutNotification(updated)
+	newNotification.ObjectMeta = n.ObjectMeta
+	return cli.put(resourceLogoutNotification, id, newNotification)
+}
+
+func (cli *client) GetAccessToken(id string) (storage.AccessToken, error) {
+	var t AccessToken
+	if err := cli.get(resourceAccessToken, id, &t); err != nil {
+		return storage.AccessToken{}, err
+	}
+	return toStorageAccessToken(t), nil
+}
+
 func (cli *client) ListClients() ([]storage.Client, error) {
 	return nil, errors.New("not implemented")
 }
@@ -356,6 +527,15 @@ func (cli *client) DeletePassword(email string) error {
 	return cli.delete(resourcePassword, p.ObjectMeta.Name)
 }
 
+func (cli *client) DeleteConsents(userID string, connID string) error {
+	// Check for hash collition.
+	c, err := cli.getConsents(userID, connID)
+	if err != nil {
+		return err
+	}
+	return cli.delete(resourceConsents, c.ObjectMeta.Name)
+}
+
 func (cli *client) DeleteOfflineSessions(userID string, connID string) error {
 	// Check for hash collition.
 	o, err := cli.getOfflineSessions(userID, connID)
@@ -369,6 +549,10 @@ func (cli *client) DeleteConnector(id string) error {
 	return cli.delete(resourceConnector, id)
 }
 
+func (cli *client) DeleteAccessToken(id string) error {
+	return cli.delete(resourceAccessToken, id)
+}
+
 func (cli *client) UpdateRefreshToken(id string, updater func(old storage.RefreshToken) (storage.RefreshToken, error)) error {
 	r, err := cli.getRefreshToken(id)
 	if err != nil {
@@ -419,6 +603,22 @@ func (cli *client) UpdatePassword(email string, updater func(old storage.Passwor
 	return cli.put(resourcePassword, p.ObjectMeta.Name, newPassword)
 }
 
+func (cli *client) UpdateConsents(userID string, connID string, updater func(old storage.Consents) (storage.Consents, error)) error {
+	c, err := cli.getConsents(userID, connID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageConsents(c))
+	if err != nil {
+		return err
+	}
+
+	newConsents := cli.fromStorageConsents(updated)
+	newConsents.ObjectMeta = c.ObjectMeta
+	return cli.put(resourceConsents, c.ObjectMeta.Name, newConsents)
+}
+
 func (cli *client) UpdateOfflineSessions(userID string, connID string, updater func(old storage.OfflineSessions) (storage.OfflineSessions, error)) error {
 	o, err := cli.getOfflineSessions(userID, connID)
 	if err != nil {
@@ -529,5 +729,178 @@ func (cli *client) GarbageCollect(now time.Time) (result storage.GCResult, err e
 			result.AuthCodes++
 		}
 	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var accessTokens AccessTokenList
+	if err := cli.list(resourceAccessToken, &accessTokens); err != nil {
+		return result, fmt.Errorf("failed to list access tokens: %v", err)
+	}
+
+	for _, accessToken := range accessTokens.AccessTokens {
+		if now.After(accessToken.Expiry) {
+			if err := cli.delete(resourceAccessToken, accessToken.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete access token %v", err)
+				delErr = fmt.Errorf("failed to delete access token: %v", err)
+			}
+			result.AccessTokens++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var deviceRequests DeviceRequestList
+	if err := cli.list(resourceDeviceRequest, &deviceRequests); err != nil {
+		return result, fmt.Errorf("failed to list device requests: %v", err)
+	}
+
+	for _, deviceRequest := range deviceRequests.DeviceRequests {
+		if now.After(deviceRequest.Expiry) {
+			if err := cli.delete(resourceDeviceRequest, deviceRequest.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete device request %v", err)
+				delErr = fmt.Errorf("failed to delete device request: %v", err)
+			}
+			result.DeviceRequests++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var assertions ClientAssertionList
+	if err := cli.list(resourceClientAssertion, &assertions); err != nil {
+		return result, fmt.Errorf("failed to list client assertions: %v", err)
+	}
+
+	for _, assertion := range assertions.ClientAssertions {
+		if now.After(assertion.Expiry) {
+			if err := cli.delete(resourceClientAssertion, assertion.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete client assertion %v", err)
+				delErr = fmt.Errorf("failed to delete client assertion: %v", err)
+			}
+			result.ClientAssertions++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var sessions SessionList
+	if err := cli.list(resourceSession, &sessions); err != nil {
+		return result, fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	for _, session := range sessions.Sessions {
+		if now.After(session.Expiry) {
+			if err := cli.delete(resourceSession, session.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete session %v", err)
+				delErr = fmt.Errorf("failed to delete session: %v", err)
+			}
+			result.Sessions++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var notifications LogoutNotificationList
+	if err := cli.list(resourceLogoutNotification, &notifications); err != nil {
+		return result, fmt.Errorf("failed to list logout notifications: %v", err)
+	}
+
+	for _, n := range notifications.LogoutNotifications {
+		if now.After(n.Expiry) {
+			if err := cli.delete(resourceLogoutNotification, n.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete logout notification %v", err)
+				delErr = fmt.Errorf("failed to delete logout notification: %v", err)
+			}
+			result.LogoutNotifications++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var pushedAuthRequests PushedAuthRequestList
+	if err := cli.list(resourcePushedAuthRequest, &pushedAuthRequests); err != nil {
+		return result, fmt.Errorf("failed to list pushed auth requests: %v", err)
+	}
+
+	for _, p := range pushedAuthRequests.PushedAuthRequests {
+		if now.After(p.Expiry) {
+			if err := cli.delete(resourcePushedAuthRequest, p.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete pushed auth request %v", err)
+				delErr = fmt.Errorf("failed to delete pushed auth request: %v", err)
+			}
+			result.PushedAuthRequests++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var refreshTokens RefreshList
+	if err := cli.list(resourceRefreshToken, &refreshTokens); err != nil {
+		return result, fmt.Errorf("failed to list refresh tokens: %v", err)
+	}
+
+	refreshIDs := make(map[string]bool)
+	for _, r := range refreshTokens.RefreshTokens {
+		// Refresh tokens that never expire have a zero expiry.
+		if r.Expiry.IsZero() || !now.After(r.Expiry) {
+			refreshIDs[r.ObjectMeta.Name] = true
+			continue
+		}
+		if err := cli.delete(resourceRefreshToken, r.ObjectMeta.Name); err != nil {
+			cli.logger.Errorf("failed to delete refresh token %v", err)
+			delErr = fmt.Errorf("failed to delete refresh token: %v", err)
+			refreshIDs[r.ObjectMeta.Name] = true
+			continue
+		}
+		result.RefreshTokens++
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	// Remove offline session references to refresh tokens that no longer
+	// exist, including the ones collected above.
+	var offlineSessions OfflineSessionsList
+	if err := cli.list(resourceOfflineSessions, &offlineSessions); err != nil {
+		return result, fmt.Errorf("failed to list offline sessions: %v", err)
+	}
+
+	for _, o := range offlineSessions.OfflineSessions {
+		var orphaned bool
+		for _, ref := range o.Refresh {
+			if !refreshIDs[ref.ID] {
+				orphaned = true
+			}
+		}
+		if !orphaned {
+			continue
+		}
+		// Refresh tokens may have been issued since they were listed, so
+		// check each reference again.
+		updater := func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+			for clientID, ref := range old.Refresh {
+				if refreshIDs[ref.ID] {
+					continue
+				}
+				if _, err := cli.getRefreshToken(ref.ID); err == storage.ErrNotFound {
+					delete(old.Refresh, clientID)
+				} else if err != nil {
+					return old, err
+				}
+			}
+			return old, nil
+		}
+		if err := cli.UpdateOfflineSessions(o.UserID, o.ConnID, updater); err != nil && err != storage.ErrNotFound {
+			cli.logger.Errorf("failed to update offline session %v", err)
+			delErr = fmt.Errorf("failed to update offline session: %v", err)
+		}
+	}
 	return result, delErr
 }
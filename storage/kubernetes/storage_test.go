@@ -93,6 +93,11 @@ func TestStorage(t *testing.T) {
 			resourceRefreshToken,
 			resourceKeys,
 			resourcePassword,
+			resourceAccessToken,
+			resourceDeviceRequest,
+			resourceClientAssertion,
+			resourceSession,
+			resourceLogoutNotification,
 		} {
 			if err := client.deleteAll(resource); err != nil {
 				// Fatalf sometimes doesn't print the error message.
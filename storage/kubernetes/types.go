@@ -82,6 +82,62 @@ var thirdPartyResources = []k8sapi.ThirdPartyResource{
 		Description: "Connectors available for login",
 		Versions:    []k8sapi.APIVersion{{Name: "v1"}},
 	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "access-token.oidc.coreos.com",
+		},
+		TypeMeta:    tprMeta,
+		Description: "Access tokens issued to clients.",
+		Versions:    []k8sapi.APIVersion{{Name: "v1"}},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "device-request.oidc.coreos.com",
+		},
+		TypeMeta:    tprMeta,
+		Description: "Pending device authorization requests.",
+		Versions:    []k8sapi.APIVersion{{Name: "v1"}},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "client-assertion.oidc.coreos.com",
+		},
+		TypeMeta:    tprMeta,
+		Description: "Used client assertions kept to prevent replays.",
+		Versions:    []k8sapi.APIVersion{{Name: "v1"}},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "session.oidc.coreos.com",
+		},
+		TypeMeta:    tprMeta,
+		Description: "End users' browser sessions.",
+		Versions:    []k8sapi.APIVersion{{Name: "v1"}},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "logout-notification.oidc.coreos.com",
+		},
+		TypeMeta:    tprMeta,
+		Description: "Back-channel logout notifications waiting to be delivered to clients.",
+		Versions:    []k8sapi.APIVersion{{Name: "v1"}},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "pushed-auth-request.oidc.coreos.com",
+		},
+		TypeMeta:    tprMeta,
+		Description: "Authorization requests pushed by clients ahead of redirecting end users.",
+		Versions:    []k8sapi.APIVersion{{Name: "v1"}},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "consents.oidc.coreos.com",
+		},
+		TypeMeta:    tprMeta,
+		Description: "Scopes end users have approved for clients.",
+		Versions:    []k8sapi.APIVersion{{Name: "v1"}},
+	},
 }
 
 // There will only ever be a single keys resource. Maintain this by setting a
@@ -106,6 +162,35 @@ type Client struct {
 
 	Name    string `json:"name,omitempty"`
 	LogoURL string `json:"logoURL,omitempty"`
+
+	PostLogoutRedirectURIs []string `json:"postLogoutRedirectURIs,omitempty"`
+	BackchannelLogoutURI   string   `json:"backchannelLogoutURI,omitempty"`
+
+	AllowedGrantTypes []string `json:"allowedGrantTypes,omitempty"`
+
+	AllowedConnectors    []string `json:"allowedConnectors,omitempty"`
+	AllowedScopes        []string `json:"allowedScopes,omitempty"`
+	AllowedResponseTypes []string `json:"allowedResponseTypes,omitempty"`
+
+	JWKS    *jose.JSONWebKeySet `json:"jwks,omitempty"`
+	JWKSURI string              `json:"jwksURI,omitempty"`
+
+	AccessTokenFormat string `json:"accessTokenFormat,omitempty"`
+
+	RefreshTokenIdleTimeout      string `json:"refreshTokenIdleTimeout,omitempty"`
+	RefreshTokenAbsoluteLifetime string `json:"refreshTokenAbsoluteLifetime,omitempty"`
+
+	IDTokenLifetime     string `json:"idTokenLifetime,omitempty"`
+	AccessTokenLifetime string `json:"accessTokenLifetime,omitempty"`
+	AuthCodeLifetime    string `json:"authCodeLifetime,omitempty"`
+
+	RequirePushedAuthRequests  bool `json:"requirePushedAuthRequests,omitempty"`
+	RequireSignedRequestObject bool `json:"requireSignedRequestObject,omitempty"`
+
+	SubjectType         string `json:"subjectType,omitempty"`
+	SectorIdentifierURI string `json:"sectorIdentifierURI,omitempty"`
+
+	RegistrationTokenHash string `json:"registrationTokenHash,omitempty"`
 }
 
 // ClientList is a list of Clients.
@@ -132,6 +217,35 @@ func (cli *client) fromStorageClient(c storage.Client) Client {
 		Public:       c.Public,
 		Name:         c.Name,
 		LogoURL:      c.LogoURL,
+
+		PostLogoutRedirectURIs: c.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:   c.BackchannelLogoutURI,
+
+		AllowedGrantTypes: c.AllowedGrantTypes,
+
+		AllowedConnectors:    c.AllowedConnectors,
+		AllowedScopes:        c.AllowedScopes,
+		AllowedResponseTypes: c.AllowedResponseTypes,
+
+		JWKS:    c.JWKS,
+		JWKSURI: c.JWKSURI,
+
+		AccessTokenFormat: c.AccessTokenFormat,
+
+		RefreshTokenIdleTimeout:      c.RefreshTokenIdleTimeout,
+		RefreshTokenAbsoluteLifetime: c.RefreshTokenAbsoluteLifetime,
+
+		IDTokenLifetime:     c.IDTokenLifetime,
+		AccessTokenLifetime: c.AccessTokenLifetime,
+		AuthCodeLifetime:    c.AuthCodeLifetime,
+
+		RequirePushedAuthRequests:  c.RequirePushedAuthRequests,
+		RequireSignedRequestObject: c.RequireSignedRequestObject,
+
+		SubjectType:         c.SubjectType,
+		SectorIdentifierURI: c.SectorIdentifierURI,
+
+		RegistrationTokenHash: c.RegistrationTokenHash,
 	}
 }
 
@@ -144,6 +258,35 @@ func toStorageClient(c Client) storage.Client {
 		Public:       c.Public,
 		Name:         c.Name,
 		LogoURL:      c.LogoURL,
+
+		PostLogoutRedirectURIs: c.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:   c.BackchannelLogoutURI,
+
+		AllowedGrantTypes: c.AllowedGrantTypes,
+
+		AllowedConnectors:    c.AllowedConnectors,
+		AllowedScopes:        c.AllowedScopes,
+		AllowedResponseTypes: c.AllowedResponseTypes,
+
+		JWKS:    c.JWKS,
+		JWKSURI: c.JWKSURI,
+
+		AccessTokenFormat: c.AccessTokenFormat,
+
+		RefreshTokenIdleTimeout:      c.RefreshTokenIdleTimeout,
+		RefreshTokenAbsoluteLifetime: c.RefreshTokenAbsoluteLifetime,
+
+		IDTokenLifetime:     c.IDTokenLifetime,
+		AccessTokenLifetime: c.AccessTokenLifetime,
+		AuthCodeLifetime:    c.AuthCodeLifetime,
+
+		RequirePushedAuthRequests:  c.RequirePushedAuthRequests,
+		RequireSignedRequestObject: c.RequireSignedRequestObject,
+
+		SubjectType:         c.SubjectType,
+		SectorIdentifierURI: c.SectorIdentifierURI,
+
+		RegistrationTokenHash: c.RegistrationTokenHash,
 	}
 }
 
@@ -186,6 +329,7 @@ type AuthRequest struct {
 	ResponseTypes []string `json:"responseTypes,omitempty"`
 	Scopes        []string `json:"scopes,omitempty"`
 	RedirectURI   string   `json:"redirectURI"`
+	ResponseMode  string   `json:"responseMode,omitempty"`
 
 	Nonce string `json:"nonce,omitempty"`
 	State string `json:"state,omitempty"`
@@ -204,6 +348,14 @@ type AuthRequest struct {
 	ConnectorID   string `json:"connectorID,omitempty"`
 	ConnectorData []byte `json:"connectorData,omitempty"`
 
+	CodeChallenge       string `json:"codeChallenge,omitempty"`
+	CodeChallengeMethod string `json:"codeChallengeMethod,omitempty"`
+
+	LoginHint string    `json:"loginHint,omitempty"`
+	AuthTime  time.Time `json:"authTime,omitempty"`
+
+	Resources []string `json:"resources,omitempty"`
+
 	Expiry time.Time `json:"expiry"`
 }
 
@@ -219,6 +371,7 @@ func toStorageAuthRequest(req AuthRequest) storage.AuthRequest {
 		ID:                  req.ObjectMeta.Name,
 		ClientID:            req.ClientID,
 		ResponseTypes:       req.ResponseTypes,
+		ResponseMode:        req.ResponseMode,
 		Scopes:              req.Scopes,
 		RedirectURI:         req.RedirectURI,
 		Nonce:               req.Nonce,
@@ -229,6 +382,13 @@ func toStorageAuthRequest(req AuthRequest) storage.AuthRequest {
 		ConnectorData:       req.ConnectorData,
 		Expiry:              req.Expiry,
 		Claims:              toStorageClaims(req.Claims),
+		PKCE: storage.PKCE{
+			CodeChallenge:       req.CodeChallenge,
+			CodeChallengeMethod: req.CodeChallengeMethod,
+		},
+		LoginHint: req.LoginHint,
+		AuthTime:  req.AuthTime,
+		Resources: req.Resources,
 	}
 	return a
 }
@@ -245,6 +405,7 @@ func (cli *client) fromStorageAuthRequest(a storage.AuthRequest) AuthRequest {
 		},
 		ClientID:            a.ClientID,
 		ResponseTypes:       a.ResponseTypes,
+		ResponseMode:        a.ResponseMode,
 		Scopes:              a.Scopes,
 		RedirectURI:         a.RedirectURI,
 		Nonce:               a.Nonce,
@@ -255,6 +416,11 @@ func (cli *client) fromStorageAuthRequest(a storage.AuthRequest) AuthRequest {
 		ConnectorData:       a.ConnectorData,
 		Expiry:              a.Expiry,
 		Claims:              fromStorageClaims(a.Claims),
+		CodeChallenge:       a.PKCE.CodeChallenge,
+		CodeChallengeMethod: a.PKCE.CodeChallengeMethod,
+		LoginHint:           a.LoginHint,
+		AuthTime:            a.AuthTime,
+		Resources:           a.Resources,
 	}
 	return req
 }
@@ -327,6 +493,13 @@ type AuthCode struct {
 	ConnectorID   string `json:"connectorID,omitempty"`
 	ConnectorData []byte `json:"connectorData,omitempty"`
 
+	CodeChallenge       string `json:"codeChallenge,omitempty"`
+	CodeChallengeMethod string `json:"codeChallengeMethod,omitempty"`
+
+	AuthTime time.Time `json:"authTime,omitempty"`
+
+	Resources []string `json:"resources,omitempty"`
+
 	Expiry time.Time `json:"expiry"`
 }
 
@@ -354,7 +527,12 @@ func (cli *client) fromStorageAuthCode(a storage.AuthCode) AuthCode {
 		Nonce:         a.Nonce,
 		Scopes:        a.Scopes,
 		Claims:        fromStorageClaims(a.Claims),
+		AuthTime:      a.AuthTime,
+		Resources:     a.Resources,
 		Expiry:        a.Expiry,
+
+		CodeChallenge:       a.PKCE.CodeChallenge,
+		CodeChallengeMethod: a.PKCE.CodeChallengeMethod,
 	}
 }
 
@@ -368,7 +546,13 @@ func toStorageAuthCode(a AuthCode) storage.AuthCode {
 		Nonce:         a.Nonce,
 		Scopes:        a.Scopes,
 		Claims:        toStorageClaims(a.Claims),
+		AuthTime:      a.AuthTime,
+		Resources:     a.Resources,
 		Expiry:        a.Expiry,
+		PKCE: storage.PKCE{
+			CodeChallenge:       a.CodeChallenge,
+			CodeChallengeMethod: a.CodeChallengeMethod,
+		},
 	}
 }
 
@@ -380,17 +564,23 @@ type RefreshToken struct {
 
 	CreatedAt time.Time
 	LastUsed  time.Time
+	Expiry    time.Time `json:"expiry,omitempty"`
 
 	ClientID string   `json:"clientID"`
 	Scopes   []string `json:"scopes,omitempty"`
 
-	Token string `json:"token,omitempty"`
+	Token         string `json:"token,omitempty"`
+	ObsoleteToken string `json:"obsoleteToken,omitempty"`
 
 	Nonce string `json:"nonce,omitempty"`
 
 	Claims        Claims `json:"claims,omitempty"`
 	ConnectorID   string `json:"connectorID,omitempty"`
 	ConnectorData []byte `json:"connectorData,omitempty"`
+
+	AuthTime time.Time `json:"authTime,omitempty"`
+
+	Resources []string `json:"resources,omitempty"`
 }
 
 // RefreshList is a list of refresh tokens.
@@ -404,14 +594,18 @@ func toStorageRefreshToken(r RefreshToken) storage.RefreshToken {
 	return storage.RefreshToken{
 		ID:            r.ObjectMeta.Name,
 		Token:         r.Token,
+		ObsoleteToken: r.ObsoleteToken,
 		CreatedAt:     r.CreatedAt,
 		LastUsed:      r.LastUsed,
+		Expiry:        r.Expiry,
 		ClientID:      r.ClientID,
 		ConnectorID:   r.ConnectorID,
 		ConnectorData: r.ConnectorData,
 		Scopes:        r.Scopes,
 		Nonce:         r.Nonce,
 		Claims:        toStorageClaims(r.Claims),
+		AuthTime:      r.AuthTime,
+		Resources:     r.Resources,
 	}
 }
 
@@ -426,14 +620,18 @@ func (cli *client) fromStorageRefreshToken(r storage.RefreshToken) RefreshToken
 			Namespace: cli.namespace,
 		},
 		Token:         r.Token,
+		ObsoleteToken: r.ObsoleteToken,
 		CreatedAt:     r.CreatedAt,
 		LastUsed:      r.LastUsed,
+		Expiry:        r.Expiry,
 		ClientID:      r.ClientID,
 		ConnectorID:   r.ConnectorID,
 		ConnectorData: r.ConnectorData,
 		Scopes:        r.Scopes,
 		Nonce:         r.Nonce,
 		Claims:        fromStorageClaims(r.Claims),
+		AuthTime:      r.AuthTime,
+		Resources:     r.Resources,
 	}
 }
 
@@ -449,6 +647,9 @@ type Keys struct {
 	// Old signing keys which have been rotated but can still be used to validate
 	// existing signatures.
 	VerificationKeys []storage.VerificationKey `json:"verificationKeys,omitempty"`
+	// Key the signing key will be replaced with at the next rotation.
+	NextSigningKey    *jose.JSONWebKey `json:"nextSigningKey,omitempty"`
+	NextSigningKeyPub *jose.JSONWebKey `json:"nextSigningKeyPub,omitempty"`
 
 	// The next time the signing key will rotate.
 	//
@@ -466,19 +667,23 @@ func (cli *client) fromStorageKeys(keys storage.Keys) Keys {
 			Name:      keysName,
 			Namespace: cli.namespace,
 		},
-		SigningKey:       keys.SigningKey,
-		SigningKeyPub:    keys.SigningKeyPub,
-		VerificationKeys: keys.VerificationKeys,
-		NextRotation:     keys.NextRotation,
+		SigningKey:        keys.SigningKey,
+		SigningKeyPub:     keys.SigningKeyPub,
+		VerificationKeys:  keys.VerificationKeys,
+		NextSigningKey:    keys.NextSigningKey,
+		NextSigningKeyPub: keys.NextSigningKeyPub,
+		NextRotation:      keys.NextRotation,
 	}
 }
 
 func toStorageKeys(keys Keys) storage.Keys {
 	return storage.Keys{
-		SigningKey:       keys.SigningKey,
-		SigningKeyPub:    keys.SigningKeyPub,
-		VerificationKeys: keys.VerificationKeys,
-		NextRotation:     keys.NextRotation,
+		SigningKey:        keys.SigningKey,
+		SigningKeyPub:     keys.SigningKeyPub,
+		VerificationKeys:  keys.VerificationKeys,
+		NextSigningKey:    keys.NextSigningKey,
+		NextSigningKeyPub: keys.NextSigningKeyPub,
+		NextRotation:      keys.NextRotation,
 	}
 }
 
@@ -493,6 +698,13 @@ type OfflineSessions struct {
 	Refresh map[string]*storage.RefreshTokenRef `json:"refresh,omitempty"`
 }
 
+// OfflineSessionsList is a list of OfflineSessions.
+type OfflineSessionsList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	OfflineSessions []OfflineSessions `json:"items"`
+}
+
 func (cli *client) fromStorageOfflineSessions(o storage.OfflineSessions) OfflineSessions {
 	return OfflineSessions{
 		TypeMeta: k8sapi.TypeMeta{
@@ -522,6 +734,46 @@ func toStorageOfflineSessions(o OfflineSessions) storage.OfflineSessions {
 	return s
 }
 
+// Consents is a mirrored struct from storage with JSON struct tags and Kubernetes
+// type metadata.
+type Consents struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	UserID  string                      `json:"userID,omitempty"`
+	ConnID  string                      `json:"connID,omitempty"`
+	Clients map[string]*storage.Consent `json:"clients,omitempty"`
+}
+
+func (cli *client) fromStorageConsents(c storage.Consents) Consents {
+	return Consents{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindConsents,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      cli.offlineTokenName(c.UserID, c.ConnID),
+			Namespace: cli.namespace,
+		},
+		UserID:  c.UserID,
+		ConnID:  c.ConnID,
+		Clients: c.Clients,
+	}
+}
+
+func toStorageConsents(c Consents) storage.Consents {
+	s := storage.Consents{
+		UserID:  c.UserID,
+		ConnID:  c.ConnID,
+		Clients: c.Clients,
+	}
+	if s.Clients == nil {
+		// Server code assumes this will be non-nil.
+		s.Clients = make(map[string]*storage.Consent)
+	}
+	return s
+}
+
 // Connector is a mirrored struct from storage with JSON struct tags and Kubernetes
 // type metadata.
 type Connector struct {
@@ -570,3 +822,307 @@ type ConnectorList struct {
 	k8sapi.ListMeta `json:"metadata,omitempty"`
 	Connectors      []Connector `json:"items"`
 }
+
+// AccessToken is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type AccessToken struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ClientID string   `json:"clientID"`
+	Scopes   []string `json:"scopes,omitempty"`
+
+	Claims Claims `json:"claims,omitempty"`
+
+	ConnectorID string `json:"connectorID,omitempty"`
+
+	Resources []string `json:"resources,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+// AccessTokenList is a list of AccessTokens.
+type AccessTokenList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	AccessTokens    []AccessToken `json:"items"`
+}
+
+func (cli *client) fromStorageAccessToken(t storage.AccessToken) AccessToken {
+	return AccessToken{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindAccessToken,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      t.ID,
+			Namespace: cli.namespace,
+		},
+		ClientID:    t.ClientID,
+		Scopes:      t.Scopes,
+		Claims:      fromStorageClaims(t.Claims),
+		ConnectorID: t.ConnectorID,
+		Resources:   t.Resources,
+		CreatedAt:   t.CreatedAt,
+		Expiry:      t.Expiry,
+	}
+}
+
+func toStorageAccessToken(t AccessToken) storage.AccessToken {
+	return storage.AccessToken{
+		ID:          t.ObjectMeta.Name,
+		ClientID:    t.ClientID,
+		Scopes:      t.Scopes,
+		Claims:      toStorageClaims(t.Claims),
+		ConnectorID: t.ConnectorID,
+		Resources:   t.Resources,
+		CreatedAt:   t.CreatedAt,
+		Expiry:      t.Expiry,
+	}
+}
+
+// DeviceRequest is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type DeviceRequest struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	DeviceCode string   `json:"deviceCode"`
+	ClientID   string   `json:"clientID"`
+	Scopes     []string `json:"scopes,omitempty"`
+
+	AuthCode string `json:"authCode,omitempty"`
+	Denied   bool   `json:"denied,omitempty"`
+
+	Interval int       `json:"interval"`
+	LastPoll time.Time `json:"lastPoll"`
+
+	Expiry time.Time `json:"expiry"`
+}
+
+// DeviceRequestList is a list of DeviceRequests.
+type DeviceRequestList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	DeviceRequests  []DeviceRequest `json:"items"`
+}
+
+func (cli *client) fromStorageDeviceRequest(d storage.DeviceRequest) DeviceRequest {
+	return DeviceRequest{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindDeviceRequest,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      d.UserCode,
+			Namespace: cli.namespace,
+		},
+		DeviceCode: d.DeviceCode,
+		ClientID:   d.ClientID,
+		Scopes:     d.Scopes,
+		AuthCode:   d.AuthCode,
+		Denied:     d.Denied,
+		Interval:   d.Interval,
+		LastPoll:   d.LastPoll,
+		Expiry:     d.Expiry,
+	}
+}
+
+func toStorageDeviceRequest(d DeviceRequest) storage.DeviceRequest {
+	return storage.DeviceRequest{
+		UserCode:   d.ObjectMeta.Name,
+		DeviceCode: d.DeviceCode,
+		ClientID:   d.ClientID,
+		Scopes:     d.Scopes,
+		AuthCode:   d.AuthCode,
+		Denied:     d.Denied,
+		Interval:   d.Interval,
+		LastPoll:   d.LastPoll,
+		Expiry:     d.Expiry,
+	}
+}
+
+// ClientAssertion is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type ClientAssertion struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ClientID string    `json:"clientID"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+// ClientAssertionList is a list of ClientAssertions.
+type ClientAssertionList struct {
+	k8sapi.TypeMeta  `json:",inline"`
+	k8sapi.ListMeta  `json:"metadata,omitempty"`
+	ClientAssertions []ClientAssertion `json:"items"`
+}
+
+func (cli *client) fromStorageClientAssertion(a storage.ClientAssertion) ClientAssertion {
+	return ClientAssertion{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindClientAssertion,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      a.ID,
+			Namespace: cli.namespace,
+		},
+		ClientID: a.ClientID,
+		Expiry:   a.Expiry,
+	}
+}
+
+// Session is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type Session struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ConnectorID   string `json:"connectorID"`
+	ConnectorData []byte `json:"connectorData,omitempty"`
+	Claims        Claims `json:"claims"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	LastUsed  time.Time `json:"lastUsed"`
+	Expiry    time.Time `json:"expiry"`
+
+	ClientIDs []string `json:"clientIDs,omitempty"`
+}
+
+// SessionList is a list of Sessions.
+type SessionList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	Sessions        []Session `json:"items"`
+}
+
+func (cli *client) fromStorageSession(s storage.Session) Session {
+	return Session{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindSession,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      s.ID,
+			Namespace: cli.namespace,
+		},
+		ConnectorID:   s.ConnectorID,
+		ConnectorData: s.ConnectorData,
+		Claims:        fromStorageClaims(s.Claims),
+		CreatedAt:     s.CreatedAt,
+		LastUsed:      s.LastUsed,
+		Expiry:        s.Expiry,
+		ClientIDs:     s.ClientIDs,
+	}
+}
+
+func toStorageSession(s Session) storage.Session {
+	return storage.Session{
+		ID:            s.ObjectMeta.Name,
+		ConnectorID:   s.ConnectorID,
+		ConnectorData: s.ConnectorData,
+		Claims:        toStorageClaims(s.Claims),
+		CreatedAt:     s.CreatedAt,
+		LastUsed:      s.LastUsed,
+		Expiry:        s.Expiry,
+		ClientIDs:     s.ClientIDs,
+	}
+}
+
+// LogoutNotification is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type LogoutNotification struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ClientID string `json:"clientID"`
+	Subject  string `json:"subject"`
+
+	Attempts    int       `json:"attempts,omitempty"`
+	NextAttempt time.Time `json:"nextAttempt"`
+
+	Expiry time.Time `json:"expiry"`
+}
+
+// LogoutNotificationList is a list of LogoutNotifications.
+type LogoutNotificationList struct {
+	k8sapi.TypeMeta     `json:",inline"`
+	k8sapi.ListMeta     `json:"metadata,omitempty"`
+	LogoutNotifications []LogoutNotification `json:"items"`
+}
+
+func (cli *client) fromStorageLogoutNotification(n storage.LogoutNotification) LogoutNotification {
+	return LogoutNotification{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindLogoutNotification,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      n.ID,
+			Namespace: cli.namespace,
+		},
+		ClientID:    n.ClientID,
+		Subject:     n.Subject,
+		Attempts:    n.Attempts,
+		NextAttempt: n.NextAttempt,
+		Expiry:      n.Expiry,
+	}
+}
+
+func toStorageLogoutNotification(n LogoutNotification) storage.LogoutNotification {
+	return storage.LogoutNotification{
+		ID:          n.ObjectMeta.Name,
+		ClientID:    n.ClientID,
+		Subject:     n.Subject,
+		Attempts:    n.Attempts,
+		NextAttempt: n.NextAttempt,
+		Expiry:      n.Expiry,
+	}
+}
+
+// PushedAuthRequest is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type PushedAuthRequest struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ClientID string              `json:"clientID"`
+	Params   map[string][]string `json:"params,omitempty"`
+
+	Expiry time.Time `json:"expiry"`
+}
+
+// PushedAuthRequestList is a list of PushedAuthRequests.
+type PushedAuthRequestList struct {
+	k8sapi.TypeMeta    `json:",inline"`
+	k8sapi.ListMeta    `json:"metadata,omitempty"`
+	PushedAuthRequests []PushedAuthRequest `json:"items"`
+}
+
+func (cli *client) fromStoragePushedAuthRequest(p storage.PushedAuthRequest) PushedAuthRequest {
+	return PushedAuthRequest{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindPushedAuthRequest,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      p.ID,
+			Namespace: cli.namespace,
+		},
+		ClientID: p.ClientID,
+		Params:   p.Params,
+		Expiry:   p.Expiry,
+	}
+}
+
+func toStoragePushedAuthRequest(p PushedAuthRequest) storage.PushedAuthRequest {
+	return storage.PushedAuthRequest{
+		ID:       p.ObjectMeta.Name,
+		ClientID: p.ClientID,
+		Params:   p.Params,
+		Expiry:   p.Expiry,
+	}
+}
@@ -20,6 +20,13 @@ func New(logger logrus.FieldLogger) storage.Storage {
 		passwords:       make(map[string]storage.Password),
 		offlineSessions: make(map[offlineSessionID]storage.OfflineSessions),
 		connectors:      make(map[string]storage.Connector),
+		accessTokens:    make(map[string]storage.AccessToken),
+		deviceRequests:  make(map[string]storage.DeviceRequest),
+		assertions:      make(map[string]storage.ClientAssertion),
+		sessions:        make(map[string]storage.Session),
+		notifications:   make(map[string]storage.LogoutNotification),
+		pushedAuthReqs:  make(map[string]storage.PushedAuthRequest),
+		consents:        make(map[offlineSessionID]storage.Consents),
 		logger:          logger,
 	}
 }
@@ -46,6 +53,13 @@ type memStorage struct {
 	passwords       map[string]storage.Password
 	offlineSessions map[offlineSessionID]storage.OfflineSessions
 	connectors      map[string]storage.Connector
+	accessTokens    map[string]storage.AccessToken
+	deviceRequests  map[string]storage.DeviceRequest
+	assertions      map[string]storage.ClientAssertion
+	sessions        map[string]storage.Session
+	notifications   map[string]storage.LogoutNotification
+	pushedAuthReqs  map[string]storage.PushedAuthRequest
+	consents        map[offlineSessionID]storage.Consents
 
 	keys storage.Keys
 
@@ -79,6 +93,59 @@ func (s *memStorage) GarbageCollect(now time.Time) (result storage.GCResult, err
 				result.AuthRequests++
 			}
 		}
+		for id, a := range s.accessTokens {
+			if now.After(a.Expiry) {
+				delete(s.accessTokens, id)
+				result.AccessTokens++
+			}
+		}
+		for id, d := range s.deviceRequests {
+			if now.After(d.Expiry) {
+				delete(s.deviceRequests, id)
+				result.DeviceRequests++
+			}
+		}
+		for id, a := range s.assertions {
+			if now.After(a.Expiry) {
+				delete(s.assertions, id)
+				result.ClientAssertions++
+			}
+		}
+		for id, session := range s.sessions {
+			if now.After(session.Expiry) {
+				delete(s.sessions, id)
+				result.Sessions++
+			}
+		}
+		for id, n := range s.notifications {
+			if now.After(n.Expiry) {
+				delete(s.notifications, id)
+				result.LogoutNotifications++
+			}
+		}
+		for id, p := range s.pushedAuthReqs {
+			if now.After(p.Expiry) {
+				delete(s.pushedAuthReqs, id)
+				result.PushedAuthRequests++
+			}
+		}
+		for id, r := range s.refreshTokens {
+			// Refresh tokens that never expire have a zero expiry.
+			if r.Expiry.IsZero() || !now.After(r.Expiry) {
+				continue
+			}
+			delete(s.refreshTokens, id)
+			result.RefreshTokens++
+		}
+		// Remove offline session references to refresh tokens that no longer
+		// exist, including the ones collected above.
+		for _, o := range s.offlineSessions {
+			for clientID, ref := range o.Refresh {
+				if _, ok := s.refreshTokens[ref.ID]; !ok {
+					delete(o.Refresh, clientID)
+				}
+			}
+		}
 	})
 	return result, nil
 }
@@ -105,6 +172,72 @@ func (s *memStorage) CreateAuthCode(c storage.AuthCode) (err error) {
 	return
 }
 
+func (s *memStorage) CreateAccessToken(t storage.AccessToken) (err error) {
+	s.tx(func() {
+		if _, ok := s.accessTokens[t.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.accessTokens[t.ID] = t
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateDeviceRequest(d storage.DeviceRequest) (err error) {
+	s.tx(func() {
+		if _, ok := s.deviceRequests[d.UserCode]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.deviceRequests[d.UserCode] = d
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateClientAssertion(a storage.ClientAssertion) (err error) {
+	s.tx(func() {
+		if _, ok := s.assertions[a.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.assertions[a.ID] = a
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateSession(session storage.Session) (err error) {
+	s.tx(func() {
+		if _, ok := s.sessions[session.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.sessions[session.ID] = session
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateLogoutNotification(n storage.LogoutNotification) (err error) {
+	s.tx(func() {
+		if _, ok := s.notifications[n.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.notifications[n.ID] = n
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreatePushedAuthRequest(p storage.PushedAuthRequest) (err error) {
+	s.tx(func() {
+		if _, ok := s.pushedAuthReqs[p.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.pushedAuthReqs[p.ID] = p
+		}
+	})
+	return
+}
+
 func (s *memStorage) CreateRefresh(r storage.RefreshToken) (err error) {
 	s.tx(func() {
 		if _, ok := s.refreshTokens[r.ID]; ok {
@@ -154,6 +287,21 @@ func (s *memStorage) CreateOfflineSessions(o storage.OfflineSessions) (err error
 	return
 }
 
+func (s *memStorage) CreateConsents(c storage.Consents) (err error) {
+	id := offlineSessionID{
+		userID: c.UserID,
+		connID: c.ConnID,
+	}
+	s.tx(func() {
+		if _, ok := s.consents[id]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.consents[id] = c
+		}
+	})
+	return
+}
+
 func (s *memStorage) CreateConnector(connector storage.Connector) (err error) {
 	s.tx(func() {
 		if _, ok := s.connectors[connector.ID]; ok {
@@ -176,6 +324,50 @@ func (s *memStorage) GetAuthCode(id string) (c storage.AuthCode, err error) {
 	return
 }
 
+func (s *memStorage) GetAccessToken(id string) (t storage.AccessToken, err error) {
+	s.tx(func() {
+		var ok bool
+		if t, ok = s.accessTokens[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetDeviceRequest(userCode string) (d storage.DeviceRequest, err error) {
+	s.tx(func() {
+		var ok bool
+		if d, ok = s.deviceRequests[userCode]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetSession(id string) (session storage.Session, err error) {
+	s.tx(func() {
+		var ok bool
+		if session, ok = s.sessions[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetPushedAuthRequest(id string) (p storage.PushedAuthRequest, err error) {
+	s.tx(func() {
+		var ok bool
+		if p, ok = s.pushedAuthReqs[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
+
 func (s *memStorage) GetPassword(email string) (p storage.Password, err error) {
 	email = strings.ToLower(email)
 	s.tx(func() {
@@ -239,6 +431,21 @@ func (s *memStorage) GetOfflineSessions(userID string, connID string) (o storage
 	return
 }
 
+func (s *memStorage) GetConsents(userID string, connID string) (c storage.Consents, err error) {
+	id := offlineSessionID{
+		userID: userID,
+		connID: connID,
+	}
+	s.tx(func() {
+		var ok bool
+		if c, ok = s.consents[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
+
 func (s *memStorage) GetConnector(id string) (connector storage.Connector, err error) {
 	s.tx(func() {
 		var ok bool
@@ -276,6 +483,15 @@ func (s *memStorage) ListPasswords() (passwords []storage.Password, err error) {
 	return
 }
 
+func (s *memStorage) ListLogoutNotifications() (notifications []storage.LogoutNotification, err error) {
+	s.tx(func() {
+		for _, n := range s.notifications {
+			notifications = append(notifications, n)
+		}
+	})
+	return
+}
+
 func (s *memStorage) ListConnectors() (conns []storage.Connector, err error) {
 	s.tx(func() {
 		for _, c := range s.connectors {
@@ -330,6 +546,61 @@ func (s *memStorage) DeleteAuthCode(id string) (err error) {
 	return
 }
 
+func (s *memStorage) DeleteAccessToken(id string) (err error) {
+	s.tx(func() {
+		if _, ok := s.accessTokens[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.accessTokens, id)
+	})
+	return
+}
+
+func (s *memStorage) DeleteDeviceRequest(userCode string) (err error) {
+	s.tx(func() {
+		if _, ok := s.deviceRequests[userCode]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.deviceRequests, userCode)
+	})
+	return
+}
+
+func (s *memStorage) DeleteSession(id string) (err error) {
+	s.tx(func() {
+		if _, ok := s.sessions[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.sessions, id)
+	})
+	return
+}
+
+func (s *memStorage) DeleteLogoutNotification(id string) (err error) {
+	s.tx(func() {
+		if _, ok := s.notifications[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.notifications, id)
+	})
+	return
+}
+
+func (s *memStorage) DeletePushedAuthRequest(id string) (err error) {
+	s.tx(func() {
+		if _, ok := s.pushedAuthReqs[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.pushedAuthReqs, id)
+	})
+	return
+}
+
 func (s *memStorage) DeleteAuthRequest(id string) (err error) {
 	s.tx(func() {
 		if _, ok := s.authReqs[id]; !ok {
@@ -341,6 +612,21 @@ func (s *memStorage) DeleteAuthRequest(id string) (err error) {
 	return
 }
 
+func (s *memStorage) DeleteConsents(userID string, connID string) (err error) {
+	id := offlineSessionID{
+		userID: userID,
+		connID: connID,
+	}
+	s.tx(func() {
+		if _, ok := s.consents[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.consents, id)
+	})
+	return
+}
+
 func (s *memStorage) DeleteOfflineSessions(userID string, connID string) (err error) {
 	id := offlineSessionID{
 		userID: userID,
@@ -452,6 +738,24 @@ func (s *memStorage) UpdateOfflineSessions(userID string, connID string, updater
 	return
 }
 
+func (s *memStorage) UpdateConsents(userID string, connID string, updater func(c storage.Consents) (storage.Consents, error)) (err error) {
+	id := offlineSessionID{
+		userID: userID,
+		connID: connID,
+	}
+	s.tx(func() {
+		r, ok := s.consents[id]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if r, err = updater(r); err == nil {
+			s.consents[id] = r
+		}
+	})
+	return
+}
+
 func (s *memStorage) UpdateConnector(id string, updater func(c storage.Connector) (storage.Connector, error)) (err error) {
 	s.tx(func() {
 		r, ok := s.connectors[id]
@@ -465,3 +769,45 @@ func (s *memStorage) UpdateConnector(id string, updater func(c storage.Connector
 	})
 	return
 }
+
+func (s *memStorage) UpdateDeviceRequest(userCode string, updater func(d storage.DeviceRequest) (storage.DeviceRequest, error)) (err error) {
+	s.tx(func() {
+		d, ok := s.deviceRequests[userCode]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if d, err = updater(d); err == nil {
+			s.deviceRequests[userCode] = d
+		}
+	})
+	return
+}
+
+func (s *memStorage) UpdateSession(id string, updater func(s storage.Session) (storage.Session, error)) (err error) {
+	s.tx(func() {
+		session, ok := s.sessions[id]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if session, err = updater(session); err == nil {
+			s.sessions[id] = session
+		}
+	})
+	return
+}
+
+func (s *memStorage) UpdateLogoutNotification(id string, updater func(n storage.LogoutNotification) (storage.LogoutNotification, error)) (err error) {
+	s.tx(func() {
+		n, ok := s.notifications[id]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if n, err = updater(n); err == nil {
+			s.notifications[id] = n
+		}
+	})
+	return
+}
@@ -38,6 +38,11 @@ func cleanDB(c *conn) error {
 		delete from refresh_token;
 		delete from keys;
 		delete from password;
+		delete from access_token;
+		delete from device_request;
+		delete from client_assertion;
+		delete from session;
+		delete from logout_notification;
 	`)
 	return err
 }
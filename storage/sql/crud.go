@@ -100,9 +100,216 @@ func (c *conn) GarbageCollect(now time.Time) (result storage.GCResult, err error
 	if n, err := r.RowsAffected(); err == nil {
 		result.AuthCodes = n
 	}
+
+	r, err = c.Exec(`delete from access_token where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc access_token: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.AccessTokens = n
+	}
+
+	r, err = c.Exec(`delete from device_request where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc device_request: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.DeviceRequests = n
+	}
+
+	r, err = c.Exec(`delete from client_assertion where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc client_assertion: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.ClientAssertions = n
+	}
+
+	r, err = c.Exec(`delete from session where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc session: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.Sessions = n
+	}
+
+	r, err = c.Exec(`delete from logout_notification where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc logout notification: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.LogoutNotifications = n
+	}
+
+	r, err = c.Exec(`delete from pushed_auth_request where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc pushed_auth_request: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.PushedAuthRequests = n
+	}
+
+	if result.RefreshTokens, err = c.gcRefreshTokens(now); err != nil {
+		return result, fmt.Errorf("gc refresh_token: %v", err)
+	}
+	if err = c.gcOfflineSessionRefs(); err != nil {
+		return result, fmt.Errorf("gc offline_session: %v", err)
+	}
 	return
 }
 
+// gcRefreshTokens deletes expired refresh tokens, along with the references to
+// them held by offline sessions.
+func (c *conn) gcRefreshTokens(now time.Time) (int64, error) {
+	// Refresh tokens that never expire have a zero expiry.
+	rows, err := c.Query(`
+		select id, client_id, claims_user_id, connector_id
+		from refresh_token where expiry > $1 and expiry < $2;
+	`, time.Unix(0, 0), now)
+	if err != nil {
+		return 0, err
+	}
+	var expired []storage.RefreshToken
+	for rows.Next() {
+		var r storage.RefreshToken
+		if err := rows.Scan(&r.ID, &r.ClientID, &r.Claims.UserID, &r.ConnectorID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, r)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for _, r := range expired {
+		// Transactions may be retried, so only count the token once committed.
+		var deleted bool
+		err := c.ExecTx(func(tx *trans) error {
+			result, err := tx.Exec(`delete from refresh_token where id = $1 and expiry < $2`, r.ID, now)
+			if err != nil {
+				return err
+			}
+			if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+				// Refreshed since it was listed.
+				deleted = false
+				return err
+			}
+			deleted = true
+
+			s, err := getOfflineSessions(tx, r.Claims.UserID, r.ConnectorID)
+			if err != nil {
+				if err == storage.ErrNotFound {
+					return nil
+				}
+				return err
+			}
+			if ref, ok := s.Refresh[r.ClientID]; !ok || ref.ID != r.ID {
+				return nil
+			}
+			delete(s.Refresh, r.ClientID)
+			_, err = tx.Exec(`
+				update offline_session set refresh = $1
+				where user_id = $2 AND conn_id = $3;
+			`, encoder(s.Refresh), s.UserID, s.ConnID)
+			return err
+		})
+		if err != nil {
+			return n, err
+		}
+		if deleted {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// gcOfflineSessionRefs removes offline session references to refresh tokens
+// that no longer exist.
+func (c *conn) gcOfflineSessionRefs() error {
+	rows, err := c.Query(`select id from refresh_token;`)
+	if err != nil {
+		return err
+	}
+	refreshIDs := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		refreshIDs[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = c.Query(`
+		select
+			user_id, conn_id, refresh
+		from offline_session;
+	`)
+	if err != nil {
+		return err
+	}
+	var sessions []storage.OfflineSessions
+	for rows.Next() {
+		s, err := scanOfflineSessions(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		for _, ref := range s.Refresh {
+			if !refreshIDs[ref.ID] {
+				sessions = append(sessions, s)
+				break
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range sessions {
+		err := c.ExecTx(func(tx *trans) error {
+			// Re-read the session and check each reference again, as refresh
+			// tokens may have been issued since they were listed.
+			s, err := getOfflineSessions(tx, s.UserID, s.ConnID)
+			if err != nil {
+				if err == storage.ErrNotFound {
+					return nil
+				}
+				return err
+			}
+			var changed bool
+			for clientID, ref := range s.Refresh {
+				var exists bool
+				err := tx.QueryRow(`select exists (select 1 from refresh_token where id = $1)`, ref.ID).Scan(&exists)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					delete(s.Refresh, clientID)
+					changed = true
+				}
+			}
+			if !changed {
+				return nil
+			}
+			_, err = tx.Exec(`
+				update offline_session set refresh = $1
+				where user_id = $2 AND conn_id = $3;
+			`, encoder(s.Refresh), s.UserID, s.ConnID)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *conn) CreateAuthRequest(a storage.AuthRequest) error {
 	_, err := c.Exec(`
 		insert into auth_request (
@@ -111,10 +318,13 @@ func (c *conn) CreateAuthRequest(a storage.AuthRequest) error {
 			claims_user_id, claims_username, claims_email, claims_email_verified,
 			claims_groups,
 			connector_id, connector_data,
+			code_challenge, code_challenge_method,
+			login_hint, auth_time, resources, response_mode,
 			expiry
 		)
 		values (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
+			$20, $21, $22, $23
 		);
 	`,
 		a.ID, a.ClientID, encoder(a.ResponseTypes), encoder(a.Scopes), a.RedirectURI, a.Nonce, a.State,
@@ -122,6 +332,8 @@ func (c *conn) CreateAuthRequest(a storage.AuthRequest) error {
 		a.Claims.UserID, a.Claims.Username, a.Claims.Email, a.Claims.EmailVerified,
 		encoder(a.Claims.Groups),
 		a.ConnectorID, a.ConnectorData,
+		a.PKCE.CodeChallenge, a.PKCE.CodeChallengeMethod,
+		a.LoginHint, a.AuthTime, encoder(a.Resources), a.ResponseMode,
 		a.Expiry,
 	)
 	if err != nil {
@@ -153,14 +365,18 @@ func (c *conn) UpdateAuthRequest(id string, updater func(a storage.AuthRequest)
 				claims_email_verified = $12,
 				claims_groups = $13,
 				connector_id = $14, connector_data = $15,
-				expiry = $16
-			where id = $17;
+				code_challenge = $16, code_challenge_method = $17,
+				login_hint = $18, auth_time = $19, resources = $20, response_mode = $21,
+				expiry = $22
+			where id = $23;
 		`,
 			a.ClientID, encoder(a.ResponseTypes), encoder(a.Scopes), a.RedirectURI, a.Nonce, a.State,
 			a.ForceApprovalPrompt, a.LoggedIn,
 			a.Claims.UserID, a.Claims.Username, a.Claims.Email, a.Claims.EmailVerified,
 			encoder(a.Claims.Groups),
 			a.ConnectorID, a.ConnectorData,
+			a.PKCE.CodeChallenge, a.PKCE.CodeChallengeMethod,
+			a.LoginHint, a.AuthTime, encoder(a.Resources), a.ResponseMode,
 			a.Expiry, r.ID,
 		)
 		if err != nil {
@@ -182,14 +398,20 @@ func getAuthRequest(q querier, id string) (a storage.AuthRequest, err error) {
 			force_approval_prompt, logged_in,
 			claims_user_id, claims_username, claims_email, claims_email_verified,
 			claims_groups,
-			connector_id, connector_data, expiry
+			connector_id, connector_data,
+			code_challenge, code_challenge_method,
+			login_hint, auth_time, resources, response_mode,
+			expiry
 		from auth_request where id = $1;
 	`, id).Scan(
 		&a.ID, &a.ClientID, decoder(&a.ResponseTypes), decoder(&a.Scopes), &a.RedirectURI, &a.Nonce, &a.State,
 		&a.ForceApprovalPrompt, &a.LoggedIn,
 		&a.Claims.UserID, &a.Claims.Username, &a.Claims.Email, &a.Claims.EmailVerified,
 		decoder(&a.Claims.Groups),
-		&a.ConnectorID, &a.ConnectorData, &a.Expiry,
+		&a.ConnectorID, &a.ConnectorData,
+		&a.PKCE.CodeChallenge, &a.PKCE.CodeChallengeMethod,
+		&a.LoginHint, &a.AuthTime, decoder(&a.Resources), &a.ResponseMode,
+		&a.Expiry,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -207,13 +429,16 @@ func (c *conn) CreateAuthCode(a storage.AuthCode) error {
 			claims_user_id, claims_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			expiry
+			code_challenge, code_challenge_method,
+			auth_time, resources, expiry
 		)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17);
 	`,
 		a.ID, a.ClientID, encoder(a.Scopes), a.Nonce, a.RedirectURI, a.Claims.UserID,
 		a.Claims.Username, a.Claims.Email, a.Claims.EmailVerified, encoder(a.Claims.Groups),
-		a.ConnectorID, a.ConnectorData, a.Expiry,
+		a.ConnectorID, a.ConnectorData,
+		a.PKCE.CodeChallenge, a.PKCE.CodeChallengeMethod,
+		a.AuthTime, encoder(a.Resources), a.Expiry,
 	)
 
 	if err != nil {
@@ -232,12 +457,15 @@ func (c *conn) GetAuthCode(id string) (a storage.AuthCode, err error) {
 			claims_user_id, claims_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			expiry
+			code_challenge, code_challenge_method,
+			auth_time, resources, expiry
 		from auth_code where id = $1;
 	`, id).Scan(
 		&a.ID, &a.ClientID, decoder(&a.Scopes), &a.Nonce, &a.RedirectURI, &a.Claims.UserID,
 		&a.Claims.Username, &a.Claims.Email, &a.Claims.EmailVerified, decoder(&a.Claims.Groups),
-		&a.ConnectorID, &a.ConnectorData, &a.Expiry,
+		&a.ConnectorID, &a.ConnectorData,
+		&a.PKCE.CodeChallenge, &a.PKCE.CodeChallengeMethod,
+		&a.AuthTime, decoder(&a.Resources), &a.Expiry,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -248,6 +476,362 @@ func (c *conn) GetAuthCode(id string) (a storage.AuthCode, err error) {
 	return a, nil
 }
 
+func (c *conn) CreateAccessToken(t storage.AccessToken) error {
+	_, err := c.Exec(`
+		insert into access_token (
+			id, client_id, scopes,
+			claims_user_id, claims_username,
+			claims_email, claims_email_verified, claims_groups,
+			connector_id, resources,
+			created_at, expiry
+		)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+	`,
+		t.ID, t.ClientID, encoder(t.Scopes),
+		t.Claims.UserID, t.Claims.Username,
+		t.Claims.Email, t.Claims.EmailVerified, encoder(t.Claims.Groups),
+		t.ConnectorID, encoder(t.Resources),
+		t.CreatedAt, t.Expiry,
+	)
+
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert access token: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) GetAccessToken(id string) (t storage.AccessToken, err error) {
+	err = c.QueryRow(`
+		select
+			id, client_id, scopes,
+			claims_user_id, claims_username,
+			claims_email, claims_email_verified, claims_groups,
+			connector_id, resources,
+			created_at, expiry
+		from access_token where id = $1;
+	`, id).Scan(
+		&t.ID, &t.ClientID, decoder(&t.Scopes),
+		&t.Claims.UserID, &t.Claims.Username,
+		&t.Claims.Email, &t.Claims.EmailVerified, decoder(&t.Claims.Groups),
+		&t.ConnectorID, decoder(&t.Resources),
+		&t.CreatedAt, &t.Expiry,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return t, storage.ErrNotFound
+		}
+		return t, fmt.Errorf("select access token: %v", err)
+	}
+	return t, nil
+}
+
+func (c *conn) CreateDeviceRequest(d storage.DeviceRequest) error {
+	_, err := c.Exec(`
+		insert into device_request (
+			user_code, device_code, client_id, scopes,
+			auth_code, denied, poll_interval, last_poll,
+			expiry
+		)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+	`,
+		d.UserCode, d.DeviceCode, d.ClientID, encoder(d.Scopes),
+		d.AuthCode, d.Denied, d.Interval, d.LastPoll,
+		d.Expiry,
+	)
+
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert device request: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) UpdateDeviceRequest(userCode string, updater func(d storage.DeviceRequest) (storage.DeviceRequest, error)) error {
+	return c.ExecTx(func(tx *trans) error {
+		r, err := getDeviceRequest(tx, userCode)
+		if err != nil {
+			return err
+		}
+
+		d, err := updater(r)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			update device_request
+			set
+				device_code = $1, client_id = $2, scopes = $3,
+				auth_code = $4, denied = $5, poll_interval = $6, last_poll = $7,
+				expiry = $8
+			where user_code = $9;
+		`,
+			d.DeviceCode, d.ClientID, encoder(d.Scopes),
+			d.AuthCode, d.Denied, d.Interval, d.LastPoll,
+			d.Expiry, r.UserCode,
+		)
+		if err != nil {
+			return fmt.Errorf("update device request: %v", err)
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetDeviceRequest(userCode string) (storage.DeviceRequest, error) {
+	return getDeviceRequest(c, userCode)
+}
+
+func getDeviceRequest(q querier, userCode string) (d storage.DeviceRequest, err error) {
+	err = q.QueryRow(`
+		select
+			user_code, device_code, client_id, scopes,
+			auth_code, denied, poll_interval, last_poll,
+			expiry
+		from device_request where user_code = $1;
+	`, userCode).Scan(
+		&d.UserCode, &d.DeviceCode, &d.ClientID, decoder(&d.Scopes),
+		&d.AuthCode, &d.Denied, &d.Interval, &d.LastPoll,
+		&d.Expiry,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return d, storage.ErrNotFound
+		}
+		return d, fmt.Errorf("select device request: %v", err)
+	}
+	return d, nil
+}
+
+func (c *conn) CreateClientAssertion(a storage.ClientAssertion) error {
+	_, err := c.Exec(`
+		insert into client_assertion (id, client_id, expiry)
+		values ($1, $2, $3);
+	`, a.ID, a.ClientID, a.Expiry)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert client assertion: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) CreateSession(s storage.Session) error {
+	_, err := c.Exec(`
+		insert into session (
+			id,
+			claims_user_id, claims_username, claims_email, claims_email_verified,
+			claims_groups,
+			connector_id, connector_data,
+			created_at, last_used, expiry,
+			client_ids
+		)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+	`,
+		s.ID,
+		s.Claims.UserID, s.Claims.Username, s.Claims.Email, s.Claims.EmailVerified,
+		encoder(s.Claims.Groups),
+		s.ConnectorID, s.ConnectorData,
+		s.CreatedAt, s.LastUsed, s.Expiry,
+		encoder(s.ClientIDs),
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert session: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) UpdateSession(id string, updater func(s storage.Session) (storage.Session, error)) error {
+	return c.ExecTx(func(tx *trans) error {
+		s, err := getSession(tx, id)
+		if err != nil {
+			return err
+		}
+		if s, err = updater(s); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			update session
+			set
+				claims_user_id = $1,
+				claims_username = $2,
+				claims_email = $3,
+				claims_email_verified = $4,
+				claims_groups = $5,
+				connector_id = $6,
+				connector_data = $7,
+				created_at = $8,
+				last_used = $9,
+				expiry = $10,
+				client_ids = $11
+			where id = $12;
+		`,
+			s.Claims.UserID, s.Claims.Username, s.Claims.Email, s.Claims.EmailVerified,
+			encoder(s.Claims.Groups),
+			s.ConnectorID, s.ConnectorData,
+			s.CreatedAt, s.LastUsed, s.Expiry,
+			encoder(s.ClientIDs), id,
+		)
+		if err != nil {
+			return fmt.Errorf("update session: %v", err)
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetSession(id string) (storage.Session, error) {
+	return getSession(c, id)
+}
+
+func getSession(q querier, id string) (s storage.Session, err error) {
+	err = q.QueryRow(`
+		select
+			id,
+			claims_user_id, claims_username, claims_email, claims_email_verified,
+			claims_groups,
+			connector_id, connector_data,
+			created_at, last_used, expiry,
+			client_ids
+		from session where id = $1;
+	`, id).Scan(
+		&s.ID,
+		&s.Claims.UserID, &s.Claims.Username, &s.Claims.Email, &s.Claims.EmailVerified,
+		decoder(&s.Claims.Groups),
+		&s.ConnectorID, &s.ConnectorData,
+		&s.CreatedAt, &s.LastUsed, &s.Expiry,
+		decoder(&s.ClientIDs),
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return s, storage.ErrNotFound
+		}
+		return s, fmt.Errorf("select session: %v", err)
+	}
+	return s, nil
+}
+
+func (c *conn) CreateLogoutNotification(n storage.LogoutNotification) error {
+	_, err := c.Exec(`
+		insert into logout_notification (
+			id, client_id, subject, attempts, next_attempt, expiry
+		)
+		values ($1, $2, $3, $4, $5, $6);
+	`, n.ID, n.ClientID, n.Subject, n.Attempts, n.NextAttempt, n.Expiry)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert logout notification: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) UpdateLogoutNotification(id string, updater func(n storage.LogoutNotification) (storage.LogoutNotification, error)) error {
+	return c.ExecTx(func(tx *trans) error {
+		n, err := getLogoutNotification(tx, id)
+		if err != nil {
+			return err
+		}
+		if n, err = updater(n); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			update logout_notification
+			set
+				client_id = $1,
+				subject = $2,
+				attempts = $3,
+				next_attempt = $4,
+				expiry = $5
+			where id = $6;
+		`, n.ClientID, n.Subject, n.Attempts, n.NextAttempt, n.Expiry, id)
+		if err != nil {
+			return fmt.Errorf("update logout notification: %v", err)
+		}
+		return nil
+	})
+}
+
+func getLogoutNotification(q querier, id string) (storage.LogoutNotification, error) {
+	return scanLogoutNotification(q.QueryRow(`
+		select
+			id, client_id, subject, attempts, next_attempt, expiry
+		from logout_notification where id = $1;
+	`, id))
+}
+
+func (c *conn) ListLogoutNotifications() ([]storage.LogoutNotification, error) {
+	rows, err := c.Query(`
+		select
+			id, client_id, subject, attempts, next_attempt, expiry
+		from logout_notification;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var notifications []storage.LogoutNotification
+	for rows.Next() {
+		n, err := scanLogoutNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func scanLogoutNotification(s scanner) (n storage.LogoutNotification, err error) {
+	err = s.Scan(&n.ID, &n.ClientID, &n.Subject, &n.Attempts, &n.NextAttempt, &n.Expiry)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return n, storage.ErrNotFound
+		}
+		return n, fmt.Errorf("select logout notification: %v", err)
+	}
+	return n, nil
+}
+
+func (c *conn) CreatePushedAuthRequest(p storage.PushedAuthRequest) error {
+	_, err := c.Exec(`
+		insert into pushed_auth_request (
+			id, client_id, params, expiry
+		)
+		values ($1, $2, $3, $4);
+	`, p.ID, p.ClientID, encoder(p.Params), p.Expiry)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert pushed auth request: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) GetPushedAuthRequest(id string) (p storage.PushedAuthRequest, err error) {
+	err = c.QueryRow(`
+		select
+			id, client_id, params, expiry
+		from pushed_auth_request where id = $1;
+	`, id).Scan(&p.ID, &p.ClientID, decoder(&p.Params), &p.Expiry)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return p, storage.ErrNotFound
+		}
+		return p, fmt.Errorf("select pushed auth request: %v", err)
+	}
+	return p, nil
+}
+
 func (c *conn) CreateRefresh(r storage.RefreshToken) error {
 	_, err := c.Exec(`
 		insert into refresh_token (
@@ -255,15 +839,17 @@ func (c *conn) CreateRefresh(r storage.RefreshToken) error {
 			claims_user_id, claims_username, claims_email, claims_email_verified,
 			claims_groups,
 			connector_id, connector_data,
-			token, created_at, last_used
+			token, created_at, last_used, auth_time,
+			resources, obsolete_token, expiry
 		)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14);
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18);
 	`,
 		r.ID, r.ClientID, encoder(r.Scopes), r.Nonce,
 		r.Claims.UserID, r.Claims.Username, r.Claims.Email, r.Claims.EmailVerified,
 		encoder(r.Claims.Groups),
 		r.ConnectorID, r.ConnectorData,
-		r.Token, r.CreatedAt, r.LastUsed,
+		r.Token, r.CreatedAt, r.LastUsed, r.AuthTime,
+		encoder(r.Resources), r.ObsoleteToken, r.Expiry,
 	)
 	if err != nil {
 		if c.alreadyExistsCheck(err) {
@@ -298,15 +884,20 @@ func (c *conn) UpdateRefreshToken(id string, updater func(old storage.RefreshTok
 				connector_data = $10,
 				token = $11,
 				created_at = $12,
-				last_used = $13
+				last_used = $13,
+				auth_time = $14,
+				resources = $15,
+				obsolete_token = $16,
+				expiry = $17
 			where
-				id = $14
+				id = $18
 		`,
 			r.ClientID, encoder(r.Scopes), r.Nonce,
 			r.Claims.UserID, r.Claims.Username, r.Claims.Email, r.Claims.EmailVerified,
 			encoder(r.Claims.Groups),
 			r.ConnectorID, r.ConnectorData,
-			r.Token, r.CreatedAt, r.LastUsed, id,
+			r.Token, r.CreatedAt, r.LastUsed, r.AuthTime,
+			encoder(r.Resources), r.ObsoleteToken, r.Expiry, id,
 		)
 		if err != nil {
 			return fmt.Errorf("update refresh token: %v", err)
@@ -326,7 +917,8 @@ func getRefresh(q querier, id string) (storage.RefreshToken, error) {
 			claims_user_id, claims_username, claims_email, claims_email_verified,
 			claims_groups,
 			connector_id, connector_data,
-			token, created_at, last_used
+			token, created_at, last_used, auth_time,
+			resources, obsolete_token, expiry
 		from refresh_token where id = $1;
 	`, id))
 }
@@ -338,7 +930,8 @@ func (c *conn) ListRefreshTokens() ([]storage.RefreshToken, error) {
 			claims_user_id, claims_username, claims_email, claims_email_verified,
 			claims_groups,
 			connector_id, connector_data,
-			token, created_at, last_used
+			token, created_at, last_used, auth_time,
+			resources, obsolete_token, expiry
 		from refresh_token;
 	`)
 	if err != nil {
@@ -364,7 +957,8 @@ func scanRefresh(s scanner) (r storage.RefreshToken, err error) {
 		&r.Claims.UserID, &r.Claims.Username, &r.Claims.Email, &r.Claims.EmailVerified,
 		decoder(&r.Claims.Groups),
 		&r.ConnectorID, &r.ConnectorData,
-		&r.Token, &r.CreatedAt, &r.LastUsed,
+		&r.Token, &r.CreatedAt, &r.LastUsed, &r.AuthTime,
+		decoder(&r.Resources), &r.ObsoleteToken, &r.Expiry,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -397,12 +991,14 @@ func (c *conn) UpdateKeys(updater func(old storage.Keys) (storage.Keys, error))
 		if firstUpdate {
 			_, err = tx.Exec(`
 				insert into keys (
-					id, verification_keys, signing_key, signing_key_pub, next_rotation
+					id, verification_keys, signing_key, signing_key_pub, next_rotation,
+					next_signing_key, next_signing_key_pub
 				)
-				values ($1, $2, $3, $4, $5);
+				values ($1, $2, $3, $4, $5, $6, $7);
 			`,
 				keysRowID, encoder(nk.VerificationKeys), encoder(nk.SigningKey),
 				encoder(nk.SigningKeyPub), nk.NextRotation,
+				encoder(nk.NextSigningKey), encoder(nk.NextSigningKeyPub),
 			)
 			if err != nil {
 				return fmt.Errorf("insert: %v", err)
@@ -414,11 +1010,14 @@ func (c *conn) UpdateKeys(updater func(old storage.Keys) (storage.Keys, error))
 				    verification_keys = $1,
 					signing_key = $2,
 					signing_key_pub = $3,
-					next_rotation = $4
-				where id = $5;
+					next_rotation = $4,
+					next_signing_key = $5,
+					next_signing_key_pub = $6
+				where id = $7;
 			`,
 				encoder(nk.VerificationKeys), encoder(nk.SigningKey),
-				encoder(nk.SigningKeyPub), nk.NextRotation, keysRowID,
+				encoder(nk.SigningKeyPub), nk.NextRotation,
+				encoder(nk.NextSigningKey), encoder(nk.NextSigningKeyPub), keysRowID,
 			)
 			if err != nil {
 				return fmt.Errorf("update: %v", err)
@@ -435,12 +1034,14 @@ func (c *conn) GetKeys() (keys storage.Keys, err error) {
 func getKeys(q querier) (keys storage.Keys, err error) {
 	err = q.QueryRow(`
 		select
-			verification_keys, signing_key, signing_key_pub, next_rotation
+			verification_keys, signing_key, signing_key_pub, next_rotation,
+			next_signing_key, next_signing_key_pub
 		from keys
 		where id=$1
 	`, keysRowID).Scan(
 		decoder(&keys.VerificationKeys), decoder(&keys.SigningKey),
 		decoder(&keys.SigningKeyPub), &keys.NextRotation,
+		decoder(&keys.NextSigningKey), decoder(&keys.NextSigningKeyPub),
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -470,9 +1071,35 @@ func (c *conn) UpdateClient(id string, updater func(old storage.Client) (storage
 				trusted_peers = $3,
 				public = $4,
 				name = $5,
-				logo_url = $6
-			where id = $7;
-		`, nc.Secret, encoder(nc.RedirectURIs), encoder(nc.TrustedPeers), nc.Public, nc.Name, nc.LogoURL, id,
+				logo_url = $6,
+				allowed_grant_types = $7,
+				jwks = $8,
+				jwks_uri = $9,
+				registration_token_hash = $10,
+				post_logout_redirect_uris = $11,
+				backchannel_logout_uri = $12,
+				access_token_format = $13,
+				refresh_token_idle_timeout = $14,
+				refresh_token_absolute_lifetime = $15,
+				require_pushed_auth_requests = $16,
+				require_signed_request_object = $17,
+				subject_type = $18,
+				sector_identifier_uri = $19,
+				allowed_connectors = $20,
+				allowed_scopes = $21,
+				allowed_response_types = $22,
+				id_token_lifetime = $23,
+				access_token_lifetime = $24,
+				auth_code_lifetime = $25
+			where id = $26;
+		`, nc.Secret, encoder(nc.RedirectURIs), encoder(nc.TrustedPeers), nc.Public, nc.Name, nc.LogoURL,
+			encoder(nc.AllowedGrantTypes), encoder(nc.JWKS), nc.JWKSURI, nc.RegistrationTokenHash,
+			encoder(nc.PostLogoutRedirectURIs), nc.BackchannelLogoutURI, nc.AccessTokenFormat,
+			nc.RefreshTokenIdleTimeout, nc.RefreshTokenAbsoluteLifetime,
+			nc.RequirePushedAuthRequests, nc.RequireSignedRequestObject,
+			nc.SubjectType, nc.SectorIdentifierURI,
+			encoder(nc.AllowedConnectors), encoder(nc.AllowedScopes), encoder(nc.AllowedResponseTypes),
+			nc.IDTokenLifetime, nc.AccessTokenLifetime, nc.AuthCodeLifetime, id,
 		)
 		if err != nil {
 			return fmt.Errorf("update client: %v", err)
@@ -484,12 +1111,28 @@ func (c *conn) UpdateClient(id string, updater func(old storage.Client) (storage
 func (c *conn) CreateClient(cli storage.Client) error {
 	_, err := c.Exec(`
 		insert into client (
-			id, secret, redirect_uris, trusted_peers, public, name, logo_url
+			id, secret, redirect_uris, trusted_peers, public, name, logo_url,
+			allowed_grant_types, jwks, jwks_uri, registration_token_hash,
+			post_logout_redirect_uris, backchannel_logout_uri,
+			access_token_format,
+			refresh_token_idle_timeout, refresh_token_absolute_lifetime,
+			require_pushed_auth_requests, require_signed_request_object,
+			subject_type, sector_identifier_uri,
+			allowed_connectors, allowed_scopes, allowed_response_types,
+			id_token_lifetime, access_token_lifetime, auth_code_lifetime
 		)
-		values ($1, $2, $3, $4, $5, $6, $7);
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26);
 	`,
 		cli.ID, cli.Secret, encoder(cli.RedirectURIs), encoder(cli.TrustedPeers),
-		cli.Public, cli.Name, cli.LogoURL,
+		cli.Public, cli.Name, cli.LogoURL, encoder(cli.AllowedGrantTypes),
+		encoder(cli.JWKS), cli.JWKSURI, cli.RegistrationTokenHash,
+		encoder(cli.PostLogoutRedirectURIs), cli.BackchannelLogoutURI,
+		cli.AccessTokenFormat,
+		cli.RefreshTokenIdleTimeout, cli.RefreshTokenAbsoluteLifetime,
+		cli.RequirePushedAuthRequests, cli.RequireSignedRequestObject,
+		cli.SubjectType, cli.SectorIdentifierURI,
+		encoder(cli.AllowedConnectors), encoder(cli.AllowedScopes), encoder(cli.AllowedResponseTypes),
+		cli.IDTokenLifetime, cli.AccessTokenLifetime, cli.AuthCodeLifetime,
 	)
 	if err != nil {
 		if c.alreadyExistsCheck(err) {
@@ -503,7 +1146,15 @@ func (c *conn) CreateClient(cli storage.Client) error {
 func getClient(q querier, id string) (storage.Client, error) {
 	return scanClient(q.QueryRow(`
 		select
-			id, secret, redirect_uris, trusted_peers, public, name, logo_url
+			id, secret, redirect_uris, trusted_peers, public, name, logo_url,
+			allowed_grant_types, jwks, jwks_uri, registration_token_hash,
+			post_logout_redirect_uris, backchannel_logout_uri,
+			access_token_format,
+			refresh_token_idle_timeout, refresh_token_absolute_lifetime,
+			require_pushed_auth_requests, require_signed_request_object,
+			subject_type, sector_identifier_uri,
+			allowed_connectors, allowed_scopes, allowed_response_types,
+			id_token_lifetime, access_token_lifetime, auth_code_lifetime
 	    from client where id = $1;
 	`, id))
 }
@@ -515,7 +1166,15 @@ func (c *conn) GetClient(id string) (storage.Client, error) {
 func (c *conn) ListClients() ([]storage.Client, error) {
 	rows, err := c.Query(`
 		select
-			id, secret, redirect_uris, trusted_peers, public, name, logo_url
+			id, secret, redirect_uris, trusted_peers, public, name, logo_url,
+			allowed_grant_types, jwks, jwks_uri, registration_token_hash,
+			post_logout_redirect_uris, backchannel_logout_uri,
+			access_token_format,
+			refresh_token_idle_timeout, refresh_token_absolute_lifetime,
+			require_pushed_auth_requests, require_signed_request_object,
+			subject_type, sector_identifier_uri,
+			allowed_connectors, allowed_scopes, allowed_response_types,
+			id_token_lifetime, access_token_lifetime, auth_code_lifetime
 		from client;
 	`)
 	if err != nil {
@@ -538,7 +1197,15 @@ func (c *conn) ListClients() ([]storage.Client, error) {
 func scanClient(s scanner) (cli storage.Client, err error) {
 	err = s.Scan(
 		&cli.ID, &cli.Secret, decoder(&cli.RedirectURIs), decoder(&cli.TrustedPeers),
-		&cli.Public, &cli.Name, &cli.LogoURL,
+		&cli.Public, &cli.Name, &cli.LogoURL, decoder(&cli.AllowedGrantTypes),
+		decoder(&cli.JWKS), &cli.JWKSURI, &cli.RegistrationTokenHash,
+		decoder(&cli.PostLogoutRedirectURIs), &cli.BackchannelLogoutURI,
+		&cli.AccessTokenFormat,
+		&cli.RefreshTokenIdleTimeout, &cli.RefreshTokenAbsoluteLifetime,
+		&cli.RequirePushedAuthRequests, &cli.RequireSignedRequestObject,
+		&cli.SubjectType, &cli.SectorIdentifierURI,
+		decoder(&cli.AllowedConnectors), decoder(&cli.AllowedScopes), decoder(&cli.AllowedResponseTypes),
+		&cli.IDTokenLifetime, &cli.AccessTokenLifetime, &cli.AuthCodeLifetime,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -717,6 +1384,72 @@ func scanOfflineSessions(s scanner) (o storage.OfflineSessions, err error) {
 	return o, nil
 }
 
+func (c *conn) CreateConsents(s storage.Consents) error {
+	_, err := c.Exec(`
+		insert into consent (
+			user_id, conn_id, clients
+		)
+		values (
+			$1, $2, $3
+		);
+	`,
+		s.UserID, s.ConnID, encoder(s.Clients),
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert consent: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) UpdateConsents(userID string, connID string, updater func(s storage.Consents) (storage.Consents, error)) error {
+	return c.ExecTx(func(tx *trans) error {
+		s, err := getConsents(tx, userID, connID)
+		if err != nil {
+			return err
+		}
+
+		newConsents, err := updater(s)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			update consent
+			set
+				clients = $1
+			where user_id = $2 AND conn_id = $3;
+		`,
+			encoder(newConsents.Clients), s.UserID, s.ConnID,
+		)
+		if err != nil {
+			return fmt.Errorf("update consent: %v", err)
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetConsents(userID string, connID string) (storage.Consents, error) {
+	return getConsents(c, userID, connID)
+}
+
+func getConsents(q querier, userID string, connID string) (s storage.Consents, err error) {
+	err = q.QueryRow(`
+		select
+			user_id, conn_id, clients
+		from consent
+		where user_id = $1 AND conn_id = $2;
+		`, userID, connID).Scan(&s.UserID, &s.ConnID, decoder(&s.Clients))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return s, storage.ErrNotFound
+		}
+		return s, fmt.Errorf("select consent: %v", err)
+	}
+	return s, nil
+}
+
 func (c *conn) CreateConnector(connector storage.Connector) error {
 	_, err := c.Exec(`
 		insert into connector (
@@ -822,7 +1555,34 @@ func (c *conn) DeleteRefresh(id string) error     { return c.delete("refresh_tok
 func (c *conn) DeletePassword(email string) error {
 	return c.delete("password", "email", strings.ToLower(email))
 }
-func (c *conn) DeleteConnector(id string) error { return c.delete("connector", "id", id) }
+func (c *conn) DeleteConnector(id string) error   { return c.delete("connector", "id", id) }
+func (c *conn) DeleteAccessToken(id string) error { return c.delete("access_token", "id", id) }
+func (c *conn) DeleteSession(id string) error     { return c.delete("session", "id", id) }
+func (c *conn) DeleteDeviceRequest(userCode string) error {
+	return c.delete("device_request", "user_code", userCode)
+}
+func (c *conn) DeleteLogoutNotification(id string) error {
+	return c.delete("logout_notification", "id", id)
+}
+func (c *conn) DeletePushedAuthRequest(id string) error {
+	return c.delete("pushed_auth_request", "id", id)
+}
+
+func (c *conn) DeleteConsents(userID string, connID string) error {
+	result, err := c.Exec(`delete from consent where user_id = $1 AND conn_id = $2`, userID, connID)
+	if err != nil {
+		return fmt.Errorf("delete consent: user_id = %s, conn_id = %s", userID, connID)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %v", err)
+	}
+	if n < 1 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
 
 func (c *conn) DeleteOfflineSessions(userID string, connID string) error {
 	result, err := c.Exec(`delete from offline_session where user_id = $1 AND conn_id = $2`, userID, connID)
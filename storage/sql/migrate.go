@@ -187,4 +187,226 @@ var migrations = []migration{
 			);
 		`,
 	},
+	{
+		stmt: `
+			alter table auth_request
+				add column code_challenge text not null default '';
+			alter table auth_request
+				add column code_challenge_method text not null default '';
+			alter table auth_code
+				add column code_challenge text not null default '';
+			alter table auth_code
+				add column code_challenge_method text not null default '';
+		`,
+	},
+	{
+		stmt: `
+			create table access_token (
+				id text not null primary key,
+				client_id text not null,
+				scopes bytea not null, -- JSON array of strings
+
+				claims_user_id text not null,
+				claims_username text not null,
+				claims_email text not null,
+				claims_email_verified boolean not null,
+				claims_groups bytea not null, -- JSON array of strings
+
+				connector_id text not null,
+
+				created_at timestamptz not null,
+				expiry timestamptz not null
+			);
+		`,
+	},
+	{
+		stmt: `
+			alter table client
+				add column allowed_grant_types bytea not null default '[]'; -- JSON array of strings
+		`,
+	},
+	{
+		stmt: `
+			create table device_request (
+				user_code text not null primary key,
+				device_code text not null,
+				client_id text not null,
+				scopes bytea not null, -- JSON array of strings
+				auth_code text not null,
+				denied boolean not null,
+				poll_interval integer not null,
+				last_poll timestamptz not null,
+				expiry timestamptz not null
+			);
+		`,
+	},
+	{
+		stmt: `
+			alter table client
+				add column jwks bytea not null default 'null'; -- JSON object
+			alter table client
+				add column jwks_uri text not null default '';
+			create table client_assertion (
+				id text not null primary key,
+				client_id text not null,
+				expiry timestamptz not null
+			);
+		`,
+	},
+	{
+		stmt: `
+			alter table client
+				add column registration_token_hash text not null default '';
+		`,
+	},
+	{
+		stmt: `
+			create table session (
+				id text not null primary key,
+
+				claims_user_id text not null,
+				claims_username text not null,
+				claims_email text not null,
+				claims_email_verified boolean not null,
+				claims_groups bytea not null, -- JSON array of strings
+
+				connector_id text not null,
+				connector_data bytea,
+
+				created_at timestamptz not null,
+				last_used timestamptz not null,
+				expiry timestamptz not null
+			);
+		`,
+	},
+	{
+		stmt: `
+			alter table auth_request
+				add column login_hint text not null default '';
+			alter table auth_request
+				add column auth_time timestamptz not null default '0001-01-01 00:00:00 UTC';
+			alter table auth_code
+				add column auth_time timestamptz not null default '0001-01-01 00:00:00 UTC';
+			alter table refresh_token
+				add column auth_time timestamptz not null default '0001-01-01 00:00:00 UTC';
+		`,
+	},
+	{
+		stmt: `
+			alter table client
+				add column post_logout_redirect_uris bytea not null default '[]'; -- JSON array of strings
+		`,
+	},
+	{
+		stmt: `
+			alter table client
+				add column backchannel_logout_uri text not null default '';
+			alter table session
+				add column client_ids bytea not null default '[]'; -- JSON array of strings
+			create table logout_notification (
+				id text not null primary key,
+				client_id text not null,
+				subject text not null,
+				attempts integer not null,
+				next_attempt timestamptz not null,
+				expiry timestamptz not null
+			);
+		`,
+	},
+	{
+		stmt: `
+			alter table client
+				add column access_token_format text not null default '';
+			alter table auth_request
+				add column resources bytea not null default '[]'; -- JSON array of strings
+			alter table auth_code
+				add column resources bytea not null default '[]'; -- JSON array of strings
+			alter table refresh_token
+				add column resources bytea not null default '[]'; -- JSON array of strings
+			alter table access_token
+				add column resources bytea not null default '[]'; -- JSON array of strings
+		`,
+	},
+	{
+		stmt: `
+			alter table refresh_token
+				add column obsolete_token text not null default '';
+		`,
+	},
+	{
+		stmt: `
+			alter table refresh_token
+				add column expiry timestamptz not null default '0001-01-01 00:00:00 UTC';
+			alter table client
+				add column refresh_token_idle_timeout text not null default '';
+			alter table client
+				add column refresh_token_absolute_lifetime text not null default '';
+		`,
+	},
+	{
+		stmt: `
+			alter table auth_request
+				add column response_mode text not null default '';
+		`,
+	},
+	{
+		stmt: `
+			alter table client
+				add column require_pushed_auth_requests boolean not null default false;
+			alter table client
+				add column require_signed_request_object boolean not null default false;
+			create table pushed_auth_request (
+				id text not null primary key,
+				client_id text not null,
+				params bytea not null, -- JSON object of string arrays
+				expiry timestamptz not null
+			);
+		`,
+	},
+	{
+		stmt: `
+			alter table client
+				add column subject_type text not null default '';
+			alter table client
+				add column sector_identifier_uri text not null default '';
+		`,
+	},
+	{
+		stmt: `
+			alter table client
+				add column allowed_connectors bytea not null default '[]'; -- JSON array of strings
+			alter table client
+				add column allowed_scopes bytea not null default '[]'; -- JSON array of strings
+			alter table client
+				add column allowed_response_types bytea not null default '[]'; -- JSON array of strings
+		`,
+	},
+	{
+		stmt: `
+			alter table client
+				add column id_token_lifetime text not null default '';
+			alter table client
+				add column access_token_lifetime text not null default '';
+			alter table client
+				add column auth_code_lifetime text not null default '';
+		`,
+	},
+	{
+		stmt: `
+			create table consent (
+				user_id text not null,
+				conn_id text not null,
+				clients bytea not null, -- JSON object of client IDs to consents
+				PRIMARY KEY (user_id, conn_id)
+			);
+		`,
+	},
+	{
+		stmt: `
+			alter table keys
+				add column next_signing_key bytea not null default 'null'; -- JSON object
+			alter table keys
+				add column next_signing_key_pub bytea not null default 'null'; -- JSON object
+		`,
+	},
 }
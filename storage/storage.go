@@ -36,8 +36,15 @@ func NewID() string {
 
 // GCResult returns the number of objects deleted by garbage collection.
 type GCResult struct {
-	AuthRequests int64
-	AuthCodes    int64
+	AuthRequests        int64
+	AuthCodes           int64
+	AccessTokens        int64
+	DeviceRequests      int64
+	ClientAssertions    int64
+	Sessions            int64
+	LogoutNotifications int64
+	RefreshTokens       int64
+	PushedAuthRequests  int64
 }
 
 // Storage is the storage interface used by the server. Implementations are
@@ -54,6 +61,13 @@ type Storage interface {
 	CreatePassword(p Password) error
 	CreateOfflineSessions(s OfflineSessions) error
 	CreateConnector(c Connector) error
+	CreateAccessToken(t AccessToken) error
+	CreateDeviceRequest(d DeviceRequest) error
+	CreateClientAssertion(a ClientAssertion) error
+	CreateSession(s Session) error
+	CreateLogoutNotification(n LogoutNotification) error
+	CreatePushedAuthRequest(p PushedAuthRequest) error
+	CreateConsents(c Consents) error
 
 	// TODO(ericchiang): return (T, bool, error) so we can indicate not found
 	// requests that way instead of using ErrNotFound.
@@ -65,11 +79,17 @@ type Storage interface {
 	GetPassword(email string) (Password, error)
 	GetOfflineSessions(userID string, connID string) (OfflineSessions, error)
 	GetConnector(id string) (Connector, error)
+	GetAccessToken(id string) (AccessToken, error)
+	GetDeviceRequest(userCode string) (DeviceRequest, error)
+	GetSession(id string) (Session, error)
+	GetPushedAuthRequest(id string) (PushedAuthRequest, error)
+	GetConsents(userID string, connID string) (Consents, error)
 
 	ListClients() ([]Client, error)
 	ListRefreshTokens() ([]RefreshToken, error)
 	ListPasswords() ([]Password, error)
 	ListConnectors() ([]Connector, error)
+	ListLogoutNotifications() ([]LogoutNotification, error)
 
 	// Delete methods MUST be atomic.
 	DeleteAuthRequest(id string) error
@@ -79,6 +99,12 @@ type Storage interface {
 	DeletePassword(email string) error
 	DeleteOfflineSessions(userID string, connID string) error
 	DeleteConnector(id string) error
+	DeleteAccessToken(id string) error
+	DeleteDeviceRequest(userCode string) error
+	DeleteSession(id string) error
+	DeleteLogoutNotification(id string) error
+	DeletePushedAuthRequest(id string) error
+	DeleteConsents(userID string, connID string) error
 
 	// Update methods take a function for updating an object then performs that update within
 	// a transaction. "updater" functions may be called multiple times by a single update call.
@@ -101,8 +127,14 @@ type Storage interface {
 	UpdatePassword(email string, updater func(p Password) (Password, error)) error
 	UpdateOfflineSessions(userID string, connID string, updater func(s OfflineSessions) (OfflineSessions, error)) error
 	UpdateConnector(id string, updater func(c Connector) (Connector, error)) error
-
-	// GarbageCollect deletes all expired AuthCodes and AuthRequests.
+	UpdateDeviceRequest(userCode string, updater func(d DeviceRequest) (DeviceRequest, error)) error
+	UpdateSession(id string, updater func(s Session) (Session, error)) error
+	UpdateLogoutNotification(id string, updater func(n LogoutNotification) (LogoutNotification, error)) error
+	UpdateConsents(userID string, connID string, updater func(c Consents) (Consents, error)) error
+
+	// GarbageCollect deletes all expired AuthCodes, AuthRequests, AccessTokens,
+	// DeviceRequests, ClientAssertions, Sessions, LogoutNotifications and
+	// PushedAuthRequests.
 	GarbageCollect(now time.Time) (GCResult, error)
 }
 
@@ -120,6 +152,14 @@ type Client struct {
 	// requested to redirect to MUST match one of these values, unless the client is "public".
 	RedirectURIs []string `json:"redirectURIs" yaml:"redirectURIs"`
 
+	// PostLogoutRedirectURIs are the URIs the client may ask dex to redirect to after
+	// logging the end user out. Unlike RedirectURIs, these must always match exactly.
+	PostLogoutRedirectURIs []string `json:"postLogoutRedirectURIs" yaml:"postLogoutRedirectURIs"`
+
+	// BackchannelLogoutURI is where the server POSTs logout tokens to tell the client
+	// that an end user has logged out. If empty, the client isn't notified.
+	BackchannelLogoutURI string `json:"backchannelLogoutURI" yaml:"backchannelLogoutURI"`
+
 	// TrustedPeers are a list of peers which can issue tokens on this client's behalf using
 	// the dynamic "oauth2:server:client_id:(client_id)" scope. If a peer makes such a request,
 	// this client's ID will appear as the ID Token's audience.
@@ -133,6 +173,60 @@ type Client struct {
 	// Name and LogoURL used when displaying this client to the end user.
 	Name    string `json:"name" yaml:"name"`
 	LogoURL string `json:"logoURL" yaml:"logoURL"`
+
+	// AllowedGrantTypes are the grant types the client may use at the token endpoint.
+	// If empty, the client may use the "authorization_code" and "refresh_token" grants.
+	AllowedGrantTypes []string `json:"allowedGrantTypes" yaml:"allowedGrantTypes"`
+
+	// AllowedConnectors, AllowedScopes and AllowedResponseTypes restrict the connectors
+	// end users may log in to the client with, the scopes the client may request and
+	// the response types it may use at the authorization endpoint. If empty, the client
+	// isn't restricted. The "openid" scope is always allowed.
+	AllowedConnectors    []string `json:"allowedConnectors" yaml:"allowedConnectors"`
+	AllowedScopes        []string `json:"allowedScopes" yaml:"allowedScopes"`
+	AllowedResponseTypes []string `json:"allowedResponseTypes" yaml:"allowedResponseTypes"`
+
+	// Public keys the client signs "private_key_jwt" client assertions with. Either
+	// listed inline or fetched from a URL. Clients using these keys don't need a
+	// secret.
+	JWKS    *jose.JSONWebKeySet `json:"jwks" yaml:"jwks"`
+	JWKSURI string              `json:"jwksURI" yaml:"jwksURI"`
+
+	// AccessTokenFormat is the format of access tokens issued to the client, either
+	// "opaque" random strings or signed "jwt"s. If empty, the server's default is used.
+	AccessTokenFormat string `json:"accessTokenFormat" yaml:"accessTokenFormat"`
+
+	// RefreshTokenIdleTimeout and RefreshTokenAbsoluteLifetime override how long the
+	// server keeps the client's refresh tokens valid, formatted as durations such as
+	// "720h". If empty, the server's defaults are used.
+	RefreshTokenIdleTimeout      string `json:"refreshTokenIdleTimeout" yaml:"refreshTokenIdleTimeout"`
+	RefreshTokenAbsoluteLifetime string `json:"refreshTokenAbsoluteLifetime" yaml:"refreshTokenAbsoluteLifetime"`
+
+	// IDTokenLifetime, AccessTokenLifetime and AuthCodeLifetime override how long ID
+	// tokens, access tokens and authorization codes issued to the client are valid,
+	// formatted like the refresh token lifetimes. If empty, the server's defaults are
+	// used. Access tokens default to the client's ID token lifetime.
+	IDTokenLifetime     string `json:"idTokenLifetime" yaml:"idTokenLifetime"`
+	AccessTokenLifetime string `json:"accessTokenLifetime" yaml:"accessTokenLifetime"`
+	AuthCodeLifetime    string `json:"authCodeLifetime" yaml:"authCodeLifetime"`
+
+	// RequirePushedAuthRequests forces the client to push its authorization requests
+	// to the server before redirecting the end user, and RequireSignedRequestObject
+	// forces it to pass the request parameters in a signed "request" JWT.
+	RequirePushedAuthRequests  bool `json:"requirePushedAuthRequests" yaml:"requirePushedAuthRequests"`
+	RequireSignedRequestObject bool `json:"requireSignedRequestObject" yaml:"requireSignedRequestObject"`
+
+	// SubjectType is "pairwise" if the client is given its own "sub" claim for end
+	// users, so it can't correlate them with other clients. Clients with the same
+	// SectorIdentifierURI host share pairwise subjects. If empty, the "public"
+	// subject every client sees is used.
+	SubjectType         string `json:"subjectType" yaml:"subjectType"`
+	SectorIdentifierURI string `json:"sectorIdentifierURI" yaml:"sectorIdentifierURI"`
+
+	// RegistrationTokenHash is the SHA-256 hash of the access token used to manage a
+	// dynamically registered client. Empty for clients that weren't registered through
+	// the registration endpoint, which can't be managed with such a token.
+	RegistrationTokenHash string `json:"-" yaml:"-"`
 }
 
 // Claims represents the ID Token claims supported by the server.
@@ -162,11 +256,26 @@ type AuthRequest struct {
 	Nonce         string
 	State         string
 
+	// How the authorization response is returned to the client: "query",
+	// "fragment" or "form_post". If empty, determined by the response types.
+	ResponseMode string
+
 	// The client has indicated that the end user must be shown an approval prompt
 	// on all requests. The server cannot cache their initial action for subsequent
 	// attempts.
 	ForceApprovalPrompt bool
 
+	// PKCE code challenge provided by the client. Empty if the client didn't use PKCE.
+	PKCE PKCE
+
+	// Hint about the login identifier the end user might use, such as their email
+	// address. Used to prefill login forms.
+	LoginHint string
+
+	// Resource servers the client requested access tokens for. Empty if the client
+	// didn't indicate any.
+	Resources []string
+
 	Expiry time.Time
 
 	// Has the user proved their identity through a backing identity provider?
@@ -182,6 +291,10 @@ type AuthRequest struct {
 	// Set when the user authenticates.
 	ConnectorID   string
 	ConnectorData []byte
+
+	// When the end user authenticated with the connector. May be earlier than the
+	// request if the user was already logged in.
+	AuthTime time.Time
 }
 
 // AuthCode represents a code which can be exchanged for an OAuth2 token response.
@@ -216,9 +329,28 @@ type AuthCode struct {
 	ConnectorData []byte
 	Claims        Claims
 
+	// PKCE code challenge carried over from the authorization request. If set,
+	// the client must present a matching code_verifier when redeeming the code.
+	PKCE PKCE
+
+	// When the end user authenticated with the connector.
+	AuthTime time.Time
+
+	// Resource servers requested in the authorization request. Token requests may
+	// narrow these down, but not add others.
+	Resources []string
+
 	Expiry time.Time
 }
 
+// PKCE holds the values needed to perform a Proof Key for Code Exchange.
+//
+// https://tools.ietf.org/html/rfc7636
+type PKCE struct {
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
 // RefreshToken is an OAuth2 refresh token which allows a client to request new
 // tokens on the end user's behalf.
 type RefreshToken struct {
@@ -229,9 +361,18 @@ type RefreshToken struct {
 	// May be empty.
 	Token string
 
+	// The value Token held before it was last rotated. Clients presenting it again
+	// are either refreshing concurrently or replaying a leaked token.
+	ObsoleteToken string
+
 	CreatedAt time.Time
 	LastUsed  time.Time
 
+	// The refresh token can't be used after this time. Servers push it back every
+	// time the token is used, up to a maximum lifetime. Zero if the refresh token
+	// never expires.
+	Expiry time.Time
+
 	// Client this refresh token is valid for.
 	ClientID string
 
@@ -248,6 +389,149 @@ type RefreshToken struct {
 	// Nonce value supplied during the initial redirect. This is required to be part
 	// of the claims of any future id_token generated by the client.
 	Nonce string
+
+	// When the end user authenticated with the connector. Refreshing doesn't
+	// reauthenticate the user, so this is carried over to refreshed ID tokens.
+	AuthTime time.Time
+
+	// Resource servers present in the initial request. Like scopes, refresh requests
+	// may only ask for access tokens for a subset of these.
+	Resources []string
+}
+
+// AccessToken is an OAuth2 access token issued to a client. Access tokens are
+// opaque to clients, so the server records them to let resource servers check
+// if a token is still active.
+type AccessToken struct {
+	// Actual string returned as the "access_token" value.
+	ID string
+
+	// Client the access token was issued to.
+	ClientID string
+
+	// Authentication data provided by an upstream source.
+	ConnectorID string
+	Claims      Claims
+
+	// Scopes authorized by the end user for the client.
+	Scopes []string
+
+	// Resource servers the access token is intended for. Empty if the client didn't
+	// indicate any.
+	Resources []string
+
+	CreatedAt time.Time
+	Expiry    time.Time
+}
+
+// DeviceRequest tracks an OAuth2 device authorization grant while the end user
+// completes the login on a second device.
+//
+// https://tools.ietf.org/html/rfc8628
+type DeviceRequest struct {
+	// Normalized user code the end user enters on the verification page. Used as
+	// the ID of the request.
+	UserCode string
+
+	// Secret half of the "device_code" handed to the client. The client must
+	// present it alongside the user code when polling the token endpoint.
+	DeviceCode string
+
+	// Client which started the flow and the scopes it requested.
+	ClientID string
+	Scopes   []string
+
+	// Set to the authorization code issued once the end user has logged in and
+	// approved the request. Empty while the request is pending.
+	AuthCode string
+
+	// The end user rejected the request.
+	Denied bool
+
+	// Minimum number of seconds the client must wait between polling requests,
+	// and the last time it polled.
+	Interval int
+	LastPoll time.Time
+
+	Expiry time.Time
+}
+
+// ClientAssertion records a JWT client assertion that has been used to
+// authenticate a client, so it can't be replayed before it expires.
+//
+// https://tools.ietf.org/html/rfc7523#section-3
+type ClientAssertion struct {
+	// Hash of the client ID and the assertion's "jti" claim. Assertion IDs are
+	// chosen by clients so they're hashed to fit storages with restricted IDs.
+	ID string
+
+	ClientID string
+
+	// The assertion's expiry. The record may be garbage collected afterwards.
+	Expiry time.Time
+}
+
+// Session is an end user's browser session. It lets a user who has logged in
+// through a connector authorize further clients without logging in again.
+type Session struct {
+	// ID used to identify the session. Stored in the end user's session cookie.
+	ID string
+
+	// The connector used to login the user and the identity it returned.
+	ConnectorID   string
+	ConnectorData []byte
+	Claims        Claims
+
+	// When the user logged in, and when the session was last used to authorize
+	// a client.
+	CreatedAt time.Time
+	LastUsed  time.Time
+
+	// The session can't be used after this time. Servers push it back every time
+	// the session is used, up to a maximum lifetime.
+	Expiry time.Time
+
+	// Clients the end user has authorized through the session. Notified if the
+	// end user logs out.
+	ClientIDs []string
+}
+
+// LogoutNotification is a pending back-channel logout notification, telling a
+// client that an end user has logged out.
+//
+// Notifications are persisted so they aren't lost if delivery fails or the
+// server restarts.
+type LogoutNotification struct {
+	// ID used to identify the notification.
+	ID string
+
+	// The client to notify and the "sub" claim of the end user who logged out.
+	ClientID string
+	Subject  string
+
+	// Number of failed delivery attempts, and when to try delivering again.
+	Attempts    int
+	NextAttempt time.Time
+
+	// Notifications which haven't been delivered by this time are dropped.
+	Expiry time.Time
+}
+
+// PushedAuthRequest holds the parameters of an authorization request a client
+// pushed to the server ahead of redirecting the end user. The end user's browser
+// only carries a reference to the request.
+//
+// https://tools.ietf.org/html/rfc9126
+type PushedAuthRequest struct {
+	// ID used to identify the request. Part of the "request_uri" handed to the
+	// client.
+	ID string
+
+	// Client which pushed the request and the authorization request parameters.
+	ClientID string
+	Params   map[string][]string
+
+	Expiry time.Time
 }
 
 // RefreshTokenRef is a reference object that contains metadata about refresh tokens.
@@ -274,6 +558,27 @@ type OfflineSessions struct {
 	Refresh map[string]*RefreshTokenRef
 }
 
+// Consent records the scopes an end user approved for a client.
+type Consent struct {
+	Scopes []string
+
+	CreatedAt   time.Time
+	LastUpdated time.Time
+}
+
+// Consents are the approvals an end user has given clients, so the end user
+// isn't asked to approve the same scopes again.
+type Consents struct {
+	// UserID of an end user who has logged in to the server.
+	UserID string
+
+	// The ID of the connector used to login the user.
+	ConnID string
+
+	// Clients is a hash table of consents indexed by client ID.
+	Clients map[string]*Consent
+}
+
 // Password is an email to password mapping managed by the storage.
 type Password struct {
 	// Email and identifying name of the password. Emails are assumed to be valid and
@@ -328,6 +633,12 @@ type Keys struct {
 	// existing signatures.
 	VerificationKeys []VerificationKey
 
+	// Key the signing key will be replaced with at the next rotation. It's
+	// published ahead of time, so clients caching the public keys know of it
+	// before any token is signed with it. These may be nil.
+	NextSigningKey    *jose.JSONWebKey
+	NextSigningKeyPub *jose.JSONWebKey
+
 	// The next time the signing key will rotate.
 	//
 	// For caching purposes, implementations MUST NOT update keys before this time.